@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os/exec"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/artifact/engine"
 	"github.com/zcicd/zcicd-server/internal/artifact/handler"
 	"github.com/zcicd/zcicd-server/internal/artifact/repository"
 	"github.com/zcicd/zcicd-server/internal/artifact/router"
 	"github.com/zcicd/zcicd-server/internal/artifact/service"
 	"github.com/zcicd/zcicd-server/pkg/config"
+	"github.com/zcicd/zcicd-server/pkg/crypto"
 	"github.com/zcicd/zcicd-server/pkg/database"
+	"github.com/zcicd/zcicd-server/pkg/k8s"
 	"github.com/zcicd/zcicd-server/pkg/logger"
 	"github.com/zcicd/zcicd-server/pkg/middleware"
+	"github.com/zcicd/zcicd-server/pkg/storage"
 )
 
 func main() {
@@ -27,19 +34,50 @@ func main() {
 		log.Fatalf("failed to connect database: %v", err)
 	}
 
+	encryptor, err := crypto.NewEncryptor(cfg.Crypto.AESKey)
+	if err != nil {
+		log.Fatalf("invalid crypto.aes_key: %v", err)
+	}
+
+	// Trivy as Kubernetes Jobs, or a local binary in development
+	var scanner engine.Scanner
+	if k8sClient, err := k8s.NewK8sClient(""); err == nil {
+		scanner = engine.NewJobScanner(k8sClient, "zcicd", 20*time.Minute)
+	} else if path, lookErr := exec.LookPath("trivy"); lookErr == nil {
+		log.Printf("warning: k8s not available: %v (scanning with local %s)", err, path)
+		scanner = engine.NewLocalScanner(path, 20*time.Minute)
+	} else {
+		log.Printf("warning: k8s not available and trivy not found: %v (image scans disabled)", err)
+	}
+
+	// MinIO for raw scan reports
+	store, err := storage.NewMinIOClient(cfg)
+	if err != nil {
+		log.Printf("warning: minio not available: %v (reports not stored)", err)
+	} else if err := store.EnsureBucket(context.Background(), cfg.MinIO.Bucket); err != nil {
+		log.Printf("warning: minio bucket: %v (reports not stored)", err)
+		store = nil
+	}
+
 	// Repositories
 	regRepo := repository.NewRegistryRepository(db)
 	scanRepo := repository.NewScanRepository(db)
+	policyRepo := repository.NewPolicyRepository(db)
 	chartRepo := repository.NewChartRepository(db)
 
 	// Services
-	regSvc := service.NewRegistryService(regRepo)
-	scanSvc := service.NewScanService(scanRepo)
+	regSvc := service.NewRegistryService(regRepo, encryptor)
+	policySvc := service.NewPolicyService(policyRepo, scanRepo)
+	policySvc.StartExpiryChecker(context.Background(), 10*time.Minute)
+	scanSvc := service.NewScanService(scanRepo, regRepo, policySvc, scanner, encryptor, store, cfg.MinIO.Bucket)
+	scanSvc.FailInterrupted()
+	scanSvc.StartRescanner(context.Background(), 24*time.Hour)
 	chartSvc := service.NewChartService(chartRepo)
 
 	// Handlers
 	regH := handler.NewRegistryHandler(regSvc)
 	scanH := handler.NewScanHandler(scanSvc)
+	policyH := handler.NewPolicyHandler(policySvc)
 	chartH := handler.NewChartHandler(chartSvc)
 
 	// Gin setup
@@ -53,7 +91,7 @@ func main() {
 	})
 
 	api := r.Group("/api/v1")
-	router.RegisterRoutes(api, cfg.JWT.Secret, regH, scanH, chartH)
+	router.RegisterRoutes(api, cfg.JWT.Secret, regH, scanH, policyH, chartH)
 
 	port := cfg.Server.Port
 	if port == 0 {
@@ -27,11 +27,14 @@ func main() {
 
 	cleaner := jobs.NewResourceCleaner(db, 7*24*time.Hour)
 	aggregator := jobs.NewDataAggregator(db)
+	reclaimer := jobs.NewPreviewReclaimer(db)
 
 	// Clean old build/deploy history every day at 2:00 AM
 	c.AddFunc("0 0 2 * * *", cleaner.Run)
 	// Aggregate dashboard stats every 10 minutes
 	c.AddFunc("0 */10 * * * *", aggregator.Run)
+	// Reclaim expired and orphaned preview environments every 5 minutes
+	c.AddFunc("0 */5 * * * *", reclaimer.Run)
 
 	c.Start()
 	log.Println("cron-service started")
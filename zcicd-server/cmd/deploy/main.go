@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/zcicd/zcicd-server/internal/deploy/engine"
@@ -12,6 +13,7 @@ import (
 	"github.com/zcicd/zcicd-server/internal/deploy/router"
 	"github.com/zcicd/zcicd-server/internal/deploy/service"
 	"github.com/zcicd/zcicd-server/pkg/config"
+	"github.com/zcicd/zcicd-server/pkg/crypto"
 	"github.com/zcicd/zcicd-server/pkg/database"
 	"github.com/zcicd/zcicd-server/pkg/k8s"
 	"github.com/zcicd/zcicd-server/pkg/logger"
@@ -41,6 +43,10 @@ func main() {
 		log.Fatalf("failed to connect nats: %v", err)
 	}
 
+	if err := engine.CheckRenderTools(); err != nil {
+		log.Fatalf("deploy tools missing: %v", err)
+	}
+
 	k8sClient, err := k8s.NewK8sClient("")
 	if err != nil {
 		log.Printf("warning: k8s not available: %v (Argo CD features disabled)", err)
@@ -51,36 +57,78 @@ func main() {
 	var appManager *engine.AppManager
 	var syncCtrl *engine.SyncController
 	var rolloutCtrl *engine.RolloutController
+	var clusters *engine.ClusterRegistry
 	if k8sClient != nil {
-		appManager = engine.NewAppManager(k8sClient.DynamicClient, argoNS)
-		syncCtrl = engine.NewSyncController(k8sClient.DynamicClient, argoNS)
+		if engine.ArgoCDInstalled(k8sClient) {
+			appManager = engine.NewAppManager(k8sClient.DynamicClient, argoNS)
+			syncCtrl = engine.NewSyncController(k8sClient.DynamicClient, argoNS)
+		} else {
+			log.Printf("warning: Argo CD not installed; environments deploy with the direct engine")
+		}
 		rolloutCtrl = engine.NewRolloutController(k8sClient.DynamicClient, argoNS)
+		clusters = engine.NewClusterRegistry(k8sClient, argoNS)
 	}
 	gitopsWriter := engine.NewGitOpsWriter(redisClient)
 
-	// Start health monitor in background
-	var healthMonitor *engine.HealthMonitor
-	if k8sClient != nil {
-		healthMonitor = engine.NewHealthMonitor(k8sClient.DynamicClient, argoNS)
-		healthMonitor.Start(context.Background(), func(appName string, status engine.AppStatus) {
-			log.Printf("health change: app=%s sync=%s health=%s", appName, status.SyncStatus, status.HealthStatus)
-		})
+	encryptor, err := crypto.NewEncryptor(cfg.Crypto.AESKey)
+	if err != nil {
+		log.Fatalf("invalid crypto.aes_key: %v", err)
 	}
-	_ = healthMonitor
 
 	// Repositories
 	deployRepo := repository.NewDeployRepository(db)
 	approvalRepo := repository.NewApprovalRepository(db)
 	envRepo := repository.NewEnvRepository(db)
+	freezeRepo := repository.NewFreezeRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	lockRepo := repository.NewLockRepository(db)
+	analysisRepo := repository.NewAnalysisRepository(db)
+	previewRepo := repository.NewPreviewRepository(db)
+	driftRepo := repository.NewDriftRepository(db)
 	// Services
-	deploySvc := service.NewDeployService(deployRepo, approvalRepo, appManager, syncCtrl, rolloutCtrl, gitopsWriter, natsClient, argoNS)
+	freezeSvc := service.NewFreezeService(freezeRepo, auditRepo)
+	gateSvc := service.NewQualityGateService(deployRepo, auditRepo)
+	vulnSvc := service.NewVulnPolicyService(deployRepo, envRepo, auditRepo)
+	lockSvc := service.NewLockService(lockRepo)
+	analysisSvc := service.NewAnalysisService(analysisRepo, envRepo, deployRepo, natsClient)
+	deploySvc := service.NewDeployService(deployRepo, approvalRepo, envRepo, freezeSvc, gateSvc, vulnSvc, lockSvc, analysisSvc, appManager, syncCtrl, rolloutCtrl, clusters, gitopsWriter, encryptor, natsClient, argoNS)
+	nsSvc := service.NewNamespaceService(envRepo, clusters, argoNS)
+	if err := nsSvc.Subscribe(natsClient); err != nil {
+		log.Printf("warning: failed to subscribe to environment events: %v", err)
+	}
 	approvalSvc := service.NewApprovalService(approvalRepo, deployRepo)
-	envSvc := service.NewEnvService(envRepo)
+	envSvc := service.NewEnvService(envRepo, auditRepo, encryptor, deploySvc, nsSvc)
+	previewSvc := service.NewPreviewService(previewRepo, envRepo, deploySvc, nsSvc, natsClient)
+	if err := previewSvc.Subscribe(natsClient); err != nil {
+		log.Printf("warning: failed to subscribe to pull request events: %v", err)
+	}
+	driftSvc := service.NewDriftService(driftRepo, deployRepo, auditRepo, deploySvc, gitopsWriter, natsClient)
+	deploySvc.StartLockReaper(context.Background(), time.Minute)
+	deploySvc.StartAppReconciler(context.Background(), 5*time.Minute)
+	previewSvc.StartReaper(context.Background(), time.Minute)
+	if n, err := envSvc.EncryptPlaintextSecrets(); err != nil {
+		log.Printf("warning: failed to encrypt plain-text secret variables: %v", err)
+	} else if n > 0 {
+		log.Printf("encrypted %d plain-text secret variables", n)
+	}
+
+	// Start health monitor in background; it feeds drift detection
+	if appManager != nil {
+		healthMonitor := engine.NewHealthMonitor(k8sClient.DynamicClient, argoNS)
+		healthMonitor.Start(context.Background(), func(appName string, status engine.AppStatus) {
+			log.Printf("health change: app=%s sync=%s health=%s", appName, status.SyncStatus, status.HealthStatus)
+			driftSvc.HandleAppStatus(appName, status)
+		})
+	}
 
 	// Handlers
 	deployH := handler.NewDeployHandler(deploySvc)
 	approvalH := handler.NewApprovalHandler(approvalSvc)
 	envH := handler.NewEnvHandler(envSvc)
+	freezeH := handler.NewFreezeHandler(freezeSvc)
+	lockH := handler.NewLockHandler(deploySvc)
+	previewH := handler.NewPreviewHandler(previewSvc)
+	driftH := handler.NewDriftHandler(driftSvc)
 
 	// Gin setup
 	r := gin.New()
@@ -93,7 +141,7 @@ func main() {
 	})
 
 	api := r.Group("/api/v1")
-	router.RegisterRoutes(api, cfg.JWT.Secret, deployH, approvalH, envH)
+	router.RegisterRoutes(api, cfg.JWT.Secret, deployH, approvalH, envH, freezeH, lockH, previewH, driftH)
 
 	port := cfg.Server.Port
 	if port == 0 {
@@ -13,6 +13,7 @@ import (
 	"github.com/zcicd/zcicd-server/pkg/database"
 	"github.com/zcicd/zcicd-server/pkg/logger"
 	"github.com/zcicd/zcicd-server/pkg/middleware"
+	"github.com/zcicd/zcicd-server/pkg/mq"
 )
 
 func main() {
@@ -27,12 +28,17 @@ func main() {
 		log.Fatalf("failed to connect database: %v", err)
 	}
 
+	natsClient, err := mq.NewNATSClient(cfg)
+	if err != nil {
+		log.Printf("warning: nats not available: %v (namespace provisioning disabled)", err)
+	}
+
 	// Wire dependencies
 	projectRepo := repository.NewProjectRepository(db)
 	serviceRepo := repository.NewServiceRepository(db)
 	envRepo := repository.NewEnvironmentRepository(db)
 
-	svc := service.NewProjectService(projectRepo, serviceRepo, envRepo)
+	svc := service.NewProjectService(projectRepo, serviceRepo, envRepo, natsClient)
 	h := handler.NewProjectHandler(svc)
 
 	// Setup Gin
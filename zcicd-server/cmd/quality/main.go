@@ -1,18 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
 	"github.com/zcicd/zcicd-server/internal/quality/handler"
 	"github.com/zcicd/zcicd-server/internal/quality/repository"
 	"github.com/zcicd/zcicd-server/internal/quality/router"
 	"github.com/zcicd/zcicd-server/internal/quality/service"
 	"github.com/zcicd/zcicd-server/pkg/config"
 	"github.com/zcicd/zcicd-server/pkg/database"
+	"github.com/zcicd/zcicd-server/pkg/k8s"
 	"github.com/zcicd/zcicd-server/pkg/logger"
 	"github.com/zcicd/zcicd-server/pkg/middleware"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"github.com/zcicd/zcicd-server/pkg/storage"
 )
 
 func main() {
@@ -27,15 +32,43 @@ func main() {
 		log.Fatalf("failed to connect database: %v", err)
 	}
 
+	natsClient, err := mq.NewNATSClient(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect nats: %v", err)
+	}
+
+	// Tekton runner for test runs
+	var runner *engine.TaskRunner
+	k8sClient, err := k8s.NewK8sClient("")
+	if err != nil {
+		log.Printf("warning: k8s not available: %v (test runs disabled)", err)
+	} else {
+		runner = engine.NewTaskRunner(k8sClient, "zcicd")
+	}
+
+	// MinIO for raw test reports
+	store, err := storage.NewMinIOClient(cfg)
+	if err != nil {
+		log.Printf("warning: minio not available: %v (reports not stored)", err)
+	} else if err := store.EnsureBucket(context.Background(), cfg.MinIO.Bucket); err != nil {
+		log.Printf("warning: minio bucket: %v (reports not stored)", err)
+		store = nil
+	}
+
 	// Repositories
 	testRepo := repository.NewTestRepository(db)
 	scanRepo := repository.NewScanRepository(db)
 	gateRepo := repository.NewQualityGateRepository(db)
 
 	// Services
-	testSvc := service.NewTestService(testRepo)
-	scanSvc := service.NewScanService(scanRepo)
-	gateSvc := service.NewQualityGateService(gateRepo)
+	testSvc := service.NewTestService(testRepo, runner, store, cfg.MinIO.Bucket, natsClient)
+	scanSvc := service.NewScanService(scanRepo, runner, natsClient)
+	gateSvc := service.NewQualityGateService(gateRepo, natsClient)
+	if err := gateSvc.Subscribe(natsClient); err != nil {
+		log.Printf("warning: quality gate subscription failed: %v", err)
+	}
+	testSvc.ResumeRuns()
+	scanSvc.ResumeRuns()
 
 	// Handlers
 	testH := handler.NewTestHandler(testSvc)
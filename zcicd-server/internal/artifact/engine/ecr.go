@@ -0,0 +1,299 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ECRClient is a Registry v2 client for Amazon ECR and registries speaking
+// its API. The access key is exchanged for a registry password through
+// GetAuthorizationToken, and repositories are listed through
+// DescribeRepositories since ECR has no catalog.
+type ECRClient struct {
+	*V2Client
+	accessKey  string
+	secretKey  string
+	registryID string
+	region     string
+	apiURL     string
+
+	loginMu sync.Mutex
+	expires time.Time
+}
+
+// NewECRClient creates a client for an endpoint of the form
+// <account>.dkr.ecr.<region>.amazonaws.com with an access key.
+func NewECRClient(endpoint, accessKey, secretKey string, httpClient *http.Client) (*ECRClient, error) {
+	v2, err := NewV2Client(endpoint, "", "", httpClient)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(v2.baseURL, "https://"), "http://")
+	parts := strings.Split(host, ".")
+	if len(parts) < 6 || parts[1] != "dkr" || parts[2] != "ecr" {
+		return nil, fmt.Errorf("invalid ECR endpoint %q, expected <account>.dkr.ecr.<region>.amazonaws.com", endpoint)
+	}
+	return &ECRClient{
+		V2Client:   v2,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		registryID: parts[0],
+		region:     parts[3],
+		apiURL:     "https://api.ecr." + parts[3] + "." + strings.Join(parts[4:], "."),
+	}, nil
+}
+
+func (c *ECRClient) Ping(ctx context.Context) error {
+	if err := c.login(ctx); err != nil {
+		return err
+	}
+	return c.V2Client.Ping(ctx)
+}
+
+// ListRepositories lists repositories through DescribeRepositories.
+func (c *ECRClient) ListRepositories(ctx context.Context) ([]string, error) {
+	var repos []string
+	var nextToken string
+	for {
+		req := map[string]interface{}{"registryId": c.registryID, "maxResults": 1000}
+		if nextToken != "" {
+			req["nextToken"] = nextToken
+		}
+		var resp struct {
+			Repositories []struct {
+				RepositoryName string `json:"repositoryName"`
+			} `json:"repositories"`
+			NextToken string `json:"nextToken"`
+		}
+		if err := c.call(ctx, "DescribeRepositories", req, &resp); err != nil {
+			return nil, err
+		}
+		for _, r := range resp.Repositories {
+			if rel, ok := c.relativeName(r.RepositoryName); ok {
+				repos = append(repos, rel)
+			}
+		}
+		if resp.NextToken == "" {
+			return repos, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+func (c *ECRClient) ListTags(ctx context.Context, repository string) ([]string, error) {
+	if err := c.login(ctx); err != nil {
+		return nil, err
+	}
+	return c.V2Client.ListTags(ctx, repository)
+}
+
+func (c *ECRClient) GetManifest(ctx context.Context, repository, reference string) (*Manifest, error) {
+	if err := c.login(ctx); err != nil {
+		return nil, err
+	}
+	return c.V2Client.GetManifest(ctx, repository, reference)
+}
+
+func (c *ECRClient) GetImageConfig(ctx context.Context, repository, digest string) (*ImageConfig, error) {
+	if err := c.login(ctx); err != nil {
+		return nil, err
+	}
+	return c.V2Client.GetImageConfig(ctx, repository, digest)
+}
+
+// PushTimes returns when the tags of a repository were pushed, from
+// DescribeImages.
+func (c *ECRClient) PushTimes(ctx context.Context, repository string) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+	var nextToken string
+	for {
+		req := map[string]interface{}{
+			"registryId":     c.registryID,
+			"repositoryName": c.fullName(repository),
+			"filter":         map[string]string{"tagStatus": "TAGGED"},
+			"maxResults":     1000,
+		}
+		if nextToken != "" {
+			req["nextToken"] = nextToken
+		}
+		var resp struct {
+			ImageDetails []struct {
+				ImageTags     []string `json:"imageTags"`
+				ImagePushedAt float64  `json:"imagePushedAt"`
+			} `json:"imageDetails"`
+			NextToken string `json:"nextToken"`
+		}
+		if err := c.call(ctx, "DescribeImages", req, &resp); err != nil {
+			return nil, err
+		}
+		for _, img := range resp.ImageDetails {
+			pushed := time.Unix(int64(img.ImagePushedAt), 0)
+			for _, tag := range img.ImageTags {
+				times[tag] = pushed
+			}
+		}
+		if resp.NextToken == "" {
+			return times, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+func (c *ECRClient) DeleteManifest(ctx context.Context, repository, digest string) error {
+	if err := c.login(ctx); err != nil {
+		return err
+	}
+	return c.V2Client.DeleteManifest(ctx, repository, digest)
+}
+
+// login gets a registry password from GetAuthorizationToken unless the
+// current one is valid for a few more minutes.
+func (c *ECRClient) login(ctx context.Context) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	if time.Until(c.expires) > 5*time.Minute {
+		return nil
+	}
+	var resp struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}
+	req := map[string]interface{}{"registryIds": []string{c.registryID}}
+	if err := c.call(ctx, "GetAuthorizationToken", req, &resp); err != nil {
+		return err
+	}
+	if len(resp.AuthorizationData) == 0 {
+		return fmt.Errorf("ecr: no authorization data for registry %s", c.registryID)
+	}
+	data := resp.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(data.AuthorizationToken)
+	if err != nil {
+		return fmt.Errorf("ecr: invalid authorization token: %w", err)
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return fmt.Errorf("ecr: invalid authorization token")
+	}
+	c.V2Client.setCredentials(username, password)
+	c.expires = time.Unix(int64(data.ExpiresAt), 0)
+	return nil
+}
+
+// call invokes an action of the ECR JSON API, signed with the access key.
+func (c *ECRClient) call(ctx context.Context, action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921."+action)
+	signV4(req, body, c.accessKey, c.secretKey, c.region, "ecr", time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &awsErr)
+		if awsErr.Message == "" {
+			awsErr.Message = tail(string(data), 500)
+		}
+		_, code, _ := strings.Cut(awsErr.Type, "#")
+		if code == "" {
+			code = awsErr.Type
+		}
+		return &RegistryError{StatusCode: resp.StatusCode, Code: code, Message: awsErr.Message}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("ecr: invalid %s response: %w", action, err)
+	}
+	return nil
+}
+
+// signV4 signs a request with AWS Signature Version 4, over its host, its
+// X-Amz-* headers and its content type.
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// harborPageSize is the page size of Harbor API lists.
+const harborPageSize = 100
+
+// harborVulnReports are the vulnerability report types a scan overview is
+// asked for.
+const harborVulnReports = "application/vnd.security.vulnerability.report; version=1.1, " +
+	"application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0"
+
+// HarborClient is a Registry v2 client with the projects, repositories and
+// built-in scan results of the Harbor API.
+type HarborClient struct {
+	*V2Client
+}
+
+// NewHarborClient creates a client for a Harbor endpoint; a path in the
+// endpoint is the project the client is limited to.
+func NewHarborClient(endpoint, username, password string, httpClient *http.Client) (*HarborClient, error) {
+	v2, err := NewV2Client(endpoint, username, password, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &HarborClient{V2Client: v2}, nil
+}
+
+// HarborProject is a Harbor project.
+type HarborProject struct {
+	ProjectID    int64     `json:"project_id"`
+	Name         string    `json:"name"`
+	Public       bool      `json:"public"`
+	RepoCount    int       `json:"repo_count"`
+	CreationTime time.Time `json:"creation_time"`
+}
+
+// HarborScanOverview is the summary of Harbor's built-in scan of an artifact.
+type HarborScanOverview struct {
+	Status    string     `json:"status"`   // Pending/Running/Success/Error/Stopped, NotScanned without report
+	Severity  string     `json:"severity"` // highest severity found: Critical/High/Medium/Low/None/Unknown
+	Total     int        `json:"total"`
+	Fixable   int        `json:"fixable"`
+	Critical  int        `json:"critical"`
+	High      int        `json:"high"`
+	Medium    int        `json:"medium"`
+	Low       int        `json:"low"`
+	Unknown   int        `json:"unknown"`
+	Scanner   string     `json:"scanner,omitempty"`
+	ScannedAt *time.Time `json:"scanned_at"`
+}
+
+// ListProjects lists the projects the credentials can see.
+func (c *HarborClient) ListProjects(ctx context.Context) ([]HarborProject, error) {
+	var projects []HarborProject
+	for page := 1; ; page++ {
+		var list []struct {
+			ProjectID    int64     `json:"project_id"`
+			Name         string    `json:"name"`
+			RepoCount    int       `json:"repo_count"`
+			CreationTime time.Time `json:"creation_time"`
+			Metadata     struct {
+				Public string `json:"public"`
+			} `json:"metadata"`
+		}
+		if err := c.api(ctx, "/api/v2.0/projects", harborPage(page), nil, &list); err != nil {
+			return nil, err
+		}
+		for _, p := range list {
+			projects = append(projects, HarborProject{
+				ProjectID:    p.ProjectID,
+				Name:         p.Name,
+				Public:       p.Metadata.Public == "true",
+				RepoCount:    p.RepoCount,
+				CreationTime: p.CreationTime,
+			})
+		}
+		if len(list) < harborPageSize {
+			return projects, nil
+		}
+	}
+}
+
+// ListRepositories lists repositories through the Harbor API, since the
+// Registry v2 catalog is limited to Harbor's system admins.
+func (c *HarborClient) ListRepositories(ctx context.Context) ([]string, error) {
+	path := "/api/v2.0/repositories"
+	if c.namespace != "" {
+		project, _, _ := strings.Cut(c.namespace, "/")
+		path = "/api/v2.0/projects/" + url.PathEscape(project) + "/repositories"
+	}
+	var repos []string
+	for page := 1; ; page++ {
+		var list []struct {
+			Name string `json:"name"`
+		}
+		if err := c.api(ctx, path, harborPage(page), nil, &list); err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			if rel, ok := c.relativeName(r.Name); ok {
+				repos = append(repos, rel)
+			}
+		}
+		if len(list) < harborPageSize {
+			return repos, nil
+		}
+	}
+}
+
+// PushTimes returns when the tags of a repository were pushed.
+func (c *HarborClient) PushTimes(ctx context.Context, repository string) (map[string]time.Time, error) {
+	path, err := c.repositoryPath(repository)
+	if err != nil {
+		return nil, err
+	}
+	times := map[string]time.Time{}
+	for page := 1; ; page++ {
+		params := harborPage(page)
+		params.Set("with_tag", "true")
+		var list []struct {
+			PushTime time.Time `json:"push_time"`
+			Tags     []struct {
+				Name     string    `json:"name"`
+				PushTime time.Time `json:"push_time"`
+			} `json:"tags"`
+		}
+		if err := c.api(ctx, path+"/artifacts", params, nil, &list); err != nil {
+			return nil, err
+		}
+		for _, a := range list {
+			for _, t := range a.Tags {
+				pushed := t.PushTime
+				if pushed.IsZero() {
+					pushed = a.PushTime
+				}
+				times[t.Name] = pushed
+			}
+		}
+		if len(list) < harborPageSize {
+			return times, nil
+		}
+	}
+}
+
+// repositoryPath returns the Harbor API path of a repository, whose name is
+// escaped twice.
+func (c *HarborClient) repositoryPath(repository string) (string, error) {
+	project, repo, ok := strings.Cut(c.fullName(repository), "/")
+	if !ok {
+		return "", fmt.Errorf("harbor repository %q has no project", repository)
+	}
+	return fmt.Sprintf("/api/v2.0/projects/%s/repositories/%s",
+		url.PathEscape(project), url.PathEscape(url.PathEscape(repo))), nil
+}
+
+// ScanOverview returns the result of Harbor's built-in scan of an artifact
+// by tag or digest.
+func (c *HarborClient) ScanOverview(ctx context.Context, repository, reference string) (*HarborScanOverview, error) {
+	path, err := c.repositoryPath(repository)
+	if err != nil {
+		return nil, err
+	}
+	path += "/artifacts/" + url.PathEscape(reference)
+	params := url.Values{"with_scan_overview": {"true"}}
+	header := http.Header{"X-Accept-Vulnerabilities": {harborVulnReports}}
+	var artifact struct {
+		ScanOverview map[string]struct {
+			ScanStatus string    `json:"scan_status"`
+			Severity   string    `json:"severity"`
+			EndTime    time.Time `json:"end_time"`
+			Scanner    struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scanner"`
+			Summary struct {
+				Total   int            `json:"total"`
+				Fixable int            `json:"fixable"`
+				Summary map[string]int `json:"summary"`
+			} `json:"summary"`
+		} `json:"scan_overview"`
+	}
+	if err := c.api(ctx, path, params, header, &artifact); err != nil {
+		return nil, err
+	}
+	overview := &HarborScanOverview{Status: "NotScanned"}
+	for _, report := range artifact.ScanOverview {
+		overview.Status = report.ScanStatus
+		overview.Severity = report.Severity
+		overview.Total = report.Summary.Total
+		overview.Fixable = report.Summary.Fixable
+		overview.Critical = report.Summary.Summary["Critical"]
+		overview.High = report.Summary.Summary["High"]
+		overview.Medium = report.Summary.Summary["Medium"]
+		overview.Low = report.Summary.Summary["Low"]
+		overview.Unknown = report.Summary.Summary["Unknown"]
+		if report.Scanner.Name != "" {
+			overview.Scanner = strings.TrimSpace(report.Scanner.Name + " " + report.Scanner.Version)
+		}
+		if !report.EndTime.IsZero() {
+			end := report.EndTime
+			overview.ScannedAt = &end
+		}
+		break
+	}
+	return overview, nil
+}
+
+func harborPage(page int) url.Values {
+	return url.Values{"page": {strconv.Itoa(page)}, "page_size": {strconv.Itoa(harborPageSize)}}
+}
+
+// api gets a Harbor API resource with basic authentication.
+func (c *HarborClient) api(ctx context.Context, path string, params url.Values, header http.Header, out interface{}) error {
+	u := c.baseURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	c.mu.Lock()
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	c.mu.Unlock()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return registryError(resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid harbor response of %s: %w", path, err)
+	}
+	return nil
+}
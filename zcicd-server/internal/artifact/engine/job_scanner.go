@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Markers around the gzipped, base64 encoded report in the scan Job's log.
+const (
+	reportBegin = "ZCICD_REPORT_BEGIN"
+	reportEnd   = "ZCICD_REPORT_END"
+	trivyImage  = "aquasec/trivy:0.58.1"
+)
+
+// trivyScript runs Trivy with its logs on stderr and prints the report
+// between the markers, so that it can be read back from the pod log.
+const trivyScript = `trivy image --quiet --format json --timeout "$TRIVY_TIMEOUT" --output /tmp/report.json "$IMAGE" >&2
+status=$?
+if [ -s /tmp/report.json ]; then
+  echo ` + reportBegin + `
+  gzip -c /tmp/report.json | base64
+  echo ` + reportEnd + `
+fi
+exit $status
+`
+
+// JobScanner runs Trivy as a Kubernetes Job in a namespace.
+type JobScanner struct {
+	client    *k8s.K8sClient
+	namespace string
+	timeout   time.Duration
+	interval  time.Duration
+}
+
+// NewJobScanner creates a scanner running Jobs in namespace. timeout bounds
+// a single scan, including pulling the vulnerability database.
+func NewJobScanner(client *k8s.K8sClient, namespace string, timeout time.Duration) *JobScanner {
+	return &JobScanner{client: client, namespace: namespace, timeout: timeout, interval: 5 * time.Second}
+}
+
+// Scan runs a Job named name, waits for it and returns the report it printed.
+// The registry credentials are passed through a Secret owned by the Job; the
+// Job is deleted once its log has been read.
+func (s *JobScanner) Scan(ctx context.Context, name string, target TrivyTarget) ([]byte, error) {
+	jobs := s.client.Clientset.BatchV1().Jobs(s.namespace)
+	secrets := s.client.Clientset.CoreV1().Secrets(s.namespace)
+	labels := map[string]string{"app.kubernetes.io/managed-by": "zcicd", "zcicd.io/image-scan": name}
+
+	env := []corev1.EnvVar{
+		{Name: "IMAGE", Value: target.Image},
+		{Name: "TRIVY_TIMEOUT", Value: s.timeout.String()},
+	}
+	var secret *corev1.Secret
+	if secretEnv := trivyEnv(target); len(secretEnv) > 0 {
+		var err error
+		secret, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-env", Labels: labels},
+			StringData: secretEnv,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create secret of scan job %s: %w", name, err)
+		}
+		for k := range secretEnv {
+			env = append(env, corev1.EnvVar{Name: k, ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  k,
+				},
+			}})
+		}
+	}
+
+	backoff := int32(0)
+	ttl := int32(3600)
+	deadline := int64((s.timeout + 5*time.Minute).Seconds())
+	job, err := jobs.Create(ctx, &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoff,
+			TTLSecondsAfterFinished: &ttl,
+			ActiveDeadlineSeconds:   &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "trivy",
+						Image:   trivyImage,
+						Command: []string{"sh", "-c", trivyScript},
+						Env:     env,
+					}},
+				},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		if secret != nil {
+			secrets.Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		}
+		return nil, fmt.Errorf("failed to create scan job %s: %w", name, err)
+	}
+	if secret != nil {
+		secret.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+			Name:       job.Name,
+			UID:        job.UID,
+		}}
+		if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			fmt.Printf("warning: failed to set owner of secret %s: %v\n", secret.Name, err)
+		}
+	}
+	defer func() {
+		policy := metav1.DeletePropagationBackground
+		if err := jobs.Delete(context.Background(), name, metav1.DeleteOptions{PropagationPolicy: &policy}); err != nil {
+			fmt.Printf("warning: failed to delete scan job %s: %v\n", name, err)
+		}
+	}()
+
+	succeeded, err := s.wait(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	log, err := s.podLog(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	report, err := parseReportLog(log)
+	if err != nil {
+		return nil, err
+	}
+	if !succeeded || report == nil {
+		return nil, fmt.Errorf("trivy %s failed: %s", target.Image, tail(stripReport(log), 2000))
+	}
+	return report, nil
+}
+
+// wait polls a Job until it succeeds or fails.
+func (s *JobScanner) wait(ctx context.Context, name string) (bool, error) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		job, err := s.client.Clientset.BatchV1().Jobs(s.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			fmt.Printf("warning: failed to get scan job %s: %v\n", name, err)
+		} else if job.Status.Succeeded > 0 {
+			return true, nil
+		} else if job.Status.Failed > 0 {
+			return false, nil
+		} else {
+			for _, cond := range job.Status.Conditions {
+				if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+					return false, fmt.Errorf("scan job %s failed: %s", name, cond.Message)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// podLog reads the log of the pod of a Job.
+func (s *JobScanner) podLog(ctx context.Context, name string) (string, error) {
+	pods, err := s.client.Clientset.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + name})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pods of scan job %s: %w", name, err)
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("scan job %s has no pod", name)
+	}
+	raw, err := s.client.Clientset.CoreV1().Pods(s.namespace).
+		GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{Container: "trivy"}).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read log of scan job %s: %w", name, err)
+	}
+	return string(raw), nil
+}
+
+// parseReportLog extracts the report printed between the markers, nil if
+// there is none.
+func parseReportLog(log string) ([]byte, error) {
+	var encoded strings.Builder
+	inReport := false
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == reportBegin:
+			inReport = true
+		case line == reportEnd:
+			inReport = false
+		case inReport:
+			encoded.WriteString(line)
+		}
+	}
+	if encoded.Len() == 0 {
+		return nil, nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid report in scan log: %w", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid report in scan log: %w", err)
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// stripReport returns a scan log without the encoded report.
+func stripReport(log string) string {
+	if i := strings.Index(log, reportBegin); i >= 0 {
+		return log[:i]
+	}
+	return log
+}
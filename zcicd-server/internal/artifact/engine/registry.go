@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RegistryConfig is how to reach a registry and authenticate to it.
+type RegistryConfig struct {
+	Type     string // harbor/ecr, or dockerhub/acr/ghcr/... speaking plain Registry v2
+	Endpoint string // https://harbor.example.com/library, harbor.example.com, http://localhost:5000
+	Username string // the access key ID for ECR
+	Password string // the secret access key for ECR
+}
+
+// RegistryClient talks to an image registry over the Docker Registry v2 API.
+// Repository names are relative to the path of the registry's endpoint, the
+// way image scans name them.
+type RegistryClient interface {
+	// Ping checks that the registry answers and accepts the credentials.
+	Ping(ctx context.Context) error
+	ListRepositories(ctx context.Context) ([]string, error)
+	ListTags(ctx context.Context, repository string) ([]string, error)
+	// GetManifest returns the manifest of a tag or a digest.
+	GetManifest(ctx context.Context, repository, reference string) (*Manifest, error)
+	// GetImageConfig returns the config blob of an image manifest.
+	GetImageConfig(ctx context.Context, repository, digest string) (*ImageConfig, error)
+	// DeleteManifest deletes a manifest by digest, and with it the tags
+	// pointing at it.
+	DeleteManifest(ctx context.Context, repository, digest string) error
+}
+
+// PushTimeLister is implemented by the registries recording when tags were
+// pushed, which the Registry v2 API does not tell.
+type PushTimeLister interface {
+	PushTimes(ctx context.Context, repository string) (map[string]time.Time, error)
+}
+
+// NewRegistryClient creates the client of a registry's type. httpClient may
+// be nil for a default one. Docker Hub, ACR, GHCR and other registries with
+// no client of their own are served by the generic V2Client: ACR's admin
+// user or service principal is exchanged for a bearer token at the realm of
+// its challenge like any other.
+func NewRegistryClient(cfg RegistryConfig, httpClient *http.Client) (RegistryClient, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	switch cfg.Type {
+	case "harbor":
+		return NewHarborClient(cfg.Endpoint, cfg.Username, cfg.Password, httpClient)
+	case "ecr":
+		return NewECRClient(cfg.Endpoint, cfg.Username, cfg.Password, httpClient)
+	default:
+		return NewV2Client(cfg.Endpoint, cfg.Username, cfg.Password, httpClient)
+	}
+}
+
+// Media types of the manifests a client accepts.
+const (
+	MediaTypeOCIIndex          = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest       = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerList        = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest    = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestV1S = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+)
+
+var manifestAccept = strings.Join([]string{
+	MediaTypeOCIIndex, MediaTypeOCIManifest, MediaTypeDockerList, MediaTypeDockerManifest, MediaTypeDockerManifestV1S,
+}, ", ")
+
+// Descriptor references a blob or a manifest.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Platform is the platform of an image in an index.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform as os/architecture[/variant].
+func (p *Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// ImageConfig is the part of an image config blob describing the image.
+type ImageConfig struct {
+	Architecture string     `json:"architecture"`
+	OS           string     `json:"os"`
+	Variant      string     `json:"variant,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// Platform returns the platform the image was built for.
+func (c *ImageConfig) Platform() *Platform {
+	return &Platform{Architecture: c.Architecture, OS: c.OS, Variant: c.Variant}
+}
+
+// Manifest is an image manifest, or an index of the images of several platforms.
+type Manifest struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"-"`
+	Config      *Descriptor       `json:"config,omitempty"`
+	Layers      []Descriptor      `json:"layers,omitempty"`
+	Manifests   []Descriptor      `json:"manifests,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Raw         []byte            `json:"-"`
+}
+
+// IsIndex reports whether the manifest lists the images of several platforms.
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeOCIIndex || m.MediaType == MediaTypeDockerList || len(m.Manifests) > 0
+}
+
+// ImageSize returns the size of an image's config and layers, or of the
+// manifests of an index.
+func (m *Manifest) ImageSize() int64 {
+	var size int64
+	if m.Config != nil {
+		size += m.Config.Size
+	}
+	for _, l := range m.Layers {
+		size += l.Size
+	}
+	for _, d := range m.Manifests {
+		size += d.Size
+	}
+	return size
+}
+
+// RegistryError is an error answered by a registry.
+type RegistryError struct {
+	StatusCode int
+	Code       string // MANIFEST_UNKNOWN, NAME_UNKNOWN, UNAUTHORIZED, ...
+	Message    string
+}
+
+func (e *RegistryError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("registry: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("registry: %d %s", e.StatusCode, e.Message)
+}
+
+// IsRegistryNotFound reports whether err is a registry answering that a
+// repository, tag or manifest does not exist.
+func IsRegistryNotFound(err error) bool {
+	var regErr *RegistryError
+	return errors.As(err, &regErr) && regErr.StatusCode == http.StatusNotFound
+}
+
+// IsRegistryUnauthorized reports whether err is a registry refusing the credentials.
+func IsRegistryUnauthorized(err error) bool {
+	var regErr *RegistryError
+	return errors.As(err, &regErr) &&
+		(regErr.StatusCode == http.StatusUnauthorized || regErr.StatusCode == http.StatusForbidden)
+}
+
+// parseRegistryEndpoint returns the base URL of a registry endpoint (https
+// unless given) and the namespace in its path. Docker Hub's web hosts are
+// mapped to its registry host.
+func parseRegistryEndpoint(endpoint string) (string, string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", "", fmt.Errorf("invalid registry endpoint %q", endpoint)
+	}
+	switch u.Host {
+	case "docker.io", "index.docker.io", "hub.docker.com":
+		u.Host = "registry-1.docker.io"
+	}
+	return u.Scheme + "://" + u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// isDigest reports whether a reference is a digest rather than a tag.
+func isDigest(reference string) bool {
+	return strings.Contains(reference, ":")
+}
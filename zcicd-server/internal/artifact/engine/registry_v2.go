@@ -0,0 +1,411 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxManifestSize bounds the manifests read from a registry.
+const maxManifestSize = 4 << 20
+
+// V2Client implements the Docker Registry v2 API with the token
+// authentication of Docker Hub, Harbor, ACR and GHCR, falling back to basic
+// authentication for registries asking for it.
+type V2Client struct {
+	baseURL    string
+	namespace  string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	username string
+	password string
+	basic    bool                   // the registry asked for basic authentication
+	tokens   map[string]bearerToken // by scope
+}
+
+type bearerToken struct {
+	token   string
+	expires time.Time
+}
+
+// NewV2Client creates a client for a registry endpoint.
+func NewV2Client(endpoint, username, password string, httpClient *http.Client) (*V2Client, error) {
+	baseURL, namespace, err := parseRegistryEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &V2Client{
+		baseURL:    baseURL,
+		namespace:  namespace,
+		httpClient: httpClient,
+		username:   username,
+		password:   password,
+		tokens:     map[string]bearerToken{},
+	}, nil
+}
+
+// setCredentials replaces the credentials, e.g. with a renewed ECR token.
+func (c *V2Client) setCredentials(username, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if username != c.username || password != c.password {
+		c.username, c.password = username, password
+		c.tokens = map[string]bearerToken{}
+	}
+}
+
+func (c *V2Client) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/v2/", "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListRepositories lists the catalog of the registry, under the namespace
+// of the endpoint if it has one.
+func (c *V2Client) ListRepositories(ctx context.Context) ([]string, error) {
+	var repos []string
+	err := c.paginate(ctx, "/v2/_catalog?n=100", "registry:catalog:*", func(body []byte) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return fmt.Errorf("invalid catalog: %w", err)
+		}
+		for _, name := range page.Repositories {
+			if rel, ok := c.relativeName(name); ok {
+				repos = append(repos, rel)
+			}
+		}
+		return nil
+	})
+	return repos, err
+}
+
+func (c *V2Client) ListTags(ctx context.Context, repository string) ([]string, error) {
+	name := c.fullName(repository)
+	var tags []string
+	err := c.paginate(ctx, "/v2/"+name+"/tags/list?n=100", pullScope(name), func(body []byte) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return fmt.Errorf("invalid tag list: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	})
+	return tags, err
+}
+
+func (c *V2Client) GetManifest(ctx context.Context, repository, reference string) (*Manifest, error) {
+	name := c.fullName(repository)
+	header := http.Header{"Accept": {manifestAccept}}
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+name+"/manifests/"+reference, pullScope(name), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s:%s: %w", repository, reference, err)
+	}
+	m.Raw = raw
+	if m.MediaType == "" {
+		m.MediaType, _, _ = strings.Cut(resp.Header.Get("Content-Type"), ";")
+	}
+	m.Digest = resp.Header.Get("Docker-Content-Digest")
+	if m.Digest == "" && isDigest(reference) {
+		m.Digest = reference
+	}
+	if m.Digest == "" {
+		sum := sha256.Sum256(raw)
+		m.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return m, nil
+}
+
+func (c *V2Client) GetImageConfig(ctx context.Context, repository, digest string) (*ImageConfig, error) {
+	name := c.fullName(repository)
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+name+"/blobs/"+digest, pullScope(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	cfg := &ImageConfig{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid image config %s of %s: %w", digest, repository, err)
+	}
+	return cfg, nil
+}
+
+func (c *V2Client) DeleteManifest(ctx context.Context, repository, digest string) error {
+	if !isDigest(digest) {
+		return fmt.Errorf("manifests are deleted by digest, not by tag %q", digest)
+	}
+	name := c.fullName(repository)
+	resp, err := c.do(ctx, http.MethodDelete, "/v2/"+name+"/manifests/"+digest, "repository:"+name+":delete", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// fullName prefixes a repository with the namespace of the endpoint.
+func (c *V2Client) fullName(repository string) string {
+	repository = strings.Trim(repository, "/")
+	if c.namespace == "" {
+		return repository
+	}
+	return c.namespace + "/" + repository
+}
+
+// relativeName strips the namespace of the endpoint from a repository, and
+// reports whether the repository is under it.
+func (c *V2Client) relativeName(name string) (string, bool) {
+	if c.namespace == "" {
+		return name, true
+	}
+	rel, ok := strings.CutPrefix(name, c.namespace+"/")
+	return rel, ok
+}
+
+func pullScope(name string) string {
+	return "repository:" + name + ":pull"
+}
+
+// paginate gets a list and the pages following it through the Link header.
+func (c *V2Client) paginate(ctx context.Context, path, scope string, page func(body []byte) error) error {
+	for path != "" {
+		resp, err := c.do(ctx, http.MethodGet, path, scope, nil)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if err := page(body); err != nil {
+			return err
+		}
+		path = nextLink(resp.Header.Get("Link"))
+	}
+	return nil
+}
+
+// nextLink returns the target of a Link header with rel="next", e.g.
+// </v2/_catalog?last=b&n=100>; rel="next".
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		if u, err := url.Parse(target); err == nil {
+			return u.RequestURI()
+		}
+	}
+	return ""
+}
+
+// do sends a request, answering the registry's authentication challenge
+// once. Statuses other than 2xx are returned as a RegistryError.
+func (c *V2Client) do(ctx context.Context, method, path, scope string, header http.Header) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, scope, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(ctx, challenge, scope); err != nil {
+			return nil, err
+		}
+		if resp, err = c.send(ctx, method, path, scope, header); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, registryError(resp)
+	}
+	return resp, nil
+}
+
+func (c *V2Client) send(ctx context.Context, method, path, scope string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.mu.Lock()
+	if tok, ok := c.tokens[scope]; ok && time.Now().Before(tok.expires) {
+		req.Header.Set("Authorization", "Bearer "+tok.token)
+	} else if c.basic && c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	c.mu.Unlock()
+	return c.httpClient.Do(req)
+}
+
+// authenticate answers a WWW-Authenticate challenge: with basic auth, or
+// with a bearer token from the realm for the scope of the challenge (or the
+// request's scope when the challenge has none).
+func (c *V2Client) authenticate(ctx context.Context, challenge, scope string) error {
+	scheme, params := parseChallenge(challenge)
+	c.mu.Lock()
+	username, password := c.username, c.password
+	c.mu.Unlock()
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return &RegistryError{StatusCode: http.StatusUnauthorized, Code: "UNAUTHORIZED", Message: "registry requires credentials"}
+		}
+		c.mu.Lock()
+		c.basic = true
+		c.mu.Unlock()
+		return nil
+	case "bearer":
+	default:
+		return &RegistryError{StatusCode: http.StatusUnauthorized, Code: "UNAUTHORIZED", Message: "unsupported authentication " + challenge}
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return fmt.Errorf("registry challenge without realm: %s", challenge)
+	}
+	q := url.Values{}
+	if v := params["service"]; v != "" {
+		q.Set("service", v)
+	}
+	tokenScope := params["scope"]
+	if tokenScope == "" {
+		tokenScope = scope
+	}
+	if tokenScope != "" {
+		q.Set("scope", tokenScope)
+	}
+	if username != "" {
+		q.Set("account", username)
+	}
+	u := realm
+	if len(q) > 0 {
+		sep := "?"
+		if strings.Contains(realm, "?") {
+			sep = "&"
+		}
+		u += sep + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return registryError(resp)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return fmt.Errorf("invalid registry token: %w", err)
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	if tok.Token == "" {
+		return fmt.Errorf("registry token endpoint %s returned no token", realm)
+	}
+	ttl := time.Duration(tok.ExpiresIn) * time.Second
+	if ttl < time.Minute {
+		ttl = time.Minute
+	}
+	c.mu.Lock()
+	c.tokens[scope] = bearerToken{token: tok.Token, expires: time.Now().Add(ttl - 10*time.Second)}
+	c.mu.Unlock()
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				b.WriteByte(value[i])
+			}
+			params[key] = b.String()
+			rest = value[min(i+1, len(value)):]
+		} else {
+			v, after, _ := strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(v)
+			rest = "," + after
+		}
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
+
+// registryError reads the error of a registry response:
+// {"errors":[{"code":"MANIFEST_UNKNOWN","message":"..."}]}.
+func registryError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	regErr := &RegistryError{StatusCode: resp.StatusCode}
+	var errs struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &errs) == nil && len(errs.Errors) > 0 {
+		regErr.Code = errs.Errors[0].Code
+		regErr.Message = errs.Errors[0].Message
+	} else {
+		regErr.Message = tail(string(body), 500)
+		if regErr.Message == "" {
+			regErr.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	return regErr
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:c0"}}`
+
+// fakeRegistry serves a Registry v2 API behind a bearer challenge, with a
+// token endpoint taking basic credentials, a catalog and a tag list of two
+// pages each, and manifests with and without Docker-Content-Digest.
+type fakeRegistry struct {
+	url        string
+	tokenQuery []url.Values // queries of the token requests
+	challenges int
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "robot" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors":[{"code":"UNAUTHORIZED","message":"bad credentials"}]}`)
+			return
+		}
+		f.tokenQuery = append(f.tokenQuery, r.URL.Query())
+		fmt.Fprintf(w, `{"access_token":%q,"expires_in":300}`, "tok:"+r.URL.Query().Get("scope"))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	scope := "registry:catalog:*"
+	if path != "_catalog" {
+		name, _, _ := strings.Cut(path, "/tags/")
+		name, _, _ = strings.Cut(name, "/manifests/")
+		scope = pullScope(name)
+	}
+	if r.Header.Get("Authorization") != "Bearer tok:"+scope {
+		f.challenges++
+		w.Header().Set("WWW-Authenticate",
+			fmt.Sprintf(`Bearer realm="%s/token",service="registry.test",scope="%s"`, f.url, scope))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	last := r.URL.Query().Get("last")
+	switch path {
+	case "_catalog":
+		if last == "" {
+			w.Header().Set("Link", `</v2/_catalog?last=lib%2Fapp&n=100>; rel="next"`)
+			fmt.Fprint(w, `{"repositories":["lib/app","other/tool"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"repositories":["lib/web"]}`)
+	case "lib/app/tags/list":
+		if last == "" {
+			w.Header().Set("Link", "<"+f.url+`/v2/lib/app/tags/list?last=v2&n=100>; rel="next"`)
+			fmt.Fprint(w, `{"name":"lib/app","tags":["v1","v2"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"name":"lib/app","tags":["v3"]}`)
+	case "lib/app/manifests/v1":
+		w.Header().Set("Docker-Content-Digest", "sha256:d1")
+		fmt.Fprint(w, testManifest)
+	case "lib/app/manifests/v2", "lib/app/manifests/sha256:d2":
+		w.Header().Set("Content-Type", MediaTypeDockerManifest)
+		fmt.Fprint(w, `{"schemaVersion":2,"config":{"digest":"sha256:c0"}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`)
+	}
+}
+
+func newFakeRegistry(t *testing.T, namespace string) (*fakeRegistry, *V2Client) {
+	t.Helper()
+	fake := &fakeRegistry{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	fake.url = srv.URL
+	client, err := NewV2Client(srv.URL+namespace, "robot", "secret", srv.Client())
+	if err != nil {
+		t.Fatalf("NewV2Client() error = %v", err)
+	}
+	return fake, client
+}
+
+func TestV2ClientBearerChallenge(t *testing.T) {
+	fake, client := newFakeRegistry(t, "")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		tags, err := client.ListTags(ctx, "lib/app")
+		if err != nil {
+			t.Fatalf("ListTags() error = %v", err)
+		}
+		if want := []string{"v1", "v2", "v3"}; !reflect.DeepEqual(tags, want) {
+			t.Fatalf("ListTags() = %v, want %v", tags, want)
+		}
+	}
+	if fake.challenges != 1 {
+		t.Errorf("challenges = %d, want 1 (token cached for the scope)", fake.challenges)
+	}
+	if len(fake.tokenQuery) != 1 {
+		t.Fatalf("token requests = %d, want 1", len(fake.tokenQuery))
+	}
+	q := fake.tokenQuery[0]
+	if q.Get("service") != "registry.test" || q.Get("scope") != "repository:lib/app:pull" || q.Get("account") != "robot" {
+		t.Errorf("token query = %v, want service, scope and account of the challenge", q)
+	}
+
+	// Another scope needs a token of its own.
+	if _, err := client.GetManifest(ctx, "lib/web", "v1"); !IsRegistryNotFound(err) {
+		t.Errorf("GetManifest(lib/web) error = %v, want not found", err)
+	}
+	if len(fake.tokenQuery) != 2 {
+		t.Errorf("token requests = %d, want 2", len(fake.tokenQuery))
+	}
+}
+
+func TestV2ClientBadCredentials(t *testing.T) {
+	fake := &fakeRegistry{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	fake.url = srv.URL
+	client, err := NewV2Client(srv.URL, "robot", "wrong", srv.Client())
+	if err != nil {
+		t.Fatalf("NewV2Client() error = %v", err)
+	}
+	if _, err := client.ListTags(context.Background(), "lib/app"); !IsRegistryUnauthorized(err) {
+		t.Errorf("ListTags() error = %v, want unauthorized", err)
+	}
+}
+
+func TestV2ClientListRepositories(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"whole registry", "", []string{"lib/app", "other/tool", "lib/web"}},
+		{"namespace of the endpoint", "/lib", []string{"app", "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newFakeRegistry(t, tt.namespace)
+			repos, err := client.ListRepositories(context.Background())
+			if err != nil {
+				t.Fatalf("ListRepositories() error = %v", err)
+			}
+			if !reflect.DeepEqual(repos, tt.want) {
+				t.Errorf("ListRepositories() = %v, want %v", repos, tt.want)
+			}
+		})
+	}
+}
+
+func TestV2ClientGetManifest(t *testing.T) {
+	sum := sha256.Sum256([]byte(`{"schemaVersion":2,"config":{"digest":"sha256:c0"}}`))
+	computed := "sha256:" + hex.EncodeToString(sum[:])
+	tests := []struct {
+		name          string
+		reference     string
+		wantDigest    string
+		wantMediaType string
+	}{
+		{"digest header", "v1", "sha256:d1", MediaTypeOCIManifest},
+		{"digest reference", "sha256:d2", "sha256:d2", MediaTypeDockerManifest},
+		{"computed from the body", "v2", computed, MediaTypeDockerManifest},
+	}
+	_, client := newFakeRegistry(t, "/lib")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := client.GetManifest(context.Background(), "app", tt.reference)
+			if err != nil {
+				t.Fatalf("GetManifest() error = %v", err)
+			}
+			if m.Digest != tt.wantDigest {
+				t.Errorf("Digest = %q, want %q", m.Digest, tt.wantDigest)
+			}
+			if m.MediaType != tt.wantMediaType {
+				t.Errorf("MediaType = %q, want %q", m.MediaType, tt.wantMediaType)
+			}
+			if m.Config.Digest != "sha256:c0" {
+				t.Errorf("Config.Digest = %q, want sha256:c0", m.Config.Digest)
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{`</v2/_catalog?last=b&n=100>; rel="next"`, "/v2/_catalog?last=b&n=100"},
+		{`<https://r.example.com/v2/app/tags/list?last=v2&n=100>;rel="next"`, "/v2/app/tags/list?last=v2&n=100"},
+		{`</v2/_catalog?last=a>; rel="prev", </v2/_catalog?last=c>; rel="next"`, "/v2/_catalog?last=c"},
+		{`</v2/_catalog?last=a>; rel="prev"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextLink(tt.header); got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:a/b:pull,push"`)
+	if scheme != "Bearer" {
+		t.Errorf("scheme = %q, want Bearer", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:a/b:pull,push",
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %v, want %v", params, want)
+	}
+}
+
+// TestSignV4 checks the signer against the get-vanilla case of the AWS
+// Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q, want 20150830T123600Z", got)
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TrivyTarget is an image to scan and the credentials of its registry.
+type TrivyTarget struct {
+	Image    string // host/repository:tag or host/repository@digest
+	Username string
+	Password string
+	Insecure bool // plain HTTP or self-signed registry
+}
+
+// Scanner runs Trivy against an image and returns its JSON report.
+type Scanner interface {
+	Scan(ctx context.Context, name string, target TrivyTarget) ([]byte, error)
+}
+
+// TrivyVulnerability is a vulnerability of a package in a Trivy report.
+type TrivyVulnerability struct {
+	VulnerabilityID  string
+	PkgName          string
+	PkgType          string
+	Target           string
+	InstalledVersion string
+	FixedVersion     string
+	Severity         string // CRITICAL/HIGH/MEDIUM/LOW/UNKNOWN
+	Title            string
+	PrimaryURL       string
+}
+
+// TrivyReport is the subset of a Trivy JSON report kept for an image scan.
+type TrivyReport struct {
+	Digest          string
+	Vulnerabilities []TrivyVulnerability
+}
+
+// ParseTrivyReport reads the vulnerabilities of every result of a report
+// produced with --format json.
+func ParseTrivyReport(data []byte) (*TrivyReport, error) {
+	var raw struct {
+		Metadata struct {
+			RepoDigests []string `json:"RepoDigests"`
+		} `json:"Metadata"`
+		Results []struct {
+			Target          string `json:"Target"`
+			Type            string `json:"Type"`
+			Vulnerabilities []struct {
+				VulnerabilityID  string `json:"VulnerabilityID"`
+				PkgName          string `json:"PkgName"`
+				InstalledVersion string `json:"InstalledVersion"`
+				FixedVersion     string `json:"FixedVersion"`
+				Severity         string `json:"Severity"`
+				Title            string `json:"Title"`
+				PrimaryURL       string `json:"PrimaryURL"`
+			} `json:"Vulnerabilities"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid trivy report: %w", err)
+	}
+	report := &TrivyReport{}
+	if len(raw.Metadata.RepoDigests) > 0 {
+		if _, digest, ok := strings.Cut(raw.Metadata.RepoDigests[0], "@"); ok {
+			report.Digest = digest
+		}
+	}
+	for _, res := range raw.Results {
+		for _, v := range res.Vulnerabilities {
+			severity := strings.ToUpper(v.Severity)
+			if severity == "" {
+				severity = "UNKNOWN"
+			}
+			report.Vulnerabilities = append(report.Vulnerabilities, TrivyVulnerability{
+				VulnerabilityID:  v.VulnerabilityID,
+				PkgName:          v.PkgName,
+				PkgType:          res.Type,
+				Target:           res.Target,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         severity,
+				Title:            v.Title,
+				PrimaryURL:       v.PrimaryURL,
+			})
+		}
+	}
+	return report, nil
+}
+
+// trivyEnv is the environment passing a target's credentials to Trivy.
+func trivyEnv(target TrivyTarget) map[string]string {
+	env := map[string]string{}
+	if target.Username != "" {
+		env["TRIVY_USERNAME"] = target.Username
+		env["TRIVY_PASSWORD"] = target.Password
+	}
+	if target.Insecure {
+		env["TRIVY_INSECURE"] = "true"
+	}
+	return env
+}
+
+// LocalScanner runs a Trivy binary on the host, for development.
+type LocalScanner struct {
+	binary  string
+	timeout time.Duration
+}
+
+// NewLocalScanner creates a scanner running binary (e.g. "trivy" from PATH).
+func NewLocalScanner(binary string, timeout time.Duration) *LocalScanner {
+	return &LocalScanner{binary: binary, timeout: timeout}
+}
+
+func (s *LocalScanner) Scan(ctx context.Context, name string, target TrivyTarget) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, s.binary, "image", "--quiet", "--format", "json",
+		"--timeout", s.timeout.String(), target.Image)
+	cmd.Env = os.Environ()
+	for k, v := range trivyEnv(target) {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("trivy %s: %w: %s", target.Image, err, tail(stderr.String(), 2000))
+	}
+	return stdout.Bytes(), nil
+}
+
+// tail returns the last n bytes of s.
+func tail(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return "..." + s[len(s)-n:]
+	}
+	return s
+}
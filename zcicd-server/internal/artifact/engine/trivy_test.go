@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testTrivyReport = `{
+  "SchemaVersion": 2,
+  "ArtifactName": "registry.test/lib/app:v1",
+  "Metadata": {
+    "RepoTags": ["registry.test/lib/app:v1"],
+    "RepoDigests": ["registry.test/lib/app@sha256:d1"]
+  },
+  "Results": [
+    {
+      "Target": "registry.test/lib/app:v1 (alpine 3.19.1)",
+      "Class": "os-pkgs",
+      "Type": "alpine",
+      "Vulnerabilities": [
+        {
+          "VulnerabilityID": "CVE-2024-0727",
+          "PkgName": "libssl3",
+          "InstalledVersion": "3.1.4-r2",
+          "FixedVersion": "3.1.4-r5",
+          "Severity": "MEDIUM",
+          "Title": "openssl: denial of service via null dereference",
+          "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2024-0727"
+        }
+      ]
+    },
+    {
+      "Target": "app/go.sum",
+      "Class": "lang-pkgs",
+      "Type": "gomod"
+    },
+    {
+      "Target": "usr/local/bin/app",
+      "Class": "lang-pkgs",
+      "Type": "gobinary",
+      "Vulnerabilities": [
+        {
+          "VulnerabilityID": "CVE-2023-45288",
+          "PkgName": "golang.org/x/net",
+          "InstalledVersion": "v0.17.0",
+          "Severity": "high"
+        },
+        {
+          "VulnerabilityID": "GHSA-xxxx",
+          "PkgName": "example.com/lib",
+          "InstalledVersion": "v1.0.0"
+        }
+      ]
+    }
+  ]
+}`
+
+func TestParseTrivyReport(t *testing.T) {
+	report, err := ParseTrivyReport([]byte(testTrivyReport))
+	if err != nil {
+		t.Fatalf("ParseTrivyReport() error = %v", err)
+	}
+	if report.Digest != "sha256:d1" {
+		t.Errorf("Digest = %q, want sha256:d1", report.Digest)
+	}
+	want := []TrivyVulnerability{
+		{
+			VulnerabilityID: "CVE-2024-0727", PkgName: "libssl3", PkgType: "alpine",
+			Target:           "registry.test/lib/app:v1 (alpine 3.19.1)",
+			InstalledVersion: "3.1.4-r2", FixedVersion: "3.1.4-r5", Severity: "MEDIUM",
+			Title:      "openssl: denial of service via null dereference",
+			PrimaryURL: "https://avd.aquasec.com/nvd/cve-2024-0727",
+		},
+		{
+			VulnerabilityID: "CVE-2023-45288", PkgName: "golang.org/x/net", PkgType: "gobinary",
+			Target: "usr/local/bin/app", InstalledVersion: "v0.17.0", Severity: "HIGH",
+		},
+		{
+			VulnerabilityID: "GHSA-xxxx", PkgName: "example.com/lib", PkgType: "gobinary",
+			Target: "usr/local/bin/app", InstalledVersion: "v1.0.0", Severity: "UNKNOWN",
+		},
+	}
+	if !reflect.DeepEqual(report.Vulnerabilities, want) {
+		t.Errorf("Vulnerabilities = %+v, want %+v", report.Vulnerabilities, want)
+	}
+}
+
+func TestParseTrivyReportEdgeCases(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantDigest string
+		wantVulns  int
+		wantErr    bool
+	}{
+		{"clean image", `{"Metadata":{"RepoDigests":["r/app@sha256:d2"]},"Results":[{"Target":"r/app (debian 12)","Type":"debian"}]}`, "sha256:d2", 0, false},
+		{"image without digest", `{"Results":[]}`, "", 0, false},
+		{"digest without separator", `{"Metadata":{"RepoDigests":["sha256:d3"]}}`, "", 0, false},
+		{"not json", `FATAL image scan error`, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ParseTrivyReport([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTrivyReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if report.Digest != tt.wantDigest || len(report.Vulnerabilities) != tt.wantVulns {
+				t.Errorf("report = %q with %d vulnerabilities, want %q with %d",
+					report.Digest, len(report.Vulnerabilities), tt.wantDigest, tt.wantVulns)
+			}
+		})
+	}
+}
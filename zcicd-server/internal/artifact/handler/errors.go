@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/response"
+)
+
+// writeAppError renders an AppError with a status matching its code and
+// reports whether err was one.
+func writeAppError(c *gin.Context, err error) bool {
+	var appErr *appErrors.AppError
+	if !errors.As(err, &appErr) {
+		return false
+	}
+	response.Error(c, appErrorStatus(appErr.Code), appErr.Code, appErr.Message)
+	return true
+}
+
+func appErrorStatus(code int) int {
+	switch code {
+	case appErrors.ErrRegistryNotFound.Code,
+		appErrors.ErrImageScanNotFound.Code,
+		appErrors.ErrImageRepoNotFound.Code,
+		appErrors.ErrImageScanNoReport.Code,
+		appErrors.ErrVulnPolicyNotFound.Code,
+		appErrors.ErrAllowlistNotFound.Code:
+		return http.StatusNotFound
+	case appErrors.ErrVulnPolicyExists.Code,
+		appErrors.ErrImageRescanRunning.Code:
+		return http.StatusConflict
+	case appErrors.ErrImageScanUnavailable.Code:
+		return http.StatusServiceUnavailable
+	case appErrors.ErrRegistryRequest.Code:
+		return http.StatusBadGateway
+	}
+	switch {
+	case code >= 50000:
+		return http.StatusInternalServerError
+	case code >= 40300 && code < 40400:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func parsePagination(c *gin.Context) (int, int) {
+	page := 1
+	pageSize := 20
+	if p := c.Query("page"); p != "" {
+		if v, err := strconv.Atoi(p); err == nil && v > 0 {
+			page = v
+		}
+	}
+	if ps := c.Query("page_size"); ps != "" {
+		if v, err := strconv.Atoi(ps); err == nil && v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+	return page, pageSize
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/artifact/service"
+	"github.com/zcicd/zcicd-server/pkg/response"
+)
+
+type PolicyHandler struct {
+	svc *service.PolicyService
+}
+
+func NewPolicyHandler(svc *service.PolicyService) *PolicyHandler {
+	return &PolicyHandler{svc: svc}
+}
+
+func (h *PolicyHandler) List(c *gin.Context) {
+	list, err := h.svc.ListPolicies(map[string]string{
+		"project_id": c.Query("project_id"),
+		"env_type":   c.Query("env_type"),
+	})
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *PolicyHandler) Create(c *gin.Context) {
+	var req service.CreatePolicyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	p, err := h.svc.CreatePolicy(req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.Created(c, p)
+}
+
+func (h *PolicyHandler) Get(c *gin.Context) {
+	p, err := h.svc.GetPolicy(c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, p)
+}
+
+func (h *PolicyHandler) Update(c *gin.Context) {
+	var req service.UpdatePolicyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	p, err := h.svc.UpdatePolicy(c.Param("id"), req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, p)
+}
+
+func (h *PolicyHandler) Delete(c *gin.Context) {
+	if err := h.svc.DeletePolicy(c.Param("id")); err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, nil)
+}
+
+// ListAllowlist lists the CVE allowlist; expired=true includes expired entries.
+func (h *PolicyHandler) ListAllowlist(c *gin.Context) {
+	list, err := h.svc.ListAllowlist(c.Query("expired") == "true")
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *PolicyHandler) CreateAllowlistEntry(c *gin.Context) {
+	var req service.CreateAllowlistReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	e, err := h.svc.CreateAllowlistEntry(c.GetString("user_id"), req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.Created(c, e)
+}
+
+func (h *PolicyHandler) DeleteAllowlistEntry(c *gin.Context) {
+	if err := h.svc.DeleteAllowlistEntry(c.Param("id")); err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, nil)
+}
+
+// ListResults returns the results of a scan against the vulnerability policies.
+func (h *PolicyHandler) ListResults(c *gin.Context) {
+	list, err := h.svc.ListResults(c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, list)
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/zcicd/zcicd-server/internal/artifact/service"
 	"github.com/zcicd/zcicd-server/pkg/response"
@@ -67,3 +69,77 @@ func (h *RegistryHandler) Delete(c *gin.Context) {
 	}
 	response.OK(c, nil)
 }
+
+// TestConnection checks that a registry answers and accepts its credentials.
+func (h *RegistryHandler) TestConnection(c *gin.Context) {
+	result, err := h.svc.TestConnection(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, result)
+}
+
+// ListProjects lists the projects of a Harbor registry.
+func (h *RegistryHandler) ListProjects(c *gin.Context) {
+	projects, err := h.svc.ListHarborProjects(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, projects)
+}
+
+// ScanOverview returns Harbor's built-in scan result of an image.
+func (h *RegistryHandler) ScanOverview(c *gin.Context) {
+	repository, reference := c.Query("repository"), c.Query("reference")
+	if repository == "" || reference == "" {
+		response.BadRequest(c, "repository and reference are required")
+		return
+	}
+	overview, err := h.svc.HarborScanOverview(c.Request.Context(), c.Param("id"), repository, reference)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, overview)
+}
+
+// ListRepositories lists the repositories of a registry.
+func (h *RegistryHandler) ListRepositories(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	list, total, err := h.svc.ListRepositories(c.Request.Context(), c.Param("id"), c.Query("keyword"), page, pageSize)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+// ListTags lists the tags of a repository with their image metadata, builds
+// and deployments. Repository names hold slashes, so the route catches the
+// rest of the path, which must end in /tags.
+func (h *RegistryHandler) ListTags(c *gin.Context) {
+	repository, ok := strings.CutSuffix(strings.Trim(c.Param("name"), "/"), "/tags")
+	if !ok || repository == "" {
+		response.NotFound(c, "接口不存在")
+		return
+	}
+	page, pageSize := parsePagination(c)
+	list, total, err := h.svc.ListTags(c.Request.Context(), c.Param("id"), repository, c.Query("keyword"), page, pageSize)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
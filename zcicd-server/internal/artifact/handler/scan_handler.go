@@ -22,7 +22,9 @@ func (h *ScanHandler) TriggerScan(c *gin.Context) {
 	}
 	scan, err := h.svc.TriggerScan(c.Param("name"), req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 	response.Created(c, scan)
@@ -41,3 +43,56 @@ func (h *ScanHandler) GetScanResults(c *gin.Context) {
 	}
 	response.OK(c, list)
 }
+
+func (h *ScanHandler) GetScan(c *gin.Context) {
+	scan, err := h.svc.GetScan(c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, scan)
+}
+
+func (h *ScanHandler) ListVulnerabilities(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	filters := map[string]string{
+		"severity":         c.Query("severity"),
+		"pkg_name":         c.Query("pkg_name"),
+		"vulnerability_id": c.Query("vulnerability_id"),
+		"fixable":          c.Query("fixable"),
+	}
+	list, total, err := h.svc.ListVulnerabilities(c.Param("id"), page, pageSize, filters)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+// GetReport returns a download link of the raw Trivy report of a scan.
+func (h *ScanHandler) GetReport(c *gin.Context) {
+	url, err := h.svc.ReportURL(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, gin.H{"url": url})
+}
+
+// Rescan scans every image again, e.g. after the vulnerability database was updated.
+func (h *ScanHandler) Rescan(c *gin.Context) {
+	queued, err := h.svc.Rescan()
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, gin.H{"queued": queued})
+}
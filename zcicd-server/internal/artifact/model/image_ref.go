@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// ImageBuild is a read-only view of a succeeded run of the workflow service's
+// build_runs table that pushed an image, joined with its build config.
+type ImageBuild struct {
+	ID            string    `json:"id"`
+	BuildConfigID string    `json:"build_config_id"`
+	ServiceID     string    `json:"service_id"`
+	RunNumber     int       `json:"run_number"`
+	Branch        string    `json:"branch"`
+	CommitSHA     string    `json:"commit_sha"`
+	ImageTag      string    `json:"image_tag"`
+	ImageDigest   string    `json:"image_digest"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ImageDeployment is a read-only view of the latest succeeded deploy of a
+// deploy config owned by the deploy service, joined with its environment.
+type ImageDeployment struct {
+	DeployConfigID  string     `json:"deploy_config_id"`
+	DeployConfig    string     `json:"deploy_config"`
+	ProjectID       string     `json:"project_id"`
+	EnvironmentID   string     `json:"environment_id"`
+	EnvironmentName string     `json:"environment_name"`
+	EnvType         string     `json:"env_type"`
+	IsProduction    bool       `json:"is_production"`
+	DeployHistoryID string     `json:"deploy_history_id"`
+	ImageTag        string     `json:"image_tag"`
+	ImageDigest     string     `json:"image_digest"`
+	DeployedAt      *time.Time `json:"deployed_at"`
+}
@@ -3,18 +3,40 @@ package model
 import "time"
 
 type ImageScan struct {
-	ID         string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	RegistryID string     `json:"registry_id" gorm:"type:uuid;not null;index"`
-	ImageName  string     `json:"image_name" gorm:"size:512;not null"`
-	Tag        string     `json:"tag" gorm:"size:256;not null"`
-	Status     string     `json:"status" gorm:"size:32;not null;default:'pending'"`
-	Critical   int        `json:"critical" gorm:"default:0"`
-	High       int        `json:"high" gorm:"default:0"`
-	Medium     int        `json:"medium" gorm:"default:0"`
-	Low        int        `json:"low" gorm:"default:0"`
-	ReportURL  string     `json:"report_url" gorm:"size:512"`
-	ScannedAt  *time.Time `json:"scanned_at"`
-	CreatedAt  time.Time  `json:"created_at"`
+	ID           string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	RegistryID   string     `json:"registry_id" gorm:"type:uuid;not null;index"`
+	ImageName    string     `json:"image_name" gorm:"size:512;not null"`
+	Tag          string     `json:"tag" gorm:"size:256;not null"`
+	Digest       string     `json:"digest" gorm:"size:128"`
+	Status       string     `json:"status" gorm:"size:32;not null;default:'pending'"`
+	Critical     int        `json:"critical" gorm:"default:0"`
+	High         int        `json:"high" gorm:"default:0"`
+	Medium       int        `json:"medium" gorm:"default:0"`
+	Low          int        `json:"low" gorm:"default:0"`
+	Unknown      int        `json:"unknown" gorm:"default:0"`
+	ReportURL    string     `json:"report_url" gorm:"size:512"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	StartedAt    *time.Time `json:"started_at"`
+	ScannedAt    *time.Time `json:"scanned_at"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 func (ImageScan) TableName() string { return "image_scans" }
+
+// ImageVulnerability is a vulnerability of a package found by an image scan.
+type ImageVulnerability struct {
+	ID               string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ScanID           string    `json:"scan_id" gorm:"type:uuid;not null;index"`
+	VulnerabilityID  string    `json:"vulnerability_id" gorm:"size:64;not null"`
+	PkgName          string    `json:"pkg_name" gorm:"size:256;not null"`
+	PkgType          string    `json:"pkg_type" gorm:"size:32"`
+	Target           string    `json:"target" gorm:"size:512"`
+	InstalledVersion string    `json:"installed_version" gorm:"size:128"`
+	FixedVersion     string    `json:"fixed_version" gorm:"size:256"`
+	Severity         string    `json:"severity" gorm:"size:16;not null"` // CRITICAL/HIGH/MEDIUM/LOW/UNKNOWN
+	Title            string    `json:"title"`
+	PrimaryURL       string    `json:"primary_url" gorm:"size:512"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (ImageVulnerability) TableName() string { return "image_vulnerabilities" }
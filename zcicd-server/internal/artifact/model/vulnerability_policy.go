@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// VulnerabilityPolicy is the highest vulnerability severity allowed in images
+// deployed to an environment type, for a project or, without one, by default.
+type VulnerabilityPolicy struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID   *string   `json:"project_id" gorm:"type:uuid"`
+	EnvType     string    `json:"env_type" gorm:"size:32;not null"`
+	MaxSeverity string    `json:"max_severity" gorm:"size:16;not null;default:'HIGH'"` // NONE/LOW/MEDIUM/HIGH/CRITICAL
+	FixableOnly bool      `json:"fixable_only" gorm:"default:false"`
+	BlockDeploy bool      `json:"block_deploy" gorm:"not null"`
+	Enabled     bool      `json:"enabled" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (VulnerabilityPolicy) TableName() string { return "vulnerability_policies" }
+
+// CVEAllowlistEntry waives a vulnerability until it expires, in a package
+// and an image or, left empty, in all of them.
+type CVEAllowlistEntry struct {
+	ID              string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	VulnerabilityID string    `json:"vulnerability_id" gorm:"size:64;not null"`
+	PkgName         string    `json:"pkg_name,omitempty" gorm:"size:256"`
+	ImageName       string    `json:"image_name,omitempty" gorm:"size:512"`
+	Justification   string    `json:"justification" gorm:"not null"`
+	ExpiresAt       time.Time `json:"expires_at" gorm:"not null"`
+	CreatedBy       *string   `json:"created_by" gorm:"type:uuid"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (CVEAllowlistEntry) TableName() string { return "cve_allowlist" }
+
+// ImagePolicyResult is the evaluation of an image scan against a policy.
+type ImagePolicyResult struct {
+	ID                 string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ScanID             string         `json:"scan_id" gorm:"type:uuid;not null"`
+	PolicyID           string         `json:"policy_id" gorm:"type:uuid;not null"`
+	Status             string         `json:"status" gorm:"size:16;not null"` // passed/failed
+	Violations         datatypes.JSON `json:"violations" gorm:"type:jsonb"`   // []PolicyViolation
+	Allowed            datatypes.JSON `json:"allowed" gorm:"type:jsonb"`      // []PolicyViolation
+	AllowlistExpiresAt *time.Time     `json:"allowlist_expires_at"`
+	EvaluatedAt        time.Time      `json:"evaluated_at"`
+}
+
+func (ImagePolicyResult) TableName() string { return "image_policy_results" }
+
+// PolicyViolation is a vulnerability over a policy's maximum severity.
+type PolicyViolation struct {
+	VulnerabilityID  string `json:"vulnerability_id"`
+	PkgName          string `json:"pkg_name"`
+	Severity         string `json:"severity"`
+	InstalledVersion string `json:"installed_version"`
+	FixedVersion     string `json:"fixed_version,omitempty"`
+	AllowlistID      string `json:"allowlist_id,omitempty"`
+}
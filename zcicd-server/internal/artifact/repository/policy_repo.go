@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/artifact/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewPolicyRepository(db *gorm.DB) *PolicyRepository {
+	return &PolicyRepository{db: db}
+}
+
+// List lists policies, filtered by project_id ("default" for those of all
+// projects) and env_type.
+func (r *PolicyRepository) List(filters map[string]string) ([]model.VulnerabilityPolicy, error) {
+	var list []model.VulnerabilityPolicy
+	q := r.db.Model(&model.VulnerabilityPolicy{})
+	switch v := filters["project_id"]; v {
+	case "":
+	case "default":
+		q = q.Where("project_id IS NULL")
+	default:
+		q = q.Where("project_id = ?", v)
+	}
+	if v := filters["env_type"]; v != "" {
+		q = q.Where("env_type = ?", v)
+	}
+	err := q.Order("project_id NULLS FIRST, env_type").Find(&list).Error
+	return list, err
+}
+
+func (r *PolicyRepository) ListEnabled() ([]model.VulnerabilityPolicy, error) {
+	var list []model.VulnerabilityPolicy
+	err := r.db.Where("enabled = ?", true).Find(&list).Error
+	return list, err
+}
+
+func (r *PolicyRepository) Get(id string) (*model.VulnerabilityPolicy, error) {
+	var p model.VulnerabilityPolicy
+	err := r.db.Where("id = ?", id).First(&p).Error
+	return &p, err
+}
+
+// Exists reports whether a project, or the default when projectID is nil,
+// has a policy for an environment type.
+func (r *PolicyRepository) Exists(projectID *string, envType string) (bool, error) {
+	var count int64
+	q := r.db.Model(&model.VulnerabilityPolicy{}).Where("env_type = ?", envType)
+	if projectID == nil {
+		q = q.Where("project_id IS NULL")
+	} else {
+		q = q.Where("project_id = ?", *projectID)
+	}
+	err := q.Count(&count).Error
+	return count > 0, err
+}
+
+func (r *PolicyRepository) Create(p *model.VulnerabilityPolicy) error {
+	return r.db.Create(p).Error
+}
+
+func (r *PolicyRepository) Update(p *model.VulnerabilityPolicy) error {
+	return r.db.Save(p).Error
+}
+
+// Delete deletes a policy and, by cascade, its results.
+func (r *PolicyRepository) Delete(id string) (bool, error) {
+	res := r.db.Where("id = ?", id).Delete(&model.VulnerabilityPolicy{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListAllowlist lists the allowlist, most recent first, with the expired
+// entries only when expired is true.
+func (r *PolicyRepository) ListAllowlist(expired bool) ([]model.CVEAllowlistEntry, error) {
+	var list []model.CVEAllowlistEntry
+	q := r.db.Model(&model.CVEAllowlistEntry{})
+	if !expired {
+		q = q.Where("expires_at > ?", time.Now())
+	}
+	err := q.Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *PolicyRepository) CreateAllowlistEntry(e *model.CVEAllowlistEntry) error {
+	return r.db.Create(e).Error
+}
+
+func (r *PolicyRepository) DeleteAllowlistEntry(id string) (bool, error) {
+	res := r.db.Where("id = ?", id).Delete(&model.CVEAllowlistEntry{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// SaveResult inserts or replaces the result of a scan against a policy.
+func (r *PolicyRepository) SaveResult(res *model.ImagePolicyResult) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scan_id"}, {Name: "policy_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "violations", "allowed", "allowlist_expires_at", "evaluated_at",
+		}),
+	}).Create(res).Error
+}
+
+func (r *PolicyRepository) ListResults(scanID string) ([]model.ImagePolicyResult, error) {
+	var list []model.ImagePolicyResult
+	err := r.db.Where("scan_id = ?", scanID).Order("evaluated_at DESC").Find(&list).Error
+	return list, err
+}
+
+// ExpiredResultScans returns the scans with a result relying on an allowlist
+// entry that has expired by now.
+func (r *PolicyRepository) ExpiredResultScans(now time.Time) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&model.ImagePolicyResult{}).
+		Where("allowlist_expires_at <= ?", now).
+		Distinct("scan_id").Pluck("scan_id", &ids).Error
+	return ids, err
+}
@@ -36,3 +36,48 @@ func (r *RegistryRepository) List() ([]model.ImageRegistry, error) {
 	err := r.db.Order("created_at DESC").Find(&list).Error
 	return list, err
 }
+
+// ListImageBuilds returns the succeeded builds that pushed tags or digests of
+// an image repository, the latest first.
+func (r *RegistryRepository) ListImageBuilds(imageRepo string, tags, digests []string) ([]model.ImageBuild, error) {
+	var list []model.ImageBuild
+	if len(tags) == 0 && len(digests) == 0 {
+		return list, nil
+	}
+	if tags == nil {
+		tags = []string{""}
+	}
+	if digests == nil {
+		digests = []string{""}
+	}
+	err := r.db.Table("build_runs").
+		Select("build_runs.id, build_runs.build_config_id, build_configs.service_id, build_runs.run_number, "+
+			"build_runs.branch, build_runs.commit_sha, build_runs.image_tag, build_runs.image_digest, build_runs.created_at").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_configs.image_repo = ? AND build_runs.status = 'succeeded'", imageRepo).
+		Where("(build_runs.image_tag IN ? OR build_runs.image_digest IN ?)", tags, digests).
+		Order("build_runs.created_at DESC").
+		Scan(&list).Error
+	return list, err
+}
+
+// ListImageDeployments returns the latest succeeded deploy of each deploy
+// config of the services building an image repository. The deployed image is
+// read from the deploy's values snapshot.
+func (r *RegistryRepository) ListImageDeployments(imageRepo string) ([]model.ImageDeployment, error) {
+	var list []model.ImageDeployment
+	err := r.db.Raw(`
+SELECT DISTINCT ON (dc.id)
+       dc.id AS deploy_config_id, dc.name AS deploy_config, dc.project_id, dc.environment_id,
+       e.name AS environment_name, e.env_type, e.is_production,
+       dh.id AS deploy_history_id,
+       COALESCE(dh.values_snapshot->'image'->>'tag', '') AS image_tag,
+       COALESCE(NULLIF(dh.image_digest, ''), dh.values_snapshot->'image'->>'digest', '') AS image_digest,
+       COALESCE(dh.finished_at, dh.created_at) AS deployed_at
+  FROM deploy_configs dc
+  JOIN environments e ON e.id = dc.environment_id
+  JOIN deploy_histories dh ON dh.deploy_config_id = dc.id AND dh.status = 'succeeded'
+ WHERE dc.service_id IN (SELECT service_id FROM build_configs WHERE image_repo = ?)
+ ORDER BY dc.id, dh.created_at DESC`, imageRepo).Scan(&list).Error
+	return list, err
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"strings"
+
 	"github.com/zcicd/zcicd-server/internal/artifact/model"
 	"gorm.io/gorm"
 )
@@ -29,3 +31,66 @@ func (r *ScanRepository) ListByImage(registryID, imageName string) ([]model.Imag
 		Order("created_at DESC").Find(&list).Error
 	return list, err
 }
+
+func (r *ScanRepository) Update(scan *model.ImageScan) error {
+	return r.db.Save(scan).Error
+}
+
+// ListByStatus returns the scans in a status, e.g. those left scanning by a restart.
+func (r *ScanRepository) ListByStatus(status string) ([]model.ImageScan, error) {
+	var list []model.ImageScan
+	err := r.db.Where("status = ?", status).Find(&list).Error
+	return list, err
+}
+
+// CreateVulnerabilities inserts the vulnerabilities found by a scan.
+func (r *ScanRepository) CreateVulnerabilities(vulns []model.ImageVulnerability) error {
+	if len(vulns) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(vulns, 500).Error
+}
+
+// ListVulnerabilities lists the vulnerabilities of a scan, most severe first,
+// filtered by severity, pkg_name and vulnerability_id, and fixable ("true"
+// for those with a fixed version).
+func (r *ScanRepository) ListVulnerabilities(scanID string, page, pageSize int, filters map[string]string) ([]model.ImageVulnerability, int64, error) {
+	var list []model.ImageVulnerability
+	var total int64
+	q := r.db.Where("scan_id = ?", scanID)
+	if v := filters["severity"]; v != "" {
+		q = q.Where("severity = ?", strings.ToUpper(v))
+	}
+	if v := filters["pkg_name"]; v != "" {
+		q = q.Where("pkg_name = ?", v)
+	}
+	if v := filters["vulnerability_id"]; v != "" {
+		q = q.Where("vulnerability_id = ?", v)
+	}
+	if filters["fixable"] == "true" {
+		q = q.Where("fixed_version <> ''")
+	}
+	q.Model(&model.ImageVulnerability{}).Count(&total)
+	err := q.Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("CASE severity WHEN 'CRITICAL' THEN 0 WHEN 'HIGH' THEN 1 WHEN 'MEDIUM' THEN 2 WHEN 'LOW' THEN 3 ELSE 4 END, vulnerability_id").
+		Find(&list).Error
+	return list, total, err
+}
+
+// AllVulnerabilities returns every vulnerability of a scan.
+func (r *ScanRepository) AllVulnerabilities(scanID string) ([]model.ImageVulnerability, error) {
+	var list []model.ImageVulnerability
+	err := r.db.Where("scan_id = ?", scanID).Find(&list).Error
+	return list, err
+}
+
+// ListLatestCompleted returns the latest completed scan of every image, by
+// registry, name, tag and digest.
+func (r *ScanRepository) ListLatestCompleted() ([]model.ImageScan, error) {
+	var list []model.ImageScan
+	err := r.db.Raw(`SELECT DISTINCT ON (registry_id, image_name, tag, COALESCE(digest, '')) *
+		FROM image_scans WHERE status = 'completed'
+		ORDER BY registry_id, image_name, tag, COALESCE(digest, ''), scanned_at DESC`).
+		Scan(&list).Error
+	return list, err
+}
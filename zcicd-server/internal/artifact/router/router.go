@@ -6,7 +6,7 @@ import (
 	"github.com/zcicd/zcicd-server/pkg/middleware"
 )
 
-func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, regH *handler.RegistryHandler, scanH *handler.ScanHandler, chartH *handler.ChartHandler) {
+func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, regH *handler.RegistryHandler, scanH *handler.ScanHandler, policyH *handler.PolicyHandler, chartH *handler.ChartHandler) {
 	auth := middleware.JWTAuth(jwtSecret)
 
 	artifacts := r.Group("/artifacts")
@@ -18,11 +18,36 @@ func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, regH *handler.Registry
 		registries.GET("/:id", regH.Get)
 		registries.PUT("/:id", regH.Update)
 		registries.DELETE("/:id", regH.Delete)
+		registries.POST("/:id/test", regH.TestConnection)
+		registries.GET("/:id/projects", regH.ListProjects)
+		registries.GET("/:id/scan-overview", regH.ScanOverview)
+		registries.GET("/:id/repositories", regH.ListRepositories)
+		registries.GET("/:id/repositories/*name", regH.ListTags)
 
 		images := artifacts.Group("/images")
 		images.GET("/:name/scan", scanH.GetScanResults)
 		images.POST("/:name/scan", scanH.TriggerScan)
 
+		scans := artifacts.Group("/scans")
+		scans.GET("/:id", scanH.GetScan)
+		scans.GET("/:id/vulnerabilities", scanH.ListVulnerabilities)
+		scans.GET("/:id/report", scanH.GetReport)
+		scans.GET("/:id/policy-results", policyH.ListResults)
+		scans.POST("/rescan", middleware.AdminRequired(), scanH.Rescan)
+
+		// Policy and allowlist changes are restricted to admins
+		policies := artifacts.Group("/vulnerability-policies")
+		policies.GET("", policyH.List)
+		policies.GET("/:id", policyH.Get)
+		policies.POST("", middleware.AdminRequired(), policyH.Create)
+		policies.PUT("/:id", middleware.AdminRequired(), policyH.Update)
+		policies.DELETE("/:id", middleware.AdminRequired(), policyH.Delete)
+
+		allowlist := artifacts.Group("/cve-allowlist")
+		allowlist.GET("", policyH.ListAllowlist)
+		allowlist.POST("", middleware.AdminRequired(), policyH.CreateAllowlistEntry)
+		allowlist.DELETE("/:id", middleware.AdminRequired(), policyH.DeleteAllowlistEntry)
+
 		charts := artifacts.Group("/charts")
 		charts.GET("", chartH.List)
 		charts.POST("", chartH.Create)
@@ -1,5 +1,11 @@
 package service
 
+import (
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/artifact/model"
+)
+
 type CreateRegistryReq struct {
 	Name         string `json:"name" binding:"required"`
 	RegistryType string `json:"registry_type"`
@@ -27,3 +33,53 @@ type CreateChartReq struct {
 	Name    string `json:"name" binding:"required"`
 	RepoURL string `json:"repo_url" binding:"required"`
 }
+
+type CreatePolicyReq struct {
+	ProjectID   *string `json:"project_id"`
+	EnvType     string  `json:"env_type" binding:"required,oneof=dev testing staging production preview"`
+	MaxSeverity string  `json:"max_severity" binding:"required,oneof=NONE LOW MEDIUM HIGH CRITICAL"`
+	FixableOnly bool    `json:"fixable_only"`
+	BlockDeploy *bool   `json:"block_deploy"`
+	Enabled     *bool   `json:"enabled"`
+}
+
+type UpdatePolicyReq struct {
+	MaxSeverity string `json:"max_severity" binding:"omitempty,oneof=NONE LOW MEDIUM HIGH CRITICAL"`
+	FixableOnly *bool  `json:"fixable_only"`
+	BlockDeploy *bool  `json:"block_deploy"`
+	Enabled     *bool  `json:"enabled"`
+}
+
+type CreateAllowlistReq struct {
+	VulnerabilityID string    `json:"vulnerability_id" binding:"required,max=64"`
+	PkgName         string    `json:"pkg_name" binding:"omitempty,max=256"`
+	ImageName       string    `json:"image_name" binding:"omitempty,max=512"`
+	Justification   string    `json:"justification" binding:"required"`
+	ExpiresAt       time.Time `json:"expires_at" binding:"required"`
+}
+
+// RegistryTestResult is the outcome of a connection test of a registry.
+type RegistryTestResult struct {
+	Connected bool   `json:"connected"`
+	Message   string `json:"message"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// ImageTag is a tag of a registry repository with its image metadata, the
+// build that pushed it and the environments it is deployed to.
+type ImageTag struct {
+	Name          string                  `json:"name"`
+	Digest        string                  `json:"digest"`
+	MediaType     string                  `json:"media_type"`
+	Size          int64                   `json:"size"` // of the linux/amd64 or first image of a multi-platform tag
+	PushedAt      *time.Time              `json:"pushed_at"`
+	CreatedAt     *time.Time              `json:"created_at"`
+	Architectures []string                `json:"architectures"`
+	Labels        map[string]string       `json:"labels"`
+	Build         *model.ImageBuild       `json:"build"`
+	Deployments   []model.ImageDeployment `json:"deployments"`
+	Error         string                  `json:"error,omitempty"` // why the manifest could not be read
+
+	// digests are the tag's digest and those of the images of an index.
+	digests []string
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/artifact/model"
+	"github.com/zcicd/zcicd-server/internal/artifact/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// severityRank orders severities; a vulnerability violates a policy when its
+// rank is above the policy's maximum severity.
+var severityRank = map[string]int{
+	"NONE":     0,
+	"UNKNOWN":  1,
+	"LOW":      2,
+	"MEDIUM":   3,
+	"HIGH":     4,
+	"CRITICAL": 5,
+}
+
+// PolicyService evaluates image scans against the vulnerability policies
+// and the CVE allowlist. The deploy service reads the results to refuse
+// deploys of images failing the policy of the target environment.
+type PolicyService struct {
+	repo     *repository.PolicyRepository
+	scanRepo *repository.ScanRepository
+	mu       sync.Mutex
+}
+
+func NewPolicyService(repo *repository.PolicyRepository, scanRepo *repository.ScanRepository) *PolicyService {
+	return &PolicyService{repo: repo, scanRepo: scanRepo}
+}
+
+// EvaluateScan evaluates a completed scan against every enabled policy.
+func (s *PolicyService) EvaluateScan(scan *model.ImageScan) error {
+	if scan.Status != "completed" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies, err := s.repo.ListEnabled()
+	if err != nil || len(policies) == 0 {
+		return err
+	}
+	vulns, err := s.scanRepo.AllVulnerabilities(scan.ID)
+	if err != nil {
+		return err
+	}
+	allowlist, err := s.repo.ListAllowlist(false)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range policies {
+		res := evaluatePolicy(&policies[i], scan, vulns, allowlist, now)
+		res.EvaluatedAt = now
+		if err := s.repo.SaveResult(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluatePolicy returns the result of a scan against a policy at now: failed
+// if a vulnerability over the maximum severity, with a fix if the policy is
+// fixable_only, is not allowlisted.
+func evaluatePolicy(policy *model.VulnerabilityPolicy, scan *model.ImageScan, vulns []model.ImageVulnerability, allowlist []model.CVEAllowlistEntry, now time.Time) *model.ImagePolicyResult {
+	res := &model.ImagePolicyResult{ScanID: scan.ID, PolicyID: policy.ID, Status: "passed"}
+	maxRank := severityRank[policy.MaxSeverity]
+	violations := []model.PolicyViolation{}
+	allowed := []model.PolicyViolation{}
+	seen := map[string]bool{}
+	for i := range vulns {
+		v := &vulns[i]
+		if severityRank[v.Severity] <= maxRank || (policy.FixableOnly && v.FixedVersion == "") {
+			continue
+		}
+		key := v.VulnerabilityID + "|" + v.PkgName + "|" + v.InstalledVersion
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		pv := model.PolicyViolation{
+			VulnerabilityID:  v.VulnerabilityID,
+			PkgName:          v.PkgName,
+			Severity:         v.Severity,
+			InstalledVersion: v.InstalledVersion,
+			FixedVersion:     v.FixedVersion,
+		}
+		entry := allowlisted(allowlist, scan.ImageName, v, now)
+		if entry == nil {
+			violations = append(violations, pv)
+			continue
+		}
+		pv.AllowlistID = entry.ID
+		allowed = append(allowed, pv)
+		if res.AllowlistExpiresAt == nil || entry.ExpiresAt.Before(*res.AllowlistExpiresAt) {
+			expires := entry.ExpiresAt
+			res.AllowlistExpiresAt = &expires
+		}
+	}
+	if len(violations) > 0 {
+		res.Status = "failed"
+	}
+	res.Violations, _ = json.Marshal(violations)
+	res.Allowed, _ = json.Marshal(allowed)
+	return res
+}
+
+// allowlisted returns the unexpired allowlist entry waiving a vulnerability
+// of an image.
+func allowlisted(allowlist []model.CVEAllowlistEntry, imageName string, v *model.ImageVulnerability, now time.Time) *model.CVEAllowlistEntry {
+	for i := range allowlist {
+		e := &allowlist[i]
+		if !e.ExpiresAt.After(now) || !strings.EqualFold(e.VulnerabilityID, v.VulnerabilityID) {
+			continue
+		}
+		if e.PkgName != "" && e.PkgName != v.PkgName {
+			continue
+		}
+		if e.ImageName != "" && strings.Trim(e.ImageName, "/") != strings.Trim(imageName, "/") {
+			continue
+		}
+		return e
+	}
+	return nil
+}
+
+// Reevaluate evaluates the latest completed scan of every image again, after
+// a policy or the allowlist changed.
+func (s *PolicyService) Reevaluate() {
+	scans, err := s.scanRepo.ListLatestCompleted()
+	if err != nil {
+		fmt.Printf("warning: failed to list image scans: %v\n", err)
+		return
+	}
+	for i := range scans {
+		if err := s.EvaluateScan(&scans[i]); err != nil {
+			fmt.Printf("warning: failed to evaluate image scan %s: %v\n", scans[i].ID, err)
+		}
+	}
+}
+
+// StartExpiryChecker periodically evaluates again the scans whose results
+// rely on an allowlist entry that has expired.
+func (s *PolicyService) StartExpiryChecker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ids, err := s.repo.ExpiredResultScans(time.Now())
+				if err != nil {
+					fmt.Printf("warning: failed to list expired policy results: %v\n", err)
+					continue
+				}
+				for _, id := range ids {
+					scan, err := s.scanRepo.Get(id)
+					if err == nil {
+						err = s.EvaluateScan(scan)
+					}
+					if err != nil {
+						fmt.Printf("warning: failed to evaluate image scan %s: %v\n", id, err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *PolicyService) ListPolicies(filters map[string]string) ([]model.VulnerabilityPolicy, error) {
+	return s.repo.List(filters)
+}
+
+func (s *PolicyService) GetPolicy(id string) (*model.VulnerabilityPolicy, error) {
+	p, err := s.repo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrVulnPolicyNotFound
+	}
+	return p, err
+}
+
+// CreatePolicy creates the policy of a project, or the default one without
+// project_id, for an environment type and evaluates the images against it.
+func (s *PolicyService) CreatePolicy(req CreatePolicyReq) (*model.VulnerabilityPolicy, error) {
+	if req.ProjectID != nil && *req.ProjectID == "" {
+		req.ProjectID = nil
+	}
+	exists, err := s.repo.Exists(req.ProjectID, req.EnvType)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, appErrors.ErrVulnPolicyExists
+	}
+	p := &model.VulnerabilityPolicy{
+		ProjectID:   req.ProjectID,
+		EnvType:     req.EnvType,
+		MaxSeverity: req.MaxSeverity,
+		FixableOnly: req.FixableOnly,
+		BlockDeploy: true,
+		Enabled:     true,
+	}
+	if req.BlockDeploy != nil {
+		p.BlockDeploy = *req.BlockDeploy
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	if err := s.repo.Create(p); err != nil {
+		return nil, err
+	}
+	go s.Reevaluate()
+	return p, nil
+}
+
+// UpdatePolicy updates a policy and evaluates the images against it again.
+func (s *PolicyService) UpdatePolicy(id string, req UpdatePolicyReq) (*model.VulnerabilityPolicy, error) {
+	p, err := s.GetPolicy(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxSeverity != "" {
+		p.MaxSeverity = req.MaxSeverity
+	}
+	if req.FixableOnly != nil {
+		p.FixableOnly = *req.FixableOnly
+	}
+	if req.BlockDeploy != nil {
+		p.BlockDeploy = *req.BlockDeploy
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	if err := s.repo.Update(p); err != nil {
+		return nil, err
+	}
+	go s.Reevaluate()
+	return p, nil
+}
+
+func (s *PolicyService) DeletePolicy(id string) error {
+	ok, err := s.repo.Delete(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return appErrors.ErrVulnPolicyNotFound
+	}
+	return nil
+}
+
+func (s *PolicyService) ListAllowlist(expired bool) ([]model.CVEAllowlistEntry, error) {
+	return s.repo.ListAllowlist(expired)
+}
+
+// CreateAllowlistEntry waives a vulnerability until it expires and evaluates
+// the images again.
+func (s *PolicyService) CreateAllowlistEntry(userID string, req CreateAllowlistReq) (*model.CVEAllowlistEntry, error) {
+	if !req.ExpiresAt.After(time.Now()) {
+		return nil, appErrors.ErrAllowlistInvalid
+	}
+	e := &model.CVEAllowlistEntry{
+		VulnerabilityID: strings.TrimSpace(req.VulnerabilityID),
+		PkgName:         req.PkgName,
+		ImageName:       strings.Trim(req.ImageName, "/"),
+		Justification:   req.Justification,
+		ExpiresAt:       req.ExpiresAt,
+	}
+	if userID != "" {
+		e.CreatedBy = &userID
+	}
+	if err := s.repo.CreateAllowlistEntry(e); err != nil {
+		return nil, err
+	}
+	go s.Reevaluate()
+	return e, nil
+}
+
+// DeleteAllowlistEntry revokes a waiver and evaluates the images again.
+func (s *PolicyService) DeleteAllowlistEntry(id string) error {
+	ok, err := s.repo.DeleteAllowlistEntry(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return appErrors.ErrAllowlistNotFound
+	}
+	go s.Reevaluate()
+	return nil
+}
+
+// ListResults returns the results of a scan against the policies.
+func (s *PolicyService) ListResults(scanID string) ([]model.ImagePolicyResult, error) {
+	if _, err := s.scanRepo.Get(scanID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrImageScanNotFound
+		}
+		return nil, err
+	}
+	return s.repo.ListResults(scanID)
+}
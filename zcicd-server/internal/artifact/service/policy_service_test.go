@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/artifact/model"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	inDays := func(days int) time.Time { return now.AddDate(0, 0, days) }
+	expires := func(days int) *time.Time { t := inDays(days); return &t }
+
+	scan := &model.ImageScan{ID: "scan-1", ImageName: "lib/app"}
+	critical := model.ImageVulnerability{VulnerabilityID: "CVE-2024-1", PkgName: "openssl", InstalledVersion: "3.0.1", FixedVersion: "3.0.2", Severity: "CRITICAL"}
+	unfixed := model.ImageVulnerability{VulnerabilityID: "CVE-2024-2", PkgName: "zlib", InstalledVersion: "1.2", Severity: "CRITICAL"}
+	high := model.ImageVulnerability{VulnerabilityID: "CVE-2024-3", PkgName: "curl", InstalledVersion: "8.0", FixedVersion: "8.1", Severity: "HIGH"}
+
+	tests := []struct {
+		name          string
+		policy        model.VulnerabilityPolicy
+		vulns         []model.ImageVulnerability
+		allowlist     []model.CVEAllowlistEntry
+		wantStatus    string
+		wantViolating []string
+		wantAllowed   []string
+		wantExpires   *time.Time
+	}{
+		{
+			name:          "over the maximum severity",
+			policy:        model.VulnerabilityPolicy{MaxSeverity: "HIGH"},
+			vulns:         []model.ImageVulnerability{critical, high},
+			wantStatus:    "failed",
+			wantViolating: []string{"CVE-2024-1"},
+		},
+		{
+			name:       "at the maximum severity",
+			policy:     model.VulnerabilityPolicy{MaxSeverity: "HIGH"},
+			vulns:      []model.ImageVulnerability{high},
+			wantStatus: "passed",
+		},
+		{
+			name:          "same vulnerability in two targets reported once",
+			policy:        model.VulnerabilityPolicy{MaxSeverity: "MEDIUM"},
+			vulns:         []model.ImageVulnerability{critical, high, critical},
+			wantStatus:    "failed",
+			wantViolating: []string{"CVE-2024-1", "CVE-2024-3"},
+		},
+		{
+			name:          "fixable_only ignores vulnerabilities without a fix",
+			policy:        model.VulnerabilityPolicy{MaxSeverity: "HIGH", FixableOnly: true},
+			vulns:         []model.ImageVulnerability{critical, unfixed},
+			wantStatus:    "failed",
+			wantViolating: []string{"CVE-2024-1"},
+		},
+		{
+			name:          "without fixable_only unfixed vulnerabilities violate",
+			policy:        model.VulnerabilityPolicy{MaxSeverity: "HIGH"},
+			vulns:         []model.ImageVulnerability{unfixed},
+			wantStatus:    "failed",
+			wantViolating: []string{"CVE-2024-2"},
+		},
+		{
+			name:   "allowlisted until the earliest expiry",
+			policy: model.VulnerabilityPolicy{MaxSeverity: "HIGH"},
+			vulns:  []model.ImageVulnerability{critical, unfixed},
+			allowlist: []model.CVEAllowlistEntry{
+				{ID: "a1", VulnerabilityID: "cve-2024-1", ExpiresAt: inDays(30)},
+				{ID: "a2", VulnerabilityID: "CVE-2024-2", PkgName: "zlib", ImageName: "/lib/app/", ExpiresAt: inDays(7)},
+			},
+			wantStatus:  "passed",
+			wantAllowed: []string{"CVE-2024-1", "CVE-2024-2"},
+			wantExpires: expires(7),
+		},
+		{
+			name:   "expired allowlist entry no longer waives",
+			policy: model.VulnerabilityPolicy{MaxSeverity: "HIGH"},
+			vulns:  []model.ImageVulnerability{critical, unfixed},
+			allowlist: []model.CVEAllowlistEntry{
+				{ID: "a1", VulnerabilityID: "CVE-2024-1", ExpiresAt: now},
+				{ID: "a2", VulnerabilityID: "CVE-2024-2", ExpiresAt: inDays(1)},
+			},
+			wantStatus:    "failed",
+			wantViolating: []string{"CVE-2024-1"},
+			wantAllowed:   []string{"CVE-2024-2"},
+			wantExpires:   expires(1),
+		},
+		{
+			name:   "allowlist entry of another package or image",
+			policy: model.VulnerabilityPolicy{MaxSeverity: "HIGH"},
+			vulns:  []model.ImageVulnerability{critical},
+			allowlist: []model.CVEAllowlistEntry{
+				{ID: "a1", VulnerabilityID: "CVE-2024-1", PkgName: "libssl", ExpiresAt: inDays(30)},
+				{ID: "a2", VulnerabilityID: "CVE-2024-1", ImageName: "lib/web", ExpiresAt: inDays(30)},
+			},
+			wantStatus:    "failed",
+			wantViolating: []string{"CVE-2024-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := evaluatePolicy(&tt.policy, scan, tt.vulns, tt.allowlist, now)
+			if res.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", res.Status, tt.wantStatus)
+			}
+			if got := violationIDs(t, res.Violations); !reflect.DeepEqual(got, tt.wantViolating) {
+				t.Errorf("Violations = %v, want %v", got, tt.wantViolating)
+			}
+			if got := violationIDs(t, res.Allowed); !reflect.DeepEqual(got, tt.wantAllowed) {
+				t.Errorf("Allowed = %v, want %v", got, tt.wantAllowed)
+			}
+			switch {
+			case res.AllowlistExpiresAt == nil && tt.wantExpires == nil:
+			case res.AllowlistExpiresAt == nil || tt.wantExpires == nil || !res.AllowlistExpiresAt.Equal(*tt.wantExpires):
+				t.Errorf("AllowlistExpiresAt = %v, want %v", res.AllowlistExpiresAt, tt.wantExpires)
+			}
+		})
+	}
+}
+
+// violationIDs returns the vulnerability IDs of a JSON list of violations.
+func violationIDs(t *testing.T, data []byte) []string {
+	t.Helper()
+	var list []model.PolicyViolation
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("invalid violations %s: %v", data, err)
+	}
+	var ids []string
+	for _, v := range list {
+		ids = append(ids, v.VulnerabilityID)
+	}
+	return ids
+}
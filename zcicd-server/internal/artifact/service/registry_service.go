@@ -1,16 +1,29 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/artifact/engine"
 	"github.com/zcicd/zcicd-server/internal/artifact/model"
 	"github.com/zcicd/zcicd-server/internal/artifact/repository"
+	"github.com/zcicd/zcicd-server/pkg/crypto"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/gorm"
 )
 
 type RegistryService struct {
-	repo *repository.RegistryRepository
+	repo      *repository.RegistryRepository
+	encryptor *crypto.Encryptor
 }
 
-func NewRegistryService(repo *repository.RegistryRepository) *RegistryService {
-	return &RegistryService{repo: repo}
+func NewRegistryService(repo *repository.RegistryRepository, encryptor *crypto.Encryptor) *RegistryService {
+	return &RegistryService{repo: repo, encryptor: encryptor}
 }
 
 func (s *RegistryService) Create(req CreateRegistryReq) (*model.ImageRegistry, error) {
@@ -24,6 +37,13 @@ func (s *RegistryService) Create(req CreateRegistryReq) (*model.ImageRegistry, e
 	if reg.RegistryType == "" {
 		reg.RegistryType = "harbor"
 	}
+	if req.Password != "" {
+		enc, err := s.encryptor.Encrypt([]byte(req.Password))
+		if err != nil {
+			return nil, err
+		}
+		reg.PasswordEnc = enc
+	}
 	return reg, s.repo.Create(reg)
 }
 
@@ -39,12 +59,22 @@ func (s *RegistryService) Update(id string, req UpdateRegistryReq) (*model.Image
 	if req.Name != "" {
 		reg.Name = req.Name
 	}
+	if req.RegistryType != "" {
+		reg.RegistryType = req.RegistryType
+	}
 	if req.Endpoint != "" {
 		reg.Endpoint = req.Endpoint
 	}
 	if req.Username != "" {
 		reg.Username = req.Username
 	}
+	if req.Password != "" {
+		enc, err := s.encryptor.Encrypt([]byte(req.Password))
+		if err != nil {
+			return nil, err
+		}
+		reg.PasswordEnc = enc
+	}
 	if req.IsDefault != nil {
 		reg.IsDefault = *req.IsDefault
 	}
@@ -58,3 +88,317 @@ func (s *RegistryService) Delete(id string) error {
 func (s *RegistryService) List() ([]model.ImageRegistry, error) {
 	return s.repo.List()
 }
+
+func (s *RegistryService) getRegistry(id string) (*model.ImageRegistry, error) {
+	reg, err := s.repo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrRegistryNotFound
+	}
+	return reg, err
+}
+
+// client returns the API client of a registry's type with its decrypted password.
+func (s *RegistryService) client(reg *model.ImageRegistry) (engine.RegistryClient, error) {
+	cfg := engine.RegistryConfig{Type: reg.RegistryType, Endpoint: reg.Endpoint, Username: reg.Username}
+	if len(reg.PasswordEnc) > 0 {
+		password, err := s.encryptor.Decrypt(reg.PasswordEnc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password of registry %s: %w", reg.Name, err)
+		}
+		cfg.Password = string(password)
+	}
+	return engine.NewRegistryClient(cfg, nil)
+}
+
+// harbor returns the Harbor client of a registry, ErrRegistryUnsupported
+// for other types.
+func (s *RegistryService) harbor(id string) (*engine.HarborClient, error) {
+	reg, err := s.getRegistry(id)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.client(reg)
+	if err != nil {
+		return nil, err
+	}
+	harbor, ok := client.(*engine.HarborClient)
+	if !ok {
+		return nil, appErrors.ErrRegistryUnsupported
+	}
+	return harbor, nil
+}
+
+// TestConnection checks that a registry answers and accepts its
+// credentials. A failure is reported in the result rather than as an error.
+func (s *RegistryService) TestConnection(ctx context.Context, id string) (*RegistryTestResult, error) {
+	reg, err := s.getRegistry(id)
+	if err != nil {
+		return nil, err
+	}
+	result := &RegistryTestResult{}
+	client, err := s.client(reg)
+	if err != nil {
+		result.Message = err.Error()
+		return result, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	start := time.Now()
+	err = client.Ping(ctx)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	switch {
+	case err == nil:
+		result.Connected = true
+		result.Message = "连接成功"
+	case engine.IsRegistryUnauthorized(err):
+		result.Message = "认证失败: " + err.Error()
+	default:
+		result.Message = err.Error()
+	}
+	return result, nil
+}
+
+// ListHarborProjects lists the projects of a Harbor registry.
+func (s *RegistryService) ListHarborProjects(ctx context.Context, id string) ([]engine.HarborProject, error) {
+	harbor, err := s.harbor(id)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := harbor.ListProjects(ctx)
+	if err != nil {
+		return nil, registryRequestError(err)
+	}
+	return projects, nil
+}
+
+// HarborScanOverview returns the result of Harbor's built-in scan of an
+// image by tag or digest.
+func (s *RegistryService) HarborScanOverview(ctx context.Context, id, repository, reference string) (*engine.HarborScanOverview, error) {
+	harbor, err := s.harbor(id)
+	if err != nil {
+		return nil, err
+	}
+	overview, err := harbor.ScanOverview(ctx, repository, reference)
+	if err != nil {
+		return nil, registryRequestError(err)
+	}
+	return overview, nil
+}
+
+// tagMetadataConcurrency is how many tags of a page have their manifests
+// read at once.
+const tagMetadataConcurrency = 5
+
+// ListRepositories returns a page of the repositories of a registry whose
+// names contain keyword.
+func (s *RegistryService) ListRepositories(ctx context.Context, id, keyword string, page, pageSize int) ([]string, int64, error) {
+	reg, err := s.getRegistry(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	client, err := s.client(reg)
+	if err != nil {
+		return nil, 0, err
+	}
+	repos, err := client.ListRepositories(ctx)
+	if err != nil {
+		return nil, 0, registryRequestError(err)
+	}
+	repos = filterNames(repos, keyword)
+	sort.Strings(repos)
+	return paginate(repos, page, pageSize), int64(len(repos)), nil
+}
+
+// ListTags returns a page of the tags of a repository whose names contain
+// keyword, the latest pushed first when the registry records push times.
+// Each tag comes with its image metadata, the build that pushed it and the
+// environments it is currently deployed to.
+func (s *RegistryService) ListTags(ctx context.Context, id, repository, keyword string, page, pageSize int) ([]ImageTag, int64, error) {
+	reg, err := s.getRegistry(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	client, err := s.client(reg)
+	if err != nil {
+		return nil, 0, err
+	}
+	names, err := client.ListTags(ctx, repository)
+	if engine.IsRegistryNotFound(err) {
+		return nil, 0, appErrors.ErrImageRepoNotFound
+	}
+	if err != nil {
+		return nil, 0, registryRequestError(err)
+	}
+	names = filterNames(names, keyword)
+
+	var pushed map[string]time.Time
+	if lister, ok := client.(engine.PushTimeLister); ok {
+		if pushed, err = lister.PushTimes(ctx, repository); err != nil {
+			fmt.Printf("warning: failed to list push times of %s in registry %s: %v\n", repository, reg.Name, err)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := pushed[names[i]], pushed[names[j]]
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return names[i] < names[j]
+	})
+	total := int64(len(names))
+	names = paginate(names, page, pageSize)
+
+	tags := make([]ImageTag, len(names))
+	sem := make(chan struct{}, tagMetadataConcurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		tags[i] = ImageTag{Name: name, Labels: map[string]string{}, Architectures: []string{}, Deployments: []model.ImageDeployment{}}
+		if t, ok := pushed[name]; ok {
+			tags[i].PushedAt = &t
+		}
+		wg.Add(1)
+		go func(tag *ImageTag) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			readTagMetadata(ctx, client, repository, tag)
+		}(&tags[i])
+	}
+	wg.Wait()
+
+	host, _ := registryHost(reg.Endpoint)
+	if err := s.linkTags(host+"/"+repository, tags); err != nil {
+		return nil, 0, err
+	}
+	return tags, total, nil
+}
+
+// readTagMetadata fills in a tag's digest, size, platforms, creation time
+// and labels from its manifest and image config. A multi-platform tag takes
+// them from its linux/amd64 image, or its first one. Failures are recorded
+// in the tag.
+func readTagMetadata(ctx context.Context, client engine.RegistryClient, repository string, tag *ImageTag) {
+	manifest, err := client.GetManifest(ctx, repository, tag.Name)
+	if err != nil {
+		tag.Error = err.Error()
+		return
+	}
+	tag.Digest, tag.MediaType = manifest.Digest, manifest.MediaType
+	tag.digests = []string{manifest.Digest}
+	image := manifest
+	if manifest.IsIndex() {
+		var chosen *engine.Descriptor
+		for i := range manifest.Manifests {
+			d := &manifest.Manifests[i]
+			// Attestations are listed under the unknown platform
+			if d.Platform == nil || d.Platform.OS == "unknown" {
+				continue
+			}
+			tag.Architectures = append(tag.Architectures, d.Platform.String())
+			tag.digests = append(tag.digests, d.Digest)
+			if chosen == nil || (d.Platform.OS == "linux" && d.Platform.Architecture == "amd64" &&
+				!(chosen.Platform.OS == "linux" && chosen.Platform.Architecture == "amd64")) {
+				chosen = d
+			}
+		}
+		if chosen == nil {
+			return
+		}
+		if image, err = client.GetManifest(ctx, repository, chosen.Digest); err != nil {
+			tag.Error = err.Error()
+			return
+		}
+	}
+	tag.Size = image.ImageSize()
+	if image.Config == nil {
+		return
+	}
+	cfg, err := client.GetImageConfig(ctx, repository, image.Config.Digest)
+	if err != nil {
+		tag.Error = err.Error()
+		return
+	}
+	tag.CreatedAt = cfg.Created
+	if cfg.Config.Labels != nil {
+		tag.Labels = cfg.Config.Labels
+	}
+	if !manifest.IsIndex() && cfg.Architecture != "" {
+		tag.Architectures = []string{cfg.Platform().String()}
+	}
+}
+
+// linkTags sets the latest build that pushed each tag, by digest or else by
+// tag, and the environments whose latest deploy runs it.
+func (s *RegistryService) linkTags(imageRepo string, tags []ImageTag) error {
+	var names, digests []string
+	for _, t := range tags {
+		names = append(names, t.Name)
+		digests = append(digests, t.digests...)
+	}
+	builds, err := s.repo.ListImageBuilds(imageRepo, names, digests)
+	if err != nil {
+		return err
+	}
+	deployments, err := s.repo.ListImageDeployments(imageRepo)
+	if err != nil {
+		return err
+	}
+	for i := range tags {
+		t := &tags[i]
+		for j := range builds {
+			if b := &builds[j]; b.ImageDigest != "" && containsString(t.digests, b.ImageDigest) {
+				t.Build = b
+				break
+			}
+		}
+		if t.Build == nil {
+			for j := range builds {
+				if b := &builds[j]; b.ImageTag == t.Name {
+					t.Build = b
+					break
+				}
+			}
+		}
+		for _, d := range deployments {
+			if d.ImageDigest != "" && containsString(t.digests, d.ImageDigest) ||
+				d.ImageDigest == "" && d.ImageTag == t.Name {
+				t.Deployments = append(t.Deployments, d)
+			}
+		}
+	}
+	return nil
+}
+
+func filterNames(names []string, keyword string) []string {
+	if keyword == "" {
+		return names
+	}
+	var out []string
+	for _, n := range names {
+		if strings.Contains(n, keyword) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func paginate(names []string, page, pageSize int) []string {
+	start := (page - 1) * pageSize
+	if start >= len(names) {
+		return []string{}
+	}
+	return names[start:min(start+pageSize, len(names))]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// registryRequestError reports a failed registry request as ErrRegistryRequest.
+func registryRequestError(err error) error {
+	return appErrors.New(appErrors.ErrRegistryRequest.Code, appErrors.ErrRegistryRequest.Message+": "+err.Error())
+}
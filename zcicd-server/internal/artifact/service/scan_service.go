@@ -1,32 +1,322 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/artifact/engine"
 	"github.com/zcicd/zcicd-server/internal/artifact/model"
 	"github.com/zcicd/zcicd-server/internal/artifact/repository"
+	"github.com/zcicd/zcicd-server/pkg/crypto"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/storage"
+	"gorm.io/gorm"
 )
 
+// scanTimeout bounds a scan, including a scan Job waiting to be scheduled.
+const scanTimeout = 30 * time.Minute
+
 type ScanService struct {
-	repo *repository.ScanRepository
+	repo       *repository.ScanRepository
+	regRepo    *repository.RegistryRepository
+	policySvc  *PolicyService
+	scanner    engine.Scanner
+	encryptor  *crypto.Encryptor
+	store      *storage.Client
+	bucket     string
+	rescanning atomic.Bool
 }
 
-func NewScanService(repo *repository.ScanRepository) *ScanService {
-	return &ScanService{repo: repo}
+func NewScanService(repo *repository.ScanRepository, regRepo *repository.RegistryRepository, policySvc *PolicyService, scanner engine.Scanner, encryptor *crypto.Encryptor, store *storage.Client, bucket string) *ScanService {
+	return &ScanService{repo: repo, regRepo: regRepo, policySvc: policySvc, scanner: scanner, encryptor: encryptor, store: store, bucket: bucket}
 }
 
+// TriggerScan starts a Trivy scan of an image of a registry. The scan runs in
+// the background; its status is "scanning" until it completes or fails.
 func (s *ScanService) TriggerScan(imageName string, req TriggerScanReq) (*model.ImageScan, error) {
+	if s.scanner == nil {
+		return nil, appErrors.ErrImageScanUnavailable
+	}
+	reg, err := s.regRepo.Get(req.RegistryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrRegistryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.target(reg, imageName, req.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	scan := &model.ImageScan{
 		RegistryID: req.RegistryID,
 		ImageName:  imageName,
 		Tag:        req.Tag,
 		Status:     "scanning",
+		StartedAt:  &now,
+	}
+	if err := s.repo.Create(scan); err != nil {
+		return nil, err
+	}
+	go s.run(scan, target)
+	return scan, nil
+}
+
+// target returns the reference of an image in a registry with the registry's
+// decrypted credentials. A tag of the form sha256:... is a digest.
+func (s *ScanService) target(reg *model.ImageRegistry, imageName, tag string) (engine.TrivyTarget, error) {
+	host, insecure := registryHost(reg.Endpoint)
+	ref := strings.Trim(imageName, "/")
+	if host != "" {
+		ref = host + "/" + ref
+	}
+	if strings.HasPrefix(tag, "sha256:") {
+		ref += "@" + tag
+	} else {
+		ref += ":" + tag
+	}
+	target := engine.TrivyTarget{Image: ref, Username: reg.Username, Insecure: insecure}
+	if len(reg.PasswordEnc) > 0 {
+		password, err := s.encryptor.Decrypt(reg.PasswordEnc)
+		if err != nil {
+			return target, fmt.Errorf("failed to decrypt password of registry %s: %w", reg.Name, err)
+		}
+		target.Password = string(password)
+	}
+	return target, nil
+}
+
+// registryHost returns the host of a registry endpoint, and whether it is
+// served over plain HTTP.
+func registryHost(endpoint string) (string, bool) {
+	endpoint = strings.TrimSpace(endpoint)
+	if !strings.Contains(endpoint, "://") {
+		return strings.TrimRight(endpoint, "/"), false
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return strings.TrimRight(endpoint, "/"), false
+	}
+	return u.Host + strings.TrimRight(u.Path, "/"), u.Scheme == "http"
+}
+
+// run scans the image of a scan, records the vulnerabilities it found and
+// evaluates it against the vulnerability policies.
+func (s *ScanService) run(scan *model.ImageScan, target engine.TrivyTarget) {
+	ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+	defer cancel()
+	if err := s.scan(ctx, scan, target); err != nil {
+		scan.Status = "failed"
+		scan.ErrorMessage = err.Error()
+	} else {
+		scan.Status = "completed"
+	}
+	now := time.Now()
+	scan.ScannedAt = &now
+	if err := s.repo.Update(scan); err != nil {
+		fmt.Printf("warning: failed to update image scan %s: %v\n", scan.ID, err)
+		return
+	}
+	if s.policySvc != nil {
+		if err := s.policySvc.EvaluateScan(scan); err != nil {
+			fmt.Printf("warning: failed to evaluate image scan %s: %v\n", scan.ID, err)
+		}
+	}
+}
+
+func (s *ScanService) scan(ctx context.Context, scan *model.ImageScan, target engine.TrivyTarget) error {
+	data, err := s.scanner.Scan(ctx, "image-scan-"+scan.ID, target)
+	if err != nil {
+		return err
+	}
+	report, err := engine.ParseTrivyReport(data)
+	if err != nil {
+		return err
+	}
+	scan.Digest = report.Digest
+
+	vulns := make([]model.ImageVulnerability, 0, len(report.Vulnerabilities))
+	for _, v := range report.Vulnerabilities {
+		switch v.Severity {
+		case "CRITICAL":
+			scan.Critical++
+		case "HIGH":
+			scan.High++
+		case "MEDIUM":
+			scan.Medium++
+		case "LOW":
+			scan.Low++
+		default:
+			scan.Unknown++
+		}
+		vulns = append(vulns, model.ImageVulnerability{
+			ScanID:           scan.ID,
+			VulnerabilityID:  v.VulnerabilityID,
+			PkgName:          v.PkgName,
+			PkgType:          v.PkgType,
+			Target:           truncate(v.Target, 512),
+			InstalledVersion: truncate(v.InstalledVersion, 128),
+			FixedVersion:     truncate(v.FixedVersion, 256),
+			Severity:         v.Severity,
+			Title:            v.Title,
+			PrimaryURL:       truncate(v.PrimaryURL, 512),
+		})
+	}
+	if err := s.repo.CreateVulnerabilities(vulns); err != nil {
+		return fmt.Errorf("failed to store vulnerabilities: %w", err)
+	}
+
+	if s.store != nil {
+		object := fmt.Sprintf("image-scans/%s/%s.json", scan.RegistryID, scan.ID)
+		err := s.store.UploadFile(ctx, s.bucket, object, bytes.NewReader(data), int64(len(data)), "application/json")
+		if err != nil {
+			fmt.Printf("warning: failed to upload report of image scan %s: %v\n", scan.ID, err)
+		} else {
+			scan.ReportURL = object
+		}
+	}
+	return nil
+}
+
+// Rescan scans the latest completed scan of every image again, by digest when
+// known, so that vulnerabilities published since are found, e.g. after the
+// Trivy database was updated. The scans run one after another in the
+// background; it returns how many are queued.
+func (s *ScanService) Rescan() (int, error) {
+	if s.scanner == nil {
+		return 0, appErrors.ErrImageScanUnavailable
+	}
+	if !s.rescanning.CompareAndSwap(false, true) {
+		return 0, appErrors.ErrImageRescanRunning
+	}
+	scans, err := s.repo.ListLatestCompleted()
+	if err != nil {
+		s.rescanning.Store(false)
+		return 0, err
+	}
+	go func() {
+		defer s.rescanning.Store(false)
+		for i := range scans {
+			if err := s.rescan(&scans[i]); err != nil {
+				fmt.Printf("warning: failed to rescan %s:%s: %v\n", scans[i].ImageName, scans[i].Tag, err)
+			}
+		}
+	}()
+	return len(scans), nil
+}
+
+func (s *ScanService) rescan(prev *model.ImageScan) error {
+	reg, err := s.regRepo.Get(prev.RegistryID)
+	if err != nil {
+		return err
+	}
+	ref := prev.Tag
+	if prev.Digest != "" {
+		ref = prev.Digest
+	}
+	target, err := s.target(reg, prev.ImageName, ref)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	scan := &model.ImageScan{
+		RegistryID: prev.RegistryID,
+		ImageName:  prev.ImageName,
+		Tag:        prev.Tag,
+		Status:     "scanning",
+		StartedAt:  &now,
+	}
+	if err := s.repo.Create(scan); err != nil {
+		return err
+	}
+	s.run(scan, target)
+	return nil
+}
+
+// StartRescanner periodically rescans every image, picking up the updates of
+// the vulnerability database Trivy downloads on each scan.
+func (s *ScanService) StartRescanner(ctx context.Context, interval time.Duration) {
+	if s.scanner == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Rescan(); err != nil {
+					fmt.Printf("warning: failed to rescan images: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// FailInterrupted fails the scans left scanning by a restart; their scanner
+// is not waited for again.
+func (s *ScanService) FailInterrupted() {
+	scans, err := s.repo.ListByStatus("scanning")
+	if err != nil {
+		fmt.Printf("warning: failed to list image scans: %v\n", err)
+		return
+	}
+	for i := range scans {
+		scan := &scans[i]
+		now := time.Now()
+		scan.Status = "failed"
+		scan.ErrorMessage = "扫描被服务重启中断，请重新扫描"
+		scan.ScannedAt = &now
+		if err := s.repo.Update(scan); err != nil {
+			fmt.Printf("warning: failed to update image scan %s: %v\n", scan.ID, err)
+		}
 	}
-	return scan, s.repo.Create(scan)
 }
 
 func (s *ScanService) GetScan(id string) (*model.ImageScan, error) {
-	return s.repo.Get(id)
+	scan, err := s.repo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrImageScanNotFound
+	}
+	return scan, err
 }
 
 func (s *ScanService) ListByImage(registryID, imageName string) ([]model.ImageScan, error) {
 	return s.repo.ListByImage(registryID, imageName)
 }
+
+func (s *ScanService) ListVulnerabilities(scanID string, page, pageSize int, filters map[string]string) ([]model.ImageVulnerability, int64, error) {
+	if _, err := s.GetScan(scanID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListVulnerabilities(scanID, page, pageSize, filters)
+}
+
+// ReportURL returns a presigned URL of the raw Trivy report of a scan.
+func (s *ScanService) ReportURL(ctx context.Context, id string) (string, error) {
+	scan, err := s.GetScan(id)
+	if err != nil {
+		return "", err
+	}
+	if scan.ReportURL == "" || s.store == nil {
+		return "", appErrors.ErrImageScanNoReport
+	}
+	return s.store.GetPresignedURL(ctx, s.bucket, scan.ReportURL, 15*time.Minute)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
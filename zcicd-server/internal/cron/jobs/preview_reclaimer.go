@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// PreviewReclaimer marks pull request previews for teardown once they pass
+// their TTL or lose their template. The deploy service tears down previews
+// marked "closing".
+type PreviewReclaimer struct {
+	db *gorm.DB
+}
+
+func NewPreviewReclaimer(db *gorm.DB) *PreviewReclaimer {
+	return &PreviewReclaimer{db: db}
+}
+
+func (p *PreviewReclaimer) Run() {
+	res := p.db.Exec(`
+		UPDATE deploy_previews SET status = 'closing', close_reason = 'expired', updated_at = NOW()
+		WHERE status NOT IN ('closing', 'closed') AND expires_at < NOW()`)
+	if res.Error != nil {
+		log.Printf("preview reclaimer: expired error: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("preview reclaimer: %d previews expired", res.RowsAffected)
+	}
+
+	// The template config was deleted or is no longer a preview template.
+	res = p.db.Exec(`
+		UPDATE deploy_previews SET status = 'closing', close_reason = 'orphaned', updated_at = NOW()
+		WHERE status NOT IN ('closing', 'closed')
+		  AND NOT EXISTS (
+			SELECT 1 FROM deploy_configs dc
+			WHERE dc.id = deploy_previews.template_config_id AND dc.preview_template)`)
+	if res.Error != nil {
+		log.Printf("preview reclaimer: orphaned error: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("preview reclaimer: %d previews orphaned", res.RowsAffected)
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
 )
 
 var argoAppGVR = schema.GroupVersionResource{
@@ -16,6 +20,13 @@ var argoAppGVR = schema.GroupVersionResource{
 	Resource: "applications",
 }
 
+// fieldManager owns the fields zcicd sets on Applications.
+const fieldManager = "zcicd"
+
+// resourcesFinalizer makes Argo CD delete an Application's resources before
+// the Application itself.
+const resourcesFinalizer = "resources-finalizer.argocd.argoproj.io"
+
 // AppManager manages Argo CD Application CRs via the dynamic client.
 type AppManager struct {
 	client        dynamic.Interface
@@ -32,35 +43,40 @@ func NewAppManager(dynamicClient dynamic.Interface, argoNamespace string) *AppMa
 
 // CreateApp creates an Argo CD Application CR.
 func (m *AppManager) CreateApp(ctx context.Context, app ArgoApp) error {
-	obj := buildApplicationCR(app, m.argoNamespace)
-	_, err := m.client.Resource(argoAppGVR).Namespace(m.argoNamespace).
-		Create(ctx, obj, metav1.CreateOptions{})
-	if err != nil {
+	if err := m.apply(ctx, app); err != nil {
 		return fmt.Errorf("failed to create argo application %s: %w", app.Name, err)
 	}
 	return nil
 }
 
-// UpdateApp updates an existing Argo CD Application CR.
+// UpdateApp updates an Argo CD Application CR, recreating it if it was deleted.
 func (m *AppManager) UpdateApp(ctx context.Context, app ArgoApp) error {
-	obj := buildApplicationCR(app, m.argoNamespace)
-
-	existing, err := m.client.Resource(argoAppGVR).Namespace(m.argoNamespace).
-		Get(ctx, app.Name, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get argo application %s: %w", app.Name, err)
-	}
-	obj.SetResourceVersion(existing.GetResourceVersion())
-
-	_, err = m.client.Resource(argoAppGVR).Namespace(m.argoNamespace).
-		Update(ctx, obj, metav1.UpdateOptions{})
-	if err != nil {
+	if err := m.apply(ctx, app); err != nil {
 		return fmt.Errorf("failed to update argo application %s: %w", app.Name, err)
 	}
 	return nil
 }
 
-// DeleteApp deletes an Argo CD Application CR.
+// apply server-side applies the Application as zcicd's field manager. Only
+// the fields zcicd sets are owned, so fields set by other controllers or by
+// hand elsewhere in the spec survive; zcicd's own fields are authoritative
+// and taken over on conflict. Transient conflicts from concurrent writers
+// are retried.
+func (m *AppManager) apply(ctx context.Context, app ArgoApp) error {
+	obj := buildApplicationCR(app, m.argoNamespace)
+	return retry.OnError(retry.DefaultBackoff, isRetriable, func() error {
+		_, err := m.client.Resource(argoAppGVR).Namespace(m.argoNamespace).
+			Apply(ctx, app.Name, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+		return err
+	})
+}
+
+func isRetriable(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err)
+}
+
+// DeleteApp deletes an Argo CD Application CR. Its finalizer makes Argo CD
+// delete the application's resources first.
 func (m *AppManager) DeleteApp(ctx context.Context, name string) error {
 	err := m.client.Resource(argoAppGVR).Namespace(m.argoNamespace).
 		Delete(ctx, name, metav1.DeleteOptions{})
@@ -120,9 +136,16 @@ func buildApplicationCR(app ArgoApp, argoNamespace string) *unstructured.Unstruc
 		"targetRevision": app.TargetRevision,
 		"path":           app.Path,
 	}
-	if len(app.ValuesOverride) > 0 {
-		source["helm"] = map[string]interface{}{
-			"values": mapToYAMLString(app.ValuesOverride),
+	switch app.SourceType {
+	case "kustomize":
+		if app.Kustomize != nil {
+			source["kustomize"] = kustomizeSource(app.Kustomize)
+		}
+	case "directory":
+		source["directory"] = map[string]interface{}{"recurse": true}
+	default:
+		if helm := helmSource(app); len(helm) > 0 {
+			source["helm"] = helm
 		}
 	}
 
@@ -150,8 +173,9 @@ func buildApplicationCR(app ArgoApp, argoNamespace string) *unstructured.Unstruc
 			"apiVersion": "argoproj.io/v1alpha1",
 			"kind":       "Application",
 			"metadata": map[string]interface{}{
-				"name":      app.Name,
-				"namespace": ns,
+				"name":       app.Name,
+				"namespace":  ns,
+				"finalizers": []interface{}{resourcesFinalizer},
 			},
 			"spec": spec,
 		},
@@ -159,6 +183,101 @@ func buildApplicationCR(app ArgoApp, argoNamespace string) *unstructured.Unstruc
 	return obj
 }
 
+// helmSource renders the spec.source.helm block of an Application. Value
+// files come first and parameters last, matching Argo CD's precedence.
+func helmSource(app ArgoApp) map[string]interface{} {
+	helm := map[string]interface{}{}
+	if len(app.ValueFiles) > 0 {
+		files := make([]interface{}, len(app.ValueFiles))
+		for i, f := range app.ValueFiles {
+			files[i] = f
+		}
+		helm["valueFiles"] = files
+	}
+	if len(app.ValuesObject) > 0 {
+		helm["valuesObject"] = app.ValuesObject
+	} else if len(app.ValuesOverride) > 0 {
+		values, err := ValuesYAML(app.ValuesOverride)
+		if err != nil {
+			fmt.Printf("warning: invalid helm values of %s: %v\n", app.Name, err)
+		} else {
+			helm["values"] = values
+		}
+	}
+	if len(app.Parameters) > 0 {
+		params := make([]interface{}, len(app.Parameters))
+		for i, p := range app.Parameters {
+			param := map[string]interface{}{"name": p.Name, "value": p.Value}
+			if p.ForceString {
+				param["forceString"] = true
+			}
+			params[i] = param
+		}
+		helm["parameters"] = params
+	}
+	return helm
+}
+
+// kustomizeSource renders the spec.source.kustomize block of an Application.
+func kustomizeSource(k *KustomizeOptions) map[string]interface{} {
+	out := map[string]interface{}{}
+	if k.NamePrefix != "" {
+		out["namePrefix"] = k.NamePrefix
+	}
+	if k.NameSuffix != "" {
+		out["nameSuffix"] = k.NameSuffix
+	}
+	if len(k.Images) > 0 {
+		images := make([]interface{}, len(k.Images))
+		for i, img := range k.Images {
+			images[i] = img
+		}
+		out["images"] = images
+	}
+	if len(k.CommonLabels) > 0 {
+		labels := map[string]interface{}{}
+		for key, v := range k.CommonLabels {
+			labels[key] = v
+		}
+		out["commonLabels"] = labels
+	}
+	if len(k.Patches) > 0 {
+		patches := make([]interface{}, 0, len(k.Patches))
+		for _, p := range k.Patches {
+			patch := map[string]interface{}{"patch": p.Patch}
+			if t := p.Target; t != nil {
+				target := map[string]interface{}{}
+				for key, v := range map[string]string{
+					"group": t.Group, "version": t.Version, "kind": t.Kind,
+					"name": t.Name, "namespace": t.Namespace, "labelSelector": t.LabelSelector,
+				} {
+					if v != "" {
+						target[key] = v
+					}
+				}
+				patch["target"] = target
+			}
+			patches = append(patches, patch)
+		}
+		out["patches"] = patches
+	}
+	return out
+}
+
+// ArgoCDInstalled reports whether the cluster serves the Argo CD Application API.
+func ArgoCDInstalled(client *k8s.K8sClient) bool {
+	list, err := client.Clientset.Discovery().ServerResourcesForGroupVersion(argoAppGVR.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	for _, r := range list.APIResources {
+		if r.Name == argoAppGVR.Resource {
+			return true
+		}
+	}
+	return false
+}
+
 // parseAppStatus extracts AppStatus from an unstructured Application.
 func parseAppStatus(obj *unstructured.Unstructured) *AppStatus {
 	status := &AppStatus{}
@@ -190,6 +309,7 @@ func parseResourceNode(rm map[string]interface{}) ResourceNode {
 	}
 	return ResourceNode{
 		Group:     getString("group"),
+		Version:   getString("version"),
 		Kind:      getString("kind"),
 		Namespace: getString("namespace"),
 		Name:      getString("name"),
@@ -198,11 +318,12 @@ func parseResourceNode(rm map[string]interface{}) ResourceNode {
 	}
 }
 
-// mapToYAMLString converts a map to a simple YAML-like key: value string.
-func mapToYAMLString(m map[string]interface{}) string {
-	result := ""
-	for k, v := range m {
-		result += fmt.Sprintf("%s: %v\n", k, v)
+// ValuesYAML renders Helm values as YAML. Keys are sorted, so the same values
+// always render the same and Argo CD sees no spurious diffs.
+func ValuesYAML(values map[string]interface{}) (string, error) {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return "", err
 	}
-	return result
+	return string(data), nil
 }
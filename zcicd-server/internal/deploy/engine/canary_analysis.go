@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/pkg/strategy"
+)
+
+// Default queries assume request metrics carry the rollouts-pod-template-hash
+// label that Argo Rollouts puts on every pod.
+const (
+	defaultErrorRateQuery = `sum(rate(http_requests_total{namespace="{{namespace}}",rollouts_pod_template_hash="{{pod_hash}}",code=~"5.."}[{{interval}}]))` +
+		` / sum(rate(http_requests_total{namespace="{{namespace}}",rollouts_pod_template_hash="{{pod_hash}}"}[{{interval}}]))`
+	defaultLatencyQuery = `histogram_quantile(0.99, sum(rate(http_request_duration_seconds_bucket{namespace="{{namespace}}",rollouts_pod_template_hash="{{pod_hash}}"}[{{interval}}])) by (le)) * 1000`
+)
+
+// CanaryAnalyzer measures canary and stable revisions and judges the canary.
+type CanaryAnalyzer struct {
+	prom *PrometheusClient
+}
+
+func NewCanaryAnalyzer(prom *PrometheusClient) *CanaryAnalyzer {
+	return &CanaryAnalyzer{prom: prom}
+}
+
+// Measure queries error rate and p99 latency of the pods with podHash.
+func (a *CanaryAnalyzer) Measure(ctx context.Context, spec strategy.AnalysisSpec, namespace, podHash string) (CanaryMetrics, error) {
+	var m CanaryMetrics
+	if podHash == "" {
+		return m, nil
+	}
+	r := strings.NewReplacer("{{namespace}}", namespace, "{{pod_hash}}", podHash, "{{interval}}", analysisInterval(spec))
+
+	errQuery := spec.ErrorRateQuery
+	if errQuery == "" {
+		errQuery = defaultErrorRateQuery
+	}
+	v, err := a.prom.Query(ctx, r.Replace(errQuery))
+	if err != nil {
+		return m, fmt.Errorf("error rate: %w", err)
+	}
+	m.ErrorRate = v
+
+	latQuery := spec.LatencyQuery
+	if latQuery == "" {
+		latQuery = defaultLatencyQuery
+	}
+	v, err = a.prom.Query(ctx, r.Replace(latQuery))
+	if err != nil {
+		return m, fmt.Errorf("latency: %w", err)
+	}
+	m.LatencyMs = v
+	return m, nil
+}
+
+// Analyze measures both revisions and evaluates the canary.
+func (a *CanaryAnalyzer) Analyze(ctx context.Context, spec strategy.AnalysisSpec, namespace, canaryHash, stableHash string) (canary, stable CanaryMetrics, verdict AnalysisVerdict, err error) {
+	if canary, err = a.Measure(ctx, spec, namespace, canaryHash); err != nil {
+		return
+	}
+	if stableHash != canaryHash {
+		if stable, err = a.Measure(ctx, spec, namespace, stableHash); err != nil {
+			return
+		}
+	}
+	verdict = EvaluateCanary(spec, canary, stable)
+	return
+}
+
+// EvaluateCanary compares canary metrics against the absolute thresholds and,
+// where stable data exists, against the stable revision. Missing canary data is
+// inconclusive rather than a pass.
+func EvaluateCanary(spec strategy.AnalysisSpec, canary, stable CanaryMetrics) AnalysisVerdict {
+	var reasons []string
+	checked := false
+
+	if canary.ErrorRate != nil {
+		cur := *canary.ErrorRate
+		if spec.MaxErrorRate > 0 {
+			checked = true
+			if cur > spec.MaxErrorRate {
+				reasons = append(reasons, fmt.Sprintf("error rate %.4f exceeds %.4f", cur, spec.MaxErrorRate))
+			}
+		}
+		if spec.MaxErrorRateIncrease > 0 && stable.ErrorRate != nil {
+			checked = true
+			if cur-*stable.ErrorRate > spec.MaxErrorRateIncrease {
+				reasons = append(reasons, fmt.Sprintf("error rate %.4f is more than %.4f above stable %.4f",
+					cur, spec.MaxErrorRateIncrease, *stable.ErrorRate))
+			}
+		}
+	}
+	if canary.LatencyMs != nil {
+		cur := *canary.LatencyMs
+		if spec.MaxLatencyMs > 0 {
+			checked = true
+			if cur > spec.MaxLatencyMs {
+				reasons = append(reasons, fmt.Sprintf("p99 latency %.0fms exceeds %.0fms", cur, spec.MaxLatencyMs))
+			}
+		}
+		if spec.MaxLatencyIncreasePct > 0 && stable.LatencyMs != nil && *stable.LatencyMs > 0 {
+			checked = true
+			pct := (cur - *stable.LatencyMs) / *stable.LatencyMs * 100
+			if pct > spec.MaxLatencyIncreasePct {
+				reasons = append(reasons, fmt.Sprintf("p99 latency %.0fms is %.0f%% above stable %.0fms",
+					cur, pct, *stable.LatencyMs))
+			}
+		}
+	}
+
+	switch {
+	case len(reasons) > 0:
+		return AnalysisVerdict{Verdict: "fail", Reasons: reasons}
+	case !checked:
+		return AnalysisVerdict{Verdict: "inconclusive", Reasons: []string{"no canary metrics to compare"}}
+	default:
+		return AnalysisVerdict{Verdict: "pass", Reasons: []string{}}
+	}
+}
+
+func analysisInterval(spec strategy.AnalysisSpec) string {
+	if spec.Interval == "" {
+		return "1m"
+	}
+	return spec.Interval
+}
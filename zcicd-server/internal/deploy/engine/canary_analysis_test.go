@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zcicd/zcicd-server/pkg/strategy"
+)
+
+// fakePrometheus answers instant queries with canned samples keyed by the pod
+// hash and metric of the query. A missing sample is an empty vector.
+type fakePrometheus struct {
+	errorRate map[string]string // pod hash -> value
+	latency   map[string]string
+	queries   []string
+}
+
+func (f *fakePrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/query" || r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"status":"error","errorType":"unauthorized","error":"bad token"}`)
+		return
+	}
+	query := r.URL.Query().Get("query")
+	f.queries = append(f.queries, query)
+	samples := f.errorRate
+	if strings.Contains(query, "histogram_quantile") {
+		samples = f.latency
+	}
+	for hash, v := range samples {
+		if strings.Contains(query, `rollouts_pod_template_hash="`+hash+`"`) {
+			fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,%q]}]}}`, v)
+			return
+		}
+	}
+	fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+}
+
+func TestCanaryAnalyzerAnalyze(t *testing.T) {
+	spec := strategy.AnalysisSpec{
+		Interval:              "2m",
+		MaxErrorRate:          0.05,
+		MaxErrorRateIncrease:  0.01,
+		MaxLatencyMs:          500,
+		MaxLatencyIncreasePct: 20,
+	}
+	tests := []struct {
+		name      string
+		errorRate map[string]string
+		latency   map[string]string
+		verdict   string
+		reason    string
+	}{
+		{
+			name:      "healthy canary passes",
+			errorRate: map[string]string{"canary": "0.002", "stable": "0.001"},
+			latency:   map[string]string{"canary": "210", "stable": "200"},
+			verdict:   "pass",
+		},
+		{
+			name:      "error rate over threshold fails",
+			errorRate: map[string]string{"canary": "0.08", "stable": "0.001"},
+			latency:   map[string]string{"canary": "200", "stable": "200"},
+			verdict:   "fail",
+			reason:    "exceeds 0.0500",
+		},
+		{
+			name:      "error rate increase over stable fails",
+			errorRate: map[string]string{"canary": "0.03", "stable": "0.001"},
+			latency:   map[string]string{"canary": "200", "stable": "200"},
+			verdict:   "fail",
+			reason:    "above stable",
+		},
+		{
+			name:      "latency regression fails",
+			errorRate: map[string]string{"canary": "0.001", "stable": "0.001"},
+			latency:   map[string]string{"canary": "300", "stable": "200"},
+			verdict:   "fail",
+			reason:    "50% above stable",
+		},
+		{
+			name:      "no canary traffic is inconclusive",
+			errorRate: map[string]string{"canary": "NaN", "stable": "0.001"},
+			latency:   map[string]string{"stable": "200"},
+			verdict:   "inconclusive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prom := &fakePrometheus{errorRate: tt.errorRate, latency: tt.latency}
+			srv := httptest.NewServer(prom)
+			defer srv.Close()
+
+			analyzer := NewCanaryAnalyzer(NewPrometheusClient(srv.URL+"/", "secret"))
+			_, _, verdict, err := analyzer.Analyze(context.Background(), spec, "shop", "canary", "stable")
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if verdict.Verdict != tt.verdict {
+				t.Fatalf("verdict = %s %v, want %s", verdict.Verdict, verdict.Reasons, tt.verdict)
+			}
+			if tt.reason != "" && !strings.Contains(strings.Join(verdict.Reasons, "; "), tt.reason) {
+				t.Errorf("reasons = %v, want one containing %q", verdict.Reasons, tt.reason)
+			}
+			if len(prom.queries) != 4 {
+				t.Fatalf("got %d queries, want 4", len(prom.queries))
+			}
+			for _, q := range prom.queries {
+				if !strings.Contains(q, `namespace="shop"`) || !strings.Contains(q, "[2m]") {
+					t.Errorf("query not templated: %s", q)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusClientQueryError(t *testing.T) {
+	srv := httptest.NewServer(&fakePrometheus{})
+	defer srv.Close()
+
+	_, err := NewPrometheusClient(srv.URL, "wrong").Query(context.Background(), "up")
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("Query error = %v, want unauthorized", err)
+	}
+}
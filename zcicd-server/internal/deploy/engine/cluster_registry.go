@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// InClusterServer is the Argo CD destination of the cluster Argo CD runs in.
+	InClusterServer = "https://kubernetes.default.svc"
+
+	argoClusterSecretLabel = "argocd.argoproj.io/secret-type"
+	defaultKubeconfigKey   = "kubeconfig"
+)
+
+var invalidSecretNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ClusterRef identifies a registered cluster and where its kubeconfig lives.
+// KubeConfigRef is either "secret:<namespace>/<name>[:<key>]" (a Secret in the
+// cluster zcicd runs in, key defaults to "kubeconfig") or a kubeconfig file path.
+type ClusterRef struct {
+	ID            string
+	Name          string
+	Server        string
+	KubeConfigRef string
+	UpdatedAt     time.Time
+}
+
+type cachedCluster struct {
+	client    *k8s.K8sClient
+	updatedAt time.Time
+}
+
+// ClusterRegistry hands out clients for target clusters, cached per cluster
+// until its record changes, and registers clusters with Argo CD.
+type ClusterRegistry struct {
+	local  *k8s.K8sClient
+	argoNS string
+
+	mu         sync.Mutex
+	clients    map[string]cachedCluster
+	registered map[string]bool
+}
+
+// NewClusterRegistry creates a registry. local is the client of the cluster
+// zcicd and Argo CD run in; it serves clusters without a kubeconfig.
+func NewClusterRegistry(local *k8s.K8sClient, argoNS string) *ClusterRegistry {
+	return &ClusterRegistry{
+		local:      local,
+		argoNS:     argoNS,
+		clients:    map[string]cachedCluster{},
+		registered: map[string]bool{},
+	}
+}
+
+// IsLocal reports whether ref is the cluster zcicd runs in: no cluster, or
+// the in-cluster server.
+func IsLocal(ref *ClusterRef) bool {
+	return ref == nil || ref.Server == InClusterServer
+}
+
+// CheckCluster returns an error for a remote cluster zcicd cannot reach, so
+// that its deploys fail instead of landing in the local cluster.
+func CheckCluster(ref *ClusterRef) error {
+	if IsLocal(ref) {
+		return nil
+	}
+	if ref.Server == "" {
+		return fmt.Errorf("cluster %s has no API server URL", ref.Name)
+	}
+	if ref.KubeConfigRef == "" {
+		return fmt.Errorf("cluster %s (%s) has no kubeconfig to authenticate with", ref.Name, ref.Server)
+	}
+	return nil
+}
+
+// Client returns a client for the cluster, building and caching it on first use.
+func (r *ClusterRegistry) Client(ctx context.Context, ref *ClusterRef) (*k8s.K8sClient, error) {
+	if IsLocal(ref) {
+		return r.local, nil
+	}
+	if err := CheckCluster(ref); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	cached, ok := r.clients[ref.ID]
+	r.mu.Unlock()
+	if ok && cached.updatedAt.Equal(ref.UpdatedAt) {
+		return cached.client, nil
+	}
+
+	cfg, err := r.restConfig(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	client, err := k8s.NewK8sClientFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", ref.Name, err)
+	}
+
+	r.mu.Lock()
+	r.clients[ref.ID] = cachedCluster{client: client, updatedAt: ref.UpdatedAt}
+	r.mu.Unlock()
+	return client, nil
+}
+
+// EnsureArgoCluster registers the cluster as an Argo CD cluster secret unless a
+// secret for its server already exists.
+func (r *ClusterRegistry) EnsureArgoCluster(ctx context.Context, ref *ClusterRef) error {
+	if IsLocal(ref) {
+		return nil
+	}
+	if err := CheckCluster(ref); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	done := r.registered[ref.Server]
+	r.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	secrets := r.local.Clientset.CoreV1().Secrets(r.argoNS)
+	list, err := secrets.List(ctx, metav1.ListOptions{LabelSelector: argoClusterSecretLabel + "=cluster"})
+	if err != nil {
+		return fmt.Errorf("list argo cluster secrets: %w", err)
+	}
+	for _, s := range list.Items {
+		if string(s.Data["server"]) == ref.Server || s.StringData["server"] == ref.Server {
+			r.markRegistered(ref.Server)
+			return nil
+		}
+	}
+
+	cfg, err := r.restConfig(ctx, ref)
+	if err != nil {
+		return err
+	}
+	argoConfig, err := argoClusterConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("cluster %s: %w", ref.Name, err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "zcicd-cluster-" + invalidSecretNameChars.ReplaceAllString(strings.ToLower(ref.Name), "-"),
+			Namespace: r.argoNS,
+			Labels: map[string]string{
+				argoClusterSecretLabel:         "cluster",
+				"app.kubernetes.io/managed-by": "zcicd",
+			},
+		},
+		StringData: map[string]string{
+			"name":   ref.Name,
+			"server": ref.Server,
+			"config": argoConfig,
+		},
+	}
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("register cluster %s with argo cd: %w", ref.Name, err)
+	}
+	r.markRegistered(ref.Server)
+	return nil
+}
+
+func (r *ClusterRegistry) markRegistered(server string) {
+	r.mu.Lock()
+	r.registered[server] = true
+	r.mu.Unlock()
+}
+
+// ListAppPods lists the pods Argo CD labelled as belonging to the application.
+func ListAppPods(ctx context.Context, client *k8s.K8sClient, namespace, appName string) ([]PodInfo, error) {
+	list, err := client.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/instance=" + appName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]PodInfo, 0, len(list.Items))
+	for _, p := range list.Items {
+		info := PodInfo{Name: p.Name, Phase: string(p.Status.Phase), Node: p.Spec.NodeName, Ready: true}
+		for _, c := range p.Spec.Containers {
+			info.Images = append(info.Images, c.Image)
+		}
+		for _, cs := range p.Status.ContainerStatuses {
+			info.Restarts += cs.RestartCount
+			info.Ready = info.Ready && cs.Ready
+		}
+		if len(p.Status.ContainerStatuses) == 0 {
+			info.Ready = false
+		}
+		pods = append(pods, info)
+	}
+	return pods, nil
+}
+
+// restConfig loads the kubeconfig referenced by the cluster.
+func (r *ClusterRegistry) restConfig(ctx context.Context, ref *ClusterRef) (*rest.Config, error) {
+	var data []byte
+	if strings.HasPrefix(ref.KubeConfigRef, "secret:") {
+		nsName, key, _ := strings.Cut(strings.TrimPrefix(ref.KubeConfigRef, "secret:"), ":")
+		ns, name, ok := strings.Cut(nsName, "/")
+		if !ok || ns == "" || name == "" {
+			return nil, fmt.Errorf("cluster %s: kube_config_ref %q must be secret:<namespace>/<name>[:<key>]", ref.Name, ref.KubeConfigRef)
+		}
+		if key == "" {
+			key = defaultKubeconfigKey
+		}
+		secret, err := r.local.Clientset.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: read kubeconfig secret: %w", ref.Name, err)
+		}
+		if data = secret.Data[key]; len(data) == 0 {
+			return nil, fmt.Errorf("cluster %s: secret %s/%s has no key %q", ref.Name, ns, name, key)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(ref.KubeConfigRef); err != nil {
+			return nil, fmt.Errorf("cluster %s: read kubeconfig: %w", ref.Name, err)
+		}
+	}
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: parse kubeconfig: %w", ref.Name, err)
+	}
+	// Inline certificate files so the config can be copied into an Argo CD secret.
+	if err := rest.LoadTLSFiles(cfg); err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", ref.Name, err)
+	}
+	return cfg, nil
+}
+
+// argoClusterConfig converts a rest.Config into the "config" field of an Argo CD
+// cluster secret. Exec and auth-provider credentials cannot be carried over.
+func argoClusterConfig(cfg *rest.Config) (string, error) {
+	if cfg.BearerToken == "" && len(cfg.CertData) == 0 && cfg.Username == "" {
+		return "", fmt.Errorf("kubeconfig must use a bearer token, client certificate or basic auth")
+	}
+	tls := map[string]interface{}{"insecure": cfg.Insecure}
+	if len(cfg.CAData) > 0 {
+		tls["caData"] = base64.StdEncoding.EncodeToString(cfg.CAData)
+	}
+	if len(cfg.CertData) > 0 {
+		tls["certData"] = base64.StdEncoding.EncodeToString(cfg.CertData)
+	}
+	if len(cfg.KeyData) > 0 {
+		tls["keyData"] = base64.StdEncoding.EncodeToString(cfg.KeyData)
+	}
+	if cfg.ServerName != "" {
+		tls["serverName"] = cfg.ServerName
+	}
+	conf := map[string]interface{}{"tlsClientConfig": tls}
+	if cfg.BearerToken != "" {
+		conf["bearerToken"] = cfg.BearerToken
+	}
+	if cfg.Username != "" {
+		conf["username"] = cfg.Username
+		conf["password"] = cfg.Password
+	}
+	out, err := json.Marshal(conf)
+	return string(out), err
+}
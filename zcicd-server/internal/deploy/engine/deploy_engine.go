@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// DeployEngine creates, syncs and inspects the applications of deploy
+// configs. ArgoEngine delegates to Argo CD; DirectEngine applies rendered
+// manifests itself for clusters without Argo CD.
+type DeployEngine interface {
+	CreateApp(ctx context.Context, app ArgoApp) error
+	UpdateApp(ctx context.Context, app ArgoApp) error
+	DeleteApp(ctx context.Context, name string) error
+	GetApp(ctx context.Context, name string) (*AppStatus, error)
+	GetResourceTree(ctx context.Context, name string) (*ResourceTree, error)
+	TriggerSync(ctx context.Context, appName string, revision string) (*SyncResult, error)
+}
+
+// SyncWaiter is implemented by engines whose TriggerSync only starts the
+// sync. WaitForSync blocks until the sync has finished.
+type SyncWaiter interface {
+	WaitForSync(ctx context.Context, appName string, timeout time.Duration) (*SyncResult, error)
+}
+
+// ArgoEngine is the DeployEngine backed by Argo CD Applications.
+type ArgoEngine struct {
+	*AppManager
+	*SyncController
+}
+
+// NewArgoEngine creates a new ArgoEngine.
+func NewArgoEngine(appManager *AppManager, syncCtrl *SyncController) *ArgoEngine {
+	return &ArgoEngine{AppManager: appManager, SyncController: syncCtrl}
+}
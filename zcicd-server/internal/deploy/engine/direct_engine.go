@@ -0,0 +1,432 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// instanceLabel marks the resources of an application, as Argo CD and Helm do.
+const instanceLabel = "app.kubernetes.io/instance"
+
+// DirectEngine is the DeployEngine for clusters without Argo CD. It renders
+// an application itself and applies it with server-side apply. There is no
+// controller: applications are synced when triggered (or on create/update
+// when AutoSync is set), and SelfHeal has no effect.
+//
+// The engine is scoped to a namespace of one cluster and keeps no state of
+// its own; each application's spec and last applied resources are stored in
+// a Secret next to them.
+type DirectEngine struct {
+	client    *k8s.K8sClient
+	namespace string
+	render    func(ctx context.Context, app ArgoApp) (*Rendered, error)
+}
+
+// NewDirectEngine creates a DirectEngine for the applications in namespace.
+func NewDirectEngine(client *k8s.K8sClient, namespace string) *DirectEngine {
+	return &DirectEngine{
+		client:    client,
+		namespace: namespace,
+		render:    RenderApp,
+	}
+}
+
+// directState is what the engine records of an application.
+type directState struct {
+	App       ArgoApp           `json:"app"`
+	Applied   string            `json:"applied,omitempty"` // checksum of the spec last applied
+	Revision  string            `json:"revision,omitempty"`
+	Resources []appliedResource `json:"resources,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	SyncedAt  string            `json:"synced_at,omitempty"`
+}
+
+// appliedResource identifies a resource the engine applied.
+type appliedResource struct {
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+func (r appliedResource) key() string {
+	return r.APIVersion + "/" + r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// CreateApp records the application and syncs it if AutoSync is set.
+func (e *DirectEngine) CreateApp(ctx context.Context, app ArgoApp) error {
+	return e.UpdateApp(ctx, app)
+}
+
+// UpdateApp records the new spec of the application and, if AutoSync is set
+// and the spec changed, syncs it. Otherwise a changed application is
+// OutOfSync until the next sync.
+func (e *DirectEngine) UpdateApp(ctx context.Context, app ArgoApp) error {
+	state, err := e.load(ctx, app.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if state == nil {
+		state = &directState{}
+	}
+	state.App = app
+	if err := e.save(ctx, state); err != nil {
+		return err
+	}
+	if app.AutoSync && state.Applied != specChecksum(app) {
+		if _, err := e.TriggerSync(ctx, app.Name, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteApp deletes the resources the application last applied and its record.
+func (e *DirectEngine) DeleteApp(ctx context.Context, name string) error {
+	state, err := e.load(ctx, name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range state.Resources {
+		err := e.client.DeleteResource(ctx, r.Namespace, r.APIVersion, r.Kind, r.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s/%s of %s: %w", r.Kind, r.Name, name, err)
+		}
+	}
+	err = e.client.Clientset.CoreV1().Secrets(e.namespace).Delete(ctx, stateSecretName(name), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete state of %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetApp returns the sync status and health of the application.
+func (e *DirectEngine) GetApp(ctx context.Context, name string) (*AppStatus, error) {
+	state, err := e.load(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application %s: %w", name, err)
+	}
+	nodes, health := e.resourceHealth(ctx, state.Resources)
+	status := &AppStatus{
+		SyncStatus:   "OutOfSync",
+		HealthStatus: health,
+		Revision:     state.Revision,
+		Message:      state.Message,
+		Resources:    nodes,
+	}
+	if state.Applied != "" && state.Applied == specChecksum(state.App) {
+		status.SyncStatus = "Synced"
+	}
+	if state.Applied == "" {
+		status.HealthStatus = "Missing"
+	}
+	return status, nil
+}
+
+// GetResourceTree returns the resources the application last applied.
+func (e *DirectEngine) GetResourceTree(ctx context.Context, name string) (*ResourceTree, error) {
+	state, err := e.load(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application %s: %w", name, err)
+	}
+	nodes, _ := e.resourceHealth(ctx, state.Resources)
+	return &ResourceTree{Nodes: nodes}, nil
+}
+
+// TriggerSync renders the application at revision (its target revision if
+// empty), applies it and, if Prune is set, deletes the resources of the
+// previous sync that are no longer rendered.
+func (e *DirectEngine) TriggerSync(ctx context.Context, appName string, revision string) (*SyncResult, error) {
+	state, err := e.load(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to trigger sync for %s: %w", appName, err)
+	}
+	started := time.Now().Format(time.RFC3339)
+	app := state.App
+	if revision != "" {
+		app.TargetRevision = revision
+	}
+
+	resources, rendered, err := e.apply(ctx, app)
+	if err != nil {
+		state.Message = err.Error()
+		e.save(ctx, state)
+		return nil, fmt.Errorf("failed to trigger sync for %s: %w", appName, err)
+	}
+
+	message := ""
+	if app.Prune {
+		keep := map[string]bool{}
+		for _, r := range resources {
+			keep[r.key()] = true
+		}
+		for _, r := range state.Resources {
+			if keep[r.key()] {
+				continue
+			}
+			err := e.client.DeleteResource(ctx, r.Namespace, r.APIVersion, r.Kind, r.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				// Keep tracking it so the next sync retries the prune.
+				resources = append(resources, r)
+				message = fmt.Sprintf("failed to prune %s/%s: %v", r.Kind, r.Name, err)
+			}
+		}
+	} else {
+		// Unpruned leftovers stay owned by the app.
+		seen := map[string]bool{}
+		for _, r := range resources {
+			seen[r.key()] = true
+		}
+		for _, r := range state.Resources {
+			if !seen[r.key()] {
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	state.Applied = specChecksum(state.App)
+	state.Revision = app.TargetRevision
+	if rendered.Commit != "" {
+		state.Revision = rendered.Commit
+	}
+	state.Resources = resources
+	state.Message = message
+	state.SyncedAt = time.Now().Format(time.RFC3339)
+	if err := e.save(ctx, state); err != nil {
+		return nil, err
+	}
+
+	_, health := e.resourceHealth(ctx, resources)
+	return &SyncResult{
+		Status:       "Synced",
+		Health:       health,
+		Revision:     state.Revision,
+		ChartVersion: rendered.ChartVersion,
+		Message:      message,
+		StartedAt:    started,
+		FinishedAt:   state.SyncedAt,
+	}, nil
+}
+
+// apply renders the application, labels its resources and applies them.
+func (e *DirectEngine) apply(ctx context.Context, app ArgoApp) ([]appliedResource, *Rendered, error) {
+	rendered, err := e.render(ctx, app)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+
+	var out bytes.Buffer
+	var resources []appliedResource
+	decoder := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(rendered.Manifests), 4096)
+	for {
+		var obj unstructured.Unstructured
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, nil, fmt.Errorf("failed to decode manifests: %w", err)
+		}
+		if obj.GetKind() == "" {
+			continue
+		}
+		labelInstance(&obj, app.Name)
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, nil, err
+		}
+		out.WriteString("---\n")
+		out.Write(data)
+
+		ns := obj.GetNamespace()
+		if app.DestNamespace != "" {
+			ns = app.DestNamespace
+		}
+		resources = append(resources, appliedResource{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  ns,
+			Name:       obj.GetName(),
+		})
+	}
+	if err := e.client.ApplyYAML(ctx, app.DestNamespace, out.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	return resources, rendered, nil
+}
+
+// labelInstance sets the instance label on a resource and on the pod
+// template of workloads, so that their pods can be found.
+func labelInstance(obj *unstructured.Unstructured, name string) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[instanceLabel] = name
+	obj.SetLabels(labels)
+
+	if _, found, _ := unstructured.NestedMap(obj.Object, "spec", "template"); found {
+		unstructured.SetNestedField(obj.Object, name, "spec", "template", "metadata", "labels", instanceLabel)
+	}
+}
+
+// resourceHealth reads the live resources and returns their nodes and the
+// aggregate health: the worst of theirs.
+func (e *DirectEngine) resourceHealth(ctx context.Context, resources []appliedResource) ([]ResourceNode, string) {
+	rank := map[string]int{"Healthy": 0, "Progressing": 1, "Unknown": 2, "Missing": 3, "Degraded": 4}
+	health := "Healthy"
+	nodes := make([]ResourceNode, 0, len(resources))
+	for _, r := range resources {
+		gv, _ := schema.ParseGroupVersion(r.APIVersion)
+		node := ResourceNode{
+			Group:     gv.Group,
+			Version:   gv.Version,
+			Kind:      r.Kind,
+			Namespace: r.Namespace,
+			Name:      r.Name,
+			Status:    "Synced",
+		}
+		obj, err := e.client.GetResource(ctx, r.Namespace, r.APIVersion, r.Kind, r.Name)
+		switch {
+		case apierrors.IsNotFound(err):
+			node.Status = "OutOfSync"
+			node.Health = "Missing"
+		case err != nil:
+			node.Health = "Unknown"
+			node.Message = err.Error()
+		default:
+			node.Health, node.Message = WorkloadHealth(obj)
+		}
+		if rank[node.Health] > rank[health] {
+			health = node.Health
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, health
+}
+
+// WorkloadHealth computes the health of a live resource from its status.
+// Deployments and StatefulSets are Progressing until their rollout is
+// complete, and a Deployment past its progress deadline is Degraded. Other
+// resources are Healthy once they exist.
+func WorkloadHealth(obj *unstructured.Unstructured) (string, string) {
+	generation := obj.GetGeneration()
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	statusInt := func(field string) int64 {
+		v, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+		return v
+	}
+
+	switch obj.GetKind() {
+	case "Deployment":
+		conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		for _, c := range conditions {
+			cond, _ := c.(map[string]interface{})
+			if cond["type"] == "Progressing" && cond["reason"] == "ProgressDeadlineExceeded" {
+				msg, _ := cond["message"].(string)
+				return "Degraded", msg
+			}
+		}
+		if observed < generation {
+			return "Progressing", "waiting for rollout to be observed"
+		}
+		updated := statusInt("updatedReplicas")
+		switch {
+		case updated < replicas:
+			return "Progressing", fmt.Sprintf("%d of %d replicas updated", updated, replicas)
+		case statusInt("replicas") > updated:
+			return "Progressing", "old replicas are pending termination"
+		case statusInt("availableReplicas") < updated:
+			return "Progressing", fmt.Sprintf("%d of %d updated replicas available", statusInt("availableReplicas"), updated)
+		}
+	case "StatefulSet":
+		if observed < generation {
+			return "Progressing", "waiting for rollout to be observed"
+		}
+		if ready := statusInt("readyReplicas"); ready < replicas {
+			return "Progressing", fmt.Sprintf("%d of %d replicas ready", ready, replicas)
+		}
+		current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if update != "" && current != update {
+			return "Progressing", "rolling update in progress"
+		}
+	}
+	return "Healthy", ""
+}
+
+func stateSecretName(appName string) string {
+	return appName + "-zcicd-app"
+}
+
+// specChecksum identifies an application spec; the app is Synced while the
+// last applied checksum matches.
+func specChecksum(app ArgoApp) string {
+	data, _ := json.Marshal(app)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *DirectEngine) load(ctx context.Context, name string) (*directState, error) {
+	secret, err := e.client.Clientset.CoreV1().Secrets(e.namespace).Get(ctx, stateSecretName(name), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var state directState
+	if err := json.Unmarshal(secret.Data["state"], &state); err != nil {
+		return nil, fmt.Errorf("invalid state of %s: %w", name, err)
+	}
+	return &state, nil
+}
+
+func (e *DirectEngine) save(ctx context.Context, state *directState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	secrets := e.client.Clientset.CoreV1().Secrets(e.namespace)
+	name := stateSecretName(state.App.Name)
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: e.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "zcicd",
+					instanceLabel:                  state.App.Name,
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{"state": data},
+		}, metav1.CreateOptions{})
+	} else if err == nil {
+		existing.Data = map[string][]byte{"state": data}
+		_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save state of %s: %w", state.App.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// DriftedResource is a resource of an application whose live state differs
+// from the desired state in Git.
+type DriftedResource struct {
+	Group     string                 `json:"group"`
+	Version   string                 `json:"version"`
+	Kind      string                 `json:"kind"`
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Status    string                 `json:"status"`
+	Health    string                 `json:"health"`
+	Live      map[string]interface{} `json:"live,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// APIVersion returns the group/version of the resource.
+func (r DriftedResource) APIVersion() string {
+	if r.Group == "" {
+		return r.Version
+	}
+	return r.Group + "/" + r.Version
+}
+
+// OutOfSyncResources returns the resources Argo CD reports as OutOfSync.
+func OutOfSyncResources(status *AppStatus) []DriftedResource {
+	var drifted []DriftedResource
+	for _, node := range status.Resources {
+		if node.Status != "OutOfSync" {
+			continue
+		}
+		drifted = append(drifted, DriftedResource{
+			Group:     node.Group,
+			Version:   node.Version,
+			Kind:      node.Kind,
+			Namespace: node.Namespace,
+			Name:      node.Name,
+			Status:    node.Status,
+			Health:    node.Health,
+		})
+	}
+	return drifted
+}
+
+// CaptureLive reads the live manifest of each drifted resource from the
+// cluster. Secrets are never captured; resources that cannot be read carry
+// the error instead.
+func CaptureLive(ctx context.Context, client *k8s.K8sClient, resources []DriftedResource) {
+	for i := range resources {
+		r := &resources[i]
+		if r.Kind == "Secret" || r.Version == "" {
+			continue
+		}
+		obj, err := client.GetResource(ctx, r.Namespace, r.APIVersion(), r.Kind, r.Name)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		r.Live = CleanManifest(obj.Object)
+	}
+}
+
+// CleanManifest strips the server-populated fields of a live object so that
+// it can be committed as a desired-state manifest.
+func CleanManifest(obj map[string]interface{}) map[string]interface{} {
+	out := unstructured.Unstructured{Object: obj}
+	clean := out.DeepCopy()
+	unstructured.RemoveNestedField(clean.Object, "status")
+	for _, f := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink", "ownerReferences"} {
+		unstructured.RemoveNestedField(clean.Object, "metadata", f)
+	}
+	annotations := clean.GetAnnotations()
+	for k := range annotations {
+		if k == "kubectl.kubernetes.io/last-applied-configuration" || strings.HasPrefix(k, "deployment.kubernetes.io/") {
+			delete(annotations, k)
+		}
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(clean.Object, "metadata", "annotations")
+	} else {
+		clean.SetAnnotations(annotations)
+	}
+	return clean.Object
+}
+
+// ManifestYAML renders a manifest as YAML with sorted keys.
+func ManifestYAML(obj map[string]interface{}) ([]byte, error) {
+	return yaml.Marshal(obj)
+}
+
+// WorkloadValues maps the live replica count and first container image of
+// drifted Deployments, StatefulSets and Rollouts onto the conventional Helm
+// values (replicaCount, image.repository, image.tag). Other drift cannot be
+// expressed in chart values and is ignored.
+func WorkloadValues(resources []DriftedResource) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, r := range resources {
+		if r.Live == nil {
+			continue
+		}
+		switch r.Kind {
+		case "Deployment", "StatefulSet", "Rollout":
+		default:
+			continue
+		}
+		if replicas, found, _ := unstructured.NestedInt64(r.Live, "spec", "replicas"); found {
+			values["replicaCount"] = replicas
+		}
+		containers, _, _ := unstructured.NestedSlice(r.Live, "spec", "template", "spec", "containers")
+		if len(containers) == 0 {
+			continue
+		}
+		c, _ := containers[0].(map[string]interface{})
+		image, _ := c["image"].(string)
+		repo, tag, digest := splitImage(image)
+		if repo == "" {
+			continue
+		}
+		img := map[string]interface{}{"repository": repo, "tag": tag}
+		if digest != "" {
+			img["digest"] = digest
+		}
+		values["image"] = img
+	}
+	return values
+}
+
+// documentSeparator splits a multi-document YAML stream.
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*\n?`)
+
+// ReplaceManifests replaces the documents of a multi-document YAML stream
+// that describe a drifted resource with its live manifest, and returns the
+// stream and how many documents were replaced. Resources the stream does not
+// describe, e.g. ones generated at deploy time, are left out.
+func ReplaceManifests(manifests []byte, resources []DriftedResource) ([]byte, int, error) {
+	var out bytes.Buffer
+	replaced := 0
+	for _, doc := range documentSeparator.Split(string(manifests), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, 0, fmt.Errorf("invalid manifest: %w", err)
+		}
+		for _, r := range resources {
+			if r.Live == nil || r.Kind != obj.Kind || r.Name != obj.Metadata.Name ||
+				(obj.Metadata.Namespace != "" && obj.Metadata.Namespace != r.Namespace) {
+				continue
+			}
+			data, err := ManifestYAML(r.Live)
+			if err != nil {
+				return nil, 0, err
+			}
+			doc = string(data)
+			replaced++
+			break
+		}
+		out.WriteString("---\n")
+		out.WriteString(strings.TrimRight(doc, "\n"))
+		out.WriteString("\n")
+	}
+	return out.Bytes(), replaced, nil
+}
+
+// splitImage splits an image reference into repository, tag and digest.
+func splitImage(image string) (repo, tag, digest string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, digest = image[:i], image[i+1:]
+	}
+	repo, tag = image, "latest"
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i+1:]
+	}
+	return repo, tag, digest
+}
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvChecksumAnnotation is set on pod templates so that a change of the
+// environment variables rolls the workload.
+const EnvChecksumAnnotation = "zcicd.io/env-checksum"
+
+// ErrNamespaceNotFound is returned by ApplyEnvConfig when the namespace does
+// not exist yet, e.g. because the sync creates it.
+var ErrNamespaceNotFound = errors.New("namespace not found")
+
+// EnvConfig is the resolved set of environment variables of a deploy, split
+// into a ConfigMap and a Secret of the same name.
+type EnvConfig struct {
+	Name       string
+	Namespace  string // where the ConfigMap and Secret live
+	Data       map[string]string
+	SecretData map[string]string
+}
+
+// NewEnvConfig creates an empty EnvConfig for the application.
+func NewEnvConfig(appName string) *EnvConfig {
+	return &EnvConfig{Name: appName + "-env", Data: map[string]string{}, SecretData: map[string]string{}}
+}
+
+// Set stores a variable, replacing one of the same key set before.
+func (c *EnvConfig) Set(key, value string, secret bool) {
+	delete(c.Data, key)
+	delete(c.SecretData, key)
+	if secret {
+		c.SecretData[key] = value
+	} else {
+		c.Data[key] = value
+	}
+}
+
+// Empty reports whether no variable is set.
+func (c *EnvConfig) Empty() bool {
+	return len(c.Data) == 0 && len(c.SecretData) == 0
+}
+
+// Checksum hashes all keys and values, secret ones included, in a stable order.
+func (c *EnvConfig) Checksum() string {
+	h := sha256.New()
+	write := func(prefix string, m map[string]string) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s\x00%s\x00%s\x00", prefix, k, m[k])
+		}
+	}
+	write("c", c.Data)
+	write("s", c.SecretData)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// HelmValues wires the ConfigMap and Secret into a chart through envFrom, and
+// puts the checksum into podAnnotations.
+func (c *EnvConfig) HelmValues() map[string]interface{} {
+	return map[string]interface{}{
+		"envFrom": []interface{}{
+			map[string]interface{}{"configMapRef": map[string]interface{}{"name": c.Name}},
+			map[string]interface{}{"secretRef": map[string]interface{}{"name": c.Name}},
+		},
+		"podAnnotations": map[string]interface{}{EnvChecksumAnnotation: c.Checksum()},
+	}
+}
+
+// envWorkloadKinds are the workloads whose pod templates get the variables.
+var envWorkloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+// InjectManifests wires the ConfigMap and Secret into the containers of the
+// workloads of raw manifests through envFrom, and puts the checksum on their
+// pod templates. Other documents are left as they are.
+func (c *EnvConfig) InjectManifests(manifests []byte) ([]byte, error) {
+	refs := []interface{}{
+		map[string]interface{}{"configMapRef": map[string]interface{}{"name": c.Name}},
+		map[string]interface{}{"secretRef": map[string]interface{}{"name": c.Name}},
+	}
+	var out bytes.Buffer
+	for _, doc := range documentSeparator.Split(string(manifests), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		kind, _ := obj["kind"].(string)
+		if containers, found, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "containers"); found && isEnvWorkload(kind) {
+			for i, item := range containers {
+				container, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				envFrom, _ := container["envFrom"].([]interface{})
+				container["envFrom"] = append(withoutEnvRefs(envFrom, c.Name), refs...)
+				containers[i] = container
+			}
+			if err := unstructured.SetNestedSlice(obj, containers, "spec", "template", "spec", "containers"); err != nil {
+				return nil, err
+			}
+			if err := unstructured.SetNestedField(obj, c.Checksum(), "spec", "template", "metadata", "annotations", EnvChecksumAnnotation); err != nil {
+				return nil, err
+			}
+			data, err := yaml.Marshal(obj)
+			if err != nil {
+				return nil, err
+			}
+			doc = string(data)
+		}
+		out.WriteString("---\n")
+		out.WriteString(strings.TrimRight(doc, "\n"))
+		out.WriteString("\n")
+	}
+	return out.Bytes(), nil
+}
+
+// KustomizePatches puts the checksum on the pod templates of a
+// kustomization's workloads, so that they roll when the variables change.
+// Kustomize cannot add envFrom to containers it does not know the names of:
+// the kustomization's own workloads reference the ConfigMap and Secret named
+// <app>-env.
+func (c *EnvConfig) KustomizePatches() []KustomizePatch {
+	patches := make([]KustomizePatch, 0, len(envWorkloadKinds))
+	for _, kind := range envWorkloadKinds {
+		patches = append(patches, KustomizePatch{
+			Target: &KustomizeTarget{Kind: kind},
+			Patch: fmt.Sprintf("apiVersion: apps/v1\nkind: %s\nmetadata:\n  name: any\nspec:\n  template:\n    metadata:\n      annotations:\n        %s: %q\n",
+				kind, EnvChecksumAnnotation, c.Checksum()),
+		})
+	}
+	return patches
+}
+
+func isEnvWorkload(kind string) bool {
+	for _, k := range envWorkloadKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutEnvRefs drops the envFrom references to the named ConfigMap and
+// Secret, so that injecting them twice does not repeat them.
+func withoutEnvRefs(envFrom []interface{}, name string) []interface{} {
+	out := make([]interface{}, 0, len(envFrom)+2)
+	for _, item := range envFrom {
+		ref, _ := item.(map[string]interface{})
+		cm, _ := ref["configMapRef"].(map[string]interface{})
+		secret, _ := ref["secretRef"].(map[string]interface{})
+		if (cm != nil && cm["name"] == name) || (secret != nil && secret["name"] == name) {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// ApplyEnvConfig creates or updates the ConfigMap and Secret in their
+// namespace. It returns ErrNamespaceNotFound if the namespace does not exist.
+func ApplyEnvConfig(ctx context.Context, client *k8s.K8sClient, c *EnvConfig) error {
+	namespace := c.Namespace
+	if _, err := client.Clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", ErrNamespaceNotFound, namespace)
+	} else if err != nil {
+		return fmt.Errorf("get namespace %s: %w", namespace, err)
+	}
+	meta := metav1.ObjectMeta{
+		Name:        c.Name,
+		Namespace:   namespace,
+		Labels:      map[string]string{"app.kubernetes.io/managed-by": "zcicd"},
+		Annotations: map[string]string{EnvChecksumAnnotation: c.Checksum()},
+	}
+
+	cms := client.Clientset.CoreV1().ConfigMaps(namespace)
+	cm := &corev1.ConfigMap{ObjectMeta: meta, Data: c.Data}
+	if _, err := cms.Update(ctx, cm, metav1.UpdateOptions{}); apierrors.IsNotFound(err) {
+		_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("create configmap %s: %w", c.Name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("update configmap %s: %w", c.Name, err)
+	}
+
+	secrets := client.Clientset.CoreV1().Secrets(namespace)
+	secret := &corev1.Secret{ObjectMeta: meta, Type: corev1.SecretTypeOpaque, StringData: c.SecretData}
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("create secret %s: %w", c.Name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("update secret %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// RollEnvChecksum sets the env checksum on the pod template of the application's
+// Deployments, so plain-manifest workloads restart when their variables change.
+// Deployments already carrying the checksum are left alone.
+func RollEnvChecksum(ctx context.Context, client *k8s.K8sClient, namespace, appName, checksum string) error {
+	deployments := client.Clientset.AppsV1().Deployments(namespace)
+	list, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: "app.kubernetes.io/instance=" + appName})
+	if err != nil {
+		return fmt.Errorf("list deployments of %s: %w", appName, err)
+	}
+	patch, _ := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{EnvChecksumAnnotation: checksum},
+				},
+			},
+		},
+	})
+	for _, d := range list.Items {
+		if d.Spec.Template.Annotations[EnvChecksumAnnotation] == checksum {
+			continue
+		}
+		if _, err := deployments.Patch(ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("roll deployment %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
@@ -1,20 +1,42 @@
 package engine
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"sigs.k8s.io/yaml"
 )
 
 const (
 	gitopsLockPrefix = "gitops:lock:"
 	gitopsLockTTL    = 60 * time.Second
+	// Environments sharing a GitOps repo wait for each other's writes.
+	gitopsLockWait  = 30 * time.Second
+	gitopsLockRetry = 500 * time.Millisecond
+)
+
+// ErrGitWriteNotConfigured is returned by writes when the server has no git
+// binary to clone, commit and push with.
+var ErrGitWriteNotConfigured = errors.New("git write not configured")
+
+// Author of the commits written to GitOps repos.
+const (
+	gitopsAuthorName  = "zcicd"
+	gitopsAuthorEmail = "zcicd@noreply.zcicd.io"
 )
 
 // GitOpsWriter handles GitOps repository updates with Redis distributed locking.
+// Repos are reached with the credentials in their URL or the git credential
+// helper and SSH keys of the server.
 type GitOpsWriter struct {
 	redisClient *redis.Client
 }
@@ -26,37 +48,163 @@ func NewGitOpsWriter(redisClient *redis.Client) *GitOpsWriter {
 	}
 }
 
-// UpdateValues acquires a distributed lock, updates values in the GitOps repo,
-// commits and pushes. Git operations are currently stubbed.
+// UpdateValues merges values into a Helm values file of the GitOps repo,
+// commits and pushes under the repo's distributed lock, and returns the
+// commit SHA. Keys of the file that values do not set are kept.
 func (w *GitOpsWriter) UpdateValues(
 	ctx context.Context,
 	repoURL, branch, filePath string,
 	values map[string]interface{},
 ) (string, error) {
-	lockKey := gitopsLockPrefix + repoURL
+	message := fmt.Sprintf("Update %s", filePath)
+	return w.commit(ctx, repoURL, branch, message, func(dir string) error {
+		file := repoFile(dir, filePath)
+		current := map[string]interface{}{}
+		data, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, &current); err != nil {
+				return fmt.Errorf("invalid values file %s: %w", filePath, err)
+			}
+			if current == nil {
+				current = map[string]interface{}{}
+			}
+		case !os.IsNotExist(err):
+			return err
+		}
+		out, err := ValuesYAML(mergeMaps(current, values))
+		if err != nil {
+			return fmt.Errorf("marshal values: %w", err)
+		}
+		return writeRepoFile(file, []byte(out))
+	})
+}
+
+// WriteFiles writes the files (path relative to the repo root) in one commit
+// and pushes under the repo's distributed lock, returning the commit SHA.
+func (w *GitOpsWriter) WriteFiles(
+	ctx context.Context,
+	repoURL, branch, message string,
+	files map[string][]byte,
+) (string, error) {
+	return w.commit(ctx, repoURL, branch, message, func(dir string) error {
+		for name, data := range files {
+			if err := writeRepoFile(repoFile(dir, name), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// commit checks out the head of a branch, lets edit change the work tree,
+// and commits and pushes the changes. Nothing is pushed when edit changed
+// nothing; the SHA of the branch head is returned either way.
+func (w *GitOpsWriter) commit(ctx context.Context, repoURL, branch, message string, edit func(dir string) error) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("write to %s: %w: %v", repoURL, ErrGitWriteNotConfigured, err)
+	}
+	if branch == "" || branch == "HEAD" {
+		branch = "main"
+	}
+	unlock, err := w.lock(ctx, repoURL)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 
-	// Acquire distributed lock
-	acquired, err := w.redisClient.SetNX(ctx, lockKey, "locked", gitopsLockTTL).Result()
+	dir, err := os.MkdirTemp("", "zcicd-gitops-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	if _, err := checkout(ctx, dir, repoURL, branch); err != nil {
+		return "", err
+	}
+	if err := edit(dir); err != nil {
+		return "", err
+	}
+	if _, err := run(ctx, dir, "git", "add", "-A"); err != nil {
+		return "", err
+	}
+	status, err := run(ctx, dir, "git", "status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(status)) > 0 {
+		for _, args := range [][]string{
+			{"-c", "user.name=" + gitopsAuthorName, "-c", "user.email=" + gitopsAuthorEmail, "commit", "-q", "-m", message},
+			{"push", "-q", "origin", "HEAD:refs/heads/" + branch},
+		} {
+			if _, err := run(ctx, dir, "git", args...); err != nil {
+				return "", err
+			}
+		}
+	}
+	sha, err := run(ctx, dir, "git", "rev-parse", "HEAD")
 	if err != nil {
-		return "", fmt.Errorf("failed to acquire gitops lock for %s: %w", repoURL, err)
+		return "", err
 	}
-	if !acquired {
-		return "", fmt.Errorf("gitops lock already held for %s, retry later", repoURL)
+	return strings.TrimSpace(string(sha)), nil
+}
+
+// repoFile returns the path of a repo file under dir, kept inside it.
+func repoFile(dir, name string) string {
+	return filepath.Join(dir, filepath.Clean("/"+name))
+}
+
+func writeRepoFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
 	}
+	return os.WriteFile(file, data, 0o644)
+}
 
-	// Ensure lock is released when done
-	defer func() {
-		if err := w.redisClient.Del(ctx, lockKey).Err(); err != nil {
-			log.Printf("warning: failed to release gitops lock for %s: %v", repoURL, err)
+// mergeMaps deep-merges override onto base.
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		if bm, ok := out[k].(map[string]interface{}); ok {
+			if om, ok := v.(map[string]interface{}); ok {
+				out[k] = mergeMaps(bm, om)
+				continue
+			}
 		}
-	}()
+		out[k] = v
+	}
+	return out
+}
+
+// lock acquires the distributed lock of a repo, waiting up to gitopsLockWait
+// for other writers, and returns the function releasing it.
+func (w *GitOpsWriter) lock(ctx context.Context, repoURL string) (func(), error) {
+	lockKey := gitopsLockPrefix + repoURL
 
-	// --- Stubbed git operations ---
-	// TODO: Wire git credentials and implement real clone/pull/commit/push.
-	log.Printf("gitops: would clone/pull repo=%s branch=%s", repoURL, branch)
-	log.Printf("gitops: would update file=%s with %d value overrides", filePath, len(values))
-	log.Printf("gitops: would commit and push changes")
+	deadline := time.Now().Add(gitopsLockWait)
+	for {
+		acquired, err := w.redisClient.SetNX(ctx, lockKey, "locked", gitopsLockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire gitops lock for %s: %w", repoURL, err)
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("gitops lock still held for %s after %s", repoURL, gitopsLockWait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(gitopsLockRetry):
+		}
+	}
 
-	stubCommitSHA := "stub-sha-pending-git-integration"
-	return stubCommitSHA, nil
-}
\ No newline at end of file
+	return func() {
+		if err := w.redisClient.Del(ctx, lockKey).Err(); err != nil {
+			log.Printf("warning: failed to release gitops lock for %s: %v", repoURL, err)
+		}
+	}, nil
+}
@@ -0,0 +1,351 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// EnvironmentIDLabel marks namespaces created for an environment. Only
+	// namespaces carrying the environment's ID are deleted with it.
+	EnvironmentIDLabel = "zcicd.io/environment-id"
+	// AdoptedByAnnotation records the environment that adopted a namespace
+	// zcicd did not create. Adopted namespaces are never deleted.
+	AdoptedByAnnotation = "zcicd.io/adopted-by"
+	// QuotaAnnotation set to "true" on a namespace zcicd did not create lets
+	// the environment adopting it apply its ResourceQuota and LimitRange.
+	QuotaAnnotation = "zcicd.io/manage-quota"
+
+	quotaObjectName = "zcicd-quota"
+	managedByLabel  = "app.kubernetes.io/managed-by"
+)
+
+// ErrSystemNamespace is returned for the namespaces of the cluster itself and
+// of Argo CD, which environments may not use.
+var ErrSystemNamespace = errors.New("system namespace")
+
+// Container defaults put into the LimitRange so pods without explicit
+// resources still fit a namespace with a ResourceQuota.
+var containerDefaults = map[corev1.ResourceName]struct{ request, limit string }{
+	corev1.ResourceCPU:    {request: "100m", limit: "500m"},
+	corev1.ResourceMemory: {request: "128Mi", limit: "512Mi"},
+}
+
+// QuotaLimits are the namespace-wide limits of an environment.
+type QuotaLimits struct {
+	CPURequest    string
+	CPULimit      string
+	MemoryRequest string
+	MemoryLimit   string
+	PodLimit      int
+	StorageLimit  string
+}
+
+// QuotaDrift is a field of the applied ResourceQuota or LimitRange that
+// differs from what the environment's quota record renders to.
+type QuotaDrift struct {
+	Object   string `json:"object"`
+	Resource string `json:"resource"`
+	Desired  string `json:"desired"`
+	Actual   string `json:"actual"`
+}
+
+// IsSystemNamespace reports whether a namespace belongs to the cluster
+// (default, kube-*) or to Argo CD.
+func IsSystemNamespace(name, argoNS string) bool {
+	return name == "default" || strings.HasPrefix(name, "kube-") || name == argoNS
+}
+
+// EnsureNamespace creates the namespace for the environment, owned by it, or
+// adopts an existing one. Labels are merged into either; only a namespace
+// created here carries the ownership label that lets DeleteNamespace remove
+// it. It reports whether the environment manages the namespace's quota: see
+// managesQuota.
+func EnsureNamespace(ctx context.Context, client *k8s.K8sClient, name, envID string, labels map[string]string) (bool, error) {
+	namespaces := client.Clientset.CoreV1().Namespaces()
+	ns, err := namespaces.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		owned := map[string]string{managedByLabel: "zcicd", EnvironmentIDLabel: envID}
+		for k, v := range labels {
+			owned[k] = v
+		}
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: owned}}
+		created, err := namespaces.Create(ctx, ns, metav1.CreateOptions{})
+		if err == nil {
+			return managesQuota(created, envID), nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return false, fmt.Errorf("create namespace %s: %w", name, err)
+		}
+		// Created concurrently by someone else: adopt it.
+		if ns, err = namespaces.Get(ctx, name, metav1.GetOptions{}); err != nil {
+			return false, fmt.Errorf("get namespace %s: %w", name, err)
+		}
+	} else if err != nil {
+		return false, fmt.Errorf("get namespace %s: %w", name, err)
+	}
+
+	changed := false
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		if ns.Labels[k] != v {
+			ns.Labels[k] = v
+			changed = true
+		}
+	}
+	if ns.Labels[EnvironmentIDLabel] != envID && ns.Annotations[AdoptedByAnnotation] != envID {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[AdoptedByAnnotation] = envID
+		changed = true
+	}
+	if !changed {
+		return managesQuota(ns, envID), nil
+	}
+	if _, err := namespaces.Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("label namespace %s: %w", name, err)
+	}
+	return managesQuota(ns, envID), nil
+}
+
+// managesQuota reports whether an environment applies its quota to a
+// namespace: one it created, or an adopted one whose QuotaAnnotation allows it.
+func managesQuota(ns *corev1.Namespace, envID string) bool {
+	_, adopted := ns.Annotations[AdoptedByAnnotation]
+	owned := ns.Labels[EnvironmentIDLabel] == envID && !adopted
+	return owned || ns.Annotations[QuotaAnnotation] == "true"
+}
+
+// DeleteNamespace deletes a namespace created for the environment. A namespace
+// without the environment's ID label, or one that was adopted, is left alone.
+func DeleteNamespace(ctx context.Context, client *k8s.K8sClient, name, envID string) error {
+	namespaces := client.Clientset.CoreV1().Namespaces()
+	ns, err := namespaces.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get namespace %s: %w", name, err)
+	}
+	if ns.Labels[EnvironmentIDLabel] != envID {
+		return nil
+	}
+	if _, adopted := ns.Annotations[AdoptedByAnnotation]; adopted {
+		return nil
+	}
+	if err := namespaces.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// NamespaceQuota reports whether the namespace exists and whether the
+// environment manages its quota.
+func NamespaceQuota(ctx context.Context, client *k8s.K8sClient, name, envID string) (exists, managed bool, err error) {
+	ns, err := client.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, managesQuota(ns, envID), nil
+}
+
+// ApplyQuota creates or replaces the ResourceQuota and LimitRange of the
+// namespace. Both are removed when q sets no limit.
+func ApplyQuota(ctx context.Context, client *k8s.K8sClient, namespace string, q QuotaLimits) error {
+	core := client.Clientset.CoreV1()
+	hard, err := q.hard()
+	if err != nil {
+		return err
+	}
+
+	if len(hard) == 0 {
+		if err := core.ResourceQuotas(namespace).Delete(ctx, quotaObjectName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete resource quota: %w", err)
+		}
+		if err := core.LimitRanges(namespace).Delete(ctx, quotaObjectName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete limit range: %w", err)
+		}
+		return nil
+	}
+
+	meta := metav1.ObjectMeta{Name: quotaObjectName, Namespace: namespace, Labels: map[string]string{managedByLabel: "zcicd"}}
+	rq := &corev1.ResourceQuota{ObjectMeta: meta, Spec: corev1.ResourceQuotaSpec{Hard: hard}}
+	quotas := core.ResourceQuotas(namespace)
+	if _, err := quotas.Update(ctx, rq, metav1.UpdateOptions{}); apierrors.IsNotFound(err) {
+		if _, err := quotas.Create(ctx, rq, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create resource quota: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("update resource quota: %w", err)
+	}
+
+	lr := &corev1.LimitRange{ObjectMeta: meta, Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{q.containerLimits()}}}
+	ranges := core.LimitRanges(namespace)
+	if _, err := ranges.Update(ctx, lr, metav1.UpdateOptions{}); apierrors.IsNotFound(err) {
+		if _, err := ranges.Create(ctx, lr, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create limit range: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("update limit range: %w", err)
+	}
+	return nil
+}
+
+// DetectQuotaDrift compares the ResourceQuota and LimitRange in the namespace
+// with what q renders to.
+func DetectQuotaDrift(ctx context.Context, client *k8s.K8sClient, namespace string, q QuotaLimits) ([]QuotaDrift, error) {
+	hard, err := q.hard()
+	if err != nil {
+		return nil, err
+	}
+	core := client.Clientset.CoreV1()
+	drift := []QuotaDrift{}
+
+	var actualHard corev1.ResourceList
+	rq, err := core.ResourceQuotas(namespace).Get(ctx, quotaObjectName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return nil, fmt.Errorf("get resource quota: %w", err)
+	default:
+		actualHard = rq.Spec.Hard
+	}
+	drift = append(drift, diffResources("ResourceQuota", "", hard, actualHard)...)
+
+	var desiredItem, actualItem corev1.LimitRangeItem
+	if len(hard) > 0 {
+		desiredItem = q.containerLimits()
+	}
+	lr, err := core.LimitRanges(namespace).Get(ctx, quotaObjectName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return nil, fmt.Errorf("get limit range: %w", err)
+	default:
+		for _, item := range lr.Spec.Limits {
+			if item.Type == corev1.LimitTypeContainer {
+				actualItem = item
+			}
+		}
+	}
+	drift = append(drift, diffResources("LimitRange", "max.", desiredItem.Max, actualItem.Max)...)
+	drift = append(drift, diffResources("LimitRange", "default.", desiredItem.Default, actualItem.Default)...)
+	drift = append(drift, diffResources("LimitRange", "defaultRequest.", desiredItem.DefaultRequest, actualItem.DefaultRequest)...)
+	return drift, nil
+}
+
+func diffResources(object, prefix string, desired, actual corev1.ResourceList) []QuotaDrift {
+	names := map[corev1.ResourceName]bool{}
+	for name := range desired {
+		names[name] = true
+	}
+	for name := range actual {
+		names[name] = true
+	}
+	var drift []QuotaDrift
+	for name := range names {
+		d, dok := desired[name]
+		a, aok := actual[name]
+		if dok && aok && d.Cmp(a) == 0 {
+			continue
+		}
+		item := QuotaDrift{Object: object, Resource: prefix + string(name)}
+		if dok {
+			item.Desired = d.String()
+		}
+		if aok {
+			item.Actual = a.String()
+		}
+		drift = append(drift, item)
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Resource < drift[j].Resource })
+	return drift
+}
+
+// Validate checks that every limit parses as a Kubernetes quantity.
+func (q QuotaLimits) Validate() error {
+	_, err := q.hard()
+	return err
+}
+
+// hard renders the ResourceQuota spec.hard of the limits.
+func (q QuotaLimits) hard() (corev1.ResourceList, error) {
+	hard := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceRequestsCPU:     q.CPURequest,
+		corev1.ResourceLimitsCPU:       q.CPULimit,
+		corev1.ResourceRequestsMemory:  q.MemoryRequest,
+		corev1.ResourceLimitsMemory:    q.MemoryLimit,
+		corev1.ResourceRequestsStorage: q.StorageLimit,
+	} {
+		if value == "" {
+			continue
+		}
+		qty, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota %s %q: %w", name, value, err)
+		}
+		hard[name] = qty
+	}
+	if q.PodLimit > 0 {
+		hard[corev1.ResourcePods] = *resource.NewQuantity(int64(q.PodLimit), resource.DecimalSI)
+	}
+	return hard, nil
+}
+
+// containerLimits renders the container LimitRange item: no container may
+// exceed the namespace limit, and defaults are capped by it.
+func (q QuotaLimits) containerLimits() corev1.LimitRangeItem {
+	item := corev1.LimitRangeItem{
+		Type:           corev1.LimitTypeContainer,
+		Max:            corev1.ResourceList{},
+		Default:        corev1.ResourceList{},
+		DefaultRequest: corev1.ResourceList{},
+	}
+	for name, caps := range map[corev1.ResourceName][2]string{
+		corev1.ResourceCPU:    {q.CPURequest, q.CPULimit},
+		corev1.ResourceMemory: {q.MemoryRequest, q.MemoryLimit},
+	} {
+		if caps[0] == "" && caps[1] == "" {
+			continue
+		}
+		def := containerDefaults[name]
+		request, limit := resource.MustParse(def.request), resource.MustParse(def.limit)
+		if caps[1] != "" {
+			if max, err := resource.ParseQuantity(caps[1]); err == nil {
+				item.Max[name] = max
+				limit = minQuantity(limit, max)
+			}
+		}
+		if caps[0] != "" {
+			if total, err := resource.ParseQuantity(caps[0]); err == nil {
+				request = minQuantity(request, total)
+			}
+		}
+		item.Default[name] = limit
+		item.DefaultRequest[name] = minQuantity(request, limit)
+	}
+	return item
+}
+
+func minQuantity(a, b resource.Quantity) resource.Quantity {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusClient runs instant queries against a Prometheus-compatible HTTP API.
+type PrometheusClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewPrometheusClient creates a client for baseURL (e.g. http://prometheus:9090).
+// token, if set, is sent as a bearer token.
+func NewPrometheusClient(baseURL, token string) *PrometheusClient {
+	return &PrometheusClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type promResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string            `json:"resultType"`
+		Result     []json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Query evaluates promql and returns the first sample of the result, or nil if
+// the result is empty or NaN (e.g. no traffic reached the pods).
+func (p *PrometheusClient) Query(ctx context.Context, promql string) (*float64, error) {
+	u := p.baseURL + "/api/v1/query?" + url.Values{"query": {promql}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus query: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("prometheus query: %w", err)
+	}
+
+	var pr promResponse
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("prometheus query: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if pr.Status != "success" {
+		return nil, fmt.Errorf("prometheus query: %s: %s", pr.ErrorType, pr.Error)
+	}
+
+	// A vector sample is {"metric":{...},"value":[ts,"v"]}; a scalar result is [ts,"v"].
+	var raw string
+	switch pr.Data.ResultType {
+	case "vector":
+		if len(pr.Data.Result) == 0 {
+			return nil, nil
+		}
+		var sample struct {
+			Value [2]json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(pr.Data.Result[0], &sample); err != nil {
+			return nil, fmt.Errorf("prometheus query: %w", err)
+		}
+		if err := json.Unmarshal(sample.Value[1], &raw); err != nil {
+			return nil, fmt.Errorf("prometheus query: %w", err)
+		}
+	case "scalar":
+		if len(pr.Data.Result) != 2 {
+			return nil, nil
+		}
+		if err := json.Unmarshal(pr.Data.Result[1], &raw); err != nil {
+			return nil, fmt.Errorf("prometheus query: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("prometheus query: unsupported result type %q", pr.Data.ResultType)
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus query: %w", err)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, nil
+	}
+	return &f, nil
+}
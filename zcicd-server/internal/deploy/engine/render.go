@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// overlayDir is the kustomization generated next to the checkout to apply a
+// config's kustomize overrides, like Argo CD does.
+const overlayDir = ".zcicd-overlay"
+
+// Rendered is what RenderApp produced and the source it rendered.
+type Rendered struct {
+	Manifests    []byte
+	Commit       string // commit SHA checked out; empty for inline manifests
+	ChartVersion string // version in Chart.yaml of a Helm source
+}
+
+// renderTools are the binaries RenderApp and the GitOps writer run.
+var renderTools = []string{"git", "helm", "kubectl"}
+
+// CheckRenderTools reports the first binary the direct engine needs that is
+// not on PATH.
+func CheckRenderTools() error {
+	for _, name := range renderTools {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf("%s is required to render and write deploy sources: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// RenderApp renders the manifests of an application the way Argo CD's repo
+// server would: inline manifests as they are, otherwise the source at the
+// app's revision, checked out with git and built with helm template,
+// kubectl kustomize or by concatenating the directory's YAML files.
+func RenderApp(ctx context.Context, app ArgoApp) (*Rendered, error) {
+	if strings.TrimSpace(app.Manifests) != "" {
+		return &Rendered{Manifests: []byte(app.Manifests)}, nil
+	}
+	if app.RepoURL == "" {
+		return nil, fmt.Errorf("application %s has no source", app.Name)
+	}
+
+	dir, err := os.MkdirTemp("", "zcicd-render-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	commit, err := checkout(ctx, dir, app.RepoURL, app.TargetRevision)
+	if err != nil {
+		return nil, err
+	}
+	src := filepath.Join(dir, filepath.Clean("/"+app.Path))
+	rendered := &Rendered{Commit: commit}
+
+	switch app.SourceType {
+	case "kustomize":
+		overlay := filepath.Join(dir, overlayDir)
+		if err := writeOverlay(overlay, src, app.Kustomize); err != nil {
+			return nil, err
+		}
+		rendered.Manifests, err = run(ctx, dir, "kubectl", "kustomize", overlay)
+	case "directory":
+		rendered.Manifests, err = concatManifests(src)
+	default:
+		rendered.ChartVersion = chartVersion(src)
+		values := app.ValuesObject
+		if len(values) == 0 {
+			values = app.ValuesOverride
+		}
+		args := []string{"template", app.Name, src, "--namespace", app.DestNamespace, "--dependency-update"}
+		for _, f := range app.ValueFiles {
+			args = append(args, "--values", filepath.Join(src, filepath.Clean("/"+f)))
+		}
+		if len(values) > 0 {
+			data, err := ValuesYAML(values)
+			if err != nil {
+				return nil, fmt.Errorf("marshal values: %w", err)
+			}
+			file := filepath.Join(dir, ".zcicd-values.yaml")
+			if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+				return nil, err
+			}
+			args = append(args, "--values", file)
+		}
+		for _, p := range app.Parameters {
+			flag := "--set"
+			if p.ForceString {
+				flag = "--set-string"
+			}
+			args = append(args, flag, p.Name+"="+p.Value)
+		}
+		rendered.Manifests, err = run(ctx, dir, "helm", args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// checkout fetches a single revision (branch, tag or commit) of a repository.
+func checkout(ctx context.Context, dir, repoURL, revision string) (string, error) {
+	if revision == "" || revision == "HEAD" {
+		revision = "main"
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "origin", repoURL},
+		{"fetch", "-q", "--depth", "1", "origin", revision},
+		{"checkout", "-q", "FETCH_HEAD"},
+	} {
+		if _, err := run(ctx, dir, "git", args...); err != nil {
+			return "", err
+		}
+	}
+	sha, err := run(ctx, dir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(sha)), nil
+}
+
+// chartVersion reads the version of the Helm chart in dir; empty if there is
+// no readable Chart.yaml.
+func chartVersion(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		return ""
+	}
+	var chart struct {
+		Version string `json:"version"`
+	}
+	if err := yaml.Unmarshal(data, &chart); err != nil {
+		return ""
+	}
+	return chart.Version
+}
+
+// writeOverlay writes a kustomization that builds src with the overrides.
+func writeOverlay(overlay, src string, k *KustomizeOptions) error {
+	rel, err := filepath.Rel(overlay, src)
+	if err != nil {
+		return err
+	}
+	kustomization := map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  []string{filepath.ToSlash(rel)},
+	}
+	if k != nil {
+		if k.NamePrefix != "" {
+			kustomization["namePrefix"] = k.NamePrefix
+		}
+		if k.NameSuffix != "" {
+			kustomization["nameSuffix"] = k.NameSuffix
+		}
+		if len(k.CommonLabels) > 0 {
+			kustomization["commonLabels"] = k.CommonLabels
+		}
+		var images []map[string]string
+		for _, img := range k.Images {
+			name, ref, found := strings.Cut(img, "=")
+			if !found {
+				name, ref = img, img
+			}
+			repo, tag, digest := splitImage(ref)
+			if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+				name = name[:i]
+			}
+			image := map[string]string{"name": name, "newName": repo}
+			if digest != "" {
+				image["digest"] = digest
+			} else {
+				image["newTag"] = tag
+			}
+			images = append(images, image)
+		}
+		if len(images) > 0 {
+			kustomization["images"] = images
+		}
+		if len(k.Patches) > 0 {
+			kustomization["patches"] = kustomizeSource(&KustomizeOptions{Patches: k.Patches})["patches"]
+		}
+	}
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(overlay, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(overlay, "kustomization.yaml"), data, 0o644)
+}
+
+// concatManifests joins the YAML and JSON files under dir, in path order.
+func concatManifests(dir string) ([]byte, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != dir {
+			return filepath.SkipDir
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// run executes a command in dir and returns its stdout.
+func run(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w: %s", name, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -8,6 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 )
 
@@ -65,6 +66,21 @@ func (r *RolloutController) Abort(ctx context.Context, name string) error {
 	return err
 }
 
+// PromoteStep resumes a rollout paused at a canary step, advancing it to the
+// next step the same way `kubectl argo rollouts promote` does.
+func (r *RolloutController) PromoteStep(ctx context.Context, name string) error {
+	res := r.client.Resource(rolloutGVR).Namespace(r.ns)
+	if _, err := res.Patch(ctx, name, types.MergePatchType,
+		[]byte(`{"status":{"pauseConditions":null}}`), metav1.PatchOptions{}, "status"); err != nil {
+		return fmt.Errorf("clear pause conditions of rollout %s: %w", name, err)
+	}
+	if _, err := res.Patch(ctx, name, types.MergePatchType,
+		[]byte(`{"spec":{"paused":false}}`), metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("unpause rollout %s: %w", name, err)
+	}
+	return nil
+}
+
 func parseRolloutStatus(obj *unstructured.Unstructured) (*RolloutStatus, error) {
 	statusRaw, ok := obj.Object["status"]
 	if !ok {
@@ -88,8 +104,17 @@ func parseRolloutStatus(obj *unstructured.Unstructured) (*RolloutStatus, error)
 	if v, ok := raw["stableRS"].(string); ok {
 		s.StableRevision = v
 	}
+	if v, ok := raw["currentPodHash"].(string); ok {
+		s.CanaryRevision = v
+	}
 	if v, ok := raw["currentStepIndex"].(float64); ok {
 		s.CurrentStep = int(v)
 	}
+	if v, ok := raw["abort"].(bool); ok {
+		s.Aborted = v
+	}
+	if steps, found, _ := unstructured.NestedSlice(obj.Object, "spec", "strategy", "canary", "steps"); found {
+		s.TotalSteps = len(steps)
+	}
 	return s, nil
 }
@@ -51,7 +51,10 @@ func (s *SyncController) GetSyncStatus(ctx context.Context, appName string) (*Sy
 	return extractSyncResult(obj), nil
 }
 
-// WaitForSync polls until the sync operation completes or the timeout is reached.
+// WaitForSync polls until the sync operation started by TriggerSync reaches a
+// terminal phase (Succeeded, Failed or Error) or the timeout is reached. Argo
+// CD clears the Application's operation once it has finished it, so a stale
+// phase from an earlier sync is never taken for this one.
 func (s *SyncController) WaitForSync(ctx context.Context, appName string, timeout time.Duration) (*SyncResult, error) {
 	deadline := time.Now().Add(timeout)
 	ticker := time.NewTicker(2 * time.Second)
@@ -65,11 +68,17 @@ func (s *SyncController) WaitForSync(ctx context.Context, appName string, timeou
 			if time.Now().After(deadline) {
 				return nil, fmt.Errorf("timeout waiting for sync of %s after %v", appName, timeout)
 			}
-			result, err := s.GetSyncStatus(ctx, appName)
+			obj, err := s.client.Resource(argoAppGVR).Namespace(s.argoNamespace).
+				Get(ctx, appName, metav1.GetOptions{})
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("failed to get sync status for %s: %w", appName, err)
 			}
-			if result.Status == "Synced" && result.Health != "Progressing" {
+			if _, pending := obj.Object["operation"]; pending {
+				continue
+			}
+			result := extractSyncResult(obj)
+			switch result.Phase {
+			case "Succeeded", "Failed", "Error":
 				return result, nil
 			}
 		}
@@ -82,6 +91,10 @@ func extractSyncResult(obj *unstructured.Unstructured) *SyncResult {
 	result.Status, _, _ = unstructured.NestedString(obj.Object, "status", "sync", "status")
 	result.Health, _, _ = unstructured.NestedString(obj.Object, "status", "health", "status")
 	result.Revision, _, _ = unstructured.NestedString(obj.Object, "status", "sync", "revision")
+	if rev, _, _ := unstructured.NestedString(obj.Object, "status", "operationState", "syncResult", "revision"); rev != "" {
+		result.Revision = rev
+	}
+	result.Phase, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "phase")
 	result.Message, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "message")
 	result.StartedAt, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "startedAt")
 	result.FinishedAt, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "finishedAt")
@@ -11,25 +11,77 @@ type ArgoApp struct {
 	DestNamespace  string // target k8s namespace
 	DestServer     string // target cluster, default "https://kubernetes.default.svc"
 	ValuesOverride map[string]interface{}
-	SyncPolicy     string // manual/auto
+	ValuesObject   map[string]interface{} // structured helm values; replaces ValuesOverride when set
+	ValueFiles     []string               // helm value files, relative to Path
+	Parameters     []HelmParameter        // helm --set parameters, applied over the values
+	SourceType     string                 // helm (default)/kustomize/directory
+	Kustomize      *KustomizeOptions      // kustomize overrides when SourceType is kustomize
+	Manifests      string                 // inline manifests; the direct engine applies them instead of Path
+	SyncPolicy     string                 // manual/auto
 	AutoSync       bool
 	SelfHeal       bool
 	Prune          bool
 }
 
+// HelmOptions are the value files and parameters Argo CD passes to Helm on
+// top of the values.
+type HelmOptions struct {
+	ValueFiles []string        `json:"value_files,omitempty"`
+	Parameters []HelmParameter `json:"parameters,omitempty"`
+}
+
+// HelmParameter is a single Helm value set by path, like helm --set.
+type HelmParameter struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	ForceString bool   `json:"force_string,omitempty"`
+}
+
+// KustomizeOptions are the overrides Argo CD applies on top of a
+// kustomization in the repo.
+type KustomizeOptions struct {
+	Images       []string          `json:"images,omitempty"` // e.g. "nginx=registry.example.com/nginx:1.25"
+	NamePrefix   string            `json:"name_prefix,omitempty"`
+	NameSuffix   string            `json:"name_suffix,omitempty"`
+	CommonLabels map[string]string `json:"common_labels,omitempty"`
+	Patches      []KustomizePatch  `json:"patches,omitempty"`
+}
+
+// KustomizePatch is a strategic merge or JSON6902 patch, applied to the
+// resources matching its target.
+type KustomizePatch struct {
+	Target *KustomizeTarget `json:"target,omitempty"`
+	Patch  string           `json:"patch"`
+}
+
+// KustomizeTarget selects the resources a patch applies to.
+type KustomizeTarget struct {
+	Group         string `json:"group,omitempty"`
+	Version       string `json:"version,omitempty"`
+	Kind          string `json:"kind,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"label_selector,omitempty"`
+}
+
 // SyncResult from a sync operation.
 type SyncResult struct {
 	Status     string // Synced/OutOfSync/Unknown
 	Health     string // Healthy/Degraded/Progressing/Missing/Suspended/Unknown
-	Revision   string
+	Revision   string // commit SHA once the sync has run
+	Phase      string // Running/Succeeded/Failed/Error/Terminating of the operation
 	Message    string
 	StartedAt  string
 	FinishedAt string
+	// ChartVersion is the version of the Helm chart synced, if the engine
+	// knows it.
+	ChartVersion string
 }
 
 // ResourceNode in the resource tree.
 type ResourceNode struct {
 	Group     string
+	Version   string
 	Kind      string
 	Namespace string
 	Name      string
@@ -42,6 +94,17 @@ type ResourceNode struct {
 // ResourceTree for an application.
 type ResourceTree struct {
 	Nodes []ResourceNode
+	Pods  []PodInfo
+}
+
+// PodInfo is a live pod of an application, read from its target cluster.
+type PodInfo struct {
+	Name     string
+	Phase    string
+	Ready    bool
+	Restarts int32
+	Node     string
+	Images   []string
 }
 
 // AppStatus represents the current status of an Argo CD Application.
@@ -68,6 +68,9 @@ func (h *ApprovalHandler) ListPending(c *gin.Context) {
 }
 
 func (h *ApprovalHandler) handleNotFoundOrInternal(c *gin.Context, err error, fallbackNotFound string) {
+	if writeAppError(c, err) {
+		return
+	}
 	if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(strings.ToLower(err.Error()), "record not found") {
 		response.NotFound(c, fallbackNotFound)
 		return
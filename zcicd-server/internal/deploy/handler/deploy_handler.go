@@ -7,10 +7,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/zcicd/zcicd-server/internal/deploy/service"
+	"github.com/zcicd/zcicd-server/pkg/middleware"
 	"github.com/zcicd/zcicd-server/pkg/response"
 	"gorm.io/gorm"
 )
 
+// Roles that may override a deploy check besides admins.
+const (
+	qualityOverrideRole = "quality_admin"
+	vulnOverrideRole    = "security_admin"
+)
+
 type DeployHandler struct {
 	svc *service.DeployService
 }
@@ -28,7 +35,9 @@ func (h *DeployHandler) CreateConfig(c *gin.Context) {
 	projectID := c.Param("project_id")
 	config, err := h.svc.CreateConfig(c.Request.Context(), projectID, req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 	response.Created(c, config)
@@ -100,6 +109,9 @@ func (h *DeployHandler) TriggerSync(c *gin.Context) {
 	if err := c.ShouldBindJSON(&req); err != nil {
 		req = service.TriggerSyncReq{}
 	}
+	if !checkSyncOverrideRoles(c, req.SyncOverrides) {
+		return
+	}
 	userID := c.GetString("user_id")
 	history, err := h.svc.TriggerSync(c.Request.Context(), c.Param("id"), userID, req)
 	if err != nil {
@@ -115,10 +127,13 @@ func (h *DeployHandler) Rollback(c *gin.Context) {
 		response.BadRequest(c, err.Error())
 		return
 	}
+	if !h.checkOverrideRole(c, req.FreezeOverride) {
+		return
+	}
 	userID := c.GetString("user_id")
 	history, err := h.svc.Rollback(c.Request.Context(), c.Param("id"), userID, req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		h.handleNotFoundOrInternal(c, err, "部署记录不存在")
 		return
 	}
 	response.Created(c, history)
@@ -171,8 +186,26 @@ func (h *DeployHandler) GetRolloutStatus(c *gin.Context) {
 	response.OK(c, status)
 }
 
+// RenderStrategy returns the environment's deploy strategy rendered for this config.
+func (h *DeployHandler) RenderStrategy(c *gin.Context) {
+	render, err := h.svc.RenderStrategy(c.Param("id"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "部署配置不存在")
+		return
+	}
+	response.OK(c, render)
+}
+
 func (h *DeployHandler) PromoteRollout(c *gin.Context) {
-	if err := h.svc.PromoteRollout(c.Request.Context(), c.Param("id")); err != nil {
+	var req service.PromoteRolloutReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		req = service.PromoteRolloutReq{}
+	}
+	if !h.checkOverrideRole(c, req.FreezeOverride) {
+		return
+	}
+	userID := c.GetString("user_id")
+	if err := h.svc.PromoteRollout(c.Request.Context(), c.Param("id"), userID, req); err != nil {
 		h.handleNotFoundOrInternal(c, err, "部署配置不存在")
 		return
 	}
@@ -187,6 +220,32 @@ func (h *DeployHandler) AbortRollout(c *gin.Context) {
 	response.OK(c, nil)
 }
 
+// checkOverrideRole rejects freeze overrides requested by non-admin users.
+func (h *DeployHandler) checkOverrideRole(c *gin.Context, override service.FreezeOverride) bool {
+	if override.EmergencyOverride && !middleware.HasRole(c, "admin") {
+		response.Forbidden(c, "紧急放行需要管理员权限")
+		return false
+	}
+	return true
+}
+
+// checkSyncOverrideRoles rejects each override of a sync requested without
+// its role: admin for freezes, admin or quality admin for the quality gate
+// and admin or security admin for the vulnerability policy.
+func checkSyncOverrideRoles(c *gin.Context, o service.SyncOverrides) bool {
+	switch {
+	case o.EmergencyOverride && !middleware.HasRole(c, "admin"):
+		response.Forbidden(c, "紧急放行需要管理员权限")
+	case o.QualityGateOverride.Override && !middleware.HasRole(c, "admin") && !middleware.HasRole(c, qualityOverrideRole):
+		response.Forbidden(c, "放行质量门禁需要管理员或质量管理员权限")
+	case o.VulnPolicyOverride.Override && !middleware.HasRole(c, "admin") && !middleware.HasRole(c, vulnOverrideRole):
+		response.Forbidden(c, "放行漏洞策略需要管理员或安全管理员权限")
+	default:
+		return true
+	}
+	return false
+}
+
 func parsePagination(c *gin.Context) (int, int) {
 	page := 1
 	pageSize := 20
@@ -204,6 +263,9 @@ func parsePagination(c *gin.Context) (int, int) {
 }
 
 func (h *DeployHandler) handleNotFoundOrInternal(c *gin.Context, err error, fallbackNotFound string) {
+	if writeAppError(c, err) {
+		return
+	}
 	if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(strings.ToLower(err.Error()), "record not found") {
 		response.NotFound(c, fallbackNotFound)
 		return
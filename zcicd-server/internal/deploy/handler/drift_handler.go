@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/deploy/service"
+	"github.com/zcicd/zcicd-server/pkg/response"
+)
+
+type DriftHandler struct {
+	svc *service.DriftService
+}
+
+func NewDriftHandler(svc *service.DriftService) *DriftHandler {
+	return &DriftHandler{svc: svc}
+}
+
+func (h *DriftHandler) List(c *gin.Context) {
+	projectID := c.Query("project_id")
+	configID := c.Query("deploy_config_id")
+	if projectID == "" && configID == "" {
+		response.BadRequest(c, "project_id or deploy_config_id is required")
+		return
+	}
+	page, pageSize := parsePagination(c)
+	list, total, err := h.svc.List(projectID, configID, c.Query("status"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+func (h *DriftHandler) Get(c *gin.Context) {
+	drift, err := h.svc.Get(c.Param("id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, drift)
+}
+
+// Reconcile syncs the application back to Git, discarding the live change.
+func (h *DriftHandler) Reconcile(c *gin.Context) {
+	var req service.SyncOverrides
+	if err := c.ShouldBindJSON(&req); err != nil {
+		req = service.SyncOverrides{}
+	}
+	if !checkSyncOverrideRoles(c, req) {
+		return
+	}
+	drift, err := h.svc.Reconcile(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("username"), req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, drift)
+}
+
+// Adopt writes the live change back to the GitOps repository.
+func (h *DriftHandler) Adopt(c *gin.Context) {
+	drift, err := h.svc.Adopt(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("username"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, drift)
+}
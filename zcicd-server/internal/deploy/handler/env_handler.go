@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/zcicd/zcicd-server/internal/deploy/service"
+	"github.com/zcicd/zcicd-server/pkg/middleware"
 	"github.com/zcicd/zcicd-server/pkg/response"
 	"gorm.io/gorm"
 )
@@ -57,12 +58,23 @@ func (h *EnvHandler) UpdateVariable(c *gin.Context) {
 
 func (h *EnvHandler) DeleteVariable(c *gin.Context) {
 	if err := h.svc.DeleteVariable(c.Param("var_id")); err != nil {
-		response.InternalError(c, err.Error())
+		h.handleNotFoundOrInternal(c, err, "环境变量不存在")
 		return
 	}
 	response.OK(c, nil)
 }
 
+// RevealVariable returns the plain value of a secret variable. Only system and
+// project admins may call it, and every call is audited.
+func (h *EnvHandler) RevealVariable(c *gin.Context) {
+	v, err := h.svc.RevealVariable(c.Param("env_id"), c.Param("var_id"), c.GetString("user_id"), c.GetString("username"), middleware.HasRole(c, "admin"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "环境变量不存在")
+		return
+	}
+	response.OK(c, v)
+}
+
 func (h *EnvHandler) BatchUpsertVariables(c *gin.Context) {
 	var req service.BatchEnvVariablesReq
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -99,7 +111,32 @@ func (h *EnvHandler) UpsertQuota(c *gin.Context) {
 	response.OK(c, q)
 }
 
+// GetNamespace reports whether the environment's namespace exists and how its
+// quota objects drifted from the quota record.
+func (h *EnvHandler) GetNamespace(c *gin.Context) {
+	status, err := h.svc.NamespaceStatus(c.Request.Context(), c.Param("env_id"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "环境不存在")
+		return
+	}
+	response.OK(c, status)
+}
+
+// SyncNamespace re-provisions the environment's namespace, overwriting hand edits
+// to its quota objects.
+func (h *EnvHandler) SyncNamespace(c *gin.Context) {
+	status, err := h.svc.SyncNamespace(c.Request.Context(), c.Param("env_id"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "环境不存在")
+		return
+	}
+	response.OK(c, status)
+}
+
 func (h *EnvHandler) handleNotFoundOrInternal(c *gin.Context, err error, fallbackNotFound string) {
+	if writeAppError(c, err) {
+		return
+	}
 	if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(strings.ToLower(err.Error()), "record not found") {
 		response.NotFound(c, fallbackNotFound)
 		return
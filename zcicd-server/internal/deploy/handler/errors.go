@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/response"
+)
+
+// writeAppError renders an AppError with a status matching its code and reports
+// whether err was one. Deploy error codes (407xx) do not follow the range
+// convention used elsewhere, so they are mapped explicitly.
+func writeAppError(c *gin.Context, err error) bool {
+	var appErr *appErrors.AppError
+	if !errors.As(err, &appErr) {
+		return false
+	}
+	response.Error(c, appErrorStatus(appErr.Code), appErr.Code, appErr.Message)
+	return true
+}
+
+func appErrorStatus(code int) int {
+	switch code {
+	case appErrors.ErrDeployFrozen.Code,
+		appErrors.ErrQualityGateFailed.Code,
+		appErrors.ErrVulnPolicyFailed.Code,
+		appErrors.ErrDeployInProgress.Code,
+		appErrors.ErrEnvLocked.Code,
+		appErrors.ErrDriftResolved.Code:
+		return http.StatusConflict
+	case appErrors.ErrFreezeOverrideInvalid.Code,
+		appErrors.ErrFreezeWindowInvalid.Code:
+		return http.StatusBadRequest
+	case appErrors.ErrFreezeWindowNotFound.Code,
+		appErrors.ErrEnvLockNotFound.Code,
+		appErrors.ErrDeployConfigNotFound.Code,
+		appErrors.ErrDeployHistoryNotFound.Code,
+		appErrors.ErrEnvVariableNotFound.Code,
+		appErrors.ErrDriftNotFound.Code:
+		return http.StatusNotFound
+	case appErrors.ErrGitWriteNotConfigured.Code:
+		return http.StatusNotImplemented
+	}
+	switch {
+	case code >= 50000:
+		return http.StatusInternalServerError
+	case code >= 40300 && code < 40400:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
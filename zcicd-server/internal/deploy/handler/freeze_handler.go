@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/deploy/service"
+	"github.com/zcicd/zcicd-server/pkg/response"
+	"gorm.io/gorm"
+)
+
+type FreezeHandler struct {
+	svc *service.FreezeService
+}
+
+func NewFreezeHandler(svc *service.FreezeService) *FreezeHandler {
+	return &FreezeHandler{svc: svc}
+}
+
+func (h *FreezeHandler) List(c *gin.Context) {
+	projectID := c.Query("project_id")
+	if projectID == "" {
+		response.BadRequest(c, "project_id is required")
+		return
+	}
+	list, err := h.svc.ListWindows(projectID)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *FreezeHandler) Create(c *gin.Context) {
+	var req service.CreateFreezeWindowReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	w, err := h.svc.CreateWindow(c.GetString("user_id"), req)
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "项目不存在")
+		return
+	}
+	response.Created(c, w)
+}
+
+func (h *FreezeHandler) Get(c *gin.Context) {
+	w, err := h.svc.GetWindow(c.Param("id"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "冻结窗口不存在")
+		return
+	}
+	response.OK(c, w)
+}
+
+func (h *FreezeHandler) Update(c *gin.Context) {
+	var req service.UpdateFreezeWindowReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	w, err := h.svc.UpdateWindow(c.Param("id"), req)
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "冻结窗口不存在")
+		return
+	}
+	response.OK(c, w)
+}
+
+func (h *FreezeHandler) Delete(c *gin.Context) {
+	if err := h.svc.DeleteWindow(c.Param("id")); err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, nil)
+}
+
+// Upcoming lists freeze occurrences active now or starting within ?days= (default 14).
+func (h *FreezeHandler) Upcoming(c *gin.Context) {
+	projectID := c.Query("project_id")
+	if projectID == "" {
+		response.BadRequest(c, "project_id is required")
+		return
+	}
+	days := 14
+	if d := c.Query("days"); d != "" {
+		if v, err := strconv.Atoi(d); err == nil && v > 0 && v <= 366 {
+			days = v
+		}
+	}
+	list, err := h.svc.Upcoming(projectID, c.Query("environment_id"), days)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *FreezeHandler) handleNotFoundOrInternal(c *gin.Context, err error, fallbackNotFound string) {
+	if writeAppError(c, err) {
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(strings.ToLower(err.Error()), "record not found") {
+		response.NotFound(c, fallbackNotFound)
+		return
+	}
+	lowerErr := strings.ToLower(err.Error())
+	if strings.Contains(lowerErr, "foreign key") || strings.Contains(lowerErr, "violates foreign key constraint") {
+		response.NotFound(c, "关联资源不存在")
+		return
+	}
+	response.InternalError(c, err.Error())
+}
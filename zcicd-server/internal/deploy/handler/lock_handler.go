@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/deploy/service"
+	"github.com/zcicd/zcicd-server/pkg/middleware"
+	"github.com/zcicd/zcicd-server/pkg/response"
+)
+
+type LockHandler struct {
+	svc *service.DeployService
+}
+
+func NewLockHandler(svc *service.DeployService) *LockHandler {
+	return &LockHandler{svc: svc}
+}
+
+// List returns lock holders and queued syncs, filtered by ?project_id= and/or ?environment_id=.
+func (h *LockHandler) List(c *gin.Context) {
+	projectID := c.Query("project_id")
+	envID := c.Query("environment_id")
+	if projectID == "" && envID == "" {
+		response.BadRequest(c, "project_id or environment_id is required")
+		return
+	}
+	list, err := h.svc.ListLocks(projectID, envID)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+// Create places a manual lock on an environment.
+func (h *LockHandler) Create(c *gin.Context) {
+	var req service.CreateEnvLockReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	lock, err := h.svc.LockEnvironment(c.GetString("user_id"), req)
+	if err != nil {
+		if writeAppError(c, err) {
+			return
+		}
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Created(c, lock)
+}
+
+// Delete releases a held lock or cancels a queued sync.
+func (h *LockHandler) Delete(c *gin.Context) {
+	err := h.svc.ReleaseLock(c.Param("id"), c.GetString("user_id"), middleware.HasRole(c, "admin"))
+	if err != nil {
+		if writeAppError(c, err) {
+			return
+		}
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, nil)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zcicd/zcicd-server/internal/deploy/service"
+	"github.com/zcicd/zcicd-server/pkg/response"
+	"gorm.io/gorm"
+)
+
+type PreviewHandler struct {
+	svc *service.PreviewService
+}
+
+func NewPreviewHandler(svc *service.PreviewService) *PreviewHandler {
+	return &PreviewHandler{svc: svc}
+}
+
+func (h *PreviewHandler) List(c *gin.Context) {
+	projectID := c.Query("project_id")
+	if projectID == "" {
+		response.BadRequest(c, "project_id is required")
+		return
+	}
+	page, pageSize := parsePagination(c)
+	list, total, err := h.svc.List(projectID, c.Query("status"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+func (h *PreviewHandler) Get(c *gin.Context) {
+	p, err := h.svc.Get(c.Param("id"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "预览环境不存在")
+		return
+	}
+	response.OK(c, p)
+}
+
+// Close tears down a preview before its pull request is merged or closed.
+func (h *PreviewHandler) Close(c *gin.Context) {
+	p, err := h.svc.Close(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleNotFoundOrInternal(c, err, "预览环境不存在")
+		return
+	}
+	response.OK(c, p)
+}
+
+func (h *PreviewHandler) handleNotFoundOrInternal(c *gin.Context, err error, fallbackNotFound string) {
+	if writeAppError(c, err) {
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.NotFound(c, fallbackNotFound)
+		return
+	}
+	response.InternalError(c, err.Error())
+}
@@ -0,0 +1,15 @@
+package model
+
+// BuildRun is a read-only view of the build_runs table owned by the workflow
+// service, joined with the image repository of its build config.
+type BuildRun struct {
+	ID            string `json:"id"`
+	BuildConfigID string `json:"build_config_id"`
+	Status        string `json:"status"`
+	Branch        string `json:"branch"`
+	CommitSHA     string `json:"commit_sha"`
+	ImageTag      string `json:"image_tag"`
+	ImageDigest   string `json:"image_digest"`
+	ServiceID     string `json:"service_id"`
+	ImageRepo     string `json:"image_repo"`
+}
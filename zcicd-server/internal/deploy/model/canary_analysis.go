@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// CanaryAnalysis is the verdict of one metric analysis of a canary step.
+type CanaryAnalysis struct {
+	ID              string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	DeployHistoryID string         `json:"deploy_history_id" gorm:"type:uuid;not null;index"`
+	StepIndex       int            `json:"step_index"`
+	Verdict         string         `json:"verdict" gorm:"size:16;not null"` // pass, fail, inconclusive
+	Action          string         `json:"action" gorm:"size:16"`           // promote, abort, retry, none
+	CanaryErrorRate *float64       `json:"canary_error_rate"`
+	StableErrorRate *float64       `json:"stable_error_rate"`
+	CanaryLatencyMs *float64       `json:"canary_latency_ms"`
+	StableLatencyMs *float64       `json:"stable_latency_ms"`
+	Reasons         datatypes.JSON `json:"reasons"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+func (CanaryAnalysis) TableName() string { return "deploy_canary_analyses" }
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Cluster is a read-only view of the clusters table owned by the system service.
+type Cluster struct {
+	ID            string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Name          string    `json:"name"`
+	APIServerURL  string    `json:"api_server_url"`
+	KubeConfigRef string    `json:"kube_config_ref"`
+	Status        string    `json:"status"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (Cluster) TableName() string { return "clusters" }
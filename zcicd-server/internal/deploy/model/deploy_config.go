@@ -17,15 +17,23 @@ type DeployConfig struct {
 	TargetRevision string         `json:"target_revision" gorm:"size:128;default:'main'"`
 	ChartPath      string         `json:"chart_path" gorm:"size:256"`
 	ValuesOverride datatypes.JSON `json:"values_override" gorm:"default:'{}'"`
+	Helm           datatypes.JSON `json:"helm"`
+	Kustomize      datatypes.JSON `json:"kustomize"`
 	SyncPolicy     string         `json:"sync_policy" gorm:"size:32;default:'manual'"`
 	AutoSync       bool           `json:"auto_sync" gorm:"default:false"`
 	SelfHeal       bool           `json:"self_heal" gorm:"default:false"`
 	Prune          bool           `json:"prune" gorm:"default:false"`
 	ArgoAppName    string         `json:"argo_app_name" gorm:"size:256;index"`
 	Namespace      string         `json:"namespace" gorm:"size:128"`
+	LockPolicy     string         `json:"lock_policy" gorm:"size:16;default:'queue'"`
 	Status         string         `json:"status" gorm:"size:32;default:'active'"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
+	// Preview settings: a template config is cloned into an ephemeral
+	// environment for each pull request of its service's repository.
+	PreviewTemplate bool      `json:"preview_template"`
+	PreviewDomain   string    `json:"preview_domain" gorm:"size:256"`
+	PreviewTTLHours int       `json:"preview_ttl_hours"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 func (DeployConfig) TableName() string { return "deploy_configs" }
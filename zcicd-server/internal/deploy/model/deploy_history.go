@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 type DeployHistory struct {
 	ID             string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
@@ -17,9 +21,15 @@ type DeployHistory struct {
 	GitopsCommit   string     `json:"gitops_commit" gorm:"size:64"`
 	DiffContent    string     `json:"diff_content" gorm:"type:text"`
 	ErrorMessage   string     `json:"error_message" gorm:"type:text"`
-	CreatedAt      time.Time  `json:"created_at"`
+	// Snapshot of what the deploy applied; written once and restored by rollbacks.
+	ValuesSnapshot datatypes.JSON `json:"values_snapshot"`
+	ImageDigest    string         `json:"image_digest" gorm:"size:128"`
+	ChartVersion   string         `json:"chart_version" gorm:"size:128"`
+	CommitSHA      string         `json:"commit_sha" gorm:"size:64"`
+	CreatedAt      time.Time      `json:"created_at"`
 
-	DeployConfig DeployConfig `json:"deploy_config,omitempty" gorm:"foreignKey:DeployConfigID"`
+	DeployConfig DeployConfig     `json:"deploy_config,omitempty" gorm:"foreignKey:DeployConfigID"`
+	Analyses     []CanaryAnalysis `json:"analyses,omitempty" gorm:"foreignKey:DeployHistoryID"`
 }
 
 func (DeployHistory) TableName() string { return "deploy_histories" }
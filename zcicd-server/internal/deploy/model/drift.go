@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// DeployDrift records an application going OutOfSync without a deploy started
+// by zcicd, e.g. after a kubectl edit in the cluster.
+type DeployDrift struct {
+	ID              string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID       string         `json:"project_id" gorm:"type:uuid;not null;index"`
+	DeployConfigID  string         `json:"deploy_config_id" gorm:"type:uuid;not null;index"`
+	EnvironmentID   string         `json:"environment_id" gorm:"type:uuid"`
+	AppName         string         `json:"app_name" gorm:"size:128;not null"`
+	Revision        string         `json:"revision" gorm:"size:128"`
+	Resources       datatypes.JSON `json:"resources"`
+	Status          string         `json:"status" gorm:"size:16;not null"` // open, reconciled, adopted, resolved
+	ResolvedBy      string         `json:"resolved_by" gorm:"type:uuid"`
+	DeployHistoryID *string        `json:"deploy_history_id" gorm:"type:uuid"`
+	GitopsCommit    string         `json:"gitops_commit" gorm:"size:64"`
+	ErrorMessage    string         `json:"error_message" gorm:"type:text"`
+	DetectedAt      time.Time      `json:"detected_at"`
+	ResolvedAt      *time.Time     `json:"resolved_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+func (DeployDrift) TableName() string { return "deploy_drifts" }
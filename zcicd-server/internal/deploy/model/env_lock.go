@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// EnvLock serializes deploys to an environment. At most one row per environment
+// is "held" (enforced by a partial unique index); later syncs wait as "queued"
+// entries and are dispatched in creation order when the holder releases.
+type EnvLock struct {
+	ID              string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID       string     `json:"project_id" gorm:"type:uuid;not null;index"`
+	EnvironmentID   string     `json:"environment_id" gorm:"type:uuid;not null;index"`
+	Kind            string     `json:"kind" gorm:"size:16;not null"`   // deploy, manual
+	Status          string     `json:"status" gorm:"size:16;not null"` // held, queued, released, expired, cancelled
+	DeployConfigID  *string    `json:"deploy_config_id" gorm:"type:uuid"`
+	DeployHistoryID *string    `json:"deploy_history_id" gorm:"type:uuid"`
+	HolderID        string     `json:"holder_id" gorm:"type:uuid"`
+	Reason          string     `json:"reason" gorm:"type:text"`
+	AcquiredAt      *time.Time `json:"acquired_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	ReleasedAt      *time.Time `json:"released_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (EnvLock) TableName() string { return "deploy_env_locks" }
@@ -2,11 +2,14 @@ package model
 
 import "time"
 
+// EnvVariable is a variable of an environment. Secret values are kept only in
+// VarValueEnc, encrypted with the platform AES key; VarValue is left empty.
 type EnvVariable struct {
 	ID            string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	EnvironmentID string    `json:"environment_id" gorm:"type:uuid;not null;index"`
 	VarKey        string    `json:"var_key" gorm:"size:256;not null"`
 	VarValue      string    `json:"var_value" gorm:"type:text"`
+	VarValueEnc   []byte    `json:"-" gorm:"type:bytea"`
 	IsSecret      bool      `json:"is_secret" gorm:"default:false"`
 	Description   string    `json:"description" gorm:"size:512"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Environment is a read-only view of the environments table owned by the project service.
+type Environment struct {
+	ID             string         `json:"id" gorm:"type:uuid;primaryKey"`
+	ProjectID      string         `json:"project_id" gorm:"type:uuid"`
+	Name           string         `json:"name"`
+	EnvType        string         `json:"env_type"`
+	Namespace      string         `json:"namespace"`
+	ClusterID      *string        `json:"cluster_id" gorm:"type:uuid"`
+	IsProduction   bool           `json:"is_production"`
+	DeployStrategy datatypes.JSON `json:"deploy_strategy"`
+	GlobalEnvVars  datatypes.JSON `json:"global_env_vars"`
+	DeployEngine   string         `json:"deploy_engine"`
+	Status         string         `json:"status"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+func (Environment) TableName() string { return "environments" }
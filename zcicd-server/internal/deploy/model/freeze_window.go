@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// FreezeWindow blocks deploys to a project (or one of its environments) while active.
+// A window is either recurring (cron Recurrence + DurationMinutes) or one-off (StartAt/EndAt).
+type FreezeWindow struct {
+	ID              string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID       string     `json:"project_id" gorm:"type:uuid;not null;index"`
+	EnvironmentID   *string    `json:"environment_id" gorm:"type:uuid;index"`
+	Name            string     `json:"name" gorm:"size:128;not null"`
+	Reason          string     `json:"reason" gorm:"type:text"`
+	Recurrence      string     `json:"recurrence" gorm:"size:128"`
+	DurationMinutes int        `json:"duration_minutes" gorm:"default:0"`
+	StartAt         *time.Time `json:"start_at"`
+	EndAt           *time.Time `json:"end_at"`
+	Timezone        string     `json:"timezone" gorm:"size:64;default:'UTC'"`
+	Enabled         bool       `json:"enabled"`
+	CreatedBy       string     `json:"created_by" gorm:"type:uuid"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (FreezeWindow) TableName() string { return "deploy_freeze_windows" }
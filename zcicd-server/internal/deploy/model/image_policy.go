@@ -0,0 +1,19 @@
+package model
+
+import "gorm.io/datatypes"
+
+// ImagePolicyResult is a read-only view of the image_policy_results table
+// owned by the artifact service, joined with its scan and the policy's
+// block_deploy setting.
+type ImagePolicyResult struct {
+	ID          string         `json:"id"`
+	ScanID      string         `json:"scan_id"`
+	PolicyID    string         `json:"policy_id"`
+	Status      string         `json:"status"`
+	Violations  datatypes.JSON `json:"violations"`
+	BlockDeploy bool           `json:"block_deploy"`
+	MaxSeverity string         `json:"max_severity"`
+	ImageName   string         `json:"image_name"`
+	Tag         string         `json:"tag"`
+	Digest      string         `json:"digest"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// Integration is a read-only view of the integrations table owned by the system service.
+type Integration struct {
+	ID        string `json:"id" gorm:"type:uuid;primaryKey"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Provider  string `json:"provider"`
+	ConfigEnc []byte `json:"-" gorm:"type:bytea"`
+	Status    string `json:"status"`
+}
+
+func (Integration) TableName() string { return "integrations" }
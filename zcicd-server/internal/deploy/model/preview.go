@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// PreviewEnvironment is an ephemeral environment deployed from a template
+// DeployConfig for one pull request. It is torn down when the pull request is
+// merged or closed, or once it has been idle past ExpiresAt.
+type PreviewEnvironment struct {
+	ID               string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID        string     `json:"project_id" gorm:"type:uuid;not null;index"`
+	ServiceID        string     `json:"service_id" gorm:"type:uuid;not null"`
+	TemplateConfigID *string    `json:"template_config_id" gorm:"type:uuid"`
+	EnvironmentID    *string    `json:"environment_id" gorm:"type:uuid"`
+	DeployConfigID   *string    `json:"deploy_config_id" gorm:"type:uuid"`
+	RepoURL          string     `json:"repo_url" gorm:"size:512;not null"`
+	PRNumber         int        `json:"pr_number" gorm:"not null"`
+	Title            string     `json:"title" gorm:"size:512"`
+	Branch           string     `json:"branch" gorm:"size:256;not null"`
+	CommitSHA        string     `json:"commit_sha" gorm:"size:64"`
+	Image            string     `json:"image" gorm:"size:512"`
+	Namespace        string     `json:"namespace" gorm:"size:128;not null"`
+	URL              string     `json:"url" gorm:"size:512"`
+	Status           string     `json:"status" gorm:"size:16;not null"` // pending, deploying, active, failed, closing, closed
+	CloseReason      string     `json:"close_reason" gorm:"size:32"`    // merged, closed, expired, orphaned, manual
+	ErrorMessage     string     `json:"error_message" gorm:"type:text"`
+	LastActivityAt   time.Time  `json:"last_activity_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	ClosedAt         *time.Time `json:"closed_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (PreviewEnvironment) TableName() string { return "deploy_previews" }
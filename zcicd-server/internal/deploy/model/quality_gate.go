@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/datatypes"
+
+// QualityGateResult is a read-only view of the quality_gate_results table
+// owned by the quality service, joined with the block_deploy setting of the
+// project's quality gate.
+type QualityGateResult struct {
+	ID          string         `json:"id"`
+	ProjectID   string         `json:"project_id"`
+	CommitSHA   string         `json:"commit_sha"`
+	Status      string         `json:"status"`
+	Conditions  datatypes.JSON `json:"conditions"`
+	BlockDeploy bool           `json:"block_deploy"`
+}
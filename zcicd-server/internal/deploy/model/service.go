@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/datatypes"
+
+// Service is a read-only view of the services table owned by the project service.
+type Service struct {
+	ID        string         `json:"id" gorm:"type:uuid;primaryKey"`
+	ProjectID string         `json:"project_id" gorm:"type:uuid"`
+	Name      string         `json:"name"`
+	EnvVars   datatypes.JSON `json:"env_vars"`
+	// K8sManifests is the raw YAML deployed by "manifests" deploy configs.
+	K8sManifests string `json:"k8s_manifests"`
+}
+
+func (Service) TableName() string { return "services" }
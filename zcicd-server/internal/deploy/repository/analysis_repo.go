@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
+)
+
+type AnalysisRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalysisRepository(db *gorm.DB) *AnalysisRepository {
+	return &AnalysisRepository{db: db}
+}
+
+func (r *AnalysisRepository) Create(a *model.CanaryAnalysis) error {
+	return r.db.Create(a).Error
+}
+
+func (r *AnalysisRepository) ListByHistory(historyID string) ([]model.CanaryAnalysis, error) {
+	var list []model.CanaryAnalysis
+	err := r.db.Where("deploy_history_id = ?", historyID).Order("created_at").Find(&list).Error
+	return list, err
+}
+
+// GetIntegration reads an integration (e.g. a Prometheus endpoint) configured in the system service.
+func (r *AnalysisRepository) GetIntegration(id string) (*model.Integration, error) {
+	var i model.Integration
+	err := r.db.Where("id = ?", id).First(&i).Error
+	return &i, err
+}
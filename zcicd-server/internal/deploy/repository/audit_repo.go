@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(log *model.AuditLog) error {
+	return r.db.Create(log).Error
+}
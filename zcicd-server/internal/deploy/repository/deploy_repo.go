@@ -55,3 +55,10 @@ func (r *DeployRepository) ListConfigsByEnv(projectID, envID string) ([]model.De
 		Order("created_at DESC").Find(&configs).Error
 	return configs, err
 }
+
+// ListAppConfigs returns the active configs that have an application.
+func (r *DeployRepository) ListAppConfigs() ([]model.DeployConfig, error) {
+	var configs []model.DeployConfig
+	err := r.db.Where("status = ? AND argo_app_name <> ''", "active").Find(&configs).Error
+	return configs, err
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
+)
+
+type DriftRepository struct {
+	db *gorm.DB
+}
+
+func NewDriftRepository(db *gorm.DB) *DriftRepository {
+	return &DriftRepository{db: db}
+}
+
+func (r *DriftRepository) Create(d *model.DeployDrift) error {
+	return r.db.Create(d).Error
+}
+
+func (r *DriftRepository) Update(d *model.DeployDrift) error {
+	return r.db.Save(d).Error
+}
+
+func (r *DriftRepository) Get(id string) (*model.DeployDrift, error) {
+	var d model.DeployDrift
+	err := r.db.Where("id = ?", id).First(&d).Error
+	return &d, err
+}
+
+func (r *DriftRepository) List(projectID, configID, status string, page, pageSize int) ([]model.DeployDrift, int64, error) {
+	var list []model.DeployDrift
+	var total int64
+	query := r.db.Model(&model.DeployDrift{})
+	if projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if configID != "" {
+		query = query.Where("deploy_config_id = ?", configID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Count(&total)
+	err := query.Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("detected_at DESC").Find(&list).Error
+	return list, total, err
+}
+
+// FindOpen returns the open drift of a deploy config.
+func (r *DriftRepository) FindOpen(configID string) (*model.DeployDrift, error) {
+	var d model.DeployDrift
+	err := r.db.Where("deploy_config_id = ? AND status = 'open'", configID).First(&d).Error
+	return &d, err
+}
+
+// HasRecentDeploy reports whether zcicd has a deploy of the config in flight
+// or finished after since; OutOfSync during or right after it is not drift.
+func (r *DriftRepository) HasRecentDeploy(configID string, since time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.DeployHistory{}).
+		Where("deploy_config_id = ?", configID).
+		Where("(status IN ('pending', 'queued', 'syncing') OR finished_at > ? OR created_at > ?)", since, since).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -27,6 +27,47 @@ func (r *EnvRepository) GetVariable(id string) (*model.EnvVariable, error) {
 	return &v, err
 }
 
+func (r *EnvRepository) GetVariableByKey(envID, key string) (*model.EnvVariable, error) {
+	var v model.EnvVariable
+	err := r.db.Where("environment_id = ? AND var_key = ?", envID, key).First(&v).Error
+	return &v, err
+}
+
+// plaintextSecretsLockKey is the advisory lock serializing the encryption of
+// plain-text secret variables across replicas.
+const plaintextSecretsLockKey = 7320401
+
+// SealPlaintextSecrets rewrites the secret variables still stored in plain
+// text with seal, in one transaction holding an advisory lock. A replica that
+// starts while another holds the lock leaves the rows to it and returns 0.
+func (r *EnvRepository) SealPlaintextSecrets(seal func(v *model.EnvVariable) error) (int, error) {
+	sealed := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", plaintextSecretsLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		var vars []model.EnvVariable
+		if err := tx.Where("is_secret AND var_value_enc IS NULL").Find(&vars).Error; err != nil {
+			return err
+		}
+		for i := range vars {
+			if err := seal(&vars[i]); err != nil {
+				return err
+			}
+			if err := tx.Save(&vars[i]).Error; err != nil {
+				return err
+			}
+		}
+		sealed = len(vars)
+		return nil
+	})
+	return sealed, err
+}
+
 func (r *EnvRepository) CreateVariable(v *model.EnvVariable) error {
 	return r.db.Create(v).Error
 }
@@ -44,7 +85,12 @@ func (r *EnvRepository) BatchUpsertVariables(envID string, vars []model.EnvVaria
 		for _, v := range vars {
 			v.EnvironmentID = envID
 			result := tx.Where("environment_id = ? AND var_key = ?", envID, v.VarKey).
-				Assign(model.EnvVariable{VarValue: v.VarValue, IsSecret: v.IsSecret, Description: v.Description}).
+				Assign(map[string]interface{}{
+					"var_value":     v.VarValue,
+					"var_value_enc": v.VarValueEnc,
+					"is_secret":     v.IsSecret,
+					"description":   v.Description,
+				}).
 				FirstOrCreate(&v)
 			if result.Error != nil {
 				return result.Error
@@ -74,3 +120,39 @@ func (r *EnvRepository) UpsertQuota(q *model.EnvResourceQuota) error {
 	q.ID = existing.ID
 	return r.db.Save(q).Error
 }
+
+// Environments (read-only, owned by the project service)
+
+func (r *EnvRepository) GetEnvironment(id string) (*model.Environment, error) {
+	var env model.Environment
+	err := r.db.Where("id = ?", id).First(&env).Error
+	return &env, err
+}
+
+func (r *EnvRepository) GetCluster(id string) (*model.Cluster, error) {
+	var c model.Cluster
+	err := r.db.Where("id = ?", id).First(&c).Error
+	return &c, err
+}
+
+func (r *EnvRepository) GetService(id string) (*model.Service, error) {
+	var svc model.Service
+	err := r.db.Where("id = ?", id).First(&svc).Error
+	return &svc, err
+}
+
+// UpdateServiceManifests writes back the raw manifests of a service, when
+// live changes to them are adopted.
+func (r *EnvRepository) UpdateServiceManifests(id, manifests string) error {
+	return r.db.Model(&model.Service{}).Where("id = ?", id).Update("k8s_manifests", manifests).Error
+}
+
+// HasProjectRole reports whether the user holds role scoped to the project in
+// the user_roles table owned by the auth service.
+func (r *EnvRepository) HasProjectRole(userID, projectID, role string) (bool, error) {
+	var count int64
+	err := r.db.Table("user_roles").
+		Where("user_id = ? AND role = ? AND scope_type = ? AND scope_id = ?", userID, role, "project", projectID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
+)
+
+type FreezeRepository struct {
+	db *gorm.DB
+}
+
+func NewFreezeRepository(db *gorm.DB) *FreezeRepository {
+	return &FreezeRepository{db: db}
+}
+
+func (r *FreezeRepository) Create(w *model.FreezeWindow) error {
+	return r.db.Create(w).Error
+}
+
+func (r *FreezeRepository) Get(id string) (*model.FreezeWindow, error) {
+	var w model.FreezeWindow
+	err := r.db.Where("id = ?", id).First(&w).Error
+	return &w, err
+}
+
+func (r *FreezeRepository) Update(w *model.FreezeWindow) error {
+	return r.db.Save(w).Error
+}
+
+func (r *FreezeRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&model.FreezeWindow{}).Error
+}
+
+func (r *FreezeRepository) ListByProject(projectID string) ([]model.FreezeWindow, error) {
+	var list []model.FreezeWindow
+	err := r.db.Where("project_id = ?", projectID).Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+// ListApplicable returns enabled windows covering the whole project or the given environment.
+func (r *FreezeRepository) ListApplicable(projectID, envID string) ([]model.FreezeWindow, error) {
+	var list []model.FreezeWindow
+	q := r.db.Where("project_id = ? AND enabled = ?", projectID, true)
+	if envID != "" {
+		q = q.Where("environment_id IS NULL OR environment_id = ?", envID)
+	} else {
+		q = q.Where("environment_id IS NULL")
+	}
+	err := q.Order("created_at").Find(&list).Error
+	return list, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
 )
 
 // DeployHistory CRUD (on same repo struct)
@@ -36,3 +37,120 @@ func (r *DeployRepository) GetLatestHistory(configID string) (*model.DeployHisto
 		Order("created_at DESC").First(&h).Error
 	return &h, err
 }
+
+// GetHistoryDetail loads a history together with its canary analysis verdicts.
+func (r *DeployRepository) GetHistoryDetail(id string) (*model.DeployHistory, error) {
+	var h model.DeployHistory
+	err := r.db.Preload("DeployConfig").
+		Preload("Analyses", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Where("id = ?", id).First(&h).Error
+	return &h, err
+}
+
+// FindImageDigest looks up the digest the workflow service recorded for an
+// image tag built for the service.
+func (r *DeployRepository) FindImageDigest(serviceID, imageTag string) (string, error) {
+	var digests []string
+	err := r.db.Table("build_runs").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_configs.service_id = ? AND build_runs.image_tag = ? AND build_runs.status = 'succeeded'", serviceID, imageTag).
+		Where("build_runs.image_digest <> ''").
+		Order("build_runs.created_at DESC").
+		Limit(1).
+		Pluck("build_runs.image_digest", &digests).Error
+	if err != nil || len(digests) == 0 {
+		return "", err
+	}
+	return digests[0], nil
+}
+
+// FindBuildCommit looks up the commit of the build of the service that
+// produced an image, by digest if set, otherwise by tag.
+func (r *DeployRepository) FindBuildCommit(serviceID, imageTag, imageDigest string) (string, error) {
+	q := r.db.Table("build_runs").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_configs.service_id = ? AND build_runs.status = 'succeeded'", serviceID).
+		Where("build_runs.commit_sha <> ''")
+	if imageDigest != "" {
+		q = q.Where("build_runs.image_digest = ?", imageDigest)
+	} else {
+		q = q.Where("build_runs.image_tag = ?", imageTag)
+	}
+	var commits []string
+	err := q.Order("build_runs.created_at DESC").Limit(1).Pluck("build_runs.commit_sha", &commits).Error
+	if err != nil || len(commits) == 0 {
+		return "", err
+	}
+	return commits[0], nil
+}
+
+// FindBuildRun finds the latest successful build of a service that produced
+// an image, with the image repository of its build config.
+func (r *DeployRepository) FindBuildRun(serviceID, imageTag, imageDigest string) (*model.BuildRun, error) {
+	var run model.BuildRun
+	q := r.db.Table("build_runs").
+		Select("build_runs.id, build_runs.build_config_id, build_runs.status, build_runs.branch, build_runs.commit_sha, "+
+			"build_runs.image_tag, build_runs.image_digest, build_configs.service_id, build_configs.image_repo").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_configs.service_id = ? AND build_runs.status = 'succeeded'", serviceID)
+	if imageDigest != "" {
+		q = q.Where("build_runs.image_digest = ?", imageDigest)
+	} else {
+		q = q.Where("build_runs.image_tag = ?", imageTag)
+	}
+	err := q.Order("build_runs.created_at DESC").Take(&run).Error
+	return &run, err
+}
+
+// HasVulnPolicy reports whether an enabled vulnerability policy of the project,
+// or a default one, applies to an environment type.
+func (r *DeployRepository) HasVulnPolicy(projectID, envType string) (bool, error) {
+	var count int64
+	err := r.db.Table("vulnerability_policies").
+		Where("enabled AND env_type = ? AND (project_id = ? OR project_id IS NULL)", envType, projectID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetImagePolicyResult reads the artifact service's result of the latest
+// completed scan of an image against the vulnerability policy of a project
+// for an environment type, or the default policy. The image is matched by
+// digest, or by repository and tag for scans that recorded no digest.
+func (r *DeployRepository) GetImagePolicyResult(projectID, envType, imageRepo, imageTag, imageDigest string) (*model.ImagePolicyResult, error) {
+	var res model.ImagePolicyResult
+	byTag := "image_scans.tag = ? AND COALESCE(image_scans.digest, '') = '' AND " +
+		"(image_scans.image_name = ? OR RIGHT(?, LENGTH(image_scans.image_name) + 1) = '/' || image_scans.image_name)"
+	q := r.db.Table("image_policy_results").
+		Select("image_policy_results.id, image_policy_results.scan_id, image_policy_results.policy_id, "+
+			"image_policy_results.status, image_policy_results.violations, vulnerability_policies.block_deploy, "+
+			"vulnerability_policies.max_severity, image_scans.image_name, image_scans.tag, image_scans.digest").
+		Joins("JOIN image_scans ON image_scans.id = image_policy_results.scan_id").
+		Joins("JOIN vulnerability_policies ON vulnerability_policies.id = image_policy_results.policy_id").
+		Where("image_scans.status = 'completed' AND vulnerability_policies.enabled AND vulnerability_policies.env_type = ?", envType).
+		Where("(vulnerability_policies.project_id = ? OR vulnerability_policies.project_id IS NULL)", projectID)
+	switch {
+	case imageDigest != "" && imageRepo != "":
+		q = q.Where("(image_scans.digest = ? OR ("+byTag+"))", imageDigest, imageTag, imageRepo, imageRepo)
+	case imageDigest != "":
+		q = q.Where("image_scans.digest = ?", imageDigest)
+	default:
+		q = q.Where(byTag, imageTag, imageRepo, imageRepo)
+	}
+	err := q.Order("image_scans.scanned_at DESC, vulnerability_policies.project_id IS NULL").Take(&res).Error
+	return &res, err
+}
+
+// GetQualityGateResult reads the quality service's gate result of a commit:
+// the latest of its branch results, or of its pull request results if it has
+// no branch result.
+func (r *DeployRepository) GetQualityGateResult(projectID, commitSHA string) (*model.QualityGateResult, error) {
+	var res model.QualityGateResult
+	err := r.db.Table("quality_gate_results").
+		Select("quality_gate_results.id, quality_gate_results.project_id, quality_gate_results.commit_sha, "+
+			"quality_gate_results.status, quality_gate_results.conditions, quality_gates.block_deploy").
+		Joins("JOIN quality_gates ON quality_gates.project_id = quality_gate_results.project_id").
+		Where("quality_gate_results.project_id = ? AND quality_gate_results.commit_sha = ?", projectID, commitSHA).
+		Order("quality_gate_results.pull_request IS NOT NULL, quality_gate_results.evaluated_at DESC").
+		Take(&res).Error
+	return &res, err
+}
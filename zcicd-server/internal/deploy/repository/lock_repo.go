@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
+)
+
+type LockRepository struct {
+	db *gorm.DB
+}
+
+func NewLockRepository(db *gorm.DB) *LockRepository {
+	return &LockRepository{db: db}
+}
+
+// Create inserts a lock entry. Inserting a second "held" row for the same
+// environment fails on the partial unique index.
+func (r *LockRepository) Create(lock *model.EnvLock) error {
+	return r.db.Create(lock).Error
+}
+
+func (r *LockRepository) Get(id string) (*model.EnvLock, error) {
+	var lock model.EnvLock
+	err := r.db.Where("id = ?", id).First(&lock).Error
+	return &lock, err
+}
+
+func (r *LockRepository) GetHeld(envID string) (*model.EnvLock, error) {
+	var lock model.EnvLock
+	err := r.db.Where("environment_id = ? AND status = ?", envID, "held").First(&lock).Error
+	return &lock, err
+}
+
+func (r *LockRepository) CountQueued(envID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.EnvLock{}).
+		Where("environment_id = ? AND status = ?", envID, "queued").Count(&count).Error
+	return count, err
+}
+
+// NextQueued returns the oldest queued entry of an environment.
+func (r *LockRepository) NextQueued(envID string) (*model.EnvLock, error) {
+	var lock model.EnvLock
+	err := r.db.Where("environment_id = ? AND status = ?", envID, "queued").
+		Order("created_at").First(&lock).Error
+	return &lock, err
+}
+
+// Transition moves a lock from one status to another and reports whether this
+// call performed the change, so concurrent releases and reapers don't race.
+func (r *LockRepository) Transition(id, from, to string, fields map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	res := r.db.Model(&model.EnvLock{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListActive returns held and queued entries, held first then queue order.
+func (r *LockRepository) ListActive(projectID, envID string) ([]model.EnvLock, error) {
+	var list []model.EnvLock
+	q := r.db.Where("status IN ?", []string{"held", "queued"})
+	if projectID != "" {
+		q = q.Where("project_id = ?", projectID)
+	}
+	if envID != "" {
+		q = q.Where("environment_id = ?", envID)
+	}
+	err := q.Order("environment_id, CASE WHEN status = 'held' THEN 0 ELSE 1 END, created_at").Find(&list).Error
+	return list, err
+}
+
+// ListExpired returns held and queued entries whose expiry has passed.
+func (r *LockRepository) ListExpired(now time.Time) ([]model.EnvLock, error) {
+	var list []model.EnvLock
+	err := r.db.Where("status IN ? AND expires_at < ?", []string{"held", "queued"}, now).Find(&list).Error
+	return list, err
+}
+
+// ListQueuedEnvironments returns environments that have syncs waiting.
+func (r *LockRepository) ListQueuedEnvironments() ([]string, error) {
+	var envIDs []string
+	err := r.db.Model(&model.EnvLock{}).Where("status = ?", "queued").
+		Distinct().Pluck("environment_id", &envIDs).Error
+	return envIDs, err
+}
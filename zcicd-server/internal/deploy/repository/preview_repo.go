@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/gorm"
+)
+
+type PreviewRepository struct {
+	db *gorm.DB
+}
+
+func NewPreviewRepository(db *gorm.DB) *PreviewRepository {
+	return &PreviewRepository{db: db}
+}
+
+func (r *PreviewRepository) Create(p *model.PreviewEnvironment) error {
+	return r.db.Create(p).Error
+}
+
+func (r *PreviewRepository) Update(p *model.PreviewEnvironment) error {
+	return r.db.Save(p).Error
+}
+
+func (r *PreviewRepository) Get(id string) (*model.PreviewEnvironment, error) {
+	var p model.PreviewEnvironment
+	err := r.db.Where("id = ?", id).First(&p).Error
+	return &p, err
+}
+
+func (r *PreviewRepository) List(projectID, status string, page, pageSize int) ([]model.PreviewEnvironment, int64, error) {
+	var previews []model.PreviewEnvironment
+	var total int64
+	q := r.db.Model(&model.PreviewEnvironment{}).Where("project_id = ?", projectID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	q.Count(&total)
+	err := q.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&previews).Error
+	return previews, total, err
+}
+
+// FindOpen returns the open preview of a pull request for a template config.
+func (r *PreviewRepository) FindOpen(templateConfigID, repoURL string, prNumber int) (*model.PreviewEnvironment, error) {
+	var p model.PreviewEnvironment
+	err := r.db.Where("template_config_id = ? AND repo_url = ? AND pr_number = ? AND status NOT IN ?",
+		templateConfigID, repoURL, prNumber, []string{"closing", "closed"}).First(&p).Error
+	return &p, err
+}
+
+// ListOpenByPR returns the open previews of a pull request across templates.
+func (r *PreviewRepository) ListOpenByPR(repoURL string, prNumber int) ([]model.PreviewEnvironment, error) {
+	var previews []model.PreviewEnvironment
+	err := r.db.Where("repo_url = ? AND pr_number = ? AND status NOT IN ?",
+		repoURL, prNumber, []string{"closing", "closed"}).Find(&previews).Error
+	return previews, err
+}
+
+// ListOpenByBranch returns the open previews of a service waiting on builds of a branch.
+func (r *PreviewRepository) ListOpenByBranch(serviceID, branch string) ([]model.PreviewEnvironment, error) {
+	var previews []model.PreviewEnvironment
+	err := r.db.Where("service_id = ? AND branch = ? AND status NOT IN ?",
+		serviceID, branch, []string{"closing", "closed"}).Find(&previews).Error
+	return previews, err
+}
+
+// ListClosing returns previews marked for teardown, by a webhook or by the
+// cron service's reclaimer.
+func (r *PreviewRepository) ListClosing() ([]model.PreviewEnvironment, error) {
+	var previews []model.PreviewEnvironment
+	err := r.db.Where("status = ?", "closing").Find(&previews).Error
+	return previews, err
+}
+
+// ListExpired returns open previews idle past their expiry.
+func (r *PreviewRepository) ListExpired(now time.Time) ([]model.PreviewEnvironment, error) {
+	var previews []model.PreviewEnvironment
+	err := r.db.Where("status NOT IN ? AND expires_at < ?", []string{"closing", "closed"}, now).Find(&previews).Error
+	return previews, err
+}
+
+// ListPreviewTemplates returns the preview template configs of services whose
+// repository is repoURL.
+func (r *PreviewRepository) ListPreviewTemplates(repoURLs []string) ([]model.DeployConfig, error) {
+	var configs []model.DeployConfig
+	err := r.db.Table("deploy_configs").
+		Select("deploy_configs.*").
+		Joins("JOIN services ON services.id = deploy_configs.service_id").
+		Where("deploy_configs.preview_template AND services.repo_url IN ?", repoURLs).
+		Find(&configs).Error
+	return configs, err
+}
+
+// GetBuildRun reads a build run of the workflow service with its image repository.
+func (r *PreviewRepository) GetBuildRun(id string) (*model.BuildRun, error) {
+	var run model.BuildRun
+	err := r.db.Table("build_runs").
+		Select("build_runs.id, build_runs.build_config_id, build_runs.status, build_runs.branch, build_runs.commit_sha, "+
+			"build_runs.image_tag, build_runs.image_digest, build_configs.service_id, build_configs.image_repo").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_runs.id = ?", id).
+		Take(&run).Error
+	return &run, err
+}
+
+// CreateEnvironment inserts a preview environment into the environments table
+// owned by the project service. Previews are the only environments the deploy
+// service creates itself.
+func (r *PreviewRepository) CreateEnvironment(env *model.Environment) error {
+	if env.ID == "" {
+		env.ID = uuid.NewString()
+	}
+	return r.db.Select("ID", "ProjectID", "Name", "EnvType", "Namespace", "ClusterID", "IsProduction", "GlobalEnvVars", "DeployEngine", "Status", "CreatedAt", "UpdatedAt").
+		Create(env).Error
+}
+
+func (r *PreviewRepository) DeleteEnvironment(id string) error {
+	return r.db.Where("id = ? AND env_type = ?", id, "preview").Delete(&model.Environment{}).Error
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/zcicd/zcicd-server/pkg/middleware"
 )
 
-func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, deployH *handler.DeployHandler, approvalH *handler.ApprovalHandler, envH *handler.EnvHandler) {
+func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, deployH *handler.DeployHandler, approvalH *handler.ApprovalHandler, envH *handler.EnvHandler, freezeH *handler.FreezeHandler, lockH *handler.LockHandler, previewH *handler.PreviewHandler, driftH *handler.DriftHandler) {
 	auth := middleware.JWTAuth(jwtSecret)
 
 	deploys := r.Group("/deploys")
@@ -24,6 +24,7 @@ func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, deployH *handler.Deplo
 		deploys.GET("/:id/resources", deployH.GetResources)
 		deploys.GET("/:id/history", deployH.ListHistories)
 		deploys.GET("/:id/history/:history_id", deployH.GetHistory)
+		deploys.GET("/:id/strategy", deployH.RenderStrategy)
 		deploys.GET("/:id/rollout", deployH.GetRolloutStatus)
 		deploys.POST("/:id/rollout/promote", deployH.PromoteRollout)
 		deploys.POST("/:id/rollout/abort", deployH.AbortRollout)
@@ -45,8 +46,47 @@ func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, deployH *handler.Deplo
 		envVars.POST("/:env_id/variables", envH.CreateVariable)
 		envVars.PUT("/:env_id/variables/:var_id", envH.UpdateVariable)
 		envVars.DELETE("/:env_id/variables/:var_id", envH.DeleteVariable)
+		envVars.POST("/:env_id/variables/:var_id/reveal", envH.RevealVariable)
 		envVars.PUT("/:env_id/variables/batch", envH.BatchUpsertVariables)
 		envVars.GET("/:env_id/quota", envH.GetQuota)
 		envVars.PUT("/:env_id/quota", envH.UpsertQuota)
+		envVars.GET("/:env_id/namespace", envH.GetNamespace)
+		envVars.POST("/:env_id/namespace/sync", envH.SyncNamespace)
+	}
+
+	freezes := r.Group("/deploy/freeze-windows")
+	freezes.Use(auth)
+	{
+		freezes.GET("", freezeH.List)
+		freezes.POST("", freezeH.Create)
+		freezes.GET("/upcoming", freezeH.Upcoming)
+		freezes.GET("/:id", freezeH.Get)
+		freezes.PUT("/:id", freezeH.Update)
+		freezes.DELETE("/:id", freezeH.Delete)
+	}
+
+	locks := r.Group("/deploy/locks")
+	locks.Use(auth)
+	{
+		locks.GET("", lockH.List)
+		locks.POST("", lockH.Create)
+		locks.DELETE("/:id", lockH.Delete)
+	}
+
+	previews := r.Group("/deploy/previews")
+	previews.Use(auth)
+	{
+		previews.GET("", previewH.List)
+		previews.GET("/:id", previewH.Get)
+		previews.DELETE("/:id", previewH.Close)
+	}
+
+	drifts := r.Group("/deploy/drifts")
+	drifts.Use(auth)
+	{
+		drifts.GET("", driftH.List)
+		drifts.GET("/:id", driftH.Get)
+		drifts.POST("/:id/reconcile", driftH.Reconcile)
+		drifts.POST("/:id/adopt", driftH.Adopt)
 	}
 }
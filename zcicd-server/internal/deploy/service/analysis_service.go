@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"github.com/zcicd/zcicd-server/pkg/strategy"
+	"gorm.io/datatypes"
+)
+
+// analysisTimeout bounds how long one rollout is watched.
+const analysisTimeout = 6 * time.Hour
+
+// AnalysisService watches canary rollouts and promotes or aborts each paused
+// step based on Prometheus metrics of the canary and stable revisions.
+type AnalysisService struct {
+	analysisRepo *repository.AnalysisRepository
+	envRepo      *repository.EnvRepository
+	deployRepo   *repository.DeployRepository
+	mqClient     *mq.Client
+}
+
+func NewAnalysisService(
+	analysisRepo *repository.AnalysisRepository,
+	envRepo *repository.EnvRepository,
+	deployRepo *repository.DeployRepository,
+	mqClient *mq.Client,
+) *AnalysisService {
+	return &AnalysisService{
+		analysisRepo: analysisRepo,
+		envRepo:      envRepo,
+		deployRepo:   deployRepo,
+		mqClient:     mqClient,
+	}
+}
+
+// prometheusConfig is the config JSON of a Prometheus integration.
+type prometheusConfig struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// Start begins analysing the rollout of a synced deploy if its environment's
+// strategy is a canary with analysis configured. rollouts is the controller of
+// the cluster the config deploys to.
+func (s *AnalysisService) Start(config *model.DeployConfig, history *model.DeployHistory, rollouts *engine.RolloutController) {
+	if rollouts == nil || config.ArgoAppName == "" {
+		return
+	}
+	spec, ok := s.analysisSpec(config.EnvironmentID)
+	if !ok {
+		return
+	}
+	integration, err := s.analysisRepo.GetIntegration(spec.IntegrationID)
+	if err != nil {
+		fmt.Printf("warning: canary analysis for %s: integration %s: %v\n", config.ArgoAppName, spec.IntegrationID, err)
+		return
+	}
+	var pc prometheusConfig
+	if err := json.Unmarshal(integration.ConfigEnc, &pc); err != nil || pc.URL == "" {
+		fmt.Printf("warning: canary analysis for %s: integration %s has no prometheus url\n", config.ArgoAppName, integration.Name)
+		return
+	}
+	analyzer := engine.NewCanaryAnalyzer(engine.NewPrometheusClient(pc.URL, pc.Token))
+	go s.run(config, history, spec, analyzer, rollouts)
+}
+
+// analysisSpec reads the canary analysis section of the environment's deploy strategy.
+func (s *AnalysisService) analysisSpec(envID string) (strategy.AnalysisSpec, bool) {
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return strategy.AnalysisSpec{}, false
+	}
+	st, err := strategy.Parse(env.DeployStrategy)
+	if err != nil || st.Type != strategy.TypeCanary || st.Canary.Analysis == nil {
+		return strategy.AnalysisSpec{}, false
+	}
+	return *st.Canary.Analysis, true
+}
+
+// run polls the rollout and analyses each step it pauses at, until the rollout
+// completes, is aborted, or analysisTimeout passes.
+func (s *AnalysisService) run(config *model.DeployConfig, history *model.DeployHistory, spec strategy.AnalysisSpec, analyzer *engine.CanaryAnalyzer, rollouts *engine.RolloutController) {
+	ctx, cancel := context.WithTimeout(context.Background(), analysisTimeout)
+	defer cancel()
+
+	interval, err := time.ParseDuration(spec.Interval)
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+	maxInconclusive := spec.MaxInconclusive
+	if maxInconclusive <= 0 {
+		maxInconclusive = 3
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	analyzedStep, inconclusive := -1, 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status, err := rollouts.GetStatus(ctx, config.ArgoAppName)
+		if err != nil {
+			fmt.Printf("warning: canary analysis for %s: %v\n", config.ArgoAppName, err)
+			continue
+		}
+		switch {
+		case status.Aborted || status.Phase == "Degraded":
+			s.failHistory(config, history.ID, "rollout aborted: "+status.Message)
+			return
+		case status.Phase == "Healthy" && status.CurrentStep >= status.TotalSteps:
+			return
+		case status.Phase != "Paused" || status.CurrentStep == analyzedStep:
+			continue
+		}
+
+		canary, stable, verdict, err := analyzer.Analyze(ctx, spec, config.Namespace, status.CanaryRevision, status.StableRevision)
+		if err != nil {
+			verdict = engine.AnalysisVerdict{Verdict: "inconclusive", Reasons: []string{err.Error()}}
+		}
+
+		action := "none"
+		switch verdict.Verdict {
+		case "pass":
+			action = "promote"
+			analyzedStep, inconclusive = status.CurrentStep, 0
+			if err := rollouts.PromoteStep(ctx, config.ArgoAppName); err != nil {
+				verdict.Reasons = append(verdict.Reasons, "promote failed: "+err.Error())
+			}
+		case "fail":
+			action = "abort"
+		case "inconclusive":
+			inconclusive++
+			action = "retry"
+			if inconclusive >= maxInconclusive {
+				action = "abort"
+				verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("inconclusive %d times", inconclusive))
+			}
+		}
+		s.record(history.ID, status.CurrentStep, canary, stable, verdict, action)
+
+		if action == "abort" {
+			if err := rollouts.Abort(ctx, config.ArgoAppName); err != nil {
+				fmt.Printf("warning: canary analysis failed to abort %s: %v\n", config.ArgoAppName, err)
+			}
+			s.failHistory(config, history.ID, fmt.Sprintf("canary analysis failed at step %d", status.CurrentStep))
+			return
+		}
+	}
+}
+
+func (s *AnalysisService) record(historyID string, step int, canary, stable engine.CanaryMetrics, verdict engine.AnalysisVerdict, action string) {
+	reasons, _ := json.Marshal(verdict.Reasons)
+	a := &model.CanaryAnalysis{
+		DeployHistoryID: historyID,
+		StepIndex:       step,
+		Verdict:         verdict.Verdict,
+		Action:          action,
+		CanaryErrorRate: canary.ErrorRate,
+		StableErrorRate: stable.ErrorRate,
+		CanaryLatencyMs: canary.LatencyMs,
+		StableLatencyMs: stable.LatencyMs,
+		Reasons:         datatypes.JSON(reasons),
+	}
+	if err := s.analysisRepo.Create(a); err != nil {
+		fmt.Printf("warning: failed to record canary analysis for history %s: %v\n", historyID, err)
+	}
+}
+
+func (s *AnalysisService) failHistory(config *model.DeployConfig, historyID, message string) {
+	history, err := s.deployRepo.GetHistory(historyID)
+	if err != nil {
+		return
+	}
+	history.Status = "failed"
+	history.ErrorMessage = message
+	finished := time.Now()
+	history.FinishedAt = &finished
+	if history.StartedAt != nil {
+		history.Duration = int(finished.Sub(*history.StartedAt).Seconds())
+	}
+	s.deployRepo.UpdateHistory(history)
+	publishDeployEvent(s.mqClient, mq.SubjectDeployFailed, config.ProjectID, history.TriggeredBy, history)
+}
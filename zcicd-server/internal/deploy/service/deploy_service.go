@@ -3,23 +3,35 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/zcicd/zcicd-server/internal/deploy/engine"
 	"github.com/zcicd/zcicd-server/internal/deploy/model"
 	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	"github.com/zcicd/zcicd-server/pkg/crypto"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/k8s"
 	"github.com/zcicd/zcicd-server/pkg/mq"
+	"github.com/zcicd/zcicd-server/pkg/strategy"
 	"gorm.io/datatypes"
 )
 
 type DeployService struct {
 	deployRepo   *repository.DeployRepository
 	approvalRepo *repository.ApprovalRepository
-	appManager   *engine.AppManager
-	syncCtrl     *engine.SyncController
+	envRepo      *repository.EnvRepository
+	freezeSvc    *FreezeService
+	gateSvc      *QualityGateService
+	vulnSvc      *VulnPolicyService
+	lockSvc      *LockService
+	analysisSvc  *AnalysisService
+	argo         engine.DeployEngine
 	rolloutCtrl  *engine.RolloutController
+	clusters     *engine.ClusterRegistry
 	gitopsWriter *engine.GitOpsWriter
+	encryptor    *crypto.Encryptor
 	mqClient     *mq.Client
 	argoNS       string
 }
@@ -27,20 +39,39 @@ type DeployService struct {
 func NewDeployService(
 	deployRepo *repository.DeployRepository,
 	approvalRepo *repository.ApprovalRepository,
+	envRepo *repository.EnvRepository,
+	freezeSvc *FreezeService,
+	gateSvc *QualityGateService,
+	vulnSvc *VulnPolicyService,
+	lockSvc *LockService,
+	analysisSvc *AnalysisService,
 	appManager *engine.AppManager,
 	syncCtrl *engine.SyncController,
 	rolloutCtrl *engine.RolloutController,
+	clusters *engine.ClusterRegistry,
 	gitopsWriter *engine.GitOpsWriter,
+	encryptor *crypto.Encryptor,
 	mqClient *mq.Client,
 	argoNS string,
 ) *DeployService {
+	var argo engine.DeployEngine
+	if appManager != nil && syncCtrl != nil {
+		argo = engine.NewArgoEngine(appManager, syncCtrl)
+	}
 	return &DeployService{
 		deployRepo:   deployRepo,
 		approvalRepo: approvalRepo,
-		appManager:   appManager,
-		syncCtrl:     syncCtrl,
+		envRepo:      envRepo,
+		freezeSvc:    freezeSvc,
+		gateSvc:      gateSvc,
+		vulnSvc:      vulnSvc,
+		lockSvc:      lockSvc,
+		analysisSvc:  analysisSvc,
+		argo:         argo,
 		rolloutCtrl:  rolloutCtrl,
+		clusters:     clusters,
 		gitopsWriter: gitopsWriter,
+		encryptor:    encryptor,
 		mqClient:     mqClient,
 		argoNS:       argoNS,
 	}
@@ -61,12 +92,19 @@ func (s *DeployService) CreateConfig(ctx context.Context, projectID string, req
 		TargetRevision: req.TargetRevision,
 		ChartPath:      req.ChartPath,
 		ValuesOverride: datatypes.JSON(valuesJSON),
+		Helm:           helmJSON(req.Helm),
+		Kustomize:      kustomizeJSON(req.Kustomize),
 		SyncPolicy:     req.SyncPolicy,
 		AutoSync:       req.AutoSync,
 		SelfHeal:       req.SelfHeal,
 		Prune:          req.Prune,
 		ArgoAppName:    argoAppName,
 		Namespace:      req.Namespace,
+		LockPolicy:     req.LockPolicy,
+
+		PreviewTemplate: req.PreviewTemplate,
+		PreviewDomain:   req.PreviewDomain,
+		PreviewTTLHours: req.PreviewTTLHours,
 	}
 	if config.TargetRevision == "" {
 		config.TargetRevision = "main"
@@ -74,17 +112,28 @@ func (s *DeployService) CreateConfig(ctx context.Context, projectID string, req
 	if config.SyncPolicy == "" {
 		config.SyncPolicy = "manual"
 	}
+	if config.LockPolicy == "" {
+		config.LockPolicy = "queue"
+	}
+	if err := s.checkStrategy(config); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewTemplate(config); err != nil {
+		return nil, err
+	}
 
 	if err := s.deployRepo.CreateConfig(config); err != nil {
 		return nil, err
 	}
 
-	// Create Argo CD Application
-	if s.appManager != nil {
-		argoApp := s.buildArgoApp(config, req.ValuesOverride)
-		if err := s.appManager.CreateApp(ctx, argoApp); err != nil {
-			// Log but don't fail — Argo CD might not be available
-			fmt.Printf("warning: failed to create argo app %s: %v\n", argoAppName, err)
+	// Create the application in the environment's deploy engine
+	if eng, err := s.engineFor(ctx, config); err != nil {
+		fmt.Printf("warning: no deploy engine for %s: %v\n", argoAppName, err)
+	} else if eng != nil {
+		argoApp := s.buildArgoApp(ctx, config, req.ValuesOverride)
+		if err := eng.CreateApp(ctx, argoApp); err != nil {
+			// Log but don't fail — the cluster might not be reachable
+			fmt.Printf("warning: failed to create app %s: %v\n", argoAppName, err)
 		}
 	}
 
@@ -96,7 +145,7 @@ func (s *DeployService) GetConfig(id string) (*model.DeployConfig, error) {
 	return s.deployRepo.GetConfig(id)
 }
 
-// UpdateConfig updates a deploy config and its Argo CD Application.
+// UpdateConfig updates a deploy config and its application.
 func (s *DeployService) UpdateConfig(ctx context.Context, id string, req UpdateDeployConfigReq) (*model.DeployConfig, error) {
 	config, err := s.deployRepo.GetConfig(id)
 	if err != nil {
@@ -122,6 +171,12 @@ func (s *DeployService) UpdateConfig(ctx context.Context, id string, req UpdateD
 		valuesJSON, _ := json.Marshal(req.ValuesOverride)
 		config.ValuesOverride = datatypes.JSON(valuesJSON)
 	}
+	if req.Helm != nil {
+		config.Helm = helmJSON(req.Helm)
+	}
+	if req.Kustomize != nil {
+		config.Kustomize = kustomizeJSON(req.Kustomize)
+	}
 	if req.SyncPolicy != "" {
 		config.SyncPolicy = req.SyncPolicy
 	}
@@ -137,36 +192,58 @@ func (s *DeployService) UpdateConfig(ctx context.Context, id string, req UpdateD
 	if req.Namespace != "" {
 		config.Namespace = req.Namespace
 	}
+	if req.LockPolicy != "" {
+		config.LockPolicy = req.LockPolicy
+	}
+	if req.PreviewTemplate != nil {
+		config.PreviewTemplate = *req.PreviewTemplate
+	}
+	if req.PreviewDomain != nil {
+		config.PreviewDomain = *req.PreviewDomain
+	}
+	if req.PreviewTTLHours != nil {
+		config.PreviewTTLHours = *req.PreviewTTLHours
+	}
+	if err := s.checkStrategy(config); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewTemplate(config); err != nil {
+		return nil, err
+	}
 
 	if err := s.deployRepo.UpdateConfig(config); err != nil {
 		return nil, err
 	}
 
-	// Update Argo CD Application
-	if s.appManager != nil && config.ArgoAppName != "" {
+	// Update the application in the environment's deploy engine
+	if eng, err := s.engineFor(ctx, config); err != nil {
+		fmt.Printf("warning: no deploy engine for %s: %v\n", config.ArgoAppName, err)
+	} else if eng != nil {
 		var values map[string]interface{}
 		if req.ValuesOverride != nil {
 			values = req.ValuesOverride
 		}
-		argoApp := s.buildArgoApp(config, values)
-		if err := s.appManager.UpdateApp(ctx, argoApp); err != nil {
-			fmt.Printf("warning: failed to update argo app %s: %v\n", config.ArgoAppName, err)
+		argoApp := s.buildArgoApp(ctx, config, values)
+		if err := eng.UpdateApp(ctx, argoApp); err != nil {
+			fmt.Printf("warning: failed to update app %s: %v\n", config.ArgoAppName, err)
 		}
 	}
 
 	return config, nil
 }
 
-// DeleteConfig deletes a deploy config and its Argo CD Application.
+// DeleteConfig deletes a deploy config and its application.
 func (s *DeployService) DeleteConfig(ctx context.Context, id string) error {
 	config, err := s.deployRepo.GetConfig(id)
 	if err != nil {
 		return err
 	}
 
-	if s.appManager != nil && config.ArgoAppName != "" {
-		if err := s.appManager.DeleteApp(ctx, config.ArgoAppName); err != nil {
-			fmt.Printf("warning: failed to delete argo app %s: %v\n", config.ArgoAppName, err)
+	if eng, err := s.engineFor(ctx, config); err != nil {
+		fmt.Printf("warning: no deploy engine for %s: %v\n", config.ArgoAppName, err)
+	} else if eng != nil {
+		if err := eng.DeleteApp(ctx, config.ArgoAppName); err != nil {
+			fmt.Printf("warning: failed to delete app %s: %v\n", config.ArgoAppName, err)
 		}
 	}
 
@@ -183,28 +260,74 @@ func (s *DeployService) ListConfigsByEnv(projectID, envID string) ([]model.Deplo
 	return s.deployRepo.ListConfigsByEnv(projectID, envID)
 }
 
-// TriggerSync triggers a sync for a deploy config.
+// TriggerSync triggers a sync for a deploy config. If another sync (or a manual
+// lock) holds the environment, the sync is queued and runs once the lock frees up,
+// or is rejected when the config's lock policy is "reject".
 func (s *DeployService) TriggerSync(ctx context.Context, configID, userID string, req TriggerSyncReq) (*model.DeployHistory, error) {
 	config, err := s.deployRepo.GetConfig(configID)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkFreeze(config, userID, "sync", req.FreezeOverride); err != nil {
+		return nil, err
+	}
+	if err := s.checkQualityGate(config, userID, "sync", req.QualityGateOverride); err != nil {
+		return nil, err
+	}
+	if err := s.checkVulnPolicy(config, userID, "sync", req.VulnPolicyOverride); err != nil {
+		return nil, err
+	}
 
-	now := time.Now()
 	history := &model.DeployHistory{
 		DeployConfigID: configID,
 		Revision:       req.Revision,
-		Status:         "syncing",
+		Status:         "pending",
 		SyncStatus:     "OutOfSync",
 		TriggeredBy:    userID,
-		StartedAt:      &now,
 	}
 	if err := s.deployRepo.CreateHistory(history); err != nil {
 		return nil, err
 	}
 
+	if s.lockSvc != nil {
+		lock, held, err := s.lockSvc.AcquireDeploy(config, history.ID, userID, config.LockPolicy)
+		if err != nil {
+			history.Status = "cancelled"
+			history.ErrorMessage = err.Error()
+			s.deployRepo.UpdateHistory(history)
+			return nil, err
+		}
+		if !held {
+			history.Status = "queued"
+			s.deployRepo.UpdateHistory(history)
+			return history, nil
+		}
+		return s.runSync(ctx, config, history, userID, lock)
+	}
+
+	return s.runSync(ctx, config, history, userID, nil)
+}
+
+// runSync writes GitOps values and triggers the sync in the environment's
+// deploy engine for a history whose environment lock is already held. The
+// lock is released once the sync has finished, which for Argo CD is after
+// runSync returns.
+func (s *DeployService) runSync(ctx context.Context, config *model.DeployConfig, history *model.DeployHistory, userID string, lock *model.EnvLock) (*model.DeployHistory, error) {
+	release := lock != nil
+	defer func() {
+		if release {
+			s.releaseLock(lock, "released")
+		}
+	}()
+
+	now := time.Now()
+	history.Status = "syncing"
+	history.StartedAt = &now
+	s.snapshot(config, history)
+	s.deployRepo.UpdateHistory(history)
+
 	// Write values to GitOps repo if override provided
-	if s.gitopsWriter != nil && req.Revision != "" {
+	if s.gitopsWriter != nil && history.Revision != "" {
 		var values map[string]interface{}
 		if len(config.ValuesOverride) > 0 {
 			json.Unmarshal(config.ValuesOverride, &values)
@@ -220,9 +343,62 @@ func (s *DeployService) TriggerSync(ctx context.Context, configID, userID string
 		}
 	}
 
-	// Trigger Argo CD sync
-	if s.syncCtrl != nil && config.ArgoAppName != "" {
-		result, err := s.syncCtrl.TriggerSync(ctx, config.ArgoAppName, req.Revision)
+	// Configs saved before the environment switched strategy may not honor it
+	if err := s.checkStrategy(config); err != nil {
+		history.Status = "failed"
+		history.ErrorMessage = err.Error()
+		finished := time.Now()
+		history.FinishedAt = &finished
+		s.deployRepo.UpdateHistory(history)
+		s.publishEvent(mq.SubjectDeployFailed, config.ProjectID, userID, history)
+		return history, err
+	}
+
+	// Materialize environment variables before the workloads that read them
+	envCfg, envPending, err := s.applyEnv(ctx, config)
+	if err != nil {
+		history.Status = "failed"
+		history.ErrorMessage = fmt.Sprintf("apply env vars: %v", err)
+		finished := time.Now()
+		history.FinishedAt = &finished
+		history.Duration = int(finished.Sub(now).Seconds())
+		s.deployRepo.UpdateHistory(history)
+		s.publishEvent(mq.SubjectDeployFailed, config.ProjectID, userID, history)
+		return history, err
+	}
+
+	// Raw manifests go to the GitOps repo for Argo CD; the direct engine
+	// applies them from the application itself
+	eng, err := s.engineFor(ctx, config)
+	if err == nil && eng == nil && isManifests(config) {
+		err = fmt.Errorf("no cluster available to apply manifests")
+	}
+	if err == nil && isManifests(config) {
+		if _, ok := eng.(*engine.ArgoEngine); ok {
+			err = s.publishManifests(ctx, config, history)
+		}
+	}
+	if err != nil {
+		history.Status = "failed"
+		history.ErrorMessage = err.Error()
+		finished := time.Now()
+		history.FinishedAt = &finished
+		history.Duration = int(finished.Sub(now).Seconds())
+		s.deployRepo.UpdateHistory(history)
+		s.publishEvent(mq.SubjectDeployFailed, config.ProjectID, userID, history)
+		return history, err
+	}
+
+	// Re-render the application so strategy and env var changes in the environment apply
+	if eng != nil {
+		if err := eng.UpdateApp(ctx, s.buildArgoApp(ctx, config, nil)); err != nil {
+			fmt.Printf("warning: failed to refresh app %s: %v\n", config.ArgoAppName, err)
+		}
+	}
+
+	// Trigger the sync
+	if eng != nil {
+		result, err := eng.TriggerSync(ctx, config.ArgoAppName, history.Revision)
 		if err != nil {
 			history.Status = "failed"
 			history.ErrorMessage = err.Error()
@@ -236,6 +412,27 @@ func (s *DeployService) TriggerSync(ctx context.Context, configID, userID string
 		history.SyncStatus = result.Status
 		history.HealthStatus = result.Health
 		history.Revision = result.Revision
+		if s.analysisSvc != nil {
+			if rollouts, err := s.rolloutFor(ctx, config); err == nil {
+				s.analysisSvc.Start(config, history, rollouts)
+			}
+		}
+		var afterSync func()
+		if envPending {
+			afterSync = func() { s.applyPendingEnv(context.Background(), config) }
+		} else {
+			s.rollEnv(ctx, config, envCfg)
+		}
+		if waiter, ok := eng.(engine.SyncWaiter); ok {
+			s.deployRepo.UpdateHistory(history)
+			release = false
+			go s.awaitSync(waiter, config, history, userID, lock, now, mq.SubjectDeploySucceeded, mq.SubjectDeployFailed, afterSync)
+			return history, nil
+		}
+		recordSynced(history, result)
+		if afterSync != nil {
+			afterSync()
+		}
 	}
 
 	// Update status based on sync result
@@ -251,44 +448,144 @@ func (s *DeployService) TriggerSync(ctx context.Context, configID, userID string
 	return history, nil
 }
 
-// Rollback rolls back to a previous deployment.
+// Rollback restores the snapshot of a succeeded deployment: its values
+// (reverting the GitOps values file) with the image pinned to the digest it
+// ran, and syncs the commit it was deployed from.
 func (s *DeployService) Rollback(ctx context.Context, configID, userID string, req RollbackReq) (*model.DeployHistory, error) {
-	prevHistory, err := s.deployRepo.GetHistory(req.HistoryID)
+	config, err := s.deployRepo.GetConfig(configID)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkFreeze(config, userID, "rollback", req.FreezeOverride); err != nil {
+		return nil, err
+	}
 
-	now := time.Now()
+	prevHistory, err := s.deployRepo.GetHistory(req.HistoryID)
+	if err != nil {
+		return nil, appErrors.ErrDeployHistoryNotFound
+	}
+	if prevHistory.DeployConfigID != configID {
+		return nil, appErrors.ErrDeployHistoryNotFound
+	}
+	if prevHistory.Status != "succeeded" {
+		return nil, appErrors.ErrRollbackTargetInvalid
+	}
+
+	// Histories recorded before commit SHAs were kept only carry a revision
+	revision := prevHistory.CommitSHA
+	if revision == "" {
+		revision = prevHistory.Revision
+	}
 	history := &model.DeployHistory{
 		DeployConfigID: configID,
-		Revision:       prevHistory.Revision,
-		Status:         "syncing",
+		Revision:       revision,
+		Status:         "pending",
 		TriggeredBy:    userID,
 		RollbackFrom:   &req.HistoryID,
-		StartedAt:      &now,
+		ImageDigest:    prevHistory.ImageDigest,
+		ChartVersion:   prevHistory.ChartVersion,
+		CommitSHA:      prevHistory.CommitSHA,
+	}
+	if len(prevHistory.ValuesSnapshot) > 0 {
+		pinned, err := pinImageDigest(prevHistory.ValuesSnapshot, prevHistory.ImageDigest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid values snapshot: %w", err)
+		}
+		history.ValuesSnapshot = pinned
 	}
 	if err := s.deployRepo.CreateHistory(history); err != nil {
 		return nil, err
 	}
 
-	config, err := s.deployRepo.GetConfig(configID)
+	var lock *model.EnvLock
+	// Rollbacks are never queued: a rollback that runs later than asked for is
+	// more surprising than one that fails fast on a locked environment.
+	if s.lockSvc != nil {
+		held, _, err := s.lockSvc.AcquireDeploy(config, history.ID, userID, "reject")
+		if err != nil {
+			history.Status = "cancelled"
+			history.ErrorMessage = err.Error()
+			s.deployRepo.UpdateHistory(history)
+			return nil, err
+		}
+		lock = held
+	}
+	release := lock != nil
+	defer func() {
+		if release {
+			s.releaseLock(lock, "released")
+		}
+	}()
+
+	now := time.Now()
+	history.Status = "syncing"
+	history.StartedAt = &now
+	s.deployRepo.UpdateHistory(history)
+
+	fail := func(err error) (*model.DeployHistory, error) {
+		history.Status = "failed"
+		history.ErrorMessage = err.Error()
+		finished := time.Now()
+		history.FinishedAt = &finished
+		history.Duration = int(finished.Sub(now).Seconds())
+		s.deployRepo.UpdateHistory(history)
+		s.publishEvent(mq.SubjectDeployRollback, config.ProjectID, userID, history)
+		return history, err
+	}
+
+	eng, err := s.engineFor(ctx, config)
 	if err != nil {
-		return nil, err
+		return fail(err)
 	}
 
-	if s.syncCtrl != nil && config.ArgoAppName != "" {
-		result, syncErr := s.syncCtrl.TriggerSync(ctx, config.ArgoAppName, prevHistory.Revision)
+	// Histories recorded before snapshots existed only carry a revision.
+	var values map[string]interface{}
+	if len(history.ValuesSnapshot) > 0 {
+		if err := json.Unmarshal(history.ValuesSnapshot, &values); err != nil {
+			return fail(fmt.Errorf("invalid values snapshot: %w", err))
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		config.ValuesOverride = history.ValuesSnapshot
+		if err := s.deployRepo.UpdateConfig(config); err != nil {
+			return fail(err)
+		}
+		if s.gitopsWriter != nil {
+			// The restored app carries the values itself when Git cannot be written
+			commitSHA, err := s.gitopsWriter.UpdateValues(ctx, config.RepoURL, config.TargetRevision, config.ChartPath+"/values.yaml", values)
+			switch {
+			case errors.Is(err, engine.ErrGitWriteNotConfigured):
+				fmt.Printf("warning: gitops values of %s not reverted: %v\n", config.ArgoAppName, err)
+			case err != nil:
+				return fail(fmt.Errorf("revert gitops values: %w", err))
+			default:
+				history.GitopsCommit = commitSHA
+			}
+		}
+		if eng != nil {
+			if err := eng.UpdateApp(ctx, s.buildArgoApp(ctx, config, values)); err != nil {
+				return fail(fmt.Errorf("restore app: %w", err))
+			}
+		}
+	}
+
+	if eng != nil {
+		result, syncErr := eng.TriggerSync(ctx, config.ArgoAppName, revision)
 		if syncErr != nil {
-			history.Status = "failed"
-			history.ErrorMessage = syncErr.Error()
-		} else {
-			history.SyncStatus = result.Status
-			history.HealthStatus = result.Health
-			history.Status = "succeeded"
+			return fail(syncErr)
 		}
-	} else {
-		history.Status = "succeeded"
+		history.SyncStatus = result.Status
+		history.HealthStatus = result.Health
+		if waiter, ok := eng.(engine.SyncWaiter); ok {
+			s.deployRepo.UpdateHistory(history)
+			release = false
+			go s.awaitSync(waiter, config, history, userID, lock, now, mq.SubjectDeployRollback, mq.SubjectDeployRollback, nil)
+			return history, nil
+		}
+		recordSynced(history, result)
 	}
+	history.Status = "succeeded"
 
 	finished := time.Now()
 	history.FinishedAt = &finished
@@ -299,33 +596,158 @@ func (s *DeployService) Rollback(ctx context.Context, configID, userID string, r
 	return history, nil
 }
 
-// GetStatus returns the current deploy status from Argo CD.
+// awaitSync waits for a sync started in Argo CD to finish, records its
+// outcome and only then releases the environment lock, so that the next
+// queued sync never starts on top of it. afterSync, if set, runs once the
+// sync has succeeded.
+func (s *DeployService) awaitSync(waiter engine.SyncWaiter, config *model.DeployConfig, history *model.DeployHistory, userID string, lock *model.EnvLock, started time.Time, succeeded, failed string, afterSync func()) {
+	if lock != nil {
+		defer s.releaseLock(lock, "released")
+	}
+	// Stop waiting before the lock reaper gives up on the lock
+	result, err := waiter.WaitForSync(context.Background(), config.ArgoAppName, deployLockTTL-time.Minute)
+	finished := time.Now()
+	history.FinishedAt = &finished
+	history.Duration = int(finished.Sub(started).Seconds())
+	subject := failed
+	switch {
+	case err != nil:
+		history.Status = "failed"
+		history.ErrorMessage = err.Error()
+	case result.Phase == "Succeeded":
+		history.Status = "succeeded"
+		history.SyncStatus = result.Status
+		history.HealthStatus = result.Health
+		recordSynced(history, result)
+		subject = succeeded
+		if afterSync != nil {
+			afterSync()
+		}
+	default:
+		history.Status = "failed"
+		history.SyncStatus = result.Status
+		history.HealthStatus = result.Health
+		history.ErrorMessage = result.Message
+	}
+	s.deployRepo.UpdateHistory(history)
+	s.publishEvent(subject, config.ProjectID, userID, history)
+}
+
+// snapshot records the values and image digest a deploy applies; the commit
+// SHA and chart version follow from the sync in recordSynced. It is written
+// once; rollbacks start with their target's snapshot.
+func (s *DeployService) snapshot(config *model.DeployConfig, history *model.DeployHistory) {
+	if len(history.ValuesSnapshot) > 0 {
+		return
+	}
+	history.ValuesSnapshot = config.ValuesOverride
+	if len(history.ValuesSnapshot) == 0 {
+		history.ValuesSnapshot = datatypes.JSON("{}")
+	}
+
+	tag, digest := valuesImage(history.ValuesSnapshot)
+	history.ImageDigest = digest
+	if history.ImageDigest == "" && tag != "" && config.ServiceID != "" {
+		digest, err := s.deployRepo.FindImageDigest(config.ServiceID, tag)
+		if err != nil {
+			fmt.Printf("warning: failed to look up digest of %s: %v\n", tag, err)
+		}
+		history.ImageDigest = digest
+	}
+}
+
+// recordSynced records the commit SHA and chart version a finished sync
+// applied, unless the history's snapshot already has them.
+func recordSynced(history *model.DeployHistory, result *engine.SyncResult) {
+	if result.Revision != "" {
+		history.Revision = result.Revision
+		if history.CommitSHA == "" {
+			history.CommitSHA = result.Revision
+		}
+	}
+	if history.ChartVersion == "" {
+		history.ChartVersion = result.ChartVersion
+	}
+}
+
+// pinImageDigest sets image.digest in Helm values, so a restored deploy runs
+// the exact image it ran before even if its tag was pushed again since.
+func pinImageDigest(values datatypes.JSON, digest string) (datatypes.JSON, error) {
+	var v map[string]interface{}
+	if err := json.Unmarshal(values, &v); err != nil {
+		return nil, err
+	}
+	if digest == "" {
+		return values, nil
+	}
+	if v == nil {
+		v = map[string]interface{}{}
+	}
+	image, _ := v["image"].(map[string]interface{})
+	if image == nil {
+		image = map[string]interface{}{}
+	}
+	image["digest"] = digest
+	v["image"] = image
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+// valuesImage returns the image tag and digest set in Helm values.
+func valuesImage(values datatypes.JSON) (tag, digest string) {
+	var v struct {
+		Image struct {
+			Tag    string `json:"tag"`
+			Digest string `json:"digest"`
+		} `json:"image"`
+	}
+	json.Unmarshal(values, &v)
+	return v.Image.Tag, v.Image.Digest
+}
+
+// GetStatus returns the current deploy status from the deploy engine.
 func (s *DeployService) GetStatus(ctx context.Context, configID string) (*engine.AppStatus, error) {
 	config, err := s.deployRepo.GetConfig(configID)
 	if err != nil {
 		return nil, err
 	}
-	if s.appManager == nil || config.ArgoAppName == "" {
+	eng, err := s.engineFor(ctx, config)
+	if err != nil || eng == nil {
 		return &engine.AppStatus{SyncStatus: "Unknown", HealthStatus: "Unknown"}, nil
 	}
-	return s.appManager.GetApp(ctx, config.ArgoAppName)
+	return eng.GetApp(ctx, config.ArgoAppName)
 }
 
-// GetResources returns the resource tree from Argo CD.
+// GetResources returns the resource tree from the deploy engine.
 func (s *DeployService) GetResources(ctx context.Context, configID string) (*engine.ResourceTree, error) {
 	config, err := s.deployRepo.GetConfig(configID)
 	if err != nil {
 		return nil, err
 	}
-	if s.appManager == nil || config.ArgoAppName == "" {
+	eng, err := s.engineFor(ctx, config)
+	if err != nil || eng == nil {
 		return &engine.ResourceTree{}, nil
 	}
-	return s.appManager.GetResourceTree(ctx, config.ArgoAppName)
+	tree, err := eng.GetResourceTree(ctx, config.ArgoAppName)
+	if err != nil {
+		return nil, err
+	}
+	if client, err := s.clusterClient(ctx, config); err != nil {
+		fmt.Printf("warning: no client for the cluster of %s: %v\n", config.ArgoAppName, err)
+	} else if client != nil {
+		if tree.Pods, err = engine.ListAppPods(ctx, client, config.Namespace, config.ArgoAppName); err != nil {
+			fmt.Printf("warning: failed to list pods of %s: %v\n", config.ArgoAppName, err)
+		}
+	}
+	return tree, nil
 }
 
-// GetHistory returns a deploy history by ID.
+// GetHistory returns a deploy history by ID, including canary analysis verdicts.
 func (s *DeployService) GetHistory(id string) (*model.DeployHistory, error) {
-	return s.deployRepo.GetHistory(id)
+	return s.deployRepo.GetHistoryDetail(id)
 }
 
 // ListHistories returns deploy histories for a config.
@@ -333,9 +755,15 @@ func (s *DeployService) ListHistories(configID string, page, pageSize int) ([]mo
 	return s.deployRepo.ListHistories(configID, page, pageSize)
 }
 
-// buildArgoApp converts a DeployConfig to an engine.ArgoApp.
-func (s *DeployService) buildArgoApp(config *model.DeployConfig, values map[string]interface{}) engine.ArgoApp {
-	return engine.ArgoApp{
+// buildArgoApp converts a DeployConfig to an engine.ArgoApp. For helm deploys the
+// environment's deploy strategy and env var wiring are rendered into the values,
+// under the config's own overrides; raw manifests get the strategy's Rollout. The destination is the environment's
+// cluster, which is registered with Argo CD first if needed.
+func (s *DeployService) buildArgoApp(ctx context.Context, config *model.DeployConfig, values map[string]interface{}) engine.ArgoApp {
+	if values == nil && len(config.ValuesOverride) > 0 {
+		json.Unmarshal(config.ValuesOverride, &values)
+	}
+	app := engine.ArgoApp{
 		Name:           config.ArgoAppName,
 		Namespace:      s.argoNS,
 		RepoURL:        config.RepoURL,
@@ -348,6 +776,164 @@ func (s *DeployService) buildArgoApp(config *model.DeployConfig, values map[stri
 		SelfHeal:       config.SelfHeal,
 		Prune:          config.Prune,
 	}
+	if ref, err := s.envCluster(config.EnvironmentID); err != nil {
+		fmt.Printf("warning: cannot resolve cluster of environment %s: %v\n", config.EnvironmentID, err)
+	} else if !engine.IsLocal(ref) {
+		if s.clusters != nil {
+			if err := s.clusters.EnsureArgoCluster(ctx, ref); err != nil {
+				fmt.Printf("warning: %v\n", err)
+			}
+		}
+		app.DestServer = ref.Server
+	}
+	switch {
+	case config.DeployType == "kustomize":
+		app.SourceType = "kustomize"
+		if len(config.Kustomize) > 0 {
+			var k engine.KustomizeOptions
+			if err := json.Unmarshal(config.Kustomize, &k); err != nil {
+				fmt.Printf("warning: invalid kustomize options of %s: %v\n", config.ArgoAppName, err)
+			} else {
+				app.Kustomize = &k
+			}
+		}
+		if envCfg, err := s.resolveEnv(config); err != nil {
+			fmt.Printf("warning: cannot resolve env vars of %s: %v\n", config.ArgoAppName, err)
+		} else if envCfg != nil {
+			if app.Kustomize == nil {
+				app.Kustomize = &engine.KustomizeOptions{}
+			}
+			app.Kustomize.Patches = append(app.Kustomize.Patches, envCfg.KustomizePatches()...)
+		}
+	case isManifests(config):
+		app.SourceType = "directory"
+		if manifests, err := s.deployManifests(config); err != nil {
+			fmt.Printf("warning: cannot read manifests of %s: %v\n", config.ArgoAppName, err)
+		} else {
+			app.Manifests = string(manifests)
+		}
+	case config.DeployType == "helm":
+		if len(config.Helm) > 0 {
+			var h engine.HelmOptions
+			if err := json.Unmarshal(config.Helm, &h); err != nil {
+				fmt.Printf("warning: invalid helm options of %s: %v\n", config.ArgoAppName, err)
+			} else {
+				app.ValueFiles = h.ValueFiles
+				app.Parameters = h.Parameters
+			}
+		}
+		var base map[string]interface{}
+		if st := s.envStrategy(config.EnvironmentID); st != nil && (st.UsesRollout() || st.Rolling != nil) {
+			base = st.HelmValues(config.ArgoAppName)
+		}
+		if envCfg, err := s.resolveEnv(config); err != nil {
+			fmt.Printf("warning: cannot resolve env vars of %s: %v\n", config.ArgoAppName, err)
+		} else if envCfg != nil {
+			base = mergeValues(base, envCfg.HelmValues())
+		}
+		if base != nil {
+			app.ValuesObject = mergeValues(base, values)
+		}
+	}
+	return app
+}
+
+// checkStrategy rejects kustomize configs in an environment whose strategy
+// needs an Argo Rollout, which cannot be added to a kustomization from the
+// outside. Helm charts render it from values, and raw manifests get it
+// appended.
+func (s *DeployService) checkStrategy(config *model.DeployConfig) error {
+	if config.DeployType != "kustomize" {
+		return nil
+	}
+	if st := s.envStrategy(config.EnvironmentID); st != nil && st.UsesRollout() {
+		return appErrors.ErrStrategyUnsupported
+	}
+	return nil
+}
+
+// checkPreviewTemplate rejects raw-manifest preview templates: previews run
+// the pull request's image, which can be set in Helm values and kustomize
+// images but not in a service's raw manifests.
+func checkPreviewTemplate(config *model.DeployConfig) error {
+	if config.PreviewTemplate && isManifests(config) {
+		return appErrors.ErrPreviewTemplateUnsupported
+	}
+	return nil
+}
+
+// envStrategy loads the deploy strategy of an environment, or nil if it has none
+// or it cannot be read.
+func (s *DeployService) envStrategy(envID string) *strategy.Strategy {
+	if s.envRepo == nil {
+		return nil
+	}
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return nil
+	}
+	st, err := strategy.Parse(env.DeployStrategy)
+	if err != nil {
+		fmt.Printf("warning: environment %s has an invalid deploy strategy: %v\n", envID, err)
+		return nil
+	}
+	return st
+}
+
+// RenderStrategy shows what the environment's deploy strategy renders to for a
+// config: helm values for helm deploys, and the Rollout manifest appended to
+// plain manifests.
+func (s *DeployService) RenderStrategy(configID string) (*StrategyRender, error) {
+	config, err := s.deployRepo.GetConfig(configID)
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.envRepo.GetEnvironment(config.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	st, err := strategy.Parse(env.DeployStrategy)
+	if err != nil {
+		return nil, appErrors.New(appErrors.ErrBadRequest.Code, err.Error())
+	}
+	deployment := config.Name
+	if isManifests(config) {
+		if manifests, err := s.serviceManifests(config); err == nil && manifestDeployment(manifests) != "" {
+			deployment = manifestDeployment(manifests)
+		}
+	}
+	return &StrategyRender{
+		Strategy:        st,
+		HelmValues:      st.HelmValues(config.ArgoAppName),
+		RolloutManifest: st.RolloutManifest(config.ArgoAppName, deployment, config.Namespace),
+	}, nil
+}
+
+// mergeValues deep-merges override onto base. The result is normalised through
+// JSON so it is safe to embed in an unstructured object.
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	merged := deepMerge(base, override)
+	data, _ := json.Marshal(merged)
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func deepMerge(base, override map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		if bm, ok := out[k].(map[string]interface{}); ok {
+			if om, ok := v.(map[string]interface{}); ok {
+				out[k] = deepMerge(bm, om)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
 }
 
 // GetRolloutStatus returns the Argo Rollout status for a deploy config.
@@ -356,22 +942,30 @@ func (s *DeployService) GetRolloutStatus(ctx context.Context, configID string) (
 	if err != nil {
 		return nil, err
 	}
-	if s.rolloutCtrl == nil || config.ArgoAppName == "" {
+	if config.ArgoAppName == "" {
+		return &engine.RolloutStatus{Phase: "Unknown"}, nil
+	}
+	rollouts, err := s.rolloutFor(ctx, config)
+	if err != nil {
 		return &engine.RolloutStatus{Phase: "Unknown"}, nil
 	}
-	return s.rolloutCtrl.GetStatus(ctx, config.ArgoAppName)
+	return rollouts.GetStatus(ctx, config.ArgoAppName)
 }
 
 // PromoteRollout promotes a canary/bluegreen rollout.
-func (s *DeployService) PromoteRollout(ctx context.Context, configID string) error {
+func (s *DeployService) PromoteRollout(ctx context.Context, configID, userID string, req PromoteRolloutReq) error {
 	config, err := s.deployRepo.GetConfig(configID)
 	if err != nil {
 		return err
 	}
-	if s.rolloutCtrl == nil || config.ArgoAppName == "" {
-		return fmt.Errorf("rollout controller not available")
+	if err := s.checkFreeze(config, userID, "promote", req.FreezeOverride); err != nil {
+		return err
 	}
-	return s.rolloutCtrl.Promote(ctx, config.ArgoAppName)
+	rollouts, err := s.rolloutFor(ctx, config)
+	if err != nil {
+		return err
+	}
+	return rollouts.Promote(ctx, config.ArgoAppName)
 }
 
 // AbortRollout aborts a canary/bluegreen rollout.
@@ -380,15 +974,358 @@ func (s *DeployService) AbortRollout(ctx context.Context, configID string) error
 	if err != nil {
 		return err
 	}
-	if s.rolloutCtrl == nil || config.ArgoAppName == "" {
-		return fmt.Errorf("rollout controller not available")
+	rollouts, err := s.rolloutFor(ctx, config)
+	if err != nil {
+		return err
+	}
+	return rollouts.Abort(ctx, config.ArgoAppName)
+}
+
+// envCluster resolves the cluster an environment deploys to, or nil for the
+// cluster zcicd runs in.
+func (s *DeployService) envCluster(envID string) (*engine.ClusterRef, error) {
+	if s.envRepo == nil {
+		return nil, nil
+	}
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	return clusterRef(s.envRepo, env)
+}
+
+// clusterRef resolves the cluster record an environment points at.
+func clusterRef(envRepo *repository.EnvRepository, env *model.Environment) (*engine.ClusterRef, error) {
+	if env.ClusterID == nil || *env.ClusterID == "" {
+		return nil, nil
+	}
+	cluster, err := envRepo.GetCluster(*env.ClusterID)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", *env.ClusterID, err)
+	}
+	return &engine.ClusterRef{
+		ID:            cluster.ID,
+		Name:          cluster.Name,
+		Server:        cluster.APIServerURL,
+		KubeConfigRef: cluster.KubeConfigRef,
+		UpdatedAt:     cluster.UpdatedAt,
+	}, nil
+}
+
+// engineFor returns the deploy engine of the config's environment: Argo CD,
+// unless the environment chose direct apply or Argo CD is not installed. It
+// returns nil if the config has no application or no engine is available.
+func (s *DeployService) engineFor(ctx context.Context, config *model.DeployConfig) (engine.DeployEngine, error) {
+	if config.ArgoAppName == "" {
+		return nil, nil
+	}
+	choice := "auto"
+	if s.envRepo != nil {
+		env, err := s.envRepo.GetEnvironment(config.EnvironmentID)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := clusterRef(s.envRepo, env)
+		if err != nil {
+			return nil, err
+		}
+		if err := engine.CheckCluster(ref); err != nil {
+			return nil, err
+		}
+		if env.DeployEngine != "" {
+			choice = env.DeployEngine
+		}
+	}
+	if choice != "direct" && s.argo != nil {
+		return s.argo, nil
+	}
+	if choice == "argocd" {
+		return nil, fmt.Errorf("Argo CD is not installed")
+	}
+	client, err := s.clusterClient(ctx, config)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	namespace := config.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return engine.NewDirectEngine(client, namespace), nil
+}
+
+// clusterClient returns the cached client of the config's target cluster, or
+// nil if no cluster clients are available.
+func (s *DeployService) clusterClient(ctx context.Context, config *model.DeployConfig) (*k8s.K8sClient, error) {
+	if s.clusters == nil {
+		return nil, nil
+	}
+	ref, err := s.envCluster(config.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.clusters.Client(ctx, ref)
+}
+
+// rolloutFor returns a rollout controller for the config's target cluster.
+// Rollouts in remote clusters live in the config's namespace.
+func (s *DeployService) rolloutFor(ctx context.Context, config *model.DeployConfig) (*engine.RolloutController, error) {
+	if config.ArgoAppName == "" {
+		return nil, fmt.Errorf("rollout controller not available")
+	}
+	ref, err := s.envCluster(config.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	if engine.IsLocal(ref) {
+		if s.rolloutCtrl == nil {
+			return nil, fmt.Errorf("rollout controller not available")
+		}
+		return s.rolloutCtrl, nil
+	}
+	if s.clusters == nil {
+		return nil, fmt.Errorf("rollout controller not available")
+	}
+	client, err := s.clusters.Client(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return engine.NewRolloutController(client.DynamicClient, config.Namespace), nil
+}
+
+// checkFreeze rejects the operation if a freeze window is active for the config's environment.
+func (s *DeployService) checkFreeze(config *model.DeployConfig, userID, operation string, override FreezeOverride) error {
+	if s.freezeSvc == nil {
+		return nil
+	}
+	return s.freezeSvc.Check(config, userID, operation, override)
+}
+
+func (s *DeployService) checkQualityGate(config *model.DeployConfig, userID, operation string, override CheckOverride) error {
+	if s.gateSvc == nil {
+		return nil
+	}
+	return s.gateSvc.Check(config, userID, operation, override)
+}
+
+func (s *DeployService) checkVulnPolicy(config *model.DeployConfig, userID, operation string, override CheckOverride) error {
+	if s.vulnSvc == nil {
+		return nil
+	}
+	return s.vulnSvc.Check(config, userID, operation, override)
+}
+
+// releaseLock ends a lock entry and dispatches the next queued sync of its environment.
+func (s *DeployService) releaseLock(lock *model.EnvLock, status string) {
+	if _, err := s.lockSvc.Finish(lock, status); err != nil {
+		fmt.Printf("warning: failed to release env lock %s: %v\n", lock.ID, err)
+	}
+	s.dispatchQueued(lock.EnvironmentID)
+}
+
+// dispatchQueued starts the oldest queued sync of an environment if it is free.
+func (s *DeployService) dispatchQueued(envID string) {
+	next, err := s.lockSvc.PromoteNext(envID)
+	if err != nil {
+		fmt.Printf("warning: failed to promote queued deploy for env %s: %v\n", envID, err)
+		return
+	}
+	if next != nil {
+		go s.runQueued(next)
+	}
+}
+
+// runQueued runs a sync that waited in the environment queue. Freeze windows
+// are checked again since one may have started while the sync was waiting.
+func (s *DeployService) runQueued(lock *model.EnvLock) {
+	if lock.DeployConfigID == nil || lock.DeployHistoryID == nil {
+		s.releaseLock(lock, "released")
+		return
+	}
+	history, err := s.deployRepo.GetHistory(*lock.DeployHistoryID)
+	if err != nil || history.Status != "queued" {
+		s.releaseLock(lock, "released")
+		return
+	}
+	config, err := s.deployRepo.GetConfig(*lock.DeployConfigID)
+	if err != nil {
+		history.Status = "failed"
+		history.ErrorMessage = err.Error()
+		s.deployRepo.UpdateHistory(history)
+		s.releaseLock(lock, "released")
+		return
+	}
+	if err := s.checkFreeze(config, history.TriggeredBy, "sync", FreezeOverride{}); err != nil {
+		history.Status = "cancelled"
+		history.ErrorMessage = err.Error()
+		s.deployRepo.UpdateHistory(history)
+		s.releaseLock(lock, "released")
+		return
+	}
+	if _, err := s.runSync(context.Background(), config, history, history.TriggeredBy, lock); err != nil {
+		fmt.Printf("warning: queued deploy %s failed: %v\n", history.ID, err)
+	}
+}
+
+// StartAppReconciler periodically reconciles the Argo CD Applications of all
+// deploy configs.
+func (s *DeployService) StartAppReconciler(ctx context.Context, interval time.Duration) {
+	if s.argo == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.ReconcileApps(ctx)
+			}
+		}
+	}()
+}
+
+// ReconcileApps re-applies the Argo CD Application of every active config
+// from its DeployConfig, recreating applications deleted by hand and
+// reverting changes to the fields zcicd owns.
+func (s *DeployService) ReconcileApps(ctx context.Context) {
+	if s.argo == nil {
+		return
+	}
+	configs, err := s.deployRepo.ListAppConfigs()
+	if err != nil {
+		fmt.Printf("warning: failed to list deploy configs: %v\n", err)
+		return
+	}
+	for i := range configs {
+		config := &configs[i]
+		eng, err := s.engineFor(ctx, config)
+		if err != nil || eng != s.argo {
+			continue
+		}
+		if err := eng.UpdateApp(ctx, s.buildArgoApp(ctx, config, nil)); err != nil {
+			fmt.Printf("warning: failed to reconcile argo app %s: %v\n", config.ArgoAppName, err)
+		}
+	}
+}
+
+// StartLockReaper periodically expires stale environment locks, closes out the
+// deploys they belonged to, and dispatches queues left without a holder.
+func (s *DeployService) StartLockReaper(ctx context.Context, interval time.Duration) {
+	if s.lockSvc == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.reapLocks()
+			}
+		}
+	}()
+}
+
+func (s *DeployService) reapLocks() {
+	expired, err := s.lockSvc.ListExpired()
+	if err != nil {
+		fmt.Printf("warning: failed to list expired env locks: %v\n", err)
+		return
+	}
+	for i := range expired {
+		lock := &expired[i]
+		ok, err := s.lockSvc.Finish(lock, "expired")
+		if err != nil || !ok || lock.DeployHistoryID == nil {
+			continue
+		}
+		history, err := s.deployRepo.GetHistory(*lock.DeployHistoryID)
+		if err != nil || (history.Status != "queued" && history.Status != "syncing") {
+			continue
+		}
+		if history.Status == "queued" {
+			history.Status = "cancelled"
+			history.ErrorMessage = "timed out waiting for environment lock"
+		} else {
+			history.Status = "failed"
+			history.ErrorMessage = "environment lock expired before sync finished"
+		}
+		finished := time.Now()
+		history.FinishedAt = &finished
+		s.deployRepo.UpdateHistory(history)
+	}
+
+	envIDs, err := s.lockSvc.ListQueuedEnvironments()
+	if err != nil {
+		fmt.Printf("warning: failed to list queued environments: %v\n", err)
+		return
+	}
+	for _, envID := range envIDs {
+		s.dispatchQueued(envID)
+	}
+}
+
+// ListLocks returns environment lock holders and queued syncs.
+func (s *DeployService) ListLocks(projectID, envID string) ([]model.EnvLock, error) {
+	if s.lockSvc == nil {
+		return []model.EnvLock{}, nil
+	}
+	return s.lockSvc.ListActive(projectID, envID)
+}
+
+// LockEnvironment places a manual lock on an environment.
+func (s *DeployService) LockEnvironment(userID string, req CreateEnvLockReq) (*model.EnvLock, error) {
+	if s.lockSvc == nil {
+		return nil, fmt.Errorf("env locks not available")
+	}
+	return s.lockSvc.AcquireManual(userID, req)
+}
+
+// ReleaseLock releases a held lock or cancels a queued sync. Only the holder
+// may do so, except admins, who may also force-release a stuck deploy lock.
+func (s *DeployService) ReleaseLock(lockID, userID string, isAdmin bool) error {
+	if s.lockSvc == nil {
+		return fmt.Errorf("env locks not available")
+	}
+	lock, err := s.lockSvc.Get(lockID)
+	if err != nil {
+		return err
+	}
+	if lock.Status != "held" && lock.Status != "queued" {
+		return appErrors.ErrEnvLockNotFound
 	}
-	return s.rolloutCtrl.Abort(ctx, config.ArgoAppName)
+	if !isAdmin && lock.HolderID != userID {
+		return appErrors.ErrForbidden
+	}
+
+	if lock.Status == "queued" {
+		ok, err := s.lockSvc.Finish(lock, "cancelled")
+		if err != nil || !ok {
+			return err
+		}
+		if lock.DeployHistoryID != nil {
+			if history, err := s.deployRepo.GetHistory(*lock.DeployHistoryID); err == nil && history.Status == "queued" {
+				history.Status = "cancelled"
+				history.ErrorMessage = "cancelled by " + userID
+				s.deployRepo.UpdateHistory(history)
+			}
+		}
+		return nil
+	}
+	s.releaseLock(lock, "released")
+	return nil
 }
 
 // publishEvent publishes a NATS event.
 func (s *DeployService) publishEvent(subject, projectID, userID string, history *model.DeployHistory) {
-	if s.mqClient == nil {
+	publishDeployEvent(s.mqClient, subject, projectID, userID, history)
+}
+
+func publishDeployEvent(mqClient *mq.Client, subject, projectID, userID string, history *model.DeployHistory) {
+	if mqClient == nil {
 		return
 	}
 	event := mq.Event{
@@ -399,5 +1336,5 @@ func (s *DeployService) publishEvent(subject, projectID, userID string, history
 		Payload:     history,
 	}
 	data, _ := json.Marshal(event)
-	s.mqClient.Publish(subject, data)
+	mqClient.Publish(subject, data)
 }
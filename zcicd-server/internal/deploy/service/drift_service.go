@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// driftGrace is how long after a zcicd deploy an OutOfSync app is still
+// considered to be converging rather than drifted.
+const driftGrace = 2 * time.Minute
+
+// DriftService records applications that go OutOfSync without a deploy
+// started by zcicd, and resolves them by reconciling the cluster to Git or by
+// adopting the live change into Git.
+type DriftService struct {
+	driftRepo    *repository.DriftRepository
+	deployRepo   *repository.DeployRepository
+	auditRepo    *repository.AuditRepository
+	deploySvc    *DeployService
+	gitopsWriter *engine.GitOpsWriter
+	mqClient     *mq.Client
+
+	mu       sync.Mutex
+	lastSync map[string]string
+}
+
+func NewDriftService(
+	driftRepo *repository.DriftRepository,
+	deployRepo *repository.DeployRepository,
+	auditRepo *repository.AuditRepository,
+	deploySvc *DeployService,
+	gitopsWriter *engine.GitOpsWriter,
+	mqClient *mq.Client,
+) *DriftService {
+	return &DriftService{
+		driftRepo:    driftRepo,
+		deployRepo:   deployRepo,
+		auditRepo:    auditRepo,
+		deploySvc:    deploySvc,
+		gitopsWriter: gitopsWriter,
+		mqClient:     mqClient,
+		lastSync:     map[string]string{},
+	}
+}
+
+// HandleAppStatus is the HealthMonitor callback. It acts on sync status
+// transitions only, since Argo CD updates Applications far more often.
+func (s *DriftService) HandleAppStatus(appName string, status engine.AppStatus) {
+	s.mu.Lock()
+	prev := s.lastSync[appName]
+	s.lastSync[appName] = status.SyncStatus
+	s.mu.Unlock()
+	if prev == status.SyncStatus {
+		return
+	}
+	switch status.SyncStatus {
+	case "OutOfSync":
+		go s.detect(appName, status)
+	case "Synced":
+		go s.resolve(appName)
+	}
+}
+
+// detect records a drift unless the app is OutOfSync because of zcicd: a
+// deploy in flight or just finished, or config changes not yet deployed.
+func (s *DriftService) detect(appName string, status engine.AppStatus) {
+	config, err := s.deployRepo.GetConfigByArgoApp(appName)
+	if err != nil {
+		return
+	}
+	if _, err := s.driftRepo.FindOpen(config.ID); err == nil {
+		return
+	}
+	if recent, err := s.driftRepo.HasRecentDeploy(config.ID, time.Now().Add(-driftGrace)); err != nil || recent {
+		return
+	}
+	last, err := s.deployRepo.GetLatestHistory(config.ID)
+	if err != nil {
+		// Never deployed by zcicd, so there is no desired state to drift from.
+		return
+	}
+	deployedAt := last.CreatedAt
+	if last.FinishedAt != nil {
+		deployedAt = *last.FinishedAt
+	}
+	if config.UpdatedAt.After(deployedAt) {
+		return
+	}
+
+	ctx := context.Background()
+	resources := engine.OutOfSyncResources(&status)
+	if client, err := s.deploySvc.clusterClient(ctx, config); err != nil {
+		fmt.Printf("warning: drift of %s: cluster client: %v\n", appName, err)
+	} else if client != nil {
+		engine.CaptureLive(ctx, client, resources)
+	}
+	resourcesJSON, _ := json.Marshal(resources)
+	drift := &model.DeployDrift{
+		ProjectID:      config.ProjectID,
+		DeployConfigID: config.ID,
+		EnvironmentID:  config.EnvironmentID,
+		AppName:        appName,
+		Revision:       status.Revision,
+		Resources:      datatypes.JSON(resourcesJSON),
+		Status:         "open",
+		DetectedAt:     time.Now(),
+	}
+	if err := s.driftRepo.Create(drift); err != nil {
+		// Another replica recorded it first.
+		return
+	}
+	s.publish(drift, "")
+}
+
+// resolve closes the open drift of an app that returned to Synced on its own,
+// e.g. through Argo CD self-heal.
+func (s *DriftService) resolve(appName string) {
+	config, err := s.deployRepo.GetConfigByArgoApp(appName)
+	if err != nil {
+		return
+	}
+	drift, err := s.driftRepo.FindOpen(config.ID)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	drift.Status = "resolved"
+	drift.ResolvedAt = &now
+	s.driftRepo.Update(drift)
+}
+
+// Reconcile syncs the application back to the state in Git, discarding the
+// live change.
+func (s *DriftService) Reconcile(ctx context.Context, id, userID, username string, overrides SyncOverrides) (*model.DeployDrift, error) {
+	drift, err := s.openDrift(id)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.deploySvc.TriggerSync(ctx, drift.DeployConfigID, userID, TriggerSyncReq{SyncOverrides: overrides})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	drift.Status = "reconciled"
+	drift.ResolvedBy = userID
+	drift.ResolvedAt = &now
+	drift.DeployHistoryID = &history.ID
+	if err := s.driftRepo.Update(drift); err != nil {
+		return nil, err
+	}
+	s.audit(drift, userID, username, "deploy_drift.reconcile", map[string]interface{}{"deploy_history_id": history.ID})
+	return drift, nil
+}
+
+// Adopt writes the live change back to Git so that it becomes the desired
+// state. Helm deploys take the live replica count and image into the config's
+// values; plain-manifest deploys replace the drifted resources in the
+// service's manifests with their live state. Kustomize sources are only
+// changed in their own repo.
+func (s *DriftService) Adopt(ctx context.Context, id, userID, username string) (*model.DeployDrift, error) {
+	drift, err := s.openDrift(id)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.deploySvc.GetConfig(drift.DeployConfigID)
+	if err != nil {
+		return nil, err
+	}
+	if s.gitopsWriter == nil {
+		return nil, appErrors.New(appErrors.ErrExternalService.Code, "GitOps 仓库未配置")
+	}
+	if config.DeployType != "helm" && !isManifests(config) {
+		return nil, appErrors.New(appErrors.ErrBadRequest.Code, "该部署类型的漂移无法自动回写，请在仓库中修改")
+	}
+
+	// Re-read the live state; it may have changed since detection.
+	var resources []engine.DriftedResource
+	json.Unmarshal(drift.Resources, &resources)
+	client, err := s.deploySvc.clusterClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		engine.CaptureLive(ctx, client, resources)
+	}
+
+	var commitSHA string
+	if config.DeployType == "helm" {
+		live := engine.WorkloadValues(resources)
+		if len(live) == 0 {
+			return nil, appErrors.New(appErrors.ErrBadRequest.Code, "漂移内容无法映射为 Helm values")
+		}
+		var values map[string]interface{}
+		if len(config.ValuesOverride) > 0 {
+			json.Unmarshal(config.ValuesOverride, &values)
+		}
+		values = mergeValues(values, live)
+		commitSHA, err = s.gitopsWriter.UpdateValues(ctx, config.RepoURL, config.TargetRevision, config.ChartPath+"/values.yaml", values)
+		if err != nil {
+			return nil, gitWriteError(err)
+		}
+		if _, err := s.deploySvc.UpdateConfig(ctx, config.ID, UpdateDeployConfigReq{ValuesOverride: values}); err != nil {
+			return nil, err
+		}
+	} else {
+		commitSHA, err = s.deploySvc.adoptManifests(ctx, config, resources)
+		if err != nil {
+			return nil, gitWriteError(err)
+		}
+	}
+
+	now := time.Now()
+	resourcesJSON, _ := json.Marshal(resources)
+	drift.Resources = datatypes.JSON(resourcesJSON)
+	drift.Status = "adopted"
+	drift.ResolvedBy = userID
+	drift.ResolvedAt = &now
+	drift.GitopsCommit = commitSHA
+	if err := s.driftRepo.Update(drift); err != nil {
+		return nil, err
+	}
+	s.audit(drift, userID, username, "deploy_drift.adopt", map[string]interface{}{"gitops_commit": commitSHA})
+	s.publish(drift, userID)
+	return drift, nil
+}
+
+// Get returns a drift by ID.
+func (s *DriftService) Get(id string) (*model.DeployDrift, error) {
+	drift, err := s.driftRepo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrDriftNotFound
+	}
+	return drift, err
+}
+
+// List returns drifts filtered by project, deploy config and status.
+func (s *DriftService) List(projectID, configID, status string, page, pageSize int) ([]model.DeployDrift, int64, error) {
+	return s.driftRepo.List(projectID, configID, status, page, pageSize)
+}
+
+func (s *DriftService) openDrift(id string) (*model.DeployDrift, error) {
+	drift, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if drift.Status != "open" {
+		return nil, appErrors.ErrDriftResolved
+	}
+	return drift, nil
+}
+
+func (s *DriftService) audit(drift *model.DeployDrift, userID, username, action string, extra map[string]interface{}) {
+	if s.auditRepo == nil {
+		return
+	}
+	extra["deploy_config_id"] = drift.DeployConfigID
+	detail, _ := json.Marshal(extra)
+	if err := s.auditRepo.Create(&model.AuditLog{
+		UserID:       userID,
+		Username:     username,
+		Action:       action,
+		ResourceType: "deploy_drift",
+		ResourceID:   drift.ID,
+		ResourceName: drift.AppName,
+		ProjectID:    drift.ProjectID,
+		Detail:       datatypes.JSON(detail),
+	}); err != nil {
+		fmt.Printf("warning: failed to audit %s of drift %s: %v\n", action, drift.ID, err)
+	}
+}
+
+// publish emits a drift event for notifications, on detection and adoption.
+func (s *DriftService) publish(drift *model.DeployDrift, userID string) {
+	if s.mqClient == nil {
+		return
+	}
+	data, _ := json.Marshal(mq.Event{
+		EventType:   mq.SubjectDeployDrift,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		ProjectID:   drift.ProjectID,
+		TriggeredBy: userID,
+		Payload:     drift,
+	})
+	s.mqClient.Publish(mq.SubjectDeployDrift, data)
+}
+
+// gitWriteError maps a GitOps writer without git to its AppError.
+func gitWriteError(err error) error {
+	if errors.Is(err, engine.ErrGitWriteNotConfigured) {
+		return appErrors.ErrGitWriteNotConfigured
+	}
+	return err
+}
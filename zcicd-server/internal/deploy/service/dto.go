@@ -1,45 +1,132 @@
 package service
 
+import (
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/pkg/strategy"
+)
+
 // DeployConfig DTOs
 
 type CreateDeployConfigReq struct {
-	ServiceID      string                 `json:"service_id" binding:"required"`
-	EnvironmentID  string                 `json:"environment_id" binding:"required"`
-	Name           string                 `json:"name" binding:"required"`
-	DeployType     string                 `json:"deploy_type" binding:"required,oneof=helm kustomize yaml"`
-	RepoURL        string                 `json:"repo_url" binding:"required"`
-	TargetRevision string                 `json:"target_revision"`
-	ChartPath      string                 `json:"chart_path"`
-	ValuesOverride map[string]interface{} `json:"values_override"`
-	SyncPolicy     string                 `json:"sync_policy" binding:"omitempty,oneof=manual auto"`
-	AutoSync       bool                   `json:"auto_sync"`
-	SelfHeal       bool                   `json:"self_heal"`
-	Prune          bool                   `json:"prune"`
-	Namespace      string                 `json:"namespace"`
+	ServiceID      string                   `json:"service_id" binding:"required"`
+	EnvironmentID  string                   `json:"environment_id" binding:"required"`
+	Name           string                   `json:"name" binding:"required"`
+	DeployType     string                   `json:"deploy_type" binding:"required,oneof=helm kustomize manifests yaml"`
+	RepoURL        string                   `json:"repo_url" binding:"required"`
+	TargetRevision string                   `json:"target_revision"`
+	ChartPath      string                   `json:"chart_path"`
+	ValuesOverride map[string]interface{}   `json:"values_override"`
+	Helm           *engine.HelmOptions      `json:"helm"`
+	Kustomize      *engine.KustomizeOptions `json:"kustomize"`
+	SyncPolicy     string                   `json:"sync_policy" binding:"omitempty,oneof=manual auto"`
+	AutoSync       bool                     `json:"auto_sync"`
+	SelfHeal       bool                     `json:"self_heal"`
+	Prune          bool                     `json:"prune"`
+	Namespace      string                   `json:"namespace"`
+	LockPolicy     string                   `json:"lock_policy" binding:"omitempty,oneof=queue reject"`
+	// Preview template settings
+	PreviewTemplate bool   `json:"preview_template"`
+	PreviewDomain   string `json:"preview_domain"`
+	PreviewTTLHours int    `json:"preview_ttl_hours" binding:"omitempty,min=1,max=720"`
 }
 
 type UpdateDeployConfigReq struct {
-	Name           string                 `json:"name"`
-	DeployType     string                 `json:"deploy_type" binding:"omitempty,oneof=helm kustomize yaml"`
-	RepoURL        string                 `json:"repo_url"`
-	TargetRevision string                 `json:"target_revision"`
-	ChartPath      string                 `json:"chart_path"`
-	ValuesOverride map[string]interface{} `json:"values_override"`
-	SyncPolicy     string                 `json:"sync_policy" binding:"omitempty,oneof=manual auto"`
-	AutoSync       *bool                  `json:"auto_sync"`
-	SelfHeal       *bool                  `json:"self_heal"`
-	Prune          *bool                  `json:"prune"`
-	Namespace      string                 `json:"namespace"`
+	Name           string                   `json:"name"`
+	DeployType     string                   `json:"deploy_type" binding:"omitempty,oneof=helm kustomize manifests yaml"`
+	RepoURL        string                   `json:"repo_url"`
+	TargetRevision string                   `json:"target_revision"`
+	ChartPath      string                   `json:"chart_path"`
+	ValuesOverride map[string]interface{}   `json:"values_override"`
+	Helm           *engine.HelmOptions      `json:"helm"`
+	Kustomize      *engine.KustomizeOptions `json:"kustomize"`
+	SyncPolicy     string                   `json:"sync_policy" binding:"omitempty,oneof=manual auto"`
+	AutoSync       *bool                    `json:"auto_sync"`
+	SelfHeal       *bool                    `json:"self_heal"`
+	Prune          *bool                    `json:"prune"`
+	Namespace      string                   `json:"namespace"`
+	LockPolicy     string                   `json:"lock_policy" binding:"omitempty,oneof=queue reject"`
+	// Preview template settings
+	PreviewTemplate *bool   `json:"preview_template"`
+	PreviewDomain   *string `json:"preview_domain"`
+	PreviewTTLHours *int    `json:"preview_ttl_hours" binding:"omitempty,min=1,max=720"`
 }
 
 // Deploy Sync/Rollback DTOs
 
 type TriggerSyncReq struct {
 	Revision string `json:"revision"`
+	SyncOverrides
 }
 
 type RollbackReq struct {
 	HistoryID string `json:"history_id" binding:"required"`
+	FreezeOverride
+}
+
+type PromoteRolloutReq struct {
+	FreezeOverride
+}
+
+// FreezeOverride lets an admin deploy during an active freeze window. The
+// justification is recorded in the audit log.
+type FreezeOverride struct {
+	EmergencyOverride bool   `json:"emergency_override"`
+	Justification     string `json:"justification"`
+}
+
+// SyncOverrides are the overrides of the checks run before a sync. Each is
+// requested on its own and needs its own role.
+type SyncOverrides struct {
+	FreezeOverride
+	QualityGateOverride CheckOverride `json:"quality_gate_override"`
+	VulnPolicyOverride  CheckOverride `json:"vuln_policy_override"`
+}
+
+// CheckOverride lets a sync deploy a commit whose quality gate failed or an
+// image failing its vulnerability policy. The justification is recorded in
+// the audit log.
+type CheckOverride struct {
+	Override      bool   `json:"override"`
+	Justification string `json:"justification"`
+}
+
+// Freeze Window DTOs
+
+type CreateFreezeWindowReq struct {
+	ProjectID       string     `json:"project_id" binding:"required"`
+	EnvironmentID   *string    `json:"environment_id"`
+	Name            string     `json:"name" binding:"required"`
+	Reason          string     `json:"reason"`
+	Recurrence      string     `json:"recurrence"`
+	DurationMinutes int        `json:"duration_minutes"`
+	StartAt         *time.Time `json:"start_at"`
+	EndAt           *time.Time `json:"end_at"`
+	Timezone        string     `json:"timezone"`
+	Enabled         *bool      `json:"enabled"`
+}
+
+type UpdateFreezeWindowReq struct {
+	EnvironmentID   *string    `json:"environment_id"`
+	Name            string     `json:"name"`
+	Reason          string     `json:"reason"`
+	Recurrence      *string    `json:"recurrence"`
+	DurationMinutes *int       `json:"duration_minutes"`
+	StartAt         *time.Time `json:"start_at"`
+	EndAt           *time.Time `json:"end_at"`
+	Timezone        string     `json:"timezone"`
+	Enabled         *bool      `json:"enabled"`
+}
+
+// FreezeOccurrence is one concrete activation of a FreezeWindow.
+type FreezeOccurrence struct {
+	WindowID      string    `json:"window_id"`
+	Name          string    `json:"name"`
+	Reason        string    `json:"reason"`
+	EnvironmentID *string   `json:"environment_id"`
+	StartAt       time.Time `json:"start_at"`
+	EndAt         time.Time `json:"end_at"`
 }
 
 // Approval DTOs
@@ -75,3 +162,21 @@ type EnvResourceQuotaReq struct {
 	PodLimit      int    `json:"pod_limit"`
 	StorageLimit  string `json:"storage_limit"`
 }
+
+// Environment Lock DTOs
+
+type CreateEnvLockReq struct {
+	ProjectID     string `json:"project_id" binding:"required"`
+	EnvironmentID string `json:"environment_id" binding:"required"`
+	Reason        string `json:"reason" binding:"required"`
+	TTLMinutes    int    `json:"ttl_minutes"`
+}
+
+// Deploy Strategy DTOs
+
+// StrategyRender is the environment's deploy strategy as rendered for one config.
+type StrategyRender struct {
+	Strategy        *strategy.Strategy     `json:"strategy"`
+	HelmValues      map[string]interface{} `json:"helm_values"`
+	RolloutManifest map[string]interface{} `json:"rollout_manifest"`
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"gorm.io/datatypes"
+)
+
+// envVarEntry is one element of the list form of Environment.GlobalEnvVars and
+// Service.EnvVars. Both columns also accept a plain {"KEY": "value"} object.
+type envVarEntry struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	IsSecret bool   `json:"is_secret"`
+}
+
+func parseEnvVarsJSON(raw datatypes.JSON) ([]envVarEntry, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var list []envVarEntry
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for i := range list {
+			if list[i].Key == "" {
+				list[i].Key = list[i].Name
+			}
+		}
+		return list, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("env vars must be a list or an object")
+	}
+	list = make([]envVarEntry, 0, len(obj))
+	for k, v := range obj {
+		value, ok := v.(string)
+		if !ok {
+			b, _ := json.Marshal(v)
+			value = string(b)
+		}
+		list = append(list, envVarEntry{Key: k, Value: value})
+	}
+	return list, nil
+}
+
+// resolveEnv merges the variables a deploy sees: the environment's global vars,
+// overridden by the service's, overridden by the environment's EnvVariable rows.
+// They go to the config's namespace, or else the environment's. It returns nil
+// if there is nothing to resolve against, no variable, or no namespace.
+func (s *DeployService) resolveEnv(config *model.DeployConfig) (*engine.EnvConfig, error) {
+	if s.envRepo == nil || config.ArgoAppName == "" {
+		return nil, nil
+	}
+	env, err := s.envRepo.GetEnvironment(config.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	cfg := engine.NewEnvConfig(config.ArgoAppName)
+	cfg.Namespace = config.Namespace
+	if cfg.Namespace == "" {
+		cfg.Namespace = env.Namespace
+	}
+
+	global, err := parseEnvVarsJSON(env.GlobalEnvVars)
+	if err != nil {
+		return nil, fmt.Errorf("environment %s global_env_vars: %w", env.Name, err)
+	}
+	for _, v := range global {
+		if v.Key != "" {
+			cfg.Set(v.Key, v.Value, v.IsSecret)
+		}
+	}
+
+	if config.ServiceID != "" {
+		svc, err := s.envRepo.GetService(config.ServiceID)
+		if err != nil {
+			return nil, err
+		}
+		vars, err := parseEnvVarsJSON(svc.EnvVars)
+		if err != nil {
+			return nil, fmt.Errorf("service %s env_vars: %w", svc.Name, err)
+		}
+		for _, v := range vars {
+			if v.Key != "" {
+				cfg.Set(v.Key, v.Value, v.IsSecret)
+			}
+		}
+	}
+
+	rows, err := s.envRepo.ListVariables(config.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		value, err := plainValue(s.encryptor, &rows[i])
+		if err != nil {
+			return nil, err
+		}
+		cfg.Set(rows[i].VarKey, value, rows[i].IsSecret)
+	}
+	if cfg.Empty() {
+		return nil, nil
+	}
+	if cfg.Namespace == "" {
+		fmt.Printf("warning: %s has env vars but neither it nor its environment has a namespace\n", config.ArgoAppName)
+		return nil, nil
+	}
+	return cfg, nil
+}
+
+// applyEnv materializes the resolved variables into their namespace of the
+// config's target cluster. It does nothing when no cluster client is
+// available. When the namespace does not exist yet, since the sync creates
+// it, the variables are returned as pending to be applied after the sync.
+func (s *DeployService) applyEnv(ctx context.Context, config *model.DeployConfig) (cfg *engine.EnvConfig, pending bool, err error) {
+	cfg, err = s.resolveEnv(config)
+	if err != nil || cfg == nil {
+		return nil, false, err
+	}
+	client, err := s.clusterClient(ctx, config)
+	if err != nil || client == nil {
+		return nil, false, err
+	}
+	if err := engine.ApplyEnvConfig(ctx, client, cfg); errors.Is(err, engine.ErrNamespaceNotFound) {
+		return cfg, true, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return cfg, false, nil
+}
+
+// applyPendingEnv applies variables whose namespace did not exist before the
+// sync created it, and rolls the workloads that started without them.
+func (s *DeployService) applyPendingEnv(ctx context.Context, config *model.DeployConfig) {
+	cfg, pending, err := s.applyEnv(ctx, config)
+	switch {
+	case err != nil:
+		fmt.Printf("warning: failed to apply env vars of %s: %v\n", config.ArgoAppName, err)
+	case pending:
+		fmt.Printf("warning: namespace %s of %s still missing after sync, env vars not applied\n", cfg.Namespace, config.ArgoAppName)
+	default:
+		s.rollEnv(ctx, config, cfg)
+	}
+}
+
+// rollEnv restarts plain-manifest and kustomize workloads whose variables
+// changed while their source, which carries the checksum, was not re-synced.
+// Helm deploys roll through the checksum in their values instead.
+func (s *DeployService) rollEnv(ctx context.Context, config *model.DeployConfig, cfg *engine.EnvConfig) {
+	if cfg == nil || config.DeployType == "helm" {
+		return
+	}
+	client, err := s.clusterClient(ctx, config)
+	if err != nil || client == nil {
+		return
+	}
+	if err := engine.RollEnvChecksum(ctx, client, cfg.Namespace, config.ArgoAppName, cfg.Checksum()); err != nil {
+		fmt.Printf("warning: failed to roll %s for changed env vars: %v\n", config.ArgoAppName, err)
+	}
+}
+
+// RefreshEnv re-applies the variables of an environment after they changed and
+// rolls its auto-synced deploys. Manually synced deploys pick the change up on
+// their next sync.
+func (s *DeployService) RefreshEnv(ctx context.Context, envID string) {
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return
+	}
+	configs, err := s.deployRepo.ListConfigsByEnv(env.ProjectID, envID)
+	if err != nil {
+		fmt.Printf("warning: failed to list deploy configs of environment %s: %v\n", envID, err)
+		return
+	}
+	for i := range configs {
+		config := &configs[i]
+		if !config.AutoSync || config.ArgoAppName == "" {
+			continue
+		}
+		envCfg, pending, err := s.applyEnv(ctx, config)
+		if err != nil {
+			fmt.Printf("warning: failed to apply env vars of %s: %v\n", config.ArgoAppName, err)
+			continue
+		}
+		if pending {
+			// Not deployed yet; the first sync applies them
+			continue
+		}
+		if eng, err := s.engineFor(ctx, config); err != nil {
+			fmt.Printf("warning: no deploy engine for %s: %v\n", config.ArgoAppName, err)
+		} else if eng != nil {
+			if err := eng.UpdateApp(ctx, s.buildArgoApp(ctx, config, nil)); err != nil {
+				fmt.Printf("warning: failed to refresh app %s: %v\n", config.ArgoAppName, err)
+			}
+		}
+		s.rollEnv(ctx, config, envCfg)
+	}
+}
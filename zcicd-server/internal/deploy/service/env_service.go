@@ -1,35 +1,80 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+
 	"github.com/zcicd/zcicd-server/internal/deploy/model"
 	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	"github.com/zcicd/zcicd-server/pkg/crypto"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/datatypes"
 )
 
+// maskedValue replaces secret values in API responses. Sending it back
+// unchanged on update keeps the stored secret.
+const maskedValue = "******"
+
 type EnvService struct {
-	envRepo *repository.EnvRepository
+	envRepo   *repository.EnvRepository
+	auditRepo *repository.AuditRepository
+	encryptor *crypto.Encryptor
+	deploySvc *DeployService
+	nsSvc     *NamespaceService
 }
 
-func NewEnvService(envRepo *repository.EnvRepository) *EnvService {
-	return &EnvService{envRepo: envRepo}
+func NewEnvService(
+	envRepo *repository.EnvRepository,
+	auditRepo *repository.AuditRepository,
+	encryptor *crypto.Encryptor,
+	deploySvc *DeployService,
+	nsSvc *NamespaceService,
+) *EnvService {
+	return &EnvService{
+		envRepo:   envRepo,
+		auditRepo: auditRepo,
+		encryptor: encryptor,
+		deploySvc: deploySvc,
+		nsSvc:     nsSvc,
+	}
+}
+
+// changed rolls the environment's auto-synced deploys in the background.
+func (s *EnvService) changed(envID string) {
+	if s.deploySvc != nil {
+		go s.deploySvc.RefreshEnv(context.Background(), envID)
+	}
 }
 
 // Variables
 
 func (s *EnvService) ListVariables(envID string) ([]model.EnvVariable, error) {
-	return s.envRepo.ListVariables(envID)
+	vars, err := s.envRepo.ListVariables(envID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vars {
+		maskVariable(&vars[i])
+	}
+	return vars, nil
 }
 
 func (s *EnvService) CreateVariable(envID string, req EnvVariableReq) (*model.EnvVariable, error) {
 	v := &model.EnvVariable{
 		EnvironmentID: envID,
 		VarKey:        req.VarKey,
-		VarValue:      req.VarValue,
 		IsSecret:      req.IsSecret,
 		Description:   req.Description,
 	}
+	if err := s.seal(v, req.VarValue); err != nil {
+		return nil, err
+	}
 	if err := s.envRepo.CreateVariable(v); err != nil {
 		return nil, err
 	}
+	s.changed(envID)
+	maskVariable(v)
 	return v, nil
 }
 
@@ -38,31 +83,162 @@ func (s *EnvService) UpdateVariable(id string, req EnvVariableReq) (*model.EnvVa
 	if err != nil {
 		return nil, err
 	}
+	value, err := s.incomingValue(v, req.VarValue)
+	if err != nil {
+		return nil, err
+	}
 	v.VarKey = req.VarKey
-	v.VarValue = req.VarValue
 	v.IsSecret = req.IsSecret
 	v.Description = req.Description
+	if err := s.seal(v, value); err != nil {
+		return nil, err
+	}
 	if err := s.envRepo.UpdateVariable(v); err != nil {
 		return nil, err
 	}
+	s.changed(v.EnvironmentID)
+	maskVariable(v)
 	return v, nil
 }
 
 func (s *EnvService) DeleteVariable(id string) error {
-	return s.envRepo.DeleteVariable(id)
+	v, err := s.envRepo.GetVariable(id)
+	if err != nil {
+		return err
+	}
+	if err := s.envRepo.DeleteVariable(id); err != nil {
+		return err
+	}
+	s.changed(v.EnvironmentID)
+	return nil
 }
 
 func (s *EnvService) BatchUpsertVariables(envID string, req BatchEnvVariablesReq) error {
 	vars := make([]model.EnvVariable, len(req.Variables))
-	for i, v := range req.Variables {
+	for i, r := range req.Variables {
+		value := r.VarValue
+		if existing, err := s.envRepo.GetVariableByKey(envID, r.VarKey); err == nil {
+			if value, err = s.incomingValue(existing, r.VarValue); err != nil {
+				return err
+			}
+		}
 		vars[i] = model.EnvVariable{
-			VarKey:      v.VarKey,
-			VarValue:    v.VarValue,
-			IsSecret:    v.IsSecret,
-			Description: v.Description,
+			VarKey:      r.VarKey,
+			IsSecret:    r.IsSecret,
+			Description: r.Description,
+		}
+		if err := s.seal(&vars[i], value); err != nil {
+			return err
 		}
 	}
-	return s.envRepo.BatchUpsertVariables(envID, vars)
+	if err := s.envRepo.BatchUpsertVariables(envID, vars); err != nil {
+		return err
+	}
+	s.changed(envID)
+	return nil
+}
+
+// RevealVariable returns the plain value of a variable to a system or project
+// admin and records the access in the audit log.
+func (s *EnvService) RevealVariable(envID, varID, userID, username string, isAdmin bool) (*model.EnvVariable, error) {
+	v, err := s.envRepo.GetVariable(varID)
+	if err != nil || v.EnvironmentID != envID {
+		return nil, appErrors.ErrEnvVariableNotFound
+	}
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		ok, err := s.envRepo.HasProjectRole(userID, env.ProjectID, "admin")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, appErrors.New(appErrors.ErrForbidden.Code, "仅项目管理员可查看密钥变量")
+		}
+	}
+	value, err := plainValue(s.encryptor, v)
+	if err != nil {
+		return nil, err
+	}
+
+	detail, _ := json.Marshal(map[string]interface{}{
+		"environment_id": envID,
+		"var_key":        v.VarKey,
+	})
+	if err := s.auditRepo.Create(&model.AuditLog{
+		UserID:       userID,
+		Username:     username,
+		Action:       "env_variable.reveal",
+		ResourceType: "env_variable",
+		ResourceID:   v.ID,
+		ResourceName: v.VarKey,
+		ProjectID:    env.ProjectID,
+		Detail:       datatypes.JSON(detail),
+	}); err != nil {
+		return nil, err
+	}
+	v.VarValue = value
+	v.VarValueEnc = nil
+	return v, nil
+}
+
+// EncryptPlaintextSecrets encrypts secret variables stored before values were
+// encrypted, and returns how many it converted. Replicas starting together
+// are serialized by an advisory lock; all rows convert or none do.
+func (s *EnvService) EncryptPlaintextSecrets() (int, error) {
+	return s.envRepo.SealPlaintextSecrets(func(v *model.EnvVariable) error {
+		return s.seal(v, v.VarValue)
+	})
+}
+
+// incomingValue resolves the value sent for an existing variable: the masked
+// placeholder stands for the stored secret.
+func (s *EnvService) incomingValue(existing *model.EnvVariable, sent string) (string, error) {
+	if sent != maskedValue || !existing.IsSecret {
+		return sent, nil
+	}
+	return plainValue(s.encryptor, existing)
+}
+
+// seal stores value in the variable, encrypted if it is secret.
+func (s *EnvService) seal(v *model.EnvVariable, value string) error {
+	if !v.IsSecret {
+		v.VarValue, v.VarValueEnc = value, nil
+		return nil
+	}
+	if s.encryptor == nil {
+		return fmt.Errorf("secret variables require crypto.aes_key to be configured")
+	}
+	enc, err := s.encryptor.Encrypt([]byte(value))
+	if err != nil {
+		return err
+	}
+	v.VarValue, v.VarValueEnc = "", enc
+	return nil
+}
+
+// plainValue returns the value of a variable, decrypting secrets. Secrets not
+// yet encrypted by EncryptPlaintextSecrets are returned as stored.
+func plainValue(encryptor *crypto.Encryptor, v *model.EnvVariable) (string, error) {
+	if !v.IsSecret || len(v.VarValueEnc) == 0 {
+		return v.VarValue, nil
+	}
+	if encryptor == nil {
+		return "", fmt.Errorf("cannot decrypt variable %s: crypto.aes_key is not configured", v.VarKey)
+	}
+	plain, err := encryptor.Decrypt(v.VarValueEnc)
+	if err != nil {
+		return "", fmt.Errorf("decrypt variable %s: %w", v.VarKey, err)
+	}
+	return string(plain), nil
+}
+
+func maskVariable(v *model.EnvVariable) {
+	if v.IsSecret {
+		v.VarValue = maskedValue
+	}
 }
 
 // Resource Quotas
@@ -81,8 +257,37 @@ func (s *EnvService) UpsertQuota(envID string, req EnvResourceQuotaReq) (*model.
 		PodLimit:      req.PodLimit,
 		StorageLimit:  req.StorageLimit,
 	}
+	if err := quotaLimits(q).Validate(); err != nil {
+		return nil, appErrors.New(appErrors.ErrBadRequest.Code, err.Error())
+	}
 	if err := s.envRepo.UpsertQuota(q); err != nil {
 		return nil, err
 	}
+	if s.nsSvc != nil {
+		go func() {
+			if err := s.nsSvc.ApplyQuota(context.Background(), envID); err != nil {
+				fmt.Printf("warning: failed to apply quota of environment %s: %v\n", envID, err)
+			}
+		}()
+	}
 	return q, nil
 }
+
+// Namespaces
+
+func (s *EnvService) NamespaceStatus(ctx context.Context, envID string) (*NamespaceStatus, error) {
+	if s.nsSvc == nil {
+		return nil, fmt.Errorf("kubernetes is not available")
+	}
+	return s.nsSvc.Status(ctx, envID)
+}
+
+func (s *EnvService) SyncNamespace(ctx context.Context, envID string) (*NamespaceStatus, error) {
+	if s.nsSvc == nil {
+		return nil, fmt.Errorf("kubernetes is not available")
+	}
+	if err := s.nsSvc.ApplyQuota(ctx, envID); err != nil {
+		return nil, err
+	}
+	return s.nsSvc.Status(ctx, envID)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// maxOccurrencesPerWindow caps how many recurrences are expanded for one window.
+const maxOccurrencesPerWindow = 200
+
+type FreezeService struct {
+	freezeRepo *repository.FreezeRepository
+	auditRepo  *repository.AuditRepository
+}
+
+func NewFreezeService(freezeRepo *repository.FreezeRepository, auditRepo *repository.AuditRepository) *FreezeService {
+	return &FreezeService{freezeRepo: freezeRepo, auditRepo: auditRepo}
+}
+
+func (s *FreezeService) CreateWindow(userID string, req CreateFreezeWindowReq) (*model.FreezeWindow, error) {
+	w := &model.FreezeWindow{
+		ProjectID:       req.ProjectID,
+		EnvironmentID:   req.EnvironmentID,
+		Name:            req.Name,
+		Reason:          req.Reason,
+		Recurrence:      strings.TrimSpace(req.Recurrence),
+		DurationMinutes: req.DurationMinutes,
+		StartAt:         req.StartAt,
+		EndAt:           req.EndAt,
+		Timezone:        req.Timezone,
+		Enabled:         true,
+		CreatedBy:       userID,
+	}
+	if w.Timezone == "" {
+		w.Timezone = "UTC"
+	}
+	if req.Enabled != nil {
+		w.Enabled = *req.Enabled
+	}
+	if err := validateFreezeWindow(w); err != nil {
+		return nil, err
+	}
+	if err := s.freezeRepo.Create(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *FreezeService) GetWindow(id string) (*model.FreezeWindow, error) {
+	w, err := s.freezeRepo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrFreezeWindowNotFound
+	}
+	return w, err
+}
+
+func (s *FreezeService) UpdateWindow(id string, req UpdateFreezeWindowReq) (*model.FreezeWindow, error) {
+	w, err := s.GetWindow(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.EnvironmentID != nil {
+		if *req.EnvironmentID == "" {
+			w.EnvironmentID = nil
+		} else {
+			w.EnvironmentID = req.EnvironmentID
+		}
+	}
+	if req.Name != "" {
+		w.Name = req.Name
+	}
+	if req.Reason != "" {
+		w.Reason = req.Reason
+	}
+	if req.Recurrence != nil {
+		w.Recurrence = strings.TrimSpace(*req.Recurrence)
+	}
+	if req.DurationMinutes != nil {
+		w.DurationMinutes = *req.DurationMinutes
+	}
+	if req.StartAt != nil {
+		w.StartAt = req.StartAt
+	}
+	if req.EndAt != nil {
+		w.EndAt = req.EndAt
+	}
+	if req.Timezone != "" {
+		w.Timezone = req.Timezone
+	}
+	if req.Enabled != nil {
+		w.Enabled = *req.Enabled
+	}
+	if err := validateFreezeWindow(w); err != nil {
+		return nil, err
+	}
+	if err := s.freezeRepo.Update(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *FreezeService) DeleteWindow(id string) error {
+	return s.freezeRepo.Delete(id)
+}
+
+func (s *FreezeService) ListWindows(projectID string) ([]model.FreezeWindow, error) {
+	return s.freezeRepo.ListByProject(projectID)
+}
+
+// Upcoming expands the applicable windows into concrete occurrences that are
+// active now or start within the next `days` days, ordered by start time.
+func (s *FreezeService) Upcoming(projectID, envID string, days int) ([]FreezeOccurrence, error) {
+	windows, err := s.freezeRepo.ListApplicable(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	from := time.Now()
+	to := from.AddDate(0, 0, days)
+
+	result := []FreezeOccurrence{}
+	for i := range windows {
+		result = append(result, freezeOccurrences(&windows[i], from, to)...)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].StartAt.Before(result[j].StartAt) })
+	return result, nil
+}
+
+// Check returns ErrDeployFrozen if a freeze window covers the config's environment
+// right now. An emergency override lets the operation through and is written to the audit log.
+func (s *FreezeService) Check(config *model.DeployConfig, userID, operation string, override FreezeOverride) error {
+	windows, err := s.freezeRepo.ListApplicable(config.ProjectID, config.EnvironmentID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var active []FreezeOccurrence
+	for i := range windows {
+		if occ, ok := activeFreezeOccurrence(&windows[i], now); ok {
+			active = append(active, occ)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	if !override.EmergencyOverride {
+		first := active[0]
+		msg := fmt.Sprintf("%s: %s (until %s)", appErrors.ErrDeployFrozen.Message, first.Name, first.EndAt.Format(time.RFC3339))
+		if first.Reason != "" {
+			msg += ", " + first.Reason
+		}
+		return appErrors.New(appErrors.ErrDeployFrozen.Code, msg)
+	}
+	if strings.TrimSpace(override.Justification) == "" {
+		return appErrors.ErrFreezeOverrideInvalid
+	}
+
+	windowIDs := make([]string, len(active))
+	for i, occ := range active {
+		windowIDs[i] = occ.WindowID
+	}
+	detail, _ := json.Marshal(map[string]interface{}{
+		"operation":         operation,
+		"freeze_window_ids": windowIDs,
+		"justification":     override.Justification,
+		"environment_id":    config.EnvironmentID,
+	})
+	return s.auditRepo.Create(&model.AuditLog{
+		UserID:       userID,
+		Action:       "deploy.freeze_override",
+		ResourceType: "deploy_config",
+		ResourceID:   config.ID,
+		ResourceName: config.Name,
+		ProjectID:    config.ProjectID,
+		Detail:       datatypes.JSON(detail),
+	})
+}
+
+// validateFreezeWindow ensures the window is either a valid recurring or a valid one-off window.
+func validateFreezeWindow(w *model.FreezeWindow) error {
+	invalid := func(reason string) error {
+		return appErrors.New(appErrors.ErrFreezeWindowInvalid.Code, appErrors.ErrFreezeWindowInvalid.Message+": "+reason)
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return invalid("unknown timezone " + w.Timezone)
+	}
+	if w.Recurrence != "" {
+		if _, err := cron.ParseStandard(w.Recurrence); err != nil {
+			return invalid("bad recurrence: " + err.Error())
+		}
+		if w.DurationMinutes <= 0 {
+			return invalid("duration_minutes is required for recurring windows")
+		}
+		return nil
+	}
+	if w.StartAt == nil || w.EndAt == nil {
+		return invalid("either recurrence or start_at/end_at is required")
+	}
+	if !w.EndAt.After(*w.StartAt) {
+		return invalid("end_at must be after start_at")
+	}
+	return nil
+}
+
+// activeFreezeOccurrence returns the occurrence of w covering `at`, if any.
+func activeFreezeOccurrence(w *model.FreezeWindow, at time.Time) (FreezeOccurrence, bool) {
+	occs := freezeOccurrences(w, at, at.Add(time.Nanosecond))
+	if len(occs) == 0 {
+		return FreezeOccurrence{}, false
+	}
+	return occs[0], true
+}
+
+// freezeOccurrences returns the occurrences of w overlapping [from, to).
+func freezeOccurrences(w *model.FreezeWindow, from, to time.Time) []FreezeOccurrence {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	newOcc := func(start, end time.Time) FreezeOccurrence {
+		return FreezeOccurrence{
+			WindowID:      w.ID,
+			Name:          w.Name,
+			Reason:        w.Reason,
+			EnvironmentID: w.EnvironmentID,
+			StartAt:       start.In(loc),
+			EndAt:         end.In(loc),
+		}
+	}
+
+	if w.Recurrence == "" {
+		if w.StartAt == nil || w.EndAt == nil {
+			return nil
+		}
+		if w.StartAt.Before(to) && w.EndAt.After(from) {
+			return []FreezeOccurrence{newOcc(*w.StartAt, *w.EndAt)}
+		}
+		return nil
+	}
+
+	sched, err := cron.ParseStandard(w.Recurrence)
+	if err != nil || w.DurationMinutes <= 0 {
+		return nil
+	}
+	d := time.Duration(w.DurationMinutes) * time.Minute
+
+	var result []FreezeOccurrence
+	// Start from the earliest activation that could still be running at `from`.
+	start := sched.Next(from.In(loc).Add(-d))
+	for !start.IsZero() && start.Before(to) && len(result) < maxOccurrencesPerWindow {
+		result = append(result, newOcc(start, start.Add(d)))
+		start = sched.Next(start)
+	}
+	return result
+}
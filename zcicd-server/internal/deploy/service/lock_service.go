@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// deployLockTTL bounds how long a sync may hold its environment before the
+	// lock is considered stale (e.g. the service crashed mid-sync).
+	deployLockTTL = 15 * time.Minute
+	// queuedLockTTL bounds how long a sync may wait in the queue.
+	queuedLockTTL        = time.Hour
+	defaultManualLockTTL = 2 * time.Hour
+	maxManualLockTTL     = 24 * time.Hour
+)
+
+// LockService keeps the per-environment deploy lock and queue.
+type LockService struct {
+	lockRepo *repository.LockRepository
+}
+
+func NewLockService(lockRepo *repository.LockRepository) *LockService {
+	return &LockService{lockRepo: lockRepo}
+}
+
+// AcquireDeploy takes the environment lock for a sync of config, or queues the
+// sync behind the current holder. It reports whether the returned entry is held.
+// With the "reject" policy a busy environment yields ErrEnvLocked instead.
+func (s *LockService) AcquireDeploy(config *model.DeployConfig, historyID, userID, policy string) (*model.EnvLock, bool, error) {
+	holder, busy, err := s.busy(config.EnvironmentID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	lock := &model.EnvLock{
+		ProjectID:       config.ProjectID,
+		EnvironmentID:   config.EnvironmentID,
+		Kind:            "deploy",
+		DeployConfigID:  &config.ID,
+		DeployHistoryID: &historyID,
+		HolderID:        userID,
+	}
+	if !busy {
+		lock.Status = "held"
+		lock.AcquiredAt = &now
+		lock.ExpiresAt = now.Add(deployLockTTL)
+		if err := s.lockRepo.Create(lock); err == nil {
+			return lock, true, nil
+		}
+		// Lost the race for the held slot; fall through to the busy path.
+		holder, _ = s.lockRepo.GetHeld(config.EnvironmentID)
+		lock.ID = ""
+		lock.AcquiredAt = nil
+	}
+
+	if policy == "reject" {
+		return nil, false, envLockedError(holder)
+	}
+	lock.Status = "queued"
+	lock.ExpiresAt = now.Add(queuedLockTTL)
+	if err := s.lockRepo.Create(lock); err != nil {
+		return nil, false, err
+	}
+	return lock, false, nil
+}
+
+// AcquireManual locks an environment on behalf of a user, e.g. while debugging it.
+// Deploys queue (or are rejected) until the lock is released or expires.
+func (s *LockService) AcquireManual(userID string, req CreateEnvLockReq) (*model.EnvLock, error) {
+	ttl := defaultManualLockTTL
+	if req.TTLMinutes > 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+	if ttl > maxManualLockTTL {
+		ttl = maxManualLockTTL
+	}
+
+	holder, err := s.lockRepo.GetHeld(req.EnvironmentID)
+	if err == nil {
+		return nil, envLockedError(holder)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	lock := &model.EnvLock{
+		ProjectID:     req.ProjectID,
+		EnvironmentID: req.EnvironmentID,
+		Kind:          "manual",
+		Status:        "held",
+		HolderID:      userID,
+		Reason:        req.Reason,
+		AcquiredAt:    &now,
+		ExpiresAt:     now.Add(ttl),
+	}
+	if err := s.lockRepo.Create(lock); err != nil {
+		holder, _ := s.lockRepo.GetHeld(req.EnvironmentID)
+		return nil, envLockedError(holder)
+	}
+	return lock, nil
+}
+
+func (s *LockService) Get(id string) (*model.EnvLock, error) {
+	lock, err := s.lockRepo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrEnvLockNotFound
+	}
+	return lock, err
+}
+
+// ListActive returns current holders and queued syncs.
+func (s *LockService) ListActive(projectID, envID string) ([]model.EnvLock, error) {
+	return s.lockRepo.ListActive(projectID, envID)
+}
+
+// Finish moves a held or queued entry to a terminal status. It reports whether
+// this call made the change.
+func (s *LockService) Finish(lock *model.EnvLock, status string) (bool, error) {
+	return s.lockRepo.Transition(lock.ID, lock.Status, status, map[string]interface{}{"released_at": time.Now()})
+}
+
+// PromoteNext hands a free environment to its oldest queued sync and returns
+// that entry, or nil when the environment is still held or nothing is queued.
+func (s *LockService) PromoteNext(envID string) (*model.EnvLock, error) {
+	if _, err := s.lockRepo.GetHeld(envID); err == nil {
+		return nil, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	next, err := s.lockRepo.NextQueued(envID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ok, err := s.lockRepo.Transition(next.ID, "queued", "held", map[string]interface{}{
+		"acquired_at": now,
+		"expires_at":  now.Add(deployLockTTL),
+	})
+	if err != nil || !ok {
+		// Another replica promoted an entry first (or the unique index refused it).
+		return nil, nil
+	}
+	next.Status = "held"
+	next.AcquiredAt = &now
+	next.ExpiresAt = now.Add(deployLockTTL)
+	return next, nil
+}
+
+// ListExpired returns held and queued entries past their expiry.
+func (s *LockService) ListExpired() ([]model.EnvLock, error) {
+	return s.lockRepo.ListExpired(time.Now())
+}
+
+// ListQueuedEnvironments returns environments that have syncs waiting.
+func (s *LockService) ListQueuedEnvironments() ([]string, error) {
+	return s.lockRepo.ListQueuedEnvironments()
+}
+
+// busy reports whether a new sync must wait: the environment has a holder or
+// earlier syncs are already queued. Stale holders are cleared by the deploy
+// service's lock reaper, not here, so their histories get closed out too.
+func (s *LockService) busy(envID string) (*model.EnvLock, bool, error) {
+	holder, err := s.lockRepo.GetHeld(envID)
+	if err == nil {
+		return holder, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	queued, err := s.lockRepo.CountQueued(envID)
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, queued > 0, nil
+}
+
+// envLockedError describes who holds the environment and until when.
+func envLockedError(holder *model.EnvLock) error {
+	if holder == nil || holder.ID == "" {
+		return appErrors.ErrEnvLocked
+	}
+	msg := fmt.Sprintf("%s: %s lock held by %s until %s", appErrors.ErrEnvLocked.Message,
+		holder.Kind, holder.HolderID, holder.ExpiresAt.Format(time.RFC3339))
+	if holder.Reason != "" {
+		msg += ", " + holder.Reason
+	}
+	return appErrors.New(appErrors.ErrEnvLocked.Code, msg)
+}
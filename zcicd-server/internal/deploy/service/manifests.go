@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"gorm.io/datatypes"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestsFile is where the raw manifests of a "manifests" deploy are
+// committed, under the config's chart path.
+const manifestsFile = "manifests.yaml"
+
+// isManifests reports whether the config deploys its service's raw
+// manifests. "yaml" is the older name of the type.
+func isManifests(config *model.DeployConfig) bool {
+	return config.DeployType == "manifests" || config.DeployType == "yaml"
+}
+
+func helmJSON(h *engine.HelmOptions) datatypes.JSON {
+	if h == nil {
+		return nil
+	}
+	data, _ := json.Marshal(h)
+	return datatypes.JSON(data)
+}
+
+func kustomizeJSON(k *engine.KustomizeOptions) datatypes.JSON {
+	if k == nil {
+		return nil
+	}
+	data, _ := json.Marshal(k)
+	return datatypes.JSON(data)
+}
+
+// serviceManifests reads the raw manifests of the config's service.
+func (s *DeployService) serviceManifests(config *model.DeployConfig) ([]byte, error) {
+	if s.envRepo == nil {
+		return nil, fmt.Errorf("service manifests not available")
+	}
+	svc, err := s.envRepo.GetService(config.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(svc.K8sManifests) == "" {
+		return nil, fmt.Errorf("service %s has no k8s manifests", svc.Name)
+	}
+	return []byte(svc.K8sManifests), nil
+}
+
+// deployManifests returns the service's manifests, with the environment's
+// variables wired into their workloads, followed by the Argo
+// Rollout of the environment's canary or blue-green strategy, which takes
+// over the service's Deployment through a workload reference.
+func (s *DeployService) deployManifests(config *model.DeployConfig) ([]byte, error) {
+	manifests, err := s.serviceManifests(config)
+	if err != nil {
+		return nil, err
+	}
+	envCfg, err := s.resolveEnv(config)
+	if err != nil {
+		return nil, fmt.Errorf("resolve env vars: %w", err)
+	}
+	if envCfg != nil {
+		if manifests, err = envCfg.InjectManifests(manifests); err != nil {
+			return nil, err
+		}
+	}
+	st := s.envStrategy(config.EnvironmentID)
+	if st == nil || !st.UsesRollout() {
+		return manifests, nil
+	}
+	deployment := manifestDeployment(manifests)
+	if deployment == "" {
+		return nil, fmt.Errorf("the %s strategy needs a Deployment in the manifests of %s", st.Type, config.ArgoAppName)
+	}
+	rollout, err := yaml.Marshal(st.RolloutManifest(config.ArgoAppName, deployment, config.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.TrimRight(manifests, "\n")
+	out = append(out, "\n---\n"...)
+	return append(out, rollout...), nil
+}
+
+// manifestDeployment returns the name of the first Deployment in manifests.
+func manifestDeployment(manifests []byte) string {
+	decoder := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 4096)
+	for {
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := decoder.Decode(&obj); err != nil {
+			return ""
+		}
+		if obj.Kind == "Deployment" && obj.Metadata.Name != "" {
+			return obj.Metadata.Name
+		}
+	}
+}
+
+// publishManifests commits the service's manifests to the GitOps repo for
+// Argo CD to sync. The direct engine needs no commit: it applies the
+// manifests carried by the application.
+func (s *DeployService) publishManifests(ctx context.Context, config *model.DeployConfig, history *model.DeployHistory) error {
+	commitSHA, err := s.commitManifests(ctx, config, fmt.Sprintf("Update manifests of %s", config.ArgoAppName))
+	if err != nil {
+		return err
+	}
+	history.GitopsCommit = commitSHA
+	s.publishEvent(mq.SubjectGitOpsUpdate, config.ProjectID, history.TriggeredBy, history)
+	return nil
+}
+
+// commitManifests writes the manifests of a "manifests" deploy to its file in
+// the GitOps repo and returns the commit SHA.
+func (s *DeployService) commitManifests(ctx context.Context, config *model.DeployConfig, message string) (string, error) {
+	manifests, err := s.deployManifests(config)
+	if err != nil {
+		return "", err
+	}
+	if s.gitopsWriter == nil {
+		return "", fmt.Errorf("gitops writer not available")
+	}
+	file := path.Join(config.ChartPath, manifestsFile)
+	commitSHA, err := s.gitopsWriter.WriteFiles(ctx, config.RepoURL, config.TargetRevision, message, map[string][]byte{file: manifests})
+	if err != nil {
+		if errors.Is(err, engine.ErrGitWriteNotConfigured) {
+			return "", appErrors.ErrGitWriteNotConfigured
+		}
+		return "", fmt.Errorf("commit manifests: %w", err)
+	}
+	return commitSHA, nil
+}
+
+// adoptManifests replaces the drifted resources in the raw manifests of the
+// config's service with their live state and commits the result, so that
+// the next deploy keeps them. It returns the commit SHA.
+func (s *DeployService) adoptManifests(ctx context.Context, config *model.DeployConfig, resources []engine.DriftedResource) (string, error) {
+	manifests, err := s.serviceManifests(config)
+	if err != nil {
+		return "", err
+	}
+	adopted, replaced, err := engine.ReplaceManifests(manifests, resources)
+	if err != nil {
+		return "", err
+	}
+	if replaced == 0 {
+		return "", appErrors.New(appErrors.ErrBadRequest.Code, "漂移的资源不在服务的清单中，无法回写")
+	}
+	if err := s.envRepo.UpdateServiceManifests(config.ServiceID, string(adopted)); err != nil {
+		return "", err
+	}
+	return s.commitManifests(ctx, config, fmt.Sprintf("Adopt live changes of %s", config.ArgoAppName))
+}
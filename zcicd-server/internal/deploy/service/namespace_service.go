@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"gorm.io/gorm"
+)
+
+// NamespaceService provisions the namespace of each environment on its cluster
+// and keeps the namespace's ResourceQuota and LimitRange in line with the
+// environment's quota record.
+type NamespaceService struct {
+	envRepo  *repository.EnvRepository
+	clusters *engine.ClusterRegistry
+	argoNS   string
+}
+
+func NewNamespaceService(envRepo *repository.EnvRepository, clusters *engine.ClusterRegistry, argoNS string) *NamespaceService {
+	return &NamespaceService{envRepo: envRepo, clusters: clusters, argoNS: argoNS}
+}
+
+// NamespaceStatus reports the namespace of an environment and any hand edits
+// to its quota objects.
+type NamespaceStatus struct {
+	Namespace    string              `json:"namespace"`
+	Exists       bool                `json:"exists"`
+	QuotaManaged bool                `json:"quota_managed"`
+	Drift        []engine.QuotaDrift `json:"drift"`
+}
+
+// Subscribe provisions and cleans up namespaces as the project service
+// publishes environment changes.
+func (s *NamespaceService) Subscribe(mqClient *mq.Client) error {
+	_, err := mqClient.Subscribe(mq.SubjectEnvAll, "deploy-namespaces", s.handleEnvEvent)
+	return err
+}
+
+func (s *NamespaceService) handleEnvEvent(msg *nats.Msg) {
+	var event struct {
+		EventType string            `json:"event_type"`
+		Payload   model.Environment `json:"payload"`
+	}
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		fmt.Printf("warning: invalid environment event: %v\n", err)
+		return
+	}
+	ctx := context.Background()
+	env := &event.Payload
+	var err error
+	switch msg.Subject {
+	case mq.SubjectEnvCreated, mq.SubjectEnvUpdated:
+		err = s.Provision(ctx, env)
+	case mq.SubjectEnvDeleted:
+		err = s.Cleanup(ctx, env)
+	}
+	if err != nil {
+		fmt.Printf("warning: namespace %s of environment %s: %v\n", env.Namespace, env.Name, err)
+	}
+}
+
+// Provision ensures the environment's namespace exists with standard labels
+// and applies its quota record. An existing namespace is adopted: it gets the
+// labels but not the ownership label, so Cleanup never deletes it, and its
+// quota is left alone unless the namespace's QuotaAnnotation allows it.
+// System namespaces are refused.
+func (s *NamespaceService) Provision(ctx context.Context, env *model.Environment) error {
+	if env.Namespace == "" {
+		return nil
+	}
+	if engine.IsSystemNamespace(env.Namespace, s.argoNS) {
+		return appErrors.ErrSystemNamespace
+	}
+	client, err := s.client(ctx, env)
+	if err != nil || client == nil {
+		return err
+	}
+	labels := map[string]string{"zcicd.io/project-id": env.ProjectID}
+	if env.EnvType != "" {
+		labels["zcicd.io/env-type"] = env.EnvType
+	}
+	managed, err := engine.EnsureNamespace(ctx, client, env.Namespace, env.ID, labels)
+	if err != nil {
+		return err
+	}
+	if !managed {
+		return nil
+	}
+	limits, err := s.limits(env.ID)
+	if err != nil {
+		return err
+	}
+	return engine.ApplyQuota(ctx, client, env.Namespace, limits)
+}
+
+// ApplyQuota re-applies the quota of an environment after its record changed.
+func (s *NamespaceService) ApplyQuota(ctx context.Context, envID string) error {
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return err
+	}
+	return s.Provision(ctx, env)
+}
+
+// Cleanup deletes the namespace of a deleted environment if zcicd created it
+// for that environment; adopted namespaces are kept.
+func (s *NamespaceService) Cleanup(ctx context.Context, env *model.Environment) error {
+	if env.Namespace == "" {
+		return nil
+	}
+	client, err := s.client(ctx, env)
+	if err != nil || client == nil {
+		return err
+	}
+	return engine.DeleteNamespace(ctx, client, env.Namespace, env.ID)
+}
+
+// Status checks the namespace of an environment and compares its quota
+// objects with the quota record.
+func (s *NamespaceService) Status(ctx context.Context, envID string) (*NamespaceStatus, error) {
+	env, err := s.envRepo.GetEnvironment(envID)
+	if err != nil {
+		return nil, err
+	}
+	status := &NamespaceStatus{Namespace: env.Namespace, Drift: []engine.QuotaDrift{}}
+	client, err := s.client(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || env.Namespace == "" {
+		return status, nil
+	}
+	status.Exists, status.QuotaManaged, err = engine.NamespaceQuota(ctx, client, env.Namespace, env.ID)
+	if err != nil || !status.QuotaManaged {
+		return status, err
+	}
+	limits, err := s.limits(env.ID)
+	if err != nil {
+		return nil, err
+	}
+	if status.Drift, err = engine.DetectQuotaDrift(ctx, client, env.Namespace, limits); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// limits reads the quota record of an environment; no record means no limits.
+func (s *NamespaceService) limits(envID string) (engine.QuotaLimits, error) {
+	q, err := s.envRepo.GetQuota(envID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return engine.QuotaLimits{}, nil
+	}
+	if err != nil {
+		return engine.QuotaLimits{}, err
+	}
+	return quotaLimits(q), nil
+}
+
+func (s *NamespaceService) client(ctx context.Context, env *model.Environment) (*k8s.K8sClient, error) {
+	if s.clusters == nil {
+		return nil, nil
+	}
+	ref, err := clusterRef(s.envRepo, env)
+	if err != nil {
+		return nil, err
+	}
+	return s.clusters.Client(ctx, ref)
+}
+
+func quotaLimits(q *model.EnvResourceQuota) engine.QuotaLimits {
+	return engine.QuotaLimits{
+		CPURequest:    q.CPURequest,
+		CPULimit:      q.CPULimit,
+		MemoryRequest: q.MemoryRequest,
+		MemoryLimit:   q.MemoryLimit,
+		PodLimit:      q.PodLimit,
+		StorageLimit:  q.StorageLimit,
+	}
+}
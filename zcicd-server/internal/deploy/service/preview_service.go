@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/zcicd/zcicd-server/internal/deploy/engine"
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPreviewTTL = 72 * time.Hour
+
+	// previewUserID triggers preview syncs; previews have no human trigger.
+	// It is the system user seeded by migration 000033.
+	previewUserID = "10000000-0000-0000-0000-000000000002"
+)
+
+var invalidDNSChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// PreviewService runs an ephemeral environment per pull request for every
+// preview template DeployConfig of the pull request's repository: it creates
+// the environment and namespace, clones the template config, deploys each
+// successful build of the branch, and tears everything down on merge/close or
+// when the cron service marks the preview expired or orphaned.
+type PreviewService struct {
+	previewRepo *repository.PreviewRepository
+	envRepo     *repository.EnvRepository
+	deploySvc   *DeployService
+	nsSvc       *NamespaceService
+	mqClient    *mq.Client
+}
+
+func NewPreviewService(
+	previewRepo *repository.PreviewRepository,
+	envRepo *repository.EnvRepository,
+	deploySvc *DeployService,
+	nsSvc *NamespaceService,
+	mqClient *mq.Client,
+) *PreviewService {
+	return &PreviewService{
+		previewRepo: previewRepo,
+		envRepo:     envRepo,
+		deploySvc:   deploySvc,
+		nsSvc:       nsSvc,
+		mqClient:    mqClient,
+	}
+}
+
+// pullRequestEvent is published by the workflow service's webhook handlers.
+type pullRequestEvent struct {
+	Provider  string `json:"provider"`
+	RepoURL   string `json:"repo_url"`
+	Number    int    `json:"number"`
+	Action    string `json:"action"` // opened, updated, closed, merged
+	Title     string `json:"title"`
+	Branch    string `json:"branch"`
+	CommitSHA string `json:"commit_sha"`
+}
+
+// buildCompletedEvent is published by the workflow service's build service.
+type buildCompletedEvent struct {
+	BuildRunID string `json:"build_run_id"`
+	Status     string `json:"status"`
+}
+
+// Subscribe starts consuming pull request and build completion events.
+func (s *PreviewService) Subscribe(mqClient *mq.Client) error {
+	if _, err := mqClient.Subscribe(mq.SubjectPullRequest, "deploy-previews", s.handlePullRequest); err != nil {
+		return err
+	}
+	_, err := mqClient.Subscribe(mq.SubjectBuildCompleted, "deploy-previews-builds", s.handleBuildCompleted)
+	return err
+}
+
+func (s *PreviewService) handlePullRequest(msg *nats.Msg) {
+	var pr pullRequestEvent
+	if err := json.Unmarshal(msg.Data, &pr); err != nil || pr.Number == 0 {
+		fmt.Printf("warning: invalid pull request event: %v\n", err)
+		return
+	}
+	ctx := context.Background()
+	switch pr.Action {
+	case "opened", "updated":
+		s.openPreviews(ctx, pr)
+	case "closed", "merged":
+		s.closePreviews(ctx, pr)
+	}
+}
+
+func (s *PreviewService) handleBuildCompleted(msg *nats.Msg) {
+	var event buildCompletedEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil || event.Status != "succeeded" {
+		return
+	}
+	run, err := s.previewRepo.GetBuildRun(event.BuildRunID)
+	if err != nil {
+		fmt.Printf("warning: preview: build run %s: %v\n", event.BuildRunID, err)
+		return
+	}
+	if run.Branch == "" || run.ImageTag == "" {
+		return
+	}
+	previews, err := s.previewRepo.ListOpenByBranch(run.ServiceID, run.Branch)
+	if err != nil {
+		fmt.Printf("warning: preview: list previews of %s: %v\n", run.Branch, err)
+		return
+	}
+	for i := range previews {
+		p := &previews[i]
+		// A build of an older head commit is superseded by the one on its way.
+		if p.CommitSHA != "" && run.CommitSHA != "" && p.CommitSHA != run.CommitSHA {
+			continue
+		}
+		s.deploy(context.Background(), p, run.ImageRepo, run.ImageTag)
+	}
+}
+
+// openPreviews creates or refreshes the previews of a pull request, one per
+// preview template of its repository.
+func (s *PreviewService) openPreviews(ctx context.Context, pr pullRequestEvent) {
+	templates, err := s.previewRepo.ListPreviewTemplates(repoURLVariants(pr.RepoURL))
+	if err != nil {
+		fmt.Printf("warning: preview: list templates for %s: %v\n", pr.RepoURL, err)
+		return
+	}
+	now := time.Now()
+	for i := range templates {
+		t := &templates[i]
+		p, err := s.previewRepo.FindOpen(t.ID, pr.RepoURL, pr.Number)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if _, err := s.create(ctx, t, pr); err != nil {
+				fmt.Printf("warning: preview for %s#%d from %s: %v\n", pr.RepoURL, pr.Number, t.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			fmt.Printf("warning: preview: %v\n", err)
+			continue
+		}
+		p.Title = pr.Title
+		p.Branch = pr.Branch
+		p.CommitSHA = pr.CommitSHA
+		p.LastActivityAt = now
+		p.ExpiresAt = now.Add(previewTTL(t))
+		s.previewRepo.Update(p)
+	}
+}
+
+// create sets up the environment, namespace and deploy config of a new preview.
+// The preview deploys once a build of its branch succeeds.
+func (s *PreviewService) create(ctx context.Context, t *model.DeployConfig, pr pullRequestEvent) (*model.PreviewEnvironment, error) {
+	if err := checkPreviewTemplate(t); err != nil {
+		return nil, err
+	}
+	templateEnv, err := s.envRepo.GetEnvironment(t.EnvironmentID)
+	if err != nil {
+		return nil, fmt.Errorf("template environment: %w", err)
+	}
+
+	name := previewName(pr, t)
+	now := time.Now()
+	p := &model.PreviewEnvironment{
+		ProjectID:        t.ProjectID,
+		ServiceID:        t.ServiceID,
+		TemplateConfigID: &t.ID,
+		RepoURL:          pr.RepoURL,
+		PRNumber:         pr.Number,
+		Title:            pr.Title,
+		Branch:           pr.Branch,
+		CommitSHA:        pr.CommitSHA,
+		Namespace:        name,
+		Status:           "pending",
+		LastActivityAt:   now,
+		ExpiresAt:        now.Add(previewTTL(t)),
+	}
+	if t.PreviewDomain != "" {
+		p.URL = "https://" + name + "." + strings.TrimPrefix(t.PreviewDomain, ".")
+	}
+	if err := s.previewRepo.Create(p); err != nil {
+		return nil, err
+	}
+
+	env := &model.Environment{
+		ProjectID:     t.ProjectID,
+		Name:          name,
+		EnvType:       "preview",
+		Namespace:     name,
+		ClusterID:     templateEnv.ClusterID,
+		GlobalEnvVars: templateEnv.GlobalEnvVars,
+		DeployEngine:  templateEnv.DeployEngine,
+		Status:        "active",
+	}
+	if err := s.previewRepo.CreateEnvironment(env); err != nil {
+		return p, s.fail(p, fmt.Errorf("create environment: %w", err))
+	}
+	p.EnvironmentID = &env.ID
+	if s.nsSvc != nil {
+		if err := s.nsSvc.Provision(ctx, env); err != nil {
+			fmt.Printf("warning: preview namespace %s: %v\n", name, err)
+		}
+	}
+
+	var values map[string]interface{}
+	if len(t.ValuesOverride) > 0 {
+		json.Unmarshal(t.ValuesOverride, &values)
+	}
+	if p.URL != "" {
+		values = mergeValues(values, map[string]interface{}{
+			"ingress": map[string]interface{}{
+				"enabled": true,
+				"hosts": []interface{}{map[string]interface{}{
+					"host":  strings.TrimPrefix(p.URL, "https://"),
+					"paths": []interface{}{map[string]interface{}{"path": "/", "pathType": "Prefix"}},
+				}},
+			},
+		})
+	}
+	var helm *engine.HelmOptions
+	if len(t.Helm) > 0 {
+		helm = &engine.HelmOptions{}
+		json.Unmarshal(t.Helm, helm)
+	}
+	var kustomize *engine.KustomizeOptions
+	if len(t.Kustomize) > 0 {
+		kustomize = &engine.KustomizeOptions{}
+		json.Unmarshal(t.Kustomize, kustomize)
+	}
+	config, err := s.deploySvc.CreateConfig(ctx, t.ProjectID, CreateDeployConfigReq{
+		ServiceID:      t.ServiceID,
+		EnvironmentID:  env.ID,
+		Name:           name,
+		DeployType:     t.DeployType,
+		RepoURL:        t.RepoURL,
+		TargetRevision: t.TargetRevision,
+		ChartPath:      t.ChartPath,
+		ValuesOverride: values,
+		Helm:           helm,
+		Kustomize:      kustomize,
+		SyncPolicy:     "manual",
+		Prune:          true,
+		Namespace:      name,
+		LockPolicy:     "queue",
+	})
+	if err != nil {
+		return p, s.fail(p, fmt.Errorf("create deploy config: %w", err))
+	}
+	p.DeployConfigID = &config.ID
+	return p, s.previewRepo.Update(p)
+}
+
+// deploy points the preview's config at a built image and syncs it.
+func (s *PreviewService) deploy(ctx context.Context, p *model.PreviewEnvironment, imageRepo, imageTag string) {
+	if p.DeployConfigID == nil {
+		return
+	}
+	config, err := s.deploySvc.GetConfig(*p.DeployConfigID)
+	if err != nil {
+		s.fail(p, fmt.Errorf("deploy config: %w", err))
+		return
+	}
+	// Helm charts take the image from values, kustomizations from images.
+	var req UpdateDeployConfigReq
+	if config.DeployType == "kustomize" {
+		req.Kustomize = &engine.KustomizeOptions{}
+		if len(config.Kustomize) > 0 {
+			json.Unmarshal(config.Kustomize, req.Kustomize)
+		}
+		req.Kustomize.Images = withImage(req.Kustomize.Images, imageRepo, imageTag)
+	} else {
+		var values map[string]interface{}
+		if len(config.ValuesOverride) > 0 {
+			json.Unmarshal(config.ValuesOverride, &values)
+		}
+		req.ValuesOverride = mergeValues(values, map[string]interface{}{
+			"image": map[string]interface{}{"repository": imageRepo, "tag": imageTag},
+		})
+	}
+	if _, err := s.deploySvc.UpdateConfig(ctx, config.ID, req); err != nil {
+		s.fail(p, fmt.Errorf("update deploy config: %w", err))
+		return
+	}
+
+	now := time.Now()
+	p.Image = imageRepo + ":" + imageTag
+	p.Status = "deploying"
+	p.ErrorMessage = ""
+	p.LastActivityAt = now
+	if t, err := s.template(p); err == nil {
+		p.ExpiresAt = now.Add(previewTTL(t))
+	}
+	s.previewRepo.Update(p)
+
+	history, err := s.deploySvc.TriggerSync(ctx, config.ID, previewUserID, TriggerSyncReq{})
+	if err != nil {
+		s.fail(p, err)
+		return
+	}
+	if history.Status == "failed" {
+		s.fail(p, errors.New(history.ErrorMessage))
+		return
+	}
+	p.Status = "active"
+	s.previewRepo.Update(p)
+	s.publish(mq.SubjectPreviewReady, p)
+}
+
+// closePreviews tears down every preview of a merged or closed pull request.
+func (s *PreviewService) closePreviews(ctx context.Context, pr pullRequestEvent) {
+	previews, err := s.previewRepo.ListOpenByPR(pr.RepoURL, pr.Number)
+	if err != nil {
+		fmt.Printf("warning: preview: list previews of %s#%d: %v\n", pr.RepoURL, pr.Number, err)
+		return
+	}
+	for i := range previews {
+		previews[i].Status = "closing"
+		previews[i].CloseReason = pr.Action
+		s.previewRepo.Update(&previews[i])
+		s.teardown(ctx, &previews[i])
+	}
+}
+
+// Close tears down a preview on request.
+func (s *PreviewService) Close(ctx context.Context, id string) (*model.PreviewEnvironment, error) {
+	p, err := s.previewRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == "closed" {
+		return p, nil
+	}
+	p.Status = "closing"
+	p.CloseReason = "manual"
+	if err := s.previewRepo.Update(p); err != nil {
+		return nil, err
+	}
+	s.teardown(ctx, p)
+	return p, nil
+}
+
+// teardown removes the deploy config, namespace and environment of a preview.
+// Failures leave the preview "closing" so the reaper retries.
+func (s *PreviewService) teardown(ctx context.Context, p *model.PreviewEnvironment) {
+	if p.DeployConfigID != nil {
+		if err := s.deploySvc.DeleteConfig(ctx, *p.DeployConfigID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			fmt.Printf("warning: preview %s: delete deploy config: %v\n", p.Namespace, err)
+			return
+		}
+		p.DeployConfigID = nil
+	}
+	if p.EnvironmentID != nil {
+		env, err := s.envRepo.GetEnvironment(*p.EnvironmentID)
+		if err == nil {
+			if s.nsSvc != nil {
+				if err := s.nsSvc.Cleanup(ctx, env); err != nil {
+					fmt.Printf("warning: preview %s: delete namespace: %v\n", p.Namespace, err)
+					return
+				}
+			}
+			if err := s.previewRepo.DeleteEnvironment(env.ID); err != nil {
+				fmt.Printf("warning: preview %s: delete environment: %v\n", p.Namespace, err)
+				return
+			}
+		}
+		p.EnvironmentID = nil
+	}
+	now := time.Now()
+	p.Status = "closed"
+	p.ClosedAt = &now
+	s.previewRepo.Update(p)
+	s.publish(mq.SubjectPreviewClosed, p)
+}
+
+// StartReaper periodically tears down previews marked "closing", by the cron
+// service's reclaimer or by an earlier teardown that failed.
+func (s *PreviewService) StartReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				previews, err := s.previewRepo.ListClosing()
+				if err != nil {
+					fmt.Printf("warning: failed to list closing previews: %v\n", err)
+					continue
+				}
+				for i := range previews {
+					s.teardown(ctx, &previews[i])
+				}
+			}
+		}
+	}()
+}
+
+// Get returns a preview by ID.
+func (s *PreviewService) Get(id string) (*model.PreviewEnvironment, error) {
+	return s.previewRepo.Get(id)
+}
+
+// List returns the previews of a project, optionally filtered by status.
+func (s *PreviewService) List(projectID, status string, page, pageSize int) ([]model.PreviewEnvironment, int64, error) {
+	return s.previewRepo.List(projectID, status, page, pageSize)
+}
+
+func (s *PreviewService) template(p *model.PreviewEnvironment) (*model.DeployConfig, error) {
+	if p.TemplateConfigID == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.deploySvc.GetConfig(*p.TemplateConfigID)
+}
+
+func (s *PreviewService) fail(p *model.PreviewEnvironment, err error) error {
+	p.Status = "failed"
+	p.ErrorMessage = err.Error()
+	s.previewRepo.Update(p)
+	return err
+}
+
+func (s *PreviewService) publish(subject string, p *model.PreviewEnvironment) {
+	if s.mqClient == nil {
+		return
+	}
+	data, _ := json.Marshal(mq.Event{
+		EventType: subject,
+		Timestamp: time.Now().Format(time.RFC3339),
+		ProjectID: p.ProjectID,
+		Payload:   p,
+	})
+	s.mqClient.Publish(subject, data)
+}
+
+func previewTTL(t *model.DeployConfig) time.Duration {
+	if t.PreviewTTLHours > 0 {
+		return time.Duration(t.PreviewTTLHours) * time.Hour
+	}
+	return defaultPreviewTTL
+}
+
+// repoURLVariants returns the forms a repository URL may be stored in.
+func repoURLVariants(url string) []string {
+	base := strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return []string{url, base, base + ".git"}
+}
+
+// withImage sets the kustomize image override of a repository to tag,
+// replacing the override of the same image name if there is one.
+func withImage(images []string, repo, tag string) []string {
+	out := make([]string, 0, len(images)+1)
+	for _, img := range images {
+		name, _, found := strings.Cut(img, "=")
+		if !found {
+			name = img
+			if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+				name = name[:i]
+			}
+		}
+		if name != repo {
+			out = append(out, img)
+		}
+	}
+	return append(out, repo+":"+tag)
+}
+
+// previewName names the namespace and environment of a pull request's
+// preview. The hash of the repository and template keeps pull requests of
+// the same number in other repos or projects apart.
+func previewName(pr pullRequestEvent, t *model.DeployConfig) string {
+	repo := strings.TrimSuffix(strings.TrimSuffix(pr.RepoURL, "/"), ".git")
+	sum := sha256.Sum256([]byte(repo + "\x00" + t.ID))
+	return dnsLabel(fmt.Sprintf("pr-%d-%s-%s", pr.Number, hex.EncodeToString(sum[:4]), t.Name))
+}
+
+// dnsLabel turns s into a valid namespace name.
+func dnsLabel(s string) string {
+	s = strings.Trim(invalidDNSChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+	return s
+}
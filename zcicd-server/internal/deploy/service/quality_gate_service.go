@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// QualityGateService enforces the quality service's gate results on deploys.
+type QualityGateService struct {
+	deployRepo *repository.DeployRepository
+	auditRepo  *repository.AuditRepository
+}
+
+func NewQualityGateService(deployRepo *repository.DeployRepository, auditRepo *repository.AuditRepository) *QualityGateService {
+	return &QualityGateService{deployRepo: deployRepo, auditRepo: auditRepo}
+}
+
+// Check returns ErrQualityGateFailed if the commit built into the config's
+// image failed its quality gate, or is still pending on scans, and the gate
+// blocks deploys. Images that no build can be traced to and commits not
+// evaluated yet are let through. A
+// quality gate override lets the operation through and is written to the
+// audit log.
+func (s *QualityGateService) Check(config *model.DeployConfig, userID, operation string, override CheckOverride) error {
+	tag, digest := valuesImage(config.ValuesOverride)
+	if (tag == "" && digest == "") || config.ServiceID == "" {
+		return nil
+	}
+	commit, err := s.deployRepo.FindBuildCommit(config.ServiceID, tag, digest)
+	if err != nil || commit == "" {
+		return err
+	}
+	res, err := s.deployRepo.GetQualityGateResult(config.ProjectID, commit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if (res.Status != "failed" && res.Status != "pending") || !res.BlockDeploy {
+		return nil
+	}
+
+	var conditions []struct {
+		Metric string `json:"metric"`
+		Status string `json:"status"`
+	}
+	json.Unmarshal(res.Conditions, &conditions)
+	var failed []string
+	for _, c := range conditions {
+		if c.Status == "failed" || c.Status == "pending" {
+			failed = append(failed, c.Metric)
+		}
+	}
+
+	if !override.Override {
+		short := commit
+		if len(short) > 7 {
+			short = short[:7]
+		}
+		msg := fmt.Sprintf("%s: %s", appErrors.ErrQualityGateFailed.Message, short)
+		if res.Status == "pending" {
+			msg = fmt.Sprintf("质量门禁尚未完成评估，禁止部署: %s", short)
+		}
+		if len(failed) > 0 {
+			msg += " (" + strings.Join(failed, ", ") + ")"
+		}
+		return appErrors.New(appErrors.ErrQualityGateFailed.Code, msg)
+	}
+	if strings.TrimSpace(override.Justification) == "" {
+		return appErrors.ErrFreezeOverrideInvalid
+	}
+
+	detail, _ := json.Marshal(map[string]interface{}{
+		"operation":         operation,
+		"commit_sha":        commit,
+		"gate_result_id":    res.ID,
+		"failed_conditions": failed,
+		"justification":     override.Justification,
+		"environment_id":    config.EnvironmentID,
+	})
+	return s.auditRepo.Create(&model.AuditLog{
+		UserID:       userID,
+		Action:       "deploy.quality_gate_override",
+		ResourceType: "deploy_config",
+		ResourceID:   config.ID,
+		ResourceName: config.Name,
+		ProjectID:    config.ProjectID,
+		Detail:       datatypes.JSON(detail),
+	})
+}
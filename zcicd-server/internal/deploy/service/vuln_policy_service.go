@@ -0,0 +1,148 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zcicd/zcicd-server/internal/deploy/model"
+	"github.com/zcicd/zcicd-server/internal/deploy/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// VulnPolicyService enforces the artifact service's vulnerability policy
+// results on deploys.
+type VulnPolicyService struct {
+	deployRepo *repository.DeployRepository
+	envRepo    *repository.EnvRepository
+	auditRepo  *repository.AuditRepository
+}
+
+func NewVulnPolicyService(deployRepo *repository.DeployRepository, envRepo *repository.EnvRepository, auditRepo *repository.AuditRepository) *VulnPolicyService {
+	return &VulnPolicyService{deployRepo: deployRepo, envRepo: envRepo, auditRepo: auditRepo}
+}
+
+// Check returns ErrVulnPolicyFailed if the latest scan of the config's image
+// has vulnerabilities over the policy of the environment's type, that the
+// CVE allowlist does not waive, and the policy blocks deploys. Production
+// environments use the production policy whatever their type. Images not
+// scanned yet are let through, except into production environments with a
+// policy, which refuse images without a scan result, including images no
+// build can be traced to. A vulnerability policy override lets the operation through and
+// is written to the audit log.
+func (s *VulnPolicyService) Check(config *model.DeployConfig, userID, operation string, override CheckOverride) error {
+	tag, digest := valuesImage(config.ValuesOverride)
+	if tag == "" && digest == "" {
+		return nil
+	}
+	env, err := s.envRepo.GetEnvironment(config.EnvironmentID)
+	if err != nil {
+		return err
+	}
+	envType := env.EnvType
+	if env.IsProduction {
+		envType = "production"
+	}
+
+	var imageRepo string
+	if config.ServiceID != "" {
+		run, err := s.deployRepo.FindBuildRun(config.ServiceID, tag, digest)
+		if err == nil {
+			imageRepo = run.ImageRepo
+			if digest == "" {
+				digest = run.ImageDigest
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	var res *model.ImagePolicyResult
+	if digest != "" || imageRepo != "" {
+		res, err = s.deployRepo.GetImagePolicyResult(config.ProjectID, envType, imageRepo, tag, digest)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			res = nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if res == nil {
+		if !env.IsProduction {
+			return nil
+		}
+		if enforced, err := s.deployRepo.HasVulnPolicy(config.ProjectID, envType); err != nil || !enforced {
+			return err
+		}
+		image := tag
+		if digest != "" {
+			image = digest
+		}
+		if !override.Override {
+			return appErrors.New(appErrors.ErrVulnPolicyFailed.Code, "生产环境禁止部署未经漏洞扫描的镜像: "+image)
+		}
+		return s.audit(config, userID, override, map[string]interface{}{
+			"operation":      operation,
+			"image":          image,
+			"unscanned":      true,
+			"justification":  override.Justification,
+			"environment_id": config.EnvironmentID,
+		})
+	}
+	if res.Status != "failed" || !res.BlockDeploy {
+		return nil
+	}
+
+	var violations []struct {
+		VulnerabilityID string `json:"vulnerability_id"`
+		Severity        string `json:"severity"`
+	}
+	json.Unmarshal(res.Violations, &violations)
+	ids := make([]string, 0, len(violations))
+	seen := map[string]bool{}
+	for _, v := range violations {
+		if !seen[v.VulnerabilityID] {
+			seen[v.VulnerabilityID] = true
+			ids = append(ids, v.VulnerabilityID)
+		}
+	}
+
+	if !override.Override {
+		msg := fmt.Sprintf("%s: %s:%s", appErrors.ErrVulnPolicyFailed.Message, res.ImageName, res.Tag)
+		if len(ids) > 5 {
+			msg += fmt.Sprintf(" (%s 等 %d 个)", strings.Join(ids[:5], ", "), len(ids))
+		} else if len(ids) > 0 {
+			msg += " (" + strings.Join(ids, ", ") + ")"
+		}
+		return appErrors.New(appErrors.ErrVulnPolicyFailed.Code, msg)
+	}
+	return s.audit(config, userID, override, map[string]interface{}{
+		"operation":       operation,
+		"image":           res.ImageName + ":" + res.Tag,
+		"digest":          res.Digest,
+		"scan_id":         res.ScanID,
+		"policy_id":       res.PolicyID,
+		"max_severity":    res.MaxSeverity,
+		"vulnerabilities": ids,
+		"justification":   override.Justification,
+		"environment_id":  config.EnvironmentID,
+	})
+}
+
+// audit records an override of the policy; it requires a justification.
+func (s *VulnPolicyService) audit(config *model.DeployConfig, userID string, override CheckOverride, detail map[string]interface{}) error {
+	if strings.TrimSpace(override.Justification) == "" {
+		return appErrors.ErrFreezeOverrideInvalid
+	}
+	data, _ := json.Marshal(detail)
+	return s.auditRepo.Create(&model.AuditLog{
+		UserID:       userID,
+		Action:       "deploy.vulnerability_policy_override",
+		ResourceType: "deploy_config",
+		ResourceID:   config.ID,
+		ResourceName: config.Name,
+		ProjectID:    config.ProjectID,
+		Detail:       datatypes.JSON(data),
+	})
+}
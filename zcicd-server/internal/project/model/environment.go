@@ -17,6 +17,7 @@ type Environment struct {
 	AutoDeploy     bool           `json:"auto_deploy" gorm:"default:false"`
 	DeployStrategy datatypes.JSON `json:"deploy_strategy"`
 	GlobalEnvVars  datatypes.JSON `json:"global_env_vars"`
+	DeployEngine   string         `json:"deploy_engine" gorm:"size:16;default:'auto'"`
 	Status         string         `json:"status" gorm:"default:'active'"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
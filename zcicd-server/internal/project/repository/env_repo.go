@@ -35,6 +35,16 @@ func (r *EnvironmentRepository) Delete(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Environment{}).Error
 }
 
+// HasKustomizeDeploys reports whether an environment has deploy configs of
+// the deploy service that deploy kustomizations.
+func (r *EnvironmentRepository) HasKustomizeDeploys(ctx context.Context, envID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("deploy_configs").
+		Where("environment_id = ? AND deploy_type = 'kustomize'", envID).
+		Count(&count).Error
+	return count > 0, err
+}
+
 func (r *EnvironmentRepository) ListByProject(ctx context.Context, projectID string) ([]model.Environment, error) {
 	var envs []model.Environment
 	if err := r.db.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at ASC").Find(&envs).Error; err != nil {
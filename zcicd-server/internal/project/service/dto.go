@@ -64,6 +64,7 @@ type CreateEnvRequest struct {
 	AutoDeploy     bool            `json:"auto_deploy"`
 	DeployStrategy json.RawMessage `json:"deploy_strategy"`
 	GlobalEnvVars  json.RawMessage `json:"global_env_vars"`
+	DeployEngine   string          `json:"deploy_engine" binding:"omitempty,oneof=auto argocd direct"`
 }
 
 type UpdateEnvRequest struct {
@@ -75,5 +76,6 @@ type UpdateEnvRequest struct {
 	AutoDeploy     *bool           `json:"auto_deploy"`
 	DeployStrategy json.RawMessage `json:"deploy_strategy"`
 	GlobalEnvVars  json.RawMessage `json:"global_env_vars"`
+	DeployEngine   string          `json:"deploy_engine" binding:"omitempty,oneof=auto argocd direct"`
 	Status         string          `json:"status" binding:"omitempty,oneof=active inactive"`
 }
@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/zcicd/zcicd-server/internal/project/model"
 	"github.com/zcicd/zcicd-server/internal/project/repository"
 	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"github.com/zcicd/zcicd-server/pkg/strategy"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -15,17 +19,20 @@ type ProjectService struct {
 	projectRepo *repository.ProjectRepository
 	serviceRepo *repository.ServiceRepository
 	envRepo     *repository.EnvironmentRepository
+	mqClient    *mq.Client
 }
 
 func NewProjectService(
 	projectRepo *repository.ProjectRepository,
 	serviceRepo *repository.ServiceRepository,
 	envRepo *repository.EnvironmentRepository,
+	mqClient *mq.Client,
 ) *ProjectService {
 	return &ProjectService{
 		projectRepo: projectRepo,
 		serviceRepo: serviceRepo,
 		envRepo:     envRepo,
+		mqClient:    mqClient,
 	}
 }
 
@@ -277,8 +284,15 @@ func (s *ProjectService) CreateEnvironment(ctx context.Context, projectID string
 		ClusterID:    req.ClusterID,
 		IsProduction: req.IsProduction,
 		AutoDeploy:   req.AutoDeploy,
+		DeployEngine: req.DeployEngine,
+	}
+	if env.DeployEngine == "" {
+		env.DeployEngine = "auto"
 	}
 	if req.DeployStrategy != nil {
+		if _, err := strategy.Parse(req.DeployStrategy); err != nil {
+			return nil, appErrors.New(appErrors.ErrBadRequest.Code, err.Error())
+		}
 		env.DeployStrategy = datatypes.JSON(req.DeployStrategy)
 	}
 	if req.GlobalEnvVars != nil {
@@ -288,6 +302,7 @@ func (s *ProjectService) CreateEnvironment(ctx context.Context, projectID string
 	if err := s.envRepo.Create(ctx, env); err != nil {
 		return nil, appErrors.Wrap(appErrors.ErrDatabaseError.Code, "创建环境失败", err)
 	}
+	s.publishEnvEvent(mq.SubjectEnvCreated, env)
 	return env, nil
 }
 
@@ -330,11 +345,28 @@ func (s *ProjectService) UpdateEnvironment(ctx context.Context, id string, req *
 		env.AutoDeploy = *req.AutoDeploy
 	}
 	if req.DeployStrategy != nil {
+		st, err := strategy.Parse(req.DeployStrategy)
+		if err != nil {
+			return nil, appErrors.New(appErrors.ErrBadRequest.Code, err.Error())
+		}
+		// Argo Rollouts cannot be added to a kustomization from the outside
+		if st.UsesRollout() {
+			kustomize, err := s.envRepo.HasKustomizeDeploys(ctx, env.ID)
+			if err != nil {
+				return nil, appErrors.Wrap(appErrors.ErrDatabaseError.Code, "查询部署配置失败", err)
+			}
+			if kustomize {
+				return nil, appErrors.ErrStrategyUnsupported
+			}
+		}
 		env.DeployStrategy = datatypes.JSON(req.DeployStrategy)
 	}
 	if req.GlobalEnvVars != nil {
 		env.GlobalEnvVars = datatypes.JSON(req.GlobalEnvVars)
 	}
+	if req.DeployEngine != "" {
+		env.DeployEngine = req.DeployEngine
+	}
 	if req.Status != "" {
 		env.Status = req.Status
 	}
@@ -342,18 +374,38 @@ func (s *ProjectService) UpdateEnvironment(ctx context.Context, id string, req *
 	if err := s.envRepo.Update(ctx, env); err != nil {
 		return nil, appErrors.Wrap(appErrors.ErrDatabaseError.Code, "更新环境失败", err)
 	}
+	s.publishEnvEvent(mq.SubjectEnvUpdated, env)
 	return env, nil
 }
 
 func (s *ProjectService) DeleteEnvironment(ctx context.Context, id string) error {
-	_, err := s.envRepo.FindByID(ctx, id)
+	env, err := s.envRepo.FindByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return appErrors.ErrEnvNotFound
 		}
 		return appErrors.Wrap(appErrors.ErrDatabaseError.Code, "查询环境失败", err)
 	}
-	return s.envRepo.Delete(ctx, id)
+	if err := s.envRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.publishEnvEvent(mq.SubjectEnvDeleted, env)
+	return nil
+}
+
+// publishEnvEvent tells the deploy service to provision or clean up the
+// environment's namespace.
+func (s *ProjectService) publishEnvEvent(subject string, env *model.Environment) {
+	if s.mqClient == nil {
+		return
+	}
+	data, _ := json.Marshal(mq.Event{
+		EventType: subject,
+		Timestamp: time.Now().Format(time.RFC3339),
+		ProjectID: env.ProjectID,
+		Payload:   env,
+	})
+	s.mqClient.Publish(subject, data)
 }
 
 func (s *ProjectService) ListEnvironments(ctx context.Context, projectID string) ([]model.Environment, error) {
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Finding is an issue reported by a static analysis tool.
+type Finding struct {
+	Tool     string
+	RuleID   string
+	Severity string // critical/high/medium/low/info
+	Category string // bug/vulnerability/code_smell/secret
+	File     string
+	Line     int
+	Message  string
+	// ToolFingerprint is the tool's own identity of the finding, for tools
+	// whose messages cannot tell two findings of a rule in a file apart.
+	ToolFingerprint string
+}
+
+// Finding categories.
+const (
+	CategoryBug           = "bug"
+	CategoryVulnerability = "vulnerability"
+	CategoryCodeSmell     = "code_smell"
+	CategorySecret        = "secret"
+)
+
+var digits = regexp.MustCompile(`\d+`)
+
+// Fingerprint identifies a finding across runs. It leaves out the line and
+// numbers in the message so that a finding keeps its fingerprint when code
+// above it moves. Findings carrying the tool's own fingerprint use it instead.
+func (f Finding) Fingerprint() string {
+	if f.ToolFingerprint != "" {
+		sum := sha256.Sum256([]byte(f.Tool + "\x00" + f.ToolFingerprint))
+		return hex.EncodeToString(sum[:])
+	}
+	msg := digits.ReplaceAllString(strings.TrimSpace(f.Message), "#")
+	sum := sha256.Sum256([]byte(f.Tool + "\x00" + f.RuleID + "\x00" + f.File + "\x00" + msg))
+	return hex.EncodeToString(sum[:])
+}
+
+// sarifLog is the subset of a SARIF 2.1.0 log read by ParseSARIF.
+type sarifLog struct {
+	Runs []struct {
+		Tool struct {
+			Driver struct {
+				Name  string `json:"name"`
+				Rules []struct {
+					ID                   string `json:"id"`
+					DefaultConfiguration struct {
+						Level string `json:"level"`
+					} `json:"defaultConfiguration"`
+					Properties struct {
+						Tags             []string `json:"tags"`
+						SecuritySeverity string   `json:"security-severity"`
+					} `json:"properties"`
+				} `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine int `json:"startLine"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// ParseSARIF reads the results of a SARIF log. Severity comes from the rule's
+// security-severity (CVSS score) if set, otherwise from the result level;
+// rules tagged security are vulnerabilities, other errors bugs, the rest code
+// smells.
+func ParseSARIF(tool string, data []byte) ([]Finding, error) {
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, run := range log.Runs {
+		type ruleInfo struct {
+			level    string
+			security bool
+			score    float64
+		}
+		rules := map[string]ruleInfo{}
+		for _, r := range run.Tool.Driver.Rules {
+			info := ruleInfo{level: r.DefaultConfiguration.Level}
+			for _, tag := range r.Properties.Tags {
+				if strings.EqualFold(tag, "security") {
+					info.security = true
+				}
+			}
+			if r.Properties.SecuritySeverity != "" {
+				info.score, _ = strconv.ParseFloat(r.Properties.SecuritySeverity, 64)
+				info.security = true
+			}
+			rules[r.ID] = info
+		}
+
+		for _, res := range run.Results {
+			rule := rules[res.RuleID]
+			level := res.Level
+			if level == "" {
+				level = rule.level
+			}
+			f := Finding{Tool: tool, RuleID: res.RuleID, Message: res.Message.Text}
+			if len(res.Locations) > 0 {
+				loc := res.Locations[0].PhysicalLocation
+				f.File = strings.TrimPrefix(loc.ArtifactLocation.URI, "file://")
+				f.Line = loc.Region.StartLine
+			}
+			switch {
+			case rule.score > 0:
+				f.Severity = cvssSeverity(rule.score)
+			case level == "error":
+				f.Severity = "high"
+			case level == "note" || level == "none":
+				f.Severity = "low"
+			default:
+				f.Severity = "medium"
+			}
+			switch {
+			case rule.security:
+				f.Category = CategoryVulnerability
+			case level == "error":
+				f.Category = CategoryBug
+			default:
+				f.Category = CategoryCodeSmell
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// golangciBugLinters report likely bugs rather than style issues.
+var golangciBugLinters = map[string]bool{
+	"govet": true, "staticcheck": true, "errcheck": true, "typecheck": true,
+	"ineffassign": true, "bodyclose": true, "nilerr": true, "rowserrcheck": true,
+	"sqlclosecheck": true, "errorlint": true, "exhaustive": true,
+}
+
+// ParseGolangciJSON reads the issues of golangci-lint's JSON output.
+func ParseGolangciJSON(data []byte) ([]Finding, error) {
+	var out struct {
+		Issues []struct {
+			FromLinter string `json:"FromLinter"`
+			Text       string `json:"Text"`
+			Severity   string `json:"Severity"`
+			Pos        struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0, len(out.Issues))
+	for _, issue := range out.Issues {
+		f := Finding{
+			Tool:    "golangci-lint",
+			RuleID:  issue.FromLinter,
+			File:    issue.Pos.Filename,
+			Line:    issue.Pos.Line,
+			Message: issue.Text,
+		}
+		switch {
+		case issue.FromLinter == "gosec":
+			f.Category, f.Severity = CategoryVulnerability, "high"
+		case golangciBugLinters[issue.FromLinter]:
+			f.Category, f.Severity = CategoryBug, "medium"
+		default:
+			f.Category, f.Severity = CategoryCodeSmell, "low"
+		}
+		if s := normalizeSeverity(issue.Severity); s != "" {
+			f.Severity = s
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// ParseGitleaksJSON reads the leaks of gitleaks' JSON report. Reports should
+// be produced with --redact; the secret itself is never kept. The description
+// is the same for every leak of a rule, so leaks are told apart by gitleaks'
+// fingerprint (commit:file:rule:line), or by file, rule and line without it.
+func ParseGitleaksJSON(data []byte) ([]Finding, error) {
+	var leaks []struct {
+		RuleID      string `json:"RuleID"`
+		Description string `json:"Description"`
+		File        string `json:"File"`
+		StartLine   int    `json:"StartLine"`
+		Fingerprint string `json:"Fingerprint"`
+	}
+	if err := json.Unmarshal(data, &leaks); err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0, len(leaks))
+	for _, leak := range leaks {
+		fingerprint := leak.Fingerprint
+		if fingerprint == "" {
+			fingerprint = leak.File + ":" + leak.RuleID + ":" + strconv.Itoa(leak.StartLine)
+		}
+		findings = append(findings, Finding{
+			Tool:     "gitleaks",
+			RuleID:   leak.RuleID,
+			Severity: "critical",
+			Category: CategorySecret,
+			File:     leak.File,
+			Line:     leak.StartLine,
+			Message:  leak.Description,
+
+			ToolFingerprint: fingerprint,
+		})
+	}
+	return findings, nil
+}
+
+func cvssSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "high"
+	case score >= 4:
+		return "medium"
+	case score > 0:
+		return "low"
+	default:
+		return "info"
+	}
+}
+
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(s) {
+	case "critical", "blocker":
+		return "critical"
+	case "high", "error", "major":
+		return "high"
+	case "medium", "warning":
+		return "medium"
+	case "low", "minor":
+		return "low"
+	case "info", "note":
+		return "info"
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Linter is a built-in static analysis tool run in a TaskRun. Its command
+// writes Report to $REPORT_DIR and exits 0 whether or not it finds issues.
+type Linter struct {
+	Image       string
+	Command     string // with %s for the arguments
+	DefaultArgs string // used when a scan config sets no arguments
+	Report      string
+	Parse       func(data []byte) ([]Finding, error)
+}
+
+// Linters are the built-in scan types by name.
+var Linters = map[string]Linter{
+	"golangci-lint": {
+		Image:   "golangci/golangci-lint:v1.64",
+		Command: `golangci-lint run --out-format json --issues-exit-code 0 %s ./... > "$REPORT_DIR/golangci-lint.json"`,
+		Report:  "golangci-lint.json",
+		Parse:   ParseGolangciJSON,
+	},
+	"semgrep": {
+		Image:       "semgrep/semgrep:latest",
+		Command:     `semgrep scan --sarif --output "$REPORT_DIR/semgrep.sarif" %s`,
+		DefaultArgs: "--config auto",
+		Report:      "semgrep.sarif",
+		Parse:       func(data []byte) ([]Finding, error) { return ParseSARIF("semgrep", data) },
+	},
+	"gitleaks": {
+		Image:   "zricethezav/gitleaks:latest",
+		Command: `gitleaks detect --source . --redact --exit-code 0 --report-format json --report-path "$REPORT_DIR/gitleaks.json" %s`,
+		Report:  "gitleaks.json",
+		Parse:   ParseGitleaksJSON,
+	},
+}
+
+// Script returns the script of a linter step: preCommand if set, then the
+// linter with args, or its default arguments.
+func (l Linter) Script(preCommand, args string) string {
+	if strings.TrimSpace(args) == "" {
+		args = l.DefaultArgs
+	}
+	var b strings.Builder
+	if strings.TrimSpace(preCommand) != "" {
+		b.WriteString("(\n" + preCommand + "\n) || echo \"warning: pre-scan command failed\"\n")
+	}
+	b.WriteString(fmt.Sprintf(l.Command, args) + "\n")
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TestCase is a single test case of a JUnit report.
+type TestCase struct {
+	Suite     string  `json:"suite"`
+	Name      string  `json:"name"`
+	ClassName string  `json:"class_name"`
+	Duration  float64 `json:"duration"` // seconds
+	Status    string  `json:"status"`   // passed/failed/skipped
+	Message   string  `json:"message,omitempty"`
+}
+
+// TestReport is the merged result of the reports of a test run.
+type TestReport struct {
+	Total    int
+	Passed   int
+	Failed   int
+	Skipped  int
+	Coverage *float64 // percent, nil without a coverage report
+	Cases    []TestCase
+	Duration float64 // seconds, summed over the test cases
+
+	covered, coverable int
+}
+
+type junitSuites struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type coberturaCoverage struct {
+	LinesCovered int     `xml:"lines-covered,attr"`
+	LinesValid   int     `xml:"lines-valid,attr"`
+	LineRate     float64 `xml:"line-rate,attr"`
+}
+
+// ParseReports merges the JUnit XML, Cobertura XML, LCOV and Go cover
+// profile reports among files. Formats are detected by content, other files
+// are ignored. Coverage is the covered share of all coverable lines (or
+// statements) across the coverage reports.
+func ParseReports(files map[string][]byte) *TestReport {
+	report := &TestReport{}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rates []float64
+	for _, name := range names {
+		data := bytes.TrimSpace(files[name])
+		head := string(data[:min(len(data), 512)])
+		switch {
+		case strings.HasPrefix(head, "mode:"):
+			report.addGoCover(data)
+		case strings.HasPrefix(head, "TN:") || strings.HasPrefix(head, "SF:"):
+			report.addLCOV(data)
+		case strings.Contains(head, "<coverage"):
+			var cov coberturaCoverage
+			if xml.Unmarshal(data, &cov) != nil {
+				continue
+			}
+			if cov.LinesValid > 0 {
+				report.covered += cov.LinesCovered
+				report.coverable += cov.LinesValid
+			} else {
+				rates = append(rates, cov.LineRate*100)
+			}
+		case strings.Contains(head, "<testsuites"):
+			var suites junitSuites
+			if xml.Unmarshal(data, &suites) == nil {
+				for _, s := range suites.Suites {
+					report.addSuite(s)
+				}
+			}
+		case strings.Contains(head, "<testsuite"):
+			var suite junitSuite
+			if xml.Unmarshal(data, &suite) == nil {
+				report.addSuite(suite)
+			}
+		}
+	}
+
+	switch {
+	case report.coverable > 0:
+		report.Coverage = roundPercent(float64(report.covered) * 100 / float64(report.coverable))
+	case len(rates) > 0:
+		sum := 0.0
+		for _, r := range rates {
+			sum += r
+		}
+		report.Coverage = roundPercent(sum / float64(len(rates)))
+	}
+	return report
+}
+
+func (r *TestReport) addSuite(s junitSuite) {
+	for _, nested := range s.Suites {
+		r.addSuite(nested)
+	}
+	for _, c := range s.Cases {
+		tc := TestCase{Suite: s.Name, Name: c.Name, ClassName: c.ClassName, Status: "passed"}
+		tc.Duration, _ = strconv.ParseFloat(c.Time, 64)
+		switch {
+		case c.Failure != nil:
+			tc.Status, tc.Message = "failed", c.Failure.text()
+		case c.Error != nil:
+			tc.Status, tc.Message = "failed", c.Error.text()
+		case c.Skipped != nil:
+			tc.Status, tc.Message = "skipped", c.Skipped.text()
+		}
+		r.Total++
+		switch tc.Status {
+		case "passed":
+			r.Passed++
+		case "failed":
+			r.Failed++
+		case "skipped":
+			r.Skipped++
+		}
+		r.Duration += tc.Duration
+		r.Cases = append(r.Cases, tc)
+	}
+}
+
+func (m *junitMessage) text() string {
+	if m.Message != "" {
+		return m.Message
+	}
+	return strings.TrimSpace(m.Text)
+}
+
+// addLCOV adds the LH/LF line totals of an LCOV tracefile.
+func (r *TestReport) addLCOV(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if v, ok := strings.CutPrefix(line, "LH:"); ok {
+			n, _ := strconv.Atoi(v)
+			r.covered += n
+		} else if v, ok := strings.CutPrefix(line, "LF:"); ok {
+			n, _ := strconv.Atoi(v)
+			r.coverable += n
+		}
+	}
+}
+
+// addGoCover adds the statements of a Go cover profile. Blocks listed more
+// than once (profiles merged from several packages) count as covered if any
+// entry has a count.
+func (r *TestReport) addGoCover(data []byte) {
+	type block struct{ stmts, count int }
+	blocks := map[string]block{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// file.go:1.2,3.4 stmts count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		stmts, err1 := strconv.Atoi(fields[1])
+		count, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		b := blocks[fields[0]]
+		b.stmts = stmts
+		b.count += count
+		blocks[fields[0]] = b
+	}
+	for _, b := range blocks {
+		r.coverable += b.stmts
+		if b.count > 0 {
+			r.covered += b.stmts
+		}
+	}
+}
+
+func roundPercent(v float64) *float64 {
+	v = math.Round(v*100) / 100
+	return &v
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"testing"
+)
+
+const junitSuitesReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="api">
+    <testcase name="TestCreate" classname="api" time="0.25"/>
+    <testcase name="TestDelete" classname="api" time="0.5">
+      <failure message="expected 204, got 500">stack</failure>
+    </testcase>
+    <testsuite name="api/nested">
+      <testcase name="TestNested" classname="api.nested" time="0.25">
+        <error>panic: nil map</error>
+      </testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+
+const junitSuiteReport = `<testsuite name="web">
+  <testcase name="renders" classname="web" time="1"/>
+  <testcase name="flaky" classname="web" time="0">
+    <skipped message="quarantined"/>
+  </testcase>
+</testsuite>`
+
+func TestParseReportsJUnit(t *testing.T) {
+	report := ParseReports(map[string][]byte{
+		"reports/api.xml":   []byte(junitSuitesReport),
+		"reports/web.xml":   []byte(junitSuiteReport),
+		"reports/notes.txt": []byte("not a report"),
+	})
+	if report.Total != 5 || report.Passed != 2 || report.Failed != 2 || report.Skipped != 1 {
+		t.Errorf("totals = %d/%d/%d/%d, want 5/2/2/1 (total/passed/failed/skipped)",
+			report.Total, report.Passed, report.Failed, report.Skipped)
+	}
+	if report.Duration != 2 {
+		t.Errorf("Duration = %v, want 2", report.Duration)
+	}
+	if report.Coverage != nil {
+		t.Errorf("Coverage = %v, want nil without a coverage report", *report.Coverage)
+	}
+
+	want := map[string]TestCase{
+		"TestDelete": {Suite: "api", Status: "failed", Message: "expected 204, got 500"},
+		"TestNested": {Suite: "api/nested", Status: "failed", Message: "panic: nil map"},
+		"flaky":      {Suite: "web", Status: "skipped", Message: "quarantined"},
+		"renders":    {Suite: "web", Status: "passed"},
+	}
+	for _, c := range report.Cases {
+		w, ok := want[c.Name]
+		if !ok {
+			continue
+		}
+		if c.Suite != w.Suite || c.Status != w.Status || c.Message != w.Message {
+			t.Errorf("case %s = %s/%s/%q, want %s/%s/%q", c.Name, c.Suite, c.Status, c.Message, w.Suite, w.Status, w.Message)
+		}
+	}
+}
+
+func TestParseReportsCoverage(t *testing.T) {
+	const goCover = `mode: set
+shop/cart.go:10.2,12.3 4 1
+shop/cart.go:14.2,16.3 6 0
+shop/order.go:5.2,8.3 10 0
+shop/order.go:5.2,8.3 10 1
+`
+	const lcov = `TN:
+SF:src/app.ts
+LF:40
+LH:30
+end_of_record
+SF:src/util.ts
+LF:10
+LH:0
+end_of_record
+`
+	const cobertura = `<?xml version="1.0" ?>
+<coverage lines-covered="45" lines-valid="50" line-rate="0.9"></coverage>`
+	const coberturaRateOnly = `<coverage line-rate="0.815"></coverage>`
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		want  *float64
+	}{
+		{"go cover profile merged across packages", map[string][]byte{"cover.out": []byte(goCover)}, percent(70)},
+		{"lcov", map[string][]byte{"lcov.info": []byte(lcov)}, percent(60)},
+		{"cobertura", map[string][]byte{"coverage.xml": []byte(cobertura)}, percent(90)},
+		{"cobertura line rate only", map[string][]byte{"coverage.xml": []byte(coberturaRateOnly)}, percent(81.5)},
+		{"lines summed across formats", map[string][]byte{
+			"cover.out":    []byte(goCover),
+			"lcov.info":    []byte(lcov),
+			"coverage.xml": []byte(cobertura),
+		}, percent(74.17)}, // (14 + 30 + 45) / (20 + 50 + 50)
+		{"no coverage report", map[string][]byte{"junit.xml": []byte(junitSuiteReport)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseReports(tt.files).Coverage
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Errorf("Coverage = %v, want %v", got, tt.want)
+			case *got != *tt.want:
+				t.Errorf("Coverage = %v, want %v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func percent(v float64) *float64 {
+	return &v
+}
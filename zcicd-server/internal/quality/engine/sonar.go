@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SonarClient reads analysis results from the SonarQube Web API.
+type SonarClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewSonarClient creates a client for baseURL (e.g. http://sonarqube:9000).
+// token, if set, is a user token sent with basic auth.
+func NewSonarClient(baseURL, token string) *SonarClient {
+	return &SonarClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// BaseURL returns the server URL the client talks to.
+func (c *SonarClient) BaseURL() string { return c.baseURL }
+
+// SonarTask is a compute engine task processing an analysis report.
+type SonarTask struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"` // PENDING/IN_PROGRESS/SUCCESS/FAILED/CANCELED
+	AnalysisID   string `json:"analysisId"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// SonarMeasures are the measures of a project's latest analysis.
+type SonarMeasures struct {
+	Bugs            int
+	Vulnerabilities int
+	CodeSmells      int
+	Coverage        *float64
+	Duplications    *float64
+	NewCoverage     *float64 // coverage on new code, per the project's new code definition
+	Rating          string   // maintainability rating A-E
+}
+
+// SonarTarget is the branch or pull request an analysis is reported for. A
+// pull request takes precedence; the zero value is the main branch.
+type SonarTarget struct {
+	Branch      string
+	PullRequest string // pull request key, e.g. its number
+	BaseBranch  string // target branch of the pull request
+}
+
+// ScannerProperties are the sonar-scanner analysis parameters reporting an
+// analysis for the target.
+func (t SonarTarget) ScannerProperties() map[string]string {
+	props := map[string]string{}
+	switch {
+	case t.PullRequest != "":
+		props["sonar.pullrequest.key"] = t.PullRequest
+		props["sonar.pullrequest.branch"] = t.Branch
+		if t.BaseBranch != "" {
+			props["sonar.pullrequest.base"] = t.BaseBranch
+		}
+	case t.Branch != "":
+		props["sonar.branch.name"] = t.Branch
+	}
+	return props
+}
+
+// DashboardQuery is the query string of the project dashboard of the target.
+func (t SonarTarget) DashboardQuery(projectKey string) string {
+	params := url.Values{"id": {projectKey}}
+	switch {
+	case t.PullRequest != "":
+		params.Set("pullRequest", t.PullRequest)
+	case t.Branch != "":
+		params.Set("branch", t.Branch)
+	}
+	return params.Encode()
+}
+
+// SonarReportTask is the report-task.txt the scanner writes after uploading
+// an analysis.
+type SonarReportTask struct {
+	ProjectKey   string
+	ServerURL    string
+	DashboardURL string
+	CeTaskID     string
+}
+
+// ParseReportTask parses a report-task.txt.
+func ParseReportTask(data []byte) SonarReportTask {
+	props := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "="); ok {
+			props[k] = v
+		}
+	}
+	return SonarReportTask{
+		ProjectKey:   props["projectKey"],
+		ServerURL:    props["serverUrl"],
+		DashboardURL: props["dashboardUrl"],
+		CeTaskID:     props["ceTaskId"],
+	}
+}
+
+// Task returns a compute engine task.
+func (c *SonarClient) Task(ctx context.Context, id string) (*SonarTask, error) {
+	var resp struct {
+		Task SonarTask `json:"task"`
+	}
+	if err := c.get(ctx, "/api/ce/task", url.Values{"id": {id}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Task, nil
+}
+
+// WaitTask polls a compute engine task until it is processed.
+func (c *SonarClient) WaitTask(ctx context.Context, id string, interval time.Duration) (*SonarTask, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		task, err := c.Task(ctx, id)
+		if err != nil {
+			fmt.Printf("warning: sonar task %s: %v\n", id, err)
+		} else {
+			switch task.Status {
+			case "SUCCESS":
+				return task, nil
+			case "FAILED", "CANCELED":
+				return task, fmt.Errorf("sonar task %s %s: %s", id, strings.ToLower(task.Status), task.ErrorMessage)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Measures returns the measures of the latest analysis of a project's branch
+// or pull request. Read right after WaitTask, that is the task's analysis.
+func (c *SonarClient) Measures(ctx context.Context, projectKey string, target SonarTarget) (*SonarMeasures, error) {
+	params := url.Values{
+		"component":  {projectKey},
+		"metricKeys": {"bugs,vulnerabilities,code_smells,coverage,duplicated_lines_density,sqale_rating,new_coverage"},
+	}
+	switch {
+	case target.PullRequest != "":
+		params.Set("pullRequest", target.PullRequest)
+	case target.Branch != "":
+		params.Set("branch", target.Branch)
+	}
+	var resp struct {
+		Component struct {
+			Measures []struct {
+				Metric string `json:"metric"`
+				Value  string `json:"value"`
+				// New code measures have their value in the period
+				// (periods before SonarQube 8.x).
+				Period struct {
+					Value string `json:"value"`
+				} `json:"period"`
+				Periods []struct {
+					Value string `json:"value"`
+				} `json:"periods"`
+			} `json:"measures"`
+		} `json:"component"`
+	}
+	if err := c.get(ctx, "/api/measures/component", params, &resp); err != nil {
+		return nil, err
+	}
+	m := &SonarMeasures{}
+	for _, measure := range resp.Component.Measures {
+		value := measure.Value
+		if value == "" {
+			value = measure.Period.Value
+		}
+		if value == "" && len(measure.Periods) > 0 {
+			value = measure.Periods[0].Value
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch measure.Metric {
+		case "bugs":
+			m.Bugs = int(v)
+		case "vulnerabilities":
+			m.Vulnerabilities = int(v)
+		case "code_smells":
+			m.CodeSmells = int(v)
+		case "coverage":
+			m.Coverage = &v
+		case "duplicated_lines_density":
+			m.Duplications = &v
+		case "new_coverage":
+			m.NewCoverage = &v
+		case "sqale_rating":
+			if v >= 1 && v <= 5 {
+				m.Rating = string(rune('A' + int(v) - 1))
+			}
+		}
+	}
+	return m, nil
+}
+
+// GateStatus returns the quality gate status (OK/WARN/ERROR/NONE) of an analysis.
+func (c *SonarClient) GateStatus(ctx context.Context, analysisID string) (string, error) {
+	var resp struct {
+		ProjectStatus struct {
+			Status string `json:"status"`
+		} `json:"projectStatus"`
+	}
+	if err := c.get(ctx, "/api/qualitygates/project_status", url.Values{"analysisId": {analysisID}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.ProjectStatus.Status, nil
+}
+
+func (c *SonarClient) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.SetBasicAuth(c.token, "")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sonar %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("sonar %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sonar %s: HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("sonar %s: %w", path, err)
+	}
+	return nil
+}
+
+// SonarScript returns the script of a sonar-scanner step: preCommand (e.g. to
+// produce coverage reports) if set, then the scanner, with report-task.txt
+// copied to ReportDir. The server URL and token are read by the scanner from
+// SONAR_HOST_URL and SONAR_TOKEN.
+func SonarScript(projectKey, revision, preCommand string, properties map[string]string) string {
+	var b strings.Builder
+	if strings.TrimSpace(preCommand) != "" {
+		b.WriteString("(\n" + preCommand + "\n) || echo \"warning: pre-scan command failed\"\n")
+	}
+	b.WriteString("sonar-scanner -Dsonar.projectKey=" + shellQuote(projectKey) +
+		" -Dsonar.working.directory=/tmp/.scannerwork")
+	if revision != "" {
+		b.WriteString(" -Dsonar.scm.revision=" + shellQuote(revision))
+	}
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(" " + shellQuote("-D"+k+"="+properties[k]))
+	}
+	b.WriteString("\nstatus=$?\ncp /tmp/.scannerwork/report-task.txt \"$REPORT_DIR/\" 2>/dev/null\nexit $status\n")
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSonar serves a compute engine task that is processed after a few polls
+// and the measures of one branch and one pull request of a project.
+type fakeSonar struct {
+	pending int    // polls answered with IN_PROGRESS before the final status
+	status  string // final task status
+	polls   int
+}
+
+func (f *fakeSonar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, _, ok := r.BasicAuth(); !ok || user != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/api/ce/task":
+		if q.Get("id") != "AX1" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"msg":"No activity found"}]}`)
+			return
+		}
+		f.polls++
+		status := f.status
+		if f.polls <= f.pending {
+			status = "IN_PROGRESS"
+		}
+		fmt.Fprintf(w, `{"task":{"id":"AX1","status":%q,"analysisId":"AN1","errorMessage":"boom"}}`, status)
+	case "/api/measures/component":
+		if q.Get("component") != "shop" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch {
+		case q.Get("pullRequest") == "42" && q.Get("branch") == "":
+			fmt.Fprint(w, `{"component":{"measures":[
+				{"metric":"bugs","value":"1"},
+				{"metric":"coverage","value":"71.5"},
+				{"metric":"new_coverage","periods":[{"index":1,"value":"90.0"}]},
+				{"metric":"sqale_rating","value":"2.0"}]}}`)
+		case q.Get("branch") == "feature/x" && q.Get("pullRequest") == "":
+			fmt.Fprint(w, `{"component":{"measures":[
+				{"metric":"bugs","value":"3"},
+				{"metric":"vulnerabilities","value":"2"},
+				{"metric":"code_smells","value":"17"},
+				{"metric":"duplicated_lines_density","value":"4.2"},
+				{"metric":"new_coverage","period":{"value":"55.0"}},
+				{"metric":"sqale_rating","value":"3.0"}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"errors":[{"msg":"unexpected query %s"}]}`, r.URL.RawQuery)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestSonarClientWaitTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		wantErr string
+	}{
+		{name: "success", status: "SUCCESS"},
+		{name: "failed", status: "FAILED", wantErr: "failed: boom"},
+		{name: "canceled", status: "CANCELED", wantErr: "canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sonar := &fakeSonar{pending: 2, status: tt.status}
+			srv := httptest.NewServer(sonar)
+			defer srv.Close()
+
+			task, err := NewSonarClient(srv.URL+"/", "token").WaitTask(context.Background(), "AX1", time.Millisecond)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("WaitTask error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WaitTask: %v", err)
+			}
+			if task.AnalysisID != "AN1" {
+				t.Errorf("AnalysisID = %q, want AN1", task.AnalysisID)
+			}
+			if sonar.polls != 3 {
+				t.Errorf("polled %d times, want 3", sonar.polls)
+			}
+		})
+	}
+}
+
+func TestSonarClientWaitTaskContext(t *testing.T) {
+	srv := httptest.NewServer(&fakeSonar{pending: 1 << 30})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := NewSonarClient(srv.URL, "token").WaitTask(ctx, "AX1", time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("WaitTask error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestSonarClientMeasures(t *testing.T) {
+	srv := httptest.NewServer(&fakeSonar{})
+	defer srv.Close()
+	client := NewSonarClient(srv.URL, "token")
+
+	pr, err := client.Measures(context.Background(), "shop", SonarTarget{Branch: "feature/x", PullRequest: "42", BaseBranch: "main"})
+	if err != nil {
+		t.Fatalf("Measures of pull request: %v", err)
+	}
+	if pr.Bugs != 1 || pr.Rating != "B" || pr.Coverage == nil || *pr.Coverage != 71.5 || pr.NewCoverage == nil || *pr.NewCoverage != 90 {
+		t.Errorf("pull request measures = %+v", pr)
+	}
+
+	branch, err := client.Measures(context.Background(), "shop", SonarTarget{Branch: "feature/x"})
+	if err != nil {
+		t.Fatalf("Measures of branch: %v", err)
+	}
+	if branch.Bugs != 3 || branch.Vulnerabilities != 2 || branch.CodeSmells != 17 || branch.Rating != "C" ||
+		branch.Duplications == nil || *branch.Duplications != 4.2 || branch.NewCoverage == nil || *branch.NewCoverage != 55 {
+		t.Errorf("branch measures = %+v", branch)
+	}
+	if branch.Coverage != nil {
+		t.Errorf("Coverage = %v, want none", *branch.Coverage)
+	}
+
+	if _, err := client.Measures(context.Background(), "shop", SonarTarget{}); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Measures of main branch error = %v, want HTTP 404", err)
+	}
+	if _, err := NewSonarClient(srv.URL, "wrong").Measures(context.Background(), "shop", SonarTarget{Branch: "feature/x"}); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("Measures with a bad token error = %v, want HTTP 401", err)
+	}
+}
+
+func TestSonarTargetScannerProperties(t *testing.T) {
+	pr := SonarTarget{Branch: "feature/x", PullRequest: "42", BaseBranch: "main"}.ScannerProperties()
+	if len(pr) != 3 || pr["sonar.pullrequest.key"] != "42" || pr["sonar.pullrequest.branch"] != "feature/x" || pr["sonar.pullrequest.base"] != "main" {
+		t.Errorf("pull request properties = %v", pr)
+	}
+	branch := SonarTarget{Branch: "feature/x"}.ScannerProperties()
+	if len(branch) != 1 || branch["sonar.branch.name"] != "feature/x" {
+		t.Errorf("branch properties = %v", branch)
+	}
+	if props := (SonarTarget{}).ScannerProperties(); len(props) != 0 {
+		t.Errorf("main branch properties = %v, want none", props)
+	}
+}
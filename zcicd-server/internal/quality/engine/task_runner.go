@@ -0,0 +1,339 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zcicd/zcicd-server/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var taskRunGVR = schema.GroupVersionResource{Group: "tekton.dev", Version: "v1", Resource: "taskruns"}
+
+// ReportDir is where a task's script should write its reports. Everything in
+// it is collected, along with the files matching the task's Collect patterns.
+const ReportDir = "/workspace/reports"
+
+// Markers around the collected reports in the collect step's log.
+const (
+	exitMarker   = "ZCICD_EXIT="
+	beginMarker  = "ZCICD_REPORTS_BEGIN"
+	endMarker    = "ZCICD_REPORTS_END"
+	collectStep  = "collect"
+	gitImage     = "alpine/git:latest"
+	collectImage = "alpine:latest"
+)
+
+// TaskSpec is a Tekton TaskRun that checks out a source revision, runs a
+// script in it and collects the reports the script produced.
+type TaskSpec struct {
+	Name      string
+	Labels    map[string]string
+	RepoURL   string
+	Branch    string
+	CommitSHA string
+	Image     string
+	Script    string
+	Env       map[string]string
+	SecretEnv map[string]string // env passed through a Secret owned by the TaskRun
+	Collect   []string          // file name patterns (find -name) collected from the source tree
+	Timeout   time.Duration
+}
+
+// TaskResult is the outcome of a finished TaskRun.
+type TaskResult struct {
+	Status     string // succeeded/failed/timeout/cancelled
+	Message    string
+	ExitCode   int // exit code of the script, -1 if it did not run
+	Archive    []byte
+	Files      map[string][]byte
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// TaskRunner runs TaskSpecs as Tekton TaskRuns in a namespace.
+type TaskRunner struct {
+	client    *k8s.K8sClient
+	namespace string
+}
+
+// NewTaskRunner creates a new TaskRunner.
+func NewTaskRunner(client *k8s.K8sClient, namespace string) *TaskRunner {
+	return &TaskRunner{client: client, namespace: namespace}
+}
+
+// Submit creates the TaskRun of a spec, and the Secret of its SecretEnv which
+// is owned by the TaskRun so it is deleted along with it.
+func (r *TaskRunner) Submit(ctx context.Context, spec TaskSpec) error {
+	secrets := r.client.Clientset.CoreV1().Secrets(r.namespace)
+	var secret *corev1.Secret
+	if len(spec.SecretEnv) > 0 {
+		var err error
+		secret, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   secretName(spec.Name),
+				Labels: map[string]string{"app.kubernetes.io/managed-by": "zcicd"},
+			},
+			StringData: spec.SecretEnv,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create secret of task run %s: %w", spec.Name, err)
+		}
+	}
+
+	obj := buildTaskRun(spec, r.namespace)
+	created, err := r.client.DynamicClient.Resource(taskRunGVR).Namespace(r.namespace).
+		Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		if secret != nil {
+			secrets.Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		}
+		return fmt.Errorf("failed to create task run %s: %w", spec.Name, err)
+	}
+	if secret != nil {
+		secret.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "tekton.dev/v1",
+			Kind:       "TaskRun",
+			Name:       created.GetName(),
+			UID:        created.GetUID(),
+		}}
+		if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			fmt.Printf("warning: failed to set owner of secret %s: %v\n", secret.Name, err)
+		}
+	}
+	return nil
+}
+
+func secretName(taskRun string) string {
+	return taskRun + "-env"
+}
+
+// Wait polls a TaskRun until it finishes and collects its reports.
+func (r *TaskRunner) Wait(ctx context.Context, name string, interval time.Duration) (*TaskResult, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		obj, err := r.client.DynamicClient.Resource(taskRunGVR).Namespace(r.namespace).
+			Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("task run %s was deleted", name)
+			}
+			fmt.Printf("warning: failed to get task run %s: %v\n", name, err)
+		} else if result := parseTaskRun(obj); result != nil {
+			r.collect(ctx, obj, result)
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect reads the reports the collect step printed to its log.
+func (r *TaskRunner) collect(ctx context.Context, obj *unstructured.Unstructured, result *TaskResult) {
+	podName, _, _ := unstructured.NestedString(obj.Object, "status", "podName")
+	if podName == "" {
+		return
+	}
+	raw, err := r.client.Clientset.CoreV1().Pods(r.namespace).
+		GetLogs(podName, &corev1.PodLogOptions{Container: "step-" + collectStep}).DoRaw(ctx)
+	if err != nil {
+		fmt.Printf("warning: failed to read reports of %s: %v\n", obj.GetName(), err)
+		return
+	}
+	exitCode, archive, err := parseCollectLog(string(raw))
+	if err != nil {
+		fmt.Printf("warning: invalid reports of %s: %v\n", obj.GetName(), err)
+	}
+	result.ExitCode = exitCode
+	result.Archive = archive
+	if len(archive) > 0 {
+		if result.Files, err = untar(archive); err != nil {
+			fmt.Printf("warning: invalid report archive of %s: %v\n", obj.GetName(), err)
+		}
+	}
+}
+
+// buildTaskRun renders a TaskSpec as an unstructured TaskRun. The script runs
+// with errors ignored so that reports are collected for failing tests too;
+// its exit code is reported by the collect step.
+func buildTaskRun(spec TaskSpec, namespace string) *unstructured.Unstructured {
+	labels := map[string]interface{}{"app.kubernetes.io/managed-by": "zcicd"}
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	var env []interface{}
+	env = append(env, map[string]interface{}{"name": "REPORT_DIR", "value": ReportDir})
+	for k, v := range spec.Env {
+		env = append(env, map[string]interface{}{"name": k, "value": v})
+	}
+	for k := range spec.SecretEnv {
+		env = append(env, map[string]interface{}{
+			"name": k,
+			"valueFrom": map[string]interface{}{
+				"secretKeyRef": map[string]interface{}{"name": secretName(spec.Name), "key": k},
+			},
+		})
+	}
+
+	clone := "git clone --single-branch"
+	if spec.Branch != "" {
+		clone += " --branch " + shellQuote(spec.Branch)
+	}
+	clone += " " + shellQuote(spec.RepoURL) + " /workspace/source"
+	if spec.CommitSHA != "" {
+		clone += "\ncd /workspace/source && git checkout " + shellQuote(spec.CommitSHA)
+	}
+
+	script := "#!/bin/sh\nmkdir -p " + ReportDir + "\n(\n" + spec.Script + "\n)\necho $? > " + ReportDir + "/.exit-code\n"
+
+	var patterns []string
+	for _, p := range spec.Collect {
+		patterns = append(patterns, "-name "+shellQuote(p))
+	}
+	collect := "#!/bin/sh\ncd /workspace\nfind reports -type f > /tmp/files 2>/dev/null\n"
+	if len(patterns) > 0 {
+		collect += "find source -type f \\( " + strings.Join(patterns, " -o ") + " \\) " +
+			"-not -path '*/.git/*' -not -path '*/node_modules/*' >> /tmp/files 2>/dev/null\n"
+	}
+	collect += "tar czf /tmp/reports.tgz -T /tmp/files\n" +
+		"echo \"" + exitMarker + "$(cat reports/.exit-code 2>/dev/null || echo -1)\"\n" +
+		"echo " + beginMarker + "\nbase64 /tmp/reports.tgz\necho " + endMarker + "\n"
+
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "tekton.dev/v1",
+		"kind":       "TaskRun",
+		"metadata": map[string]interface{}{
+			"name":      spec.Name,
+			"namespace": namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]interface{}{
+			"timeout": timeout.String(),
+			"taskSpec": map[string]interface{}{
+				"steps": []interface{}{
+					map[string]interface{}{"name": "git-clone", "image": gitImage, "script": "#!/bin/sh\nset -e\n" + clone + "\n"},
+					map[string]interface{}{"name": "run", "image": spec.Image, "workingDir": "/workspace/source", "env": env, "script": script},
+					map[string]interface{}{"name": collectStep, "image": collectImage, "script": collect},
+				},
+			},
+		},
+	}}
+}
+
+// parseTaskRun returns the result of a finished TaskRun, or nil while it runs.
+func parseTaskRun(obj *unstructured.Unstructured) *TaskResult {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if len(conditions) == 0 {
+		return nil
+	}
+	cond, _ := conditions[0].(map[string]interface{})
+	status, _ := cond["status"].(string)
+	reason, _ := cond["reason"].(string)
+	message, _ := cond["message"].(string)
+
+	result := &TaskResult{Message: message, ExitCode: -1}
+	switch {
+	case status == "True":
+		result.Status = "succeeded"
+	case status == "False" && reason == "TaskRunTimeout":
+		result.Status = "timeout"
+	case status == "False" && reason == "TaskRunCancelled":
+		result.Status = "cancelled"
+	case status == "False":
+		result.Status = "failed"
+	default:
+		return nil
+	}
+	if s, ok, _ := unstructured.NestedString(obj.Object, "status", "startTime"); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			result.StartedAt = &t
+		}
+	}
+	if s, ok, _ := unstructured.NestedString(obj.Object, "status", "completionTime"); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			result.FinishedAt = &t
+		}
+	}
+	return result
+}
+
+// parseCollectLog extracts the script's exit code and the report archive
+// from the collect step's log.
+func parseCollectLog(log string) (int, []byte, error) {
+	exitCode := -1
+	var encoded strings.Builder
+	inArchive := false
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, exitMarker):
+			if v, err := strconv.Atoi(strings.TrimPrefix(line, exitMarker)); err == nil {
+				exitCode = v
+			}
+		case line == beginMarker:
+			inArchive = true
+		case line == endMarker:
+			inArchive = false
+		case inArchive:
+			encoded.WriteString(line)
+		}
+	}
+	if encoded.Len() == 0 {
+		return exitCode, nil, nil
+	}
+	archive, err := base64.StdEncoding.DecodeString(encoded.String())
+	return exitCode, archive, err
+}
+
+// untar reads the regular files of a gzipped tar archive.
+func untar(archive []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return files, err
+		}
+		files[hdr.Name] = data
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
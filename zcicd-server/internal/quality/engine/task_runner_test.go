@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseCollectLog(t *testing.T) {
+	archive := []byte("not really a tarball")
+	encoded := base64.StdEncoding.EncodeToString(archive)
+
+	tests := []struct {
+		name        string
+		log         string
+		wantExit    int
+		wantArchive string
+		wantErr     bool
+	}{
+		{
+			name:        "exit code and archive split over lines",
+			log:         "collecting\n" + exitMarker + "1\n" + beginMarker + "\n" + encoded[:8] + "\n  " + encoded[8:] + "\n" + endMarker + "\ndone\n",
+			wantExit:    1,
+			wantArchive: string(archive),
+		},
+		{
+			name:     "no reports",
+			log:      exitMarker + "0\n" + beginMarker + "\n" + endMarker + "\n",
+			wantExit: 0,
+		},
+		{
+			name:     "script never ran",
+			log:      "pod evicted\n",
+			wantExit: -1,
+		},
+		{
+			name:     "invalid exit code",
+			log:      exitMarker + "abc\n",
+			wantExit: -1,
+		},
+		{
+			name:     "corrupt archive",
+			log:      exitMarker + "0\n" + beginMarker + "\n!!!\n" + endMarker + "\n",
+			wantExit: 0,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exit, got, err := parseCollectLog(tt.log)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCollectLog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if exit != tt.wantExit {
+				t.Errorf("exit code = %d, want %d", exit, tt.wantExit)
+			}
+			if !tt.wantErr && string(got) != tt.wantArchive {
+				t.Errorf("archive = %q, want %q", got, tt.wantArchive)
+			}
+		})
+	}
+}
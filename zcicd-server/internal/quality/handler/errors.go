@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/response"
+)
+
+// writeAppError renders an AppError with a status matching its code and reports
+// whether err was one.
+func writeAppError(c *gin.Context, err error) bool {
+	var appErr *appErrors.AppError
+	if !errors.As(err, &appErr) {
+		return false
+	}
+	response.Error(c, appErrorStatus(appErr.Code), appErr.Code, appErr.Message)
+	return true
+}
+
+func appErrorStatus(code int) int {
+	switch code {
+	case appErrors.ErrTestQuarantined.Code:
+		return http.StatusConflict
+	case appErrors.ErrTestConfigNotFound.Code,
+		appErrors.ErrScanConfigNotFound.Code,
+		appErrors.ErrTestRunNotFound.Code,
+		appErrors.ErrTestReportNotFound.Code,
+		appErrors.ErrQuarantineNotFound.Code,
+		appErrors.ErrSuppressionNotFound.Code,
+		appErrors.ErrGateResultNotFound.Code,
+		appErrors.ErrBuildRunNotFound.Code:
+		return http.StatusNotFound
+	}
+	switch {
+	case code >= 50000:
+		return http.StatusInternalServerError
+	case code >= 40300 && code < 40400:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
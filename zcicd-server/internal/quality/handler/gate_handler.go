@@ -42,3 +42,67 @@ func (h *QualityGateHandler) Upsert(c *gin.Context) {
 	}
 	response.OK(c, g)
 }
+
+// Evaluate re-evaluates the quality gate of a commit, e.g. after the gate changed.
+func (h *QualityGateHandler) Evaluate(c *gin.Context) {
+	var req service.EvaluateGateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	res, err := h.svc.Evaluate(c.Param("project_id"), req.CommitSHA)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	if res == nil {
+		response.NotFound(c, "质量门禁未配置")
+		return
+	}
+	response.OK(c, res)
+}
+
+func (h *QualityGateHandler) ListResults(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	filters := map[string]string{
+		"branch":       c.Query("branch"),
+		"pull_request": c.Query("pull_request"),
+		"status":       c.Query("status"),
+	}
+	list, total, err := h.svc.ListResults(c.Param("project_id"), page, pageSize, filters)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+// BranchResults returns the latest result of each branch of a project.
+func (h *QualityGateHandler) BranchResults(c *gin.Context) {
+	h.latestResults(c, false)
+}
+
+// PullRequestResults returns the latest result of each pull request of a project.
+func (h *QualityGateHandler) PullRequestResults(c *gin.Context) {
+	h.latestResults(c, true)
+}
+
+func (h *QualityGateHandler) latestResults(c *gin.Context, pullRequests bool) {
+	list, err := h.svc.LatestResults(c.Param("project_id"), pullRequests)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *QualityGateHandler) GetResult(c *gin.Context) {
+	res, err := h.svc.GetResult(c.Param("project_id"), c.Param("sha"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, res)
+}
@@ -22,7 +22,9 @@ func (h *ScanHandler) CreateConfig(c *gin.Context) {
 	}
 	cfg, err := h.svc.CreateConfig(c.Param("project_id"), req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 	response.Created(c, cfg)
@@ -45,7 +47,9 @@ func (h *ScanHandler) UpdateConfig(c *gin.Context) {
 	}
 	cfg, err := h.svc.UpdateConfig(c.Param("sid"), req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 	response.OK(c, cfg)
@@ -70,9 +74,16 @@ func (h *ScanHandler) ListConfigs(c *gin.Context) {
 }
 
 func (h *ScanHandler) TriggerRun(c *gin.Context) {
-	run, err := h.svc.TriggerRun(c.Param("sid"))
+	var req service.TriggerScanRunReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	run, err := h.svc.TriggerRun(c.Request.Context(), c.Param("sid"), req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 	response.Created(c, run)
@@ -96,3 +107,75 @@ func (h *ScanHandler) ListRuns(c *gin.Context) {
 	}
 	response.OKWithPage(c, list, total, page, pageSize)
 }
+
+// ListFindings lists the findings of a run, filtered by severity, category,
+// tool, rule, file prefix, status and new (not in the baseline).
+func (h *ScanHandler) ListFindings(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	filters := map[string]string{
+		"severity": c.Query("severity"),
+		"category": c.Query("category"),
+		"tool":     c.Query("tool"),
+		"rule_id":  c.Query("rule_id"),
+		"file":     c.Query("file"),
+		"status":   c.Query("status"),
+		"new":      c.Query("new"),
+	}
+	list, total, err := h.svc.ListFindings(c.Param("rid"), page, pageSize, filters)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+func (h *ScanHandler) ListSuppressions(c *gin.Context) {
+	list, err := h.svc.ListSuppressions(c.Param("sid"))
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *ScanHandler) CreateSuppression(c *gin.Context) {
+	var req service.CreateSuppressionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	sup, err := h.svc.CreateSuppression(c.Param("sid"), c.GetString("user_id"), req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.Created(c, sup)
+}
+
+func (h *ScanHandler) DeleteSuppression(c *gin.Context) {
+	if err := h.svc.DeleteSuppression(c.Param("sid"), c.Param("supid")); err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, nil)
+}
+
+func (h *ScanHandler) SetBaseline(c *gin.Context) {
+	var req service.SetScanBaselineReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	cfg, err := h.svc.SetBaseline(c.Param("sid"), req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, cfg)
+}
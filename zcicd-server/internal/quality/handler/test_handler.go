@@ -72,9 +72,16 @@ func (h *TestHandler) ListConfigs(c *gin.Context) {
 }
 
 func (h *TestHandler) TriggerRun(c *gin.Context) {
-	run, err := h.svc.TriggerRun(c.Param("id"))
+	var req service.TriggerTestRunReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	run, err := h.svc.TriggerRun(c.Request.Context(), c.Param("id"), req)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 	response.Created(c, run)
@@ -89,6 +96,18 @@ func (h *TestHandler) GetRun(c *gin.Context) {
 	response.OK(c, run)
 }
 
+// GetReport returns a download link of the raw reports of a run.
+func (h *TestHandler) GetReport(c *gin.Context) {
+	url, err := h.svc.ReportURL(c.Request.Context(), c.Param("run_id"))
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, gin.H{"url": url})
+}
+
 func (h *TestHandler) ListRuns(c *gin.Context) {
 	page, pageSize := parsePagination(c)
 	list, total, err := h.svc.ListRuns(c.Param("id"), page, pageSize)
@@ -99,6 +118,91 @@ func (h *TestHandler) ListRuns(c *gin.Context) {
 	response.OKWithPage(c, list, total, page, pageSize)
 }
 
+// ListCases lists the test cases of a run, optionally filtered by status.
+func (h *TestHandler) ListCases(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	list, total, err := h.svc.ListCaseResults(c.Param("run_id"), c.Query("status"), page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+// CaseHistory returns the results of the test given by ?key= across runs.
+func (h *TestHandler) CaseHistory(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		response.BadRequest(c, "缺少测试用例标识 key")
+		return
+	}
+	page, pageSize := parsePagination(c)
+	list, total, err := h.svc.CaseHistory(c.Param("id"), key, page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OKWithPage(c, list, total, page, pageSize)
+}
+
+// FlakyTests lists the flaky tests over the latest ?runs= runs.
+func (h *TestHandler) FlakyTests(c *gin.Context) {
+	runs, _ := strconv.Atoi(c.Query("runs"))
+	list, err := h.svc.FlakyTests(c.Param("id"), runs)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+// SlowTests lists the slowest tests over the latest ?runs= runs.
+func (h *TestHandler) SlowTests(c *gin.Context) {
+	runs, _ := strconv.Atoi(c.Query("runs"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	list, err := h.svc.SlowTests(c.Param("id"), runs, limit)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *TestHandler) ListQuarantines(c *gin.Context) {
+	list, err := h.svc.ListQuarantines(c.Param("id"))
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, list)
+}
+
+func (h *TestHandler) Quarantine(c *gin.Context) {
+	var req service.QuarantineTestReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	q, err := h.svc.Quarantine(c.Param("id"), c.GetString("user_id"), req)
+	if err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.Created(c, q)
+}
+
+func (h *TestHandler) Unquarantine(c *gin.Context) {
+	if err := h.svc.Unquarantine(c.Param("id"), c.Param("qid")); err != nil {
+		if !writeAppError(c, err) {
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+	response.OK(c, nil)
+}
+
 func parsePagination(c *gin.Context) (int, int) {
 	page := 1
 	pageSize := 20
@@ -0,0 +1,13 @@
+package model
+
+// BuildRun is a read-only view of the build_runs table owned by the workflow
+// service, joined with the source repository of its build config.
+type BuildRun struct {
+	ID            string `json:"id"`
+	BuildConfigID string `json:"build_config_id"`
+	ProjectID     string `json:"project_id"`
+	Status        string `json:"status"`
+	RepoURL       string `json:"repo_url"`
+	Branch        string `json:"branch"`
+	CommitSHA     string `json:"commit_sha"`
+}
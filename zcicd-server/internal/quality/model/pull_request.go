@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// PullRequest is a pull/merge request seen by the workflow service's SCM
+// webhooks. Commits of its branch are compared with its base branch.
+type PullRequest struct {
+	ID         string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	RepoURL    string    `json:"repo_url" gorm:"size:512;not null"`
+	Number     int       `json:"number" gorm:"not null"`
+	Title      string    `json:"title" gorm:"size:512"`
+	Branch     string    `json:"branch" gorm:"size:128;not null"`
+	BaseBranch string    `json:"base_branch" gorm:"size:128"`
+	CommitSHA  string    `json:"commit_sha" gorm:"size:64"`
+	State      string    `json:"state" gorm:"size:16;not null;default:'open'"` // open/closed/merged
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (PullRequest) TableName() string { return "quality_pull_requests" }
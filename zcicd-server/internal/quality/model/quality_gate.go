@@ -3,16 +3,23 @@ package model
 import "time"
 
 type QualityGate struct {
-	ID                  string   `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	ProjectID           string   `json:"project_id" gorm:"type:uuid;not null;uniqueIndex"`
-	MinCoverage         *float64 `json:"min_coverage" gorm:"type:numeric(5,2);default:80.00"`
-	MaxBugs             int      `json:"max_bugs" gorm:"default:0"`
-	MaxVulnerabilities  int      `json:"max_vulnerabilities" gorm:"default:0"`
-	MaxCodeSmells       int      `json:"max_code_smells" gorm:"default:50"`
-	MaxDuplications     *float64 `json:"max_duplications" gorm:"type:numeric(5,2);default:5.00"`
-	BlockDeploy         bool     `json:"block_deploy" gorm:"default:false"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                 string   `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID          string   `json:"project_id" gorm:"type:uuid;not null;uniqueIndex"`
+	MinCoverage        *float64 `json:"min_coverage" gorm:"type:numeric(5,2);default:80.00"`
+	MaxBugs            int      `json:"max_bugs" gorm:"default:0"`
+	MaxVulnerabilities int      `json:"max_vulnerabilities" gorm:"default:0"`
+	MaxCodeSmells      int      `json:"max_code_smells" gorm:"default:50"`
+	MaxDuplications    *float64 `json:"max_duplications" gorm:"type:numeric(5,2);default:5.00"`
+	MaxFailedTests     int      `json:"max_failed_tests" gorm:"default:0"` // quarantined failures excluded
+	// New-code conditions, measured against the latest scans of the baseline
+	// branch (the project's default branch if empty); nil disables a condition.
+	BaselineBranch        string    `json:"baseline_branch" gorm:"size:128"`
+	MinNewCoverage        *float64  `json:"min_new_coverage" gorm:"type:numeric(5,2)"`
+	MaxNewVulnerabilities *int      `json:"max_new_vulnerabilities"`
+	MaxNewCodeSmells      *int      `json:"max_new_code_smells"`
+	BlockDeploy           bool      `json:"block_deploy" gorm:"default:false"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 func (QualityGate) TableName() string { return "quality_gates" }
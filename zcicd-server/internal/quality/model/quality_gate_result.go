@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// QualityGateResult is the evaluation of a project's quality gate against the
+// latest test and scan runs of a commit.
+type QualityGateResult struct {
+	ID                string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProjectID         string         `json:"project_id" gorm:"type:uuid;not null;index"`
+	CommitSHA         string         `json:"commit_sha" gorm:"size:64;not null"`
+	Branch            string         `json:"branch" gorm:"size:128"`
+	PullRequest       *int           `json:"pull_request"`
+	BaselineBranch    string         `json:"baseline_branch" gorm:"size:128"`
+	BaselineCommitSHA string         `json:"baseline_commit_sha" gorm:"size:64"`
+	Status            string         `json:"status" gorm:"size:16;not null"` // passed/failed/pending
+	Conditions        datatypes.JSON `json:"conditions"`                     // []GateCondition
+	ScanRunIDs        datatypes.JSON `json:"scan_run_ids"`
+	TestRunIDs        datatypes.JSON `json:"test_run_ids"`
+	EvaluatedAt       time.Time      `json:"evaluated_at"`
+	CreatedAt         time.Time      `json:"created_at"`
+}
+
+func (QualityGateResult) TableName() string { return "quality_gate_results" }
+
+// GateCondition is the outcome of one threshold of a quality gate. Actual is
+// nil when no run of the commit reported the metric.
+type GateCondition struct {
+	Metric    string   `json:"metric"`   // coverage/bugs/vulnerabilities/code_smells/duplications/failed_tests, new_* for new code, scans
+	Operator  string   `json:"operator"` // gte/lte
+	Threshold float64  `json:"threshold"`
+	Actual    *float64 `json:"actual"`
+	Status    string   `json:"status"` // passed/failed/no_data/pending
+}
@@ -13,6 +13,7 @@ type ScanConfig struct {
 	ScanType        string         `json:"scan_type" gorm:"size:32;not null;default:'sonar'"`
 	SonarProjectKey string         `json:"sonar_project_key" gorm:"size:256"`
 	Config          datatypes.JSON `json:"config" gorm:"default:'{}'"`
+	BaselineRunID   *string        `json:"baseline_run_id" gorm:"type:uuid"`
 	Enabled         bool           `json:"enabled" gorm:"default:true"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
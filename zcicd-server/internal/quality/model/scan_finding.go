@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// ScanFinding is an issue found by a built-in static analysis scan.
+type ScanFinding struct {
+	ID            string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ScanRunID     string    `json:"scan_run_id" gorm:"type:uuid;not null;index"`
+	ScanConfigID  string    `json:"scan_config_id" gorm:"type:uuid;not null;index"`
+	Tool          string    `json:"tool" gorm:"size:32;not null"`
+	RuleID        string    `json:"rule_id" gorm:"size:256;not null"`
+	Severity      string    `json:"severity" gorm:"size:16;not null"`
+	Category      string    `json:"category" gorm:"size:32;not null"`
+	File          string    `json:"file" gorm:"size:512"`
+	Line          int       `json:"line"`
+	Message       string    `json:"message"`
+	Fingerprint   string    `json:"fingerprint" gorm:"size:64;not null"`
+	Status        string    `json:"status" gorm:"size:16;not null;default:'open'"` // open/suppressed
+	SuppressionID *string   `json:"suppression_id" gorm:"type:uuid"`
+	Baseline      bool      `json:"baseline" gorm:"default:false"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (ScanFinding) TableName() string { return "scan_findings" }
+
+// ScanSuppression hides findings of a scan config: a single finding by its
+// fingerprint, or the findings of a rule, optionally only in some files.
+type ScanSuppression struct {
+	ID           string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ScanConfigID string     `json:"scan_config_id" gorm:"type:uuid;not null;index"`
+	Fingerprint  string     `json:"fingerprint,omitempty" gorm:"size:64"`
+	RuleID       string     `json:"rule_id,omitempty" gorm:"size:256"`
+	PathPattern  string     `json:"path_pattern,omitempty" gorm:"size:512"`
+	Reason       string     `json:"reason" gorm:"not null"`
+	CreatedBy    *string    `json:"created_by" gorm:"type:uuid"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (ScanSuppression) TableName() string { return "scan_suppressions" }
@@ -5,15 +5,23 @@ import "time"
 type ScanRun struct {
 	ID             string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	ScanConfigID   string     `json:"scan_config_id" gorm:"type:uuid;not null;index"`
+	BuildRunID     string     `json:"build_run_id" gorm:"type:uuid"`
+	Branch         string     `json:"branch" gorm:"size:128"`
+	CommitSHA      string     `json:"commit_sha" gorm:"size:64"`
+	PullRequest    *int       `json:"pull_request"`
 	Status         string     `json:"status" gorm:"size:32;not null;default:'pending'"`
 	Bugs           int        `json:"bugs" gorm:"default:0"`
 	Vulnerabilities int       `json:"vulnerabilities" gorm:"default:0"`
 	CodeSmells     int        `json:"code_smells" gorm:"default:0"`
+	NewFindings    int        `json:"new_findings" gorm:"default:0"`
 	Coverage       *float64   `json:"coverage" gorm:"type:numeric(5,2)"`
+	NewCoverage    *float64   `json:"new_coverage" gorm:"type:numeric(5,2)"`
 	Duplications   *float64   `json:"duplications" gorm:"type:numeric(5,2)"`
 	QualityRating  string     `json:"quality_rating" gorm:"size:1"`
 	GateStatus     string     `json:"gate_status" gorm:"size:16"`
 	ReportURL      string     `json:"report_url" gorm:"size:512"`
+	TektonRef      string     `json:"tekton_ref" gorm:"size:256"`
+	AnalysisID     string     `json:"analysis_id" gorm:"size:64"`
 	ErrorMessage   string     `json:"error_message,omitempty"`
 	StartedAt      *time.Time `json:"started_at"`
 	FinishedAt     *time.Time `json:"finished_at"`
@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+// TestCaseResult is the result of one test case in a test run.
+type TestCaseResult struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TestRunID    string    `json:"test_run_id" gorm:"type:uuid;not null;index"`
+	TestConfigID string    `json:"test_config_id" gorm:"type:uuid;not null;index"`
+	TestKey      string    `json:"test_key" gorm:"size:512;not null"`
+	Suite        string    `json:"suite" gorm:"size:256"`
+	ClassName    string    `json:"class_name" gorm:"size:256"`
+	Name         string    `json:"name" gorm:"size:256;not null"`
+	Status       string    `json:"status" gorm:"size:16;not null"` // passed/failed/skipped
+	Duration     int       `json:"duration"`                       // milliseconds
+	Message      string    `json:"message,omitempty"`
+	Quarantined  bool      `json:"quarantined" gorm:"default:false"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (TestCaseResult) TableName() string { return "test_case_results" }
+
+// TestQuarantine excludes the failures of a test from the status of its runs.
+type TestQuarantine struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TestConfigID string    `json:"test_config_id" gorm:"type:uuid;not null;index"`
+	TestKey      string    `json:"test_key" gorm:"size:512;not null"`
+	Reason       string    `json:"reason"`
+	CreatedBy    *string   `json:"created_by" gorm:"type:uuid"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (TestQuarantine) TableName() string { return "test_quarantines" }
+
+// TestCaseHistory is a result of a test case with the commit of its run.
+type TestCaseHistory struct {
+	TestRunID string    `json:"test_run_id"`
+	CommitSHA string    `json:"commit_sha"`
+	Status    string    `json:"status"`
+	Duration  int       `json:"duration"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FlakyTest is a test with differing results on the same commit.
+type FlakyTest struct {
+	TestKey      string    `json:"test_key"`
+	Suite        string    `json:"suite"`
+	ClassName    string    `json:"class_name"`
+	Name         string    `json:"name"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	Flips        int       `json:"flips"`         // result changes between consecutive runs
+	FlakyCommits int       `json:"flaky_commits"` // commits with both passing and failing results
+	LastFlakyAt  time.Time `json:"last_flaky_at"`
+	Quarantined  bool      `json:"quarantined"`
+}
+
+// SlowTest is the duration of a test over recent runs.
+type SlowTest struct {
+	TestKey     string  `json:"test_key"`
+	Suite       string  `json:"suite"`
+	ClassName   string  `json:"class_name"`
+	Name        string  `json:"name"`
+	Runs        int     `json:"runs"`
+	AvgDuration float64 `json:"avg_duration"` // milliseconds
+	MaxDuration int     `json:"max_duration"` // milliseconds
+}
+
+// TestCaseRun is a test case result with the commit of its run.
+type TestCaseRun struct {
+	TestCaseResult
+	CommitSHA string `json:"commit_sha"`
+}
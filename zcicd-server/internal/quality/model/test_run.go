@@ -6,14 +6,17 @@ type TestRun struct {
 	ID           string     `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	TestConfigID string     `json:"test_config_id" gorm:"type:uuid;not null;index"`
 	BuildRunID   string     `json:"build_run_id" gorm:"type:uuid"`
+	CommitSHA    string     `json:"commit_sha" gorm:"size:64"`
 	Status       string     `json:"status" gorm:"size:32;not null;default:'pending'"`
 	Total        int        `json:"total" gorm:"default:0"`
 	Passed       int        `json:"passed" gorm:"default:0"`
 	Failed       int        `json:"failed" gorm:"default:0"`
 	Skipped      int        `json:"skipped" gorm:"default:0"`
+	Quarantined  int        `json:"quarantined" gorm:"default:0"`
 	Coverage     *float64   `json:"coverage" gorm:"type:numeric(5,2)"`
 	Duration     *int       `json:"duration"`
 	ReportURL    string     `json:"report_url" gorm:"size:512"`
+	TektonRef    string     `json:"tekton_ref" gorm:"size:256"`
 	ErrorMessage string     `json:"error_message,omitempty"`
 	StartedAt    *time.Time `json:"started_at"`
 	FinishedAt   *time.Time `json:"finished_at"`
@@ -22,7 +22,234 @@ func (r *QualityGateRepository) Get(projectID string) (*model.QualityGate, error
 
 func (r *QualityGateRepository) Upsert(g *model.QualityGate) error {
 	return r.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "project_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"min_coverage", "max_bugs", "max_vulnerabilities", "max_code_smells", "max_duplications", "block_deploy", "updated_at"}),
+		Columns: []clause.Column{{Name: "project_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"min_coverage", "max_bugs", "max_vulnerabilities", "max_code_smells", "max_duplications", "max_failed_tests",
+			"baseline_branch", "min_new_coverage", "max_new_vulnerabilities", "max_new_code_smells", "block_deploy", "updated_at",
+		}),
 	}).Create(g).Error
 }
+
+// LatestScanRuns returns the latest completed run of each scan config of a
+// project for a commit.
+func (r *QualityGateRepository) LatestScanRuns(projectID, commitSHA string) ([]model.ScanRun, error) {
+	var list []model.ScanRun
+	err := r.db.Table("scan_runs").
+		Select("DISTINCT ON (scan_runs.scan_config_id) scan_runs.*").
+		Joins("JOIN scan_configs ON scan_configs.id = scan_runs.scan_config_id").
+		Where("scan_configs.project_id = ? AND scan_runs.commit_sha = ? AND scan_runs.status = ?", projectID, commitSHA, "completed").
+		Order("scan_runs.scan_config_id, scan_runs.created_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// RepoScanConfigIDs returns the enabled scan configs of a project that have
+// scanned a build of the repository.
+func (r *QualityGateRepository) RepoScanConfigIDs(projectID, repoURL string) ([]string, error) {
+	var ids []string
+	if repoURL == "" {
+		return ids, nil
+	}
+	scanned := r.db.Table("scan_runs").Select("1").
+		Joins("JOIN build_runs ON build_runs.id = scan_runs.build_run_id").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("scan_runs.scan_config_id = scan_configs.id AND build_configs.repo_url = ?", repoURL)
+	err := r.db.Table("scan_configs").
+		Where("scan_configs.project_id = ? AND scan_configs.enabled = ?", projectID, true).
+		Where("EXISTS (?)", scanned).
+		Pluck("scan_configs.id", &ids).Error
+	return ids, err
+}
+
+// ScanRunStatuses returns by scan config the status of the latest finished
+// (completed or failed) scan run of a project for a commit.
+func (r *QualityGateRepository) ScanRunStatuses(projectID, commitSHA string) (map[string]string, error) {
+	var rows []struct {
+		ScanConfigID string
+		Status       string
+	}
+	err := r.db.Table("scan_runs").
+		Select("DISTINCT ON (scan_runs.scan_config_id) scan_runs.scan_config_id, scan_runs.status").
+		Joins("JOIN scan_configs ON scan_configs.id = scan_runs.scan_config_id").
+		Where("scan_configs.project_id = ? AND scan_runs.commit_sha = ? AND scan_runs.status IN ?", projectID, commitSHA, []string{"completed", "failed"}).
+		Order("scan_runs.scan_config_id, scan_runs.created_at DESC").
+		Scan(&rows).Error
+	statuses := make(map[string]string, len(rows))
+	for _, row := range rows {
+		statuses[row.ScanConfigID] = row.Status
+	}
+	return statuses, err
+}
+
+// LatestTestRuns returns the latest finished run of each test config of a
+// project for a commit.
+func (r *QualityGateRepository) LatestTestRuns(projectID, commitSHA string) ([]model.TestRun, error) {
+	var list []model.TestRun
+	err := r.db.Table("test_runs").
+		Select("DISTINCT ON (test_runs.test_config_id) test_runs.*").
+		Joins("JOIN test_configs ON test_configs.id = test_runs.test_config_id").
+		Where("test_configs.project_id = ? AND test_runs.commit_sha = ? AND test_runs.status IN ?", projectID, commitSHA, []string{"passed", "failed"}).
+		Order("test_runs.test_config_id, test_runs.created_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// LatestBranchScanRuns returns the latest completed run of each scan config
+// of a project on a branch, leaving out the runs of a commit.
+func (r *QualityGateRepository) LatestBranchScanRuns(projectID, branch, excludeCommit string) ([]model.ScanRun, error) {
+	var list []model.ScanRun
+	err := r.db.Table("scan_runs").
+		Select("DISTINCT ON (scan_runs.scan_config_id) scan_runs.*").
+		Joins("JOIN scan_configs ON scan_configs.id = scan_runs.scan_config_id").
+		Where("scan_configs.project_id = ? AND scan_runs.branch = ? AND scan_runs.status = ?", projectID, branch, "completed").
+		Where("scan_runs.commit_sha <> ?", excludeCommit).
+		Order("scan_runs.scan_config_id, scan_runs.created_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// ScanConfigTypes returns the scan type of each scan config of a project.
+func (r *QualityGateRepository) ScanConfigTypes(projectID string) (map[string]string, error) {
+	var rows []struct {
+		ID       string
+		ScanType string
+	}
+	err := r.db.Table("scan_configs").Select("id, scan_type").Where("project_id = ?", projectID).Scan(&rows).Error
+	types := make(map[string]string, len(rows))
+	for _, row := range rows {
+		types[row.ID] = row.ScanType
+	}
+	return types, err
+}
+
+// NewFindingCounts counts by category the open findings of a run whose
+// fingerprint is not among the findings of the baseline run.
+func (r *QualityGateRepository) NewFindingCounts(runID, baselineRunID string) (map[string]int, error) {
+	var rows []struct {
+		Category string
+		Count    int
+	}
+	err := r.db.Table("scan_findings").
+		Select("category, COUNT(*) AS count").
+		Where("scan_run_id = ? AND status = ?", runID, "open").
+		Where("fingerprint NOT IN (?)", r.db.Table("scan_findings").Select("fingerprint").Where("scan_run_id = ?", baselineRunID)).
+		Group("category").
+		Scan(&rows).Error
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Category] = row.Count
+	}
+	return counts, err
+}
+
+// CommitSource returns the branch and repository the workflow service built a
+// commit of a project from, or "" if it built none.
+func (r *QualityGateRepository) CommitSource(projectID, commitSHA string) (branch, repoURL string, err error) {
+	var rows []struct {
+		Branch  string
+		RepoURL string
+	}
+	err = r.db.Table("build_runs").
+		Select("build_runs.branch, build_configs.repo_url").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_configs.project_id = ? AND build_runs.commit_sha = ?", projectID, commitSHA).
+		Order("build_runs.created_at DESC").
+		Limit(1).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return "", "", err
+	}
+	return rows[0].Branch, rows[0].RepoURL, nil
+}
+
+// DefaultBranch returns the default branch of a project.
+func (r *QualityGateRepository) DefaultBranch(projectID string) (string, error) {
+	var branches []string
+	err := r.db.Table("projects").Where("id = ?", projectID).Pluck("default_branch", &branches).Error
+	if err != nil || len(branches) == 0 {
+		return "", err
+	}
+	return branches[0], nil
+}
+
+// SavePullRequest inserts a pull request or updates its branch, commit and state.
+func (r *QualityGateRepository) SavePullRequest(pr *model.PullRequest) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "repo_url"}, {Name: "number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "branch", "base_branch", "commit_sha", "state", "updated_at"}),
+	}).Create(pr).Error
+}
+
+// ListOpenPullRequests returns the open pull requests of a head branch.
+func (r *QualityGateRepository) ListOpenPullRequests(branch string) ([]model.PullRequest, error) {
+	var list []model.PullRequest
+	err := r.db.Where("branch = ? AND state = ?", branch, "open").Order("updated_at DESC").Find(&list).Error
+	return list, err
+}
+
+// SaveResult inserts the result of a commit for its branch and pull request
+// or replaces the previous one of the same branch and pull request.
+func (r *QualityGateRepository) SaveResult(res *model.QualityGateResult) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "project_id"}, {Name: "commit_sha"},
+			{Name: "(COALESCE(branch, ''))", Raw: true}, {Name: "(COALESCE(pull_request, 0))", Raw: true},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"baseline_branch", "baseline_commit_sha",
+			"status", "conditions", "scan_run_ids", "test_run_ids", "evaluated_at",
+		}),
+	}).Create(res).Error
+}
+
+// GetResult returns the latest result of a commit, whatever its branch or
+// pull request.
+func (r *QualityGateRepository) GetResult(projectID, commitSHA string) (*model.QualityGateResult, error) {
+	var res model.QualityGateResult
+	err := r.db.Where("project_id = ? AND commit_sha = ?", projectID, commitSHA).Order("evaluated_at DESC").First(&res).Error
+	return &res, err
+}
+
+// ListResults lists the results of a project, filtered by branch,
+// pull_request and status.
+func (r *QualityGateRepository) ListResults(projectID string, page, pageSize int, filters map[string]string) ([]model.QualityGateResult, int64, error) {
+	var list []model.QualityGateResult
+	var total int64
+	q := r.db.Where("project_id = ?", projectID)
+	if v := filters["branch"]; v != "" {
+		q = q.Where("branch = ?", v)
+	}
+	if v := filters["pull_request"]; v != "" {
+		q = q.Where("pull_request = ?", v)
+	}
+	if v := filters["status"]; v != "" {
+		q = q.Where("status = ?", v)
+	}
+	q.Model(&model.QualityGateResult{}).Count(&total)
+	err := q.Offset((page - 1) * pageSize).Limit(pageSize).Order("evaluated_at DESC").Find(&list).Error
+	return list, total, err
+}
+
+// LatestResults returns the latest result of each branch of a project, or of
+// each pull request if pullRequests is set.
+func (r *QualityGateRepository) LatestResults(projectID string, pullRequests bool) ([]model.QualityGateResult, error) {
+	q := r.db.Table("quality_gate_results").Where("project_id = ?", projectID)
+	if pullRequests {
+		q = q.Select("DISTINCT ON (pull_request) *").Where("pull_request IS NOT NULL").Order("pull_request, evaluated_at DESC")
+	} else {
+		q = q.Select("DISTINCT ON (branch) *").Where("branch <> ''").Order("branch, evaluated_at DESC")
+	}
+	var list []model.QualityGateResult
+	err := q.Find(&list).Error
+	return list, err
+}
+
+// MarkScanRuns records the gate status and pull request of the scan runs a
+// result was evaluated from.
+func (r *QualityGateRepository) MarkScanRuns(runIDs []string, status string, pullRequest *int) error {
+	if len(runIDs) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.ScanRun{}).Where("id IN ?", runIDs).
+		Updates(map[string]interface{}{"gate_status": status, "pull_request": pullRequest}).Error
+}
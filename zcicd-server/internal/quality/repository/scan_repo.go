@@ -62,3 +62,172 @@ func (r *ScanRepository) ListRuns(configID string, page, pageSize int) ([]model.
 	err := q.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&list).Error
 	return list, total, err
 }
+
+// ListActiveRuns returns the runs whose TaskRun is still being watched.
+func (r *ScanRepository) ListActiveRuns() ([]model.ScanRun, error) {
+	var list []model.ScanRun
+	err := r.db.Where("status = ? AND tekton_ref <> ''", "running").Find(&list).Error
+	return list, err
+}
+
+// GetBuildRun reads a build run of the workflow service with its source repository.
+func (r *ScanRepository) GetBuildRun(id string) (*model.BuildRun, error) {
+	var run model.BuildRun
+	err := r.db.Table("build_runs").
+		Select("build_runs.id, build_runs.build_config_id, build_configs.project_id, build_runs.status, "+
+			"build_configs.repo_url, build_runs.branch, build_runs.commit_sha").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_runs.id = ?", id).
+		Take(&run).Error
+	return &run, err
+}
+
+// GetIntegration reads an integration configured in the system service.
+func (r *ScanRepository) GetIntegration(id string) (*model.Integration, error) {
+	var i model.Integration
+	err := r.db.Where("id = ?", id).First(&i).Error
+	return &i, err
+}
+
+// FindIntegration returns the first active integration of a provider.
+func (r *ScanRepository) FindIntegration(provider string) (*model.Integration, error) {
+	var i model.Integration
+	err := r.db.Where("provider = ? AND status = ?", provider, "active").Order("created_at").First(&i).Error
+	return &i, err
+}
+
+func (r *ScanRepository) CreateFindings(findings []model.ScanFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(findings, 500).Error
+}
+
+// ListFindings returns the findings of a run, most severe first, filtered by
+// severity, category, tool, rule_id, file (a path prefix), status and new
+// ("true" for findings not in the baseline).
+func (r *ScanRepository) ListFindings(runID string, page, pageSize int, filters map[string]string) ([]model.ScanFinding, int64, error) {
+	var list []model.ScanFinding
+	var total int64
+	q := r.db.Model(&model.ScanFinding{}).Where("scan_run_id = ?", runID)
+	for _, col := range []string{"severity", "category", "tool", "rule_id", "status"} {
+		if v, ok := filters[col]; ok && v != "" {
+			q = q.Where(col+" = ?", v)
+		}
+	}
+	if v, ok := filters["file"]; ok && v != "" {
+		q = q.Where("file LIKE ?", v+"%")
+	}
+	switch filters["new"] {
+	case "true":
+		q = q.Where("NOT baseline")
+	case "false":
+		q = q.Where("baseline")
+	}
+	q.Count(&total)
+	err := q.Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("CASE severity WHEN 'critical' THEN 0 WHEN 'high' THEN 1 WHEN 'medium' THEN 2 WHEN 'low' THEN 3 ELSE 4 END, file, line").
+		Find(&list).Error
+	return list, total, err
+}
+
+// RunFingerprints returns the fingerprints of the findings of a run.
+func (r *ScanRepository) RunFingerprints(runID string) (map[string]bool, error) {
+	var fps []string
+	err := r.db.Model(&model.ScanFinding{}).Where("scan_run_id = ?", runID).Distinct().Pluck("fingerprint", &fps).Error
+	set := make(map[string]bool, len(fps))
+	for _, fp := range fps {
+		set[fp] = true
+	}
+	return set, err
+}
+
+// ListOpenFindings returns the unsuppressed findings of a scan config.
+func (r *ScanRepository) ListOpenFindings(configID string) ([]model.ScanFinding, error) {
+	var list []model.ScanFinding
+	err := r.db.Where("scan_config_id = ? AND status = ?", configID, "open").Find(&list).Error
+	return list, err
+}
+
+// SuppressFindings marks findings as suppressed by a suppression.
+func (r *ScanRepository) SuppressFindings(ids []string, suppressionID string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.ScanFinding{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"status": "suppressed", "suppression_id": suppressionID}).Error
+}
+
+// UnsuppressFindings reopens the findings of a suppression and returns their runs.
+func (r *ScanRepository) UnsuppressFindings(suppressionID string) ([]string, error) {
+	var runIDs []string
+	if err := r.db.Model(&model.ScanFinding{}).Where("suppression_id = ?", suppressionID).
+		Distinct().Pluck("scan_run_id", &runIDs).Error; err != nil {
+		return nil, err
+	}
+	err := r.db.Model(&model.ScanFinding{}).Where("suppression_id = ?", suppressionID).
+		Updates(map[string]interface{}{"status": "open", "suppression_id": nil}).Error
+	return runIDs, err
+}
+
+// SetBaseline sets the baseline run of a scan config and flags the findings of
+// its runs that the baseline run also has. A nil runID clears the baseline.
+func (r *ScanRepository) SetBaseline(configID string, runID *string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.ScanConfig{}).Where("id = ?", configID).
+			Update("baseline_run_id", runID).Error; err != nil {
+			return err
+		}
+		if runID == nil {
+			return tx.Model(&model.ScanFinding{}).Where("scan_config_id = ?", configID).
+				Update("baseline", false).Error
+		}
+		return tx.Exec(`UPDATE scan_findings SET baseline = fingerprint IN
+			(SELECT fingerprint FROM scan_findings WHERE scan_run_id = ?)
+			WHERE scan_config_id = ?`, *runID, configID).Error
+	})
+}
+
+// RecountRuns recomputes the finding counts of runs from their open findings.
+func (r *ScanRepository) RecountRuns(runIDs []string) error {
+	if len(runIDs) == 0 {
+		return nil
+	}
+	return r.db.Exec(`UPDATE scan_runs SET
+		bugs = (SELECT COUNT(*) FROM scan_findings f WHERE f.scan_run_id = scan_runs.id AND f.status = 'open' AND f.category = 'bug'),
+		vulnerabilities = (SELECT COUNT(*) FROM scan_findings f WHERE f.scan_run_id = scan_runs.id AND f.status = 'open' AND f.category IN ('vulnerability', 'secret')),
+		code_smells = (SELECT COUNT(*) FROM scan_findings f WHERE f.scan_run_id = scan_runs.id AND f.status = 'open' AND f.category = 'code_smell'),
+		new_findings = (SELECT COUNT(*) FROM scan_findings f WHERE f.scan_run_id = scan_runs.id AND f.status = 'open' AND NOT f.baseline)
+		WHERE id IN ?`, runIDs).Error
+}
+
+// FindingRunIDs returns the runs of a scan config that have findings.
+func (r *ScanRepository) FindingRunIDs(configID string) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&model.ScanFinding{}).Where("scan_config_id = ?", configID).
+		Distinct().Pluck("scan_run_id", &ids).Error
+	return ids, err
+}
+
+func (r *ScanRepository) ListSuppressions(configID string) ([]model.ScanSuppression, error) {
+	var list []model.ScanSuppression
+	err := r.db.Where("scan_config_id = ?", configID).Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *ScanRepository) CreateSuppression(s *model.ScanSuppression) error {
+	return r.db.Create(s).Error
+}
+
+// DeleteSuppression deletes a suppression of a config and reports whether it existed.
+func (r *ScanRepository) DeleteSuppression(configID, id string) (bool, error) {
+	res := r.db.Where("id = ? AND scan_config_id = ?", id, configID).Delete(&model.ScanSuppression{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListOpenPullRequests returns the open pull requests of a head branch.
+func (r *ScanRepository) ListOpenPullRequests(branch string) ([]model.PullRequest, error) {
+	var list []model.PullRequest
+	err := r.db.Where("branch = ? AND state = ?", branch, "open").Order("updated_at DESC").Find(&list).Error
+	return list, err
+}
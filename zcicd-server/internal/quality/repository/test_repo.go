@@ -62,3 +62,117 @@ func (r *TestRepository) ListRuns(configID string, page, pageSize int) ([]model.
 	err := q.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&list).Error
 	return list, total, err
 }
+
+// ListActiveRuns returns the runs whose TaskRun is still being watched.
+func (r *TestRepository) ListActiveRuns() ([]model.TestRun, error) {
+	var list []model.TestRun
+	err := r.db.Where("status = ? AND tekton_ref <> ''", "running").Find(&list).Error
+	return list, err
+}
+
+// GetBuildRun reads a build run of the workflow service with its source repository.
+func (r *TestRepository) GetBuildRun(id string) (*model.BuildRun, error) {
+	var run model.BuildRun
+	err := r.db.Table("build_runs").
+		Select("build_runs.id, build_runs.build_config_id, build_configs.project_id, build_runs.status, "+
+			"build_configs.repo_url, build_runs.branch, build_runs.commit_sha").
+		Joins("JOIN build_configs ON build_configs.id = build_runs.build_config_id").
+		Where("build_runs.id = ?", id).
+		Take(&run).Error
+	return &run, err
+}
+
+// CreateCaseResults inserts the test case results of a run.
+func (r *TestRepository) CreateCaseResults(results []model.TestCaseResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(results, 500).Error
+}
+
+// ListCaseResults returns the test cases of a run, failures first.
+func (r *TestRepository) ListCaseResults(runID, status string, page, pageSize int) ([]model.TestCaseResult, int64, error) {
+	var list []model.TestCaseResult
+	var total int64
+	q := r.db.Model(&model.TestCaseResult{}).Where("test_run_id = ?", runID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	q.Count(&total)
+	err := q.Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("CASE status WHEN 'failed' THEN 0 WHEN 'skipped' THEN 1 ELSE 2 END, test_key").
+		Find(&list).Error
+	return list, total, err
+}
+
+// CaseHistory returns the results of a test across the runs of its config.
+func (r *TestRepository) CaseHistory(configID, testKey string, page, pageSize int) ([]model.TestCaseHistory, int64, error) {
+	var list []model.TestCaseHistory
+	var total int64
+	q := r.db.Table("test_case_results").
+		Joins("JOIN test_runs ON test_runs.id = test_case_results.test_run_id").
+		Where("test_case_results.test_config_id = ? AND test_case_results.test_key = ?", configID, testKey)
+	q.Count(&total)
+	err := q.Select("test_case_results.test_run_id, test_runs.commit_sha, test_case_results.status, " +
+		"test_case_results.duration, test_case_results.message, test_case_results.created_at").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("test_case_results.created_at DESC").
+		Scan(&list).Error
+	return list, total, err
+}
+
+// recentRuns limits a query to the latest finished runs of a config.
+func (r *TestRepository) recentRuns(configID string, runs int) *gorm.DB {
+	return r.db.Model(&model.TestRun{}).Select("id").
+		Where("test_config_id = ? AND finished_at IS NOT NULL", configID).
+		Order("created_at DESC").Limit(runs)
+}
+
+// RecentCaseResults returns the test case results of the latest runs of a
+// config with their commits, oldest first.
+func (r *TestRepository) RecentCaseResults(configID string, runs int) ([]model.TestCaseRun, error) {
+	var list []model.TestCaseRun
+	err := r.db.Table("test_case_results").
+		Select("test_case_results.*, test_runs.commit_sha").
+		Joins("JOIN test_runs ON test_runs.id = test_case_results.test_run_id").
+		Where("test_case_results.test_run_id IN (?)", r.recentRuns(configID, runs)).
+		Order("test_case_results.created_at, test_case_results.test_key").
+		Scan(&list).Error
+	return list, err
+}
+
+// SlowTests returns the tests with the highest average duration over the
+// latest runs of a config.
+func (r *TestRepository) SlowTests(configID string, runs, limit int) ([]model.SlowTest, error) {
+	var list []model.SlowTest
+	err := r.db.Table("test_case_results").
+		Select("test_key, MAX(suite) AS suite, MAX(class_name) AS class_name, MAX(name) AS name, "+
+			"COUNT(*) AS runs, AVG(duration) AS avg_duration, MAX(duration) AS max_duration").
+		Where("test_run_id IN (?) AND status <> ?", r.recentRuns(configID, runs), "skipped").
+		Group("test_key").
+		Order("avg_duration DESC").Limit(limit).
+		Scan(&list).Error
+	return list, err
+}
+
+func (r *TestRepository) ListQuarantines(configID string) ([]model.TestQuarantine, error) {
+	var list []model.TestQuarantine
+	err := r.db.Where("test_config_id = ?", configID).Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *TestRepository) GetQuarantine(configID, testKey string) (*model.TestQuarantine, error) {
+	var q model.TestQuarantine
+	err := r.db.Where("test_config_id = ? AND test_key = ?", configID, testKey).First(&q).Error
+	return &q, err
+}
+
+func (r *TestRepository) CreateQuarantine(q *model.TestQuarantine) error {
+	return r.db.Create(q).Error
+}
+
+// DeleteQuarantine deletes a quarantine of a config and reports whether it existed.
+func (r *TestRepository) DeleteQuarantine(configID, id string) (bool, error) {
+	res := r.db.Where("id = ? AND test_config_id = ?", id, configID).Delete(&model.TestQuarantine{})
+	return res.RowsAffected > 0, res.Error
+}
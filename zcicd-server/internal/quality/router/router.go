@@ -22,6 +22,14 @@ func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, testH *handler.TestHan
 		tests.POST("/:id/run", testH.TriggerRun)
 		tests.GET("/:id/runs", testH.ListRuns)
 		tests.GET("/:id/runs/:run_id", testH.GetRun)
+		tests.GET("/:id/runs/:run_id/report", testH.GetReport)
+		tests.GET("/:id/runs/:run_id/cases", testH.ListCases)
+		tests.GET("/:id/cases/history", testH.CaseHistory)
+		tests.GET("/:id/cases/flaky", testH.FlakyTests)
+		tests.GET("/:id/cases/slow", testH.SlowTests)
+		tests.GET("/:id/quarantines", testH.ListQuarantines)
+		tests.POST("/:id/quarantines", testH.Quarantine)
+		tests.DELETE("/:id/quarantines/:qid", testH.Unquarantine)
 
 		// Scan configs
 		scans := projects.Group("/scans")
@@ -32,14 +40,24 @@ func RegisterRoutes(r *gin.RouterGroup, jwtSecret string, testH *handler.TestHan
 		scans.DELETE("/:sid", scanH.DeleteConfig)
 		scans.POST("/:sid/run", scanH.TriggerRun)
 		scans.GET("/:sid/runs", scanH.ListRuns)
+		scans.PUT("/:sid/baseline", scanH.SetBaseline)
+		scans.GET("/:sid/suppressions", scanH.ListSuppressions)
+		scans.POST("/:sid/suppressions", scanH.CreateSuppression)
+		scans.DELETE("/:sid/suppressions/:supid", scanH.DeleteSuppression)
 
 		// Quality gate
 		projects.GET("/quality-gate", gateH.Get)
 		projects.PUT("/quality-gate", gateH.Upsert)
+		projects.POST("/quality-gate/evaluate", gateH.Evaluate)
+		projects.GET("/quality-gate/results", gateH.ListResults)
+		projects.GET("/quality-gate/results/:sha", gateH.GetResult)
+		projects.GET("/quality-gate/branches", gateH.BranchResults)
+		projects.GET("/quality-gate/pull-requests", gateH.PullRequestResults)
 	}
 
 	// Scan run detail (cross-project)
 	scanRuns := r.Group("/scans/runs")
 	scanRuns.Use(auth)
 	scanRuns.GET("/:rid", scanH.GetRun)
+	scanRuns.GET("/:rid/findings", scanH.ListFindings)
 }
@@ -1,5 +1,10 @@
 package service
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type CreateTestConfigReq struct {
 	Name      string `json:"name" binding:"required"`
 	TestType  string `json:"test_type"`
@@ -17,17 +22,45 @@ type UpdateTestConfigReq struct {
 	Enabled   *bool  `json:"enabled"`
 }
 
+type TriggerTestRunReq struct {
+	BuildRunID string `json:"build_run_id" binding:"required"`
+}
+
+type QuarantineTestReq struct {
+	TestKey string `json:"test_key" binding:"required,max=512"`
+	Reason  string `json:"reason"`
+}
+
 type CreateScanConfigReq struct {
-	Name            string `json:"name" binding:"required"`
-	ScanType        string `json:"scan_type"`
-	SonarProjectKey string `json:"sonar_project_key"`
+	Name            string          `json:"name" binding:"required"`
+	ScanType        string          `json:"scan_type"`
+	SonarProjectKey string          `json:"sonar_project_key"`
+	Config          json.RawMessage `json:"config"`
 }
 
 type UpdateScanConfigReq struct {
-	Name            string `json:"name"`
-	ScanType        string `json:"scan_type"`
-	SonarProjectKey string `json:"sonar_project_key"`
-	Enabled         *bool  `json:"enabled"`
+	Name            string          `json:"name"`
+	ScanType        string          `json:"scan_type"`
+	SonarProjectKey string          `json:"sonar_project_key"`
+	Config          json.RawMessage `json:"config"`
+	Enabled         *bool           `json:"enabled"`
+}
+
+type TriggerScanRunReq struct {
+	BuildRunID string `json:"build_run_id" binding:"required"`
+}
+
+type CreateSuppressionReq struct {
+	Fingerprint string     `json:"fingerprint" binding:"omitempty,max=64"`
+	RuleID      string     `json:"rule_id" binding:"omitempty,max=256"`
+	PathPattern string     `json:"path_pattern" binding:"omitempty,max=512"`
+	Reason      string     `json:"reason" binding:"required"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// SetScanBaselineReq sets the baseline run of a scan config; a null run_id clears it.
+type SetScanBaselineReq struct {
+	RunID *string `json:"run_id"`
 }
 
 type QualityGateReq struct {
@@ -36,5 +69,16 @@ type QualityGateReq struct {
 	MaxVulnerabilities *int     `json:"max_vulnerabilities"`
 	MaxCodeSmells      *int     `json:"max_code_smells"`
 	MaxDuplications    *float64 `json:"max_duplications"`
+	MaxFailedTests     *int     `json:"max_failed_tests" binding:"omitempty,min=0"`
 	BlockDeploy        *bool    `json:"block_deploy"`
+	// New-code conditions are disabled when omitted.
+	BaselineBranch        *string  `json:"baseline_branch"`
+	MinNewCoverage        *float64 `json:"min_new_coverage"`
+	MaxNewVulnerabilities *int     `json:"max_new_vulnerabilities"`
+	MaxNewCodeSmells      *int     `json:"max_new_code_smells"`
+}
+
+// EvaluateGateReq re-evaluates the quality gate of a commit.
+type EvaluateGateReq struct {
+	CommitSHA string `json:"commit_sha" binding:"required"`
 }
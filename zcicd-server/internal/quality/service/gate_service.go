@@ -1,16 +1,29 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
 	"github.com/zcicd/zcicd-server/internal/quality/model"
 	"github.com/zcicd/zcicd-server/internal/quality/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 type QualityGateService struct {
-	repo *repository.QualityGateRepository
+	repo     *repository.QualityGateRepository
+	mqClient *mq.Client
 }
 
-func NewQualityGateService(repo *repository.QualityGateRepository) *QualityGateService {
-	return &QualityGateService{repo: repo}
+func NewQualityGateService(repo *repository.QualityGateRepository, mqClient *mq.Client) *QualityGateService {
+	return &QualityGateService{repo: repo, mqClient: mqClient}
 }
 
 func (s *QualityGateService) Get(projectID string) (*model.QualityGate, error) {
@@ -34,8 +47,437 @@ func (s *QualityGateService) Upsert(projectID string, req QualityGateReq) (*mode
 	if req.MaxDuplications != nil {
 		g.MaxDuplications = req.MaxDuplications
 	}
+	if req.MaxFailedTests != nil {
+		g.MaxFailedTests = *req.MaxFailedTests
+	}
+	if req.BaselineBranch != nil {
+		g.BaselineBranch = *req.BaselineBranch
+	}
+	g.MinNewCoverage = req.MinNewCoverage
+	g.MaxNewVulnerabilities = req.MaxNewVulnerabilities
+	g.MaxNewCodeSmells = req.MaxNewCodeSmells
 	if req.BlockDeploy != nil {
 		g.BlockDeploy = *req.BlockDeploy
 	}
 	return g, s.repo.Upsert(g)
 }
+
+// runCompletedEvent is the envelope of the test and scan completed events;
+// only the commit of the run is read.
+type runCompletedEvent struct {
+	ProjectID string `json:"project_id"`
+	Payload   struct {
+		CommitSHA string `json:"commit_sha"`
+	} `json:"payload"`
+}
+
+// Subscribe evaluates the quality gate of a commit whenever one of its test or
+// scan runs completes, and tracks pull requests to find their base branch.
+func (s *QualityGateService) Subscribe(mqClient *mq.Client) error {
+	if _, err := mqClient.Subscribe(mq.SubjectTestCompleted, "quality-gates-tests", s.handleRunCompleted); err != nil {
+		return err
+	}
+	if _, err := mqClient.Subscribe(mq.SubjectScanCompleted, "quality-gates-scans", s.handleRunCompleted); err != nil {
+		return err
+	}
+	_, err := mqClient.Subscribe(mq.SubjectPullRequest, "quality-pull-requests", s.handlePullRequest)
+	return err
+}
+
+// pullRequestEvent is published by the workflow service's SCM webhooks.
+type pullRequestEvent struct {
+	RepoURL    string `json:"repo_url"`
+	Number     int    `json:"number"`
+	Action     string `json:"action"` // opened, updated, closed, merged
+	Title      string `json:"title"`
+	Branch     string `json:"branch"`
+	BaseBranch string `json:"base_branch"`
+	CommitSHA  string `json:"commit_sha"`
+}
+
+// handlePullRequest records the pull requests whose commits are compared with
+// their base branch.
+func (s *QualityGateService) handlePullRequest(msg *nats.Msg) {
+	var event pullRequestEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil || event.Number == 0 || event.Branch == "" {
+		return
+	}
+	state := "open"
+	switch event.Action {
+	case "closed", "merged":
+		state = event.Action
+	}
+	pr := &model.PullRequest{
+		RepoURL:    event.RepoURL,
+		Number:     event.Number,
+		Title:      truncate(event.Title, 512),
+		Branch:     event.Branch,
+		BaseBranch: event.BaseBranch,
+		CommitSHA:  event.CommitSHA,
+		State:      state,
+	}
+	if err := s.repo.SavePullRequest(pr); err != nil {
+		fmt.Printf("warning: failed to record pull request %d: %v\n", event.Number, err)
+	}
+}
+
+func (s *QualityGateService) handleRunCompleted(msg *nats.Msg) {
+	var event runCompletedEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		fmt.Printf("warning: invalid run completed event: %v\n", err)
+		return
+	}
+	if event.ProjectID == "" || event.Payload.CommitSHA == "" {
+		return
+	}
+	if _, err := s.Evaluate(event.ProjectID, event.Payload.CommitSHA); err != nil {
+		fmt.Printf("warning: quality gate of %s: %v\n", event.Payload.CommitSHA, err)
+	}
+}
+
+// Evaluate compares the latest completed test and scan runs of a commit with
+// the project's quality gate, stores the result and marks the scan runs with
+// it. A gate that has not failed is pending until every enabled scan config
+// that scans the commit's repository has reported on the commit, and fails
+// when the latest scan of the commit by any config failed. It returns nil if
+// the project has no quality gate.
+func (s *QualityGateService) Evaluate(projectID, commitSHA string) (*model.QualityGateResult, error) {
+	gate, err := s.repo.Get(projectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scans, err := s.repo.LatestScanRuns(projectID, commitSHA)
+	if err != nil {
+		return nil, err
+	}
+	tests, err := s.repo.LatestTestRuns(projectID, commitSHA)
+	if err != nil {
+		return nil, err
+	}
+	branch, repoURL, err := s.repo.CommitSource(projectID, commitSHA)
+	if err != nil {
+		fmt.Printf("warning: branch of commit %s: %v\n", commitSHA, err)
+	}
+	if branch == "" {
+		for _, run := range scans {
+			if run.Branch != "" {
+				branch = run.Branch
+			}
+		}
+	}
+	pr := s.pullRequest(branch, repoURL)
+	baselineBranch := s.baselineBranch(gate, pr)
+	var baseline []model.ScanRun
+	if baselineBranch != "" {
+		if baseline, err = s.repo.LatestBranchScanRuns(projectID, baselineBranch, commitSHA); err != nil {
+			return nil, err
+		}
+	}
+
+	configIDs, err := s.repo.RepoScanConfigIDs(projectID, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	scanStatuses, err := s.repo.ScanRunStatuses(projectID, commitSHA)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := evaluateGate(gate, scans, tests)
+	conditions = append(conditions, s.newCodeConditions(gate, projectID, scans, baseline)...)
+	conditions = append(conditions, scansReported(configIDs, scanStatuses))
+	status := "passed"
+	for _, c := range conditions {
+		switch {
+		case c.Status == "failed":
+			status = "failed"
+		case c.Status == "pending" && status == "passed":
+			status = "pending"
+		}
+	}
+	scanIDs := make([]string, len(scans))
+	for i, run := range scans {
+		scanIDs[i] = run.ID
+	}
+	testIDs := make([]string, len(tests))
+	for i, run := range tests {
+		testIDs[i] = run.ID
+	}
+	condJSON, _ := json.Marshal(conditions)
+	scanJSON, _ := json.Marshal(scanIDs)
+	testJSON, _ := json.Marshal(testIDs)
+	res := &model.QualityGateResult{
+		ProjectID:      projectID,
+		CommitSHA:      commitSHA,
+		Branch:         branch,
+		BaselineBranch: baselineBranch,
+		Status:         status,
+		Conditions:     datatypes.JSON(condJSON),
+		ScanRunIDs:     datatypes.JSON(scanJSON),
+		TestRunIDs:     datatypes.JSON(testJSON),
+		EvaluatedAt:    time.Now(),
+	}
+	if pr != nil {
+		res.PullRequest = &pr.Number
+	}
+	var latest time.Time
+	for _, run := range baseline {
+		if run.CreatedAt.After(latest) {
+			latest = run.CreatedAt
+			res.BaselineCommitSHA = run.CommitSHA
+		}
+	}
+	if err := s.repo.SaveResult(res); err != nil {
+		return nil, err
+	}
+	if err := s.repo.MarkScanRuns(scanIDs, status, res.PullRequest); err != nil {
+		fmt.Printf("warning: failed to set gate status of scan runs: %v\n", err)
+	}
+	s.publishEvent(res)
+	return res, nil
+}
+
+// evaluateGate checks each threshold of a gate. Counts are summed over the
+// scan runs and failed tests over the test runs, whose failures exclude
+// quarantined tests; coverage is the lowest reported by the test runs, or by
+// the scan runs if no test run measured it; duplications the highest of the
+// scan runs.
+func evaluateGate(gate *model.QualityGate, scans []model.ScanRun, tests []model.TestRun) []model.GateCondition {
+	var bugs, vulnerabilities, codeSmells *float64
+	if len(scans) > 0 {
+		var b, v, cs float64
+		for _, run := range scans {
+			b += float64(run.Bugs)
+			v += float64(run.Vulnerabilities)
+			cs += float64(run.CodeSmells)
+		}
+		bugs, vulnerabilities, codeSmells = &b, &v, &cs
+	}
+	var failedTests *float64
+	if len(tests) > 0 {
+		var f float64
+		for _, run := range tests {
+			f += float64(run.Failed)
+		}
+		failedTests = &f
+	}
+	var coverage, duplications *float64
+	for _, run := range tests {
+		coverage = lowest(coverage, run.Coverage)
+	}
+	if coverage == nil {
+		for _, run := range scans {
+			coverage = lowest(coverage, run.Coverage)
+		}
+	}
+	for _, run := range scans {
+		if run.Duplications != nil && (duplications == nil || *run.Duplications > *duplications) {
+			duplications = run.Duplications
+		}
+	}
+
+	var conditions []model.GateCondition
+	if gate.MinCoverage != nil {
+		conditions = append(conditions, gateCondition("coverage", "gte", *gate.MinCoverage, coverage))
+	}
+	conditions = append(conditions,
+		gateCondition("bugs", "lte", float64(gate.MaxBugs), bugs),
+		gateCondition("vulnerabilities", "lte", float64(gate.MaxVulnerabilities), vulnerabilities),
+		gateCondition("code_smells", "lte", float64(gate.MaxCodeSmells), codeSmells),
+	)
+	if gate.MaxDuplications != nil {
+		conditions = append(conditions, gateCondition("duplications", "lte", *gate.MaxDuplications, duplications))
+	}
+	conditions = append(conditions, gateCondition("failed_tests", "lte", float64(gate.MaxFailedTests), failedTests))
+	return conditions
+}
+
+// scansReported is the condition that each of the scan configs has a
+// completed run for the commit, given the status of the latest finished run
+// of each config. It fails when a run failed, and is pending until the
+// configs have all reported.
+func scansReported(configIDs []string, statuses map[string]string) model.GateCondition {
+	var reported float64
+	for _, id := range configIDs {
+		if statuses[id] != "" {
+			reported++
+		}
+	}
+	c := model.GateCondition{Metric: "scans", Operator: "gte", Threshold: float64(len(configIDs)), Actual: &reported, Status: "passed"}
+	for _, status := range statuses {
+		if status == "failed" {
+			c.Status = "failed"
+			return c
+		}
+	}
+	if reported < c.Threshold {
+		c.Status = "pending"
+	}
+	return c
+}
+
+func gateCondition(metric, operator string, threshold float64, actual *float64) model.GateCondition {
+	c := model.GateCondition{Metric: metric, Operator: operator, Threshold: threshold, Actual: actual, Status: "no_data"}
+	if actual == nil {
+		return c
+	}
+	ok := *actual <= threshold
+	if operator == "gte" {
+		ok = *actual >= threshold
+	}
+	c.Status = "failed"
+	if ok {
+		c.Status = "passed"
+	}
+	return c
+}
+
+// newCodeConditions checks the new-code thresholds of a gate. New findings of
+// linter scans are those whose fingerprint is not in the baseline run of their
+// scan config; for SonarQube scans they are the increase of the counts over the
+// baseline run. Coverage on new code is SonarQube's.
+func (s *QualityGateService) newCodeConditions(gate *model.QualityGate, projectID string, scans, baseline []model.ScanRun) []model.GateCondition {
+	if gate.MinNewCoverage == nil && gate.MaxNewVulnerabilities == nil && gate.MaxNewCodeSmells == nil {
+		return nil
+	}
+	types, err := s.repo.ScanConfigTypes(projectID)
+	if err != nil {
+		fmt.Printf("warning: scan types of project %s: %v\n", projectID, err)
+	}
+	return compareNewCode(gate, types, scans, baseline, s.repo.NewFindingCounts)
+}
+
+// compareNewCode computes the new-code conditions of a gate given the scan
+// type of each config and the counts by category of the findings of a run
+// that are not in a baseline run.
+func compareNewCode(gate *model.QualityGate, types map[string]string, scans, baseline []model.ScanRun,
+	newFindings func(runID, baselineRunID string) (map[string]int, error)) []model.GateCondition {
+	base := make(map[string]model.ScanRun, len(baseline))
+	for _, run := range baseline {
+		base[run.ScanConfigID] = run
+	}
+
+	var newVulnerabilities, newCodeSmells, newCoverage *float64
+	var vulns, smells float64
+	compared := false
+	for _, run := range scans {
+		newCoverage = lowest(newCoverage, run.NewCoverage)
+		b, ok := base[run.ScanConfigID]
+		if !ok {
+			continue
+		}
+		if types[run.ScanConfigID] == "sonar" {
+			vulns += float64(max(run.Vulnerabilities-b.Vulnerabilities, 0))
+			smells += float64(max(run.CodeSmells-b.CodeSmells, 0))
+		} else {
+			counts, err := newFindings(run.ID, b.ID)
+			if err != nil {
+				fmt.Printf("warning: new findings of scan run %s: %v\n", run.ID, err)
+				continue
+			}
+			vulns += float64(counts[engine.CategoryVulnerability] + counts[engine.CategorySecret])
+			smells += float64(counts[engine.CategoryCodeSmell])
+		}
+		compared = true
+	}
+	if compared {
+		newVulnerabilities, newCodeSmells = &vulns, &smells
+	}
+
+	var conditions []model.GateCondition
+	if gate.MinNewCoverage != nil {
+		conditions = append(conditions, gateCondition("new_coverage", "gte", *gate.MinNewCoverage, newCoverage))
+	}
+	if gate.MaxNewVulnerabilities != nil {
+		conditions = append(conditions, gateCondition("new_vulnerabilities", "lte", float64(*gate.MaxNewVulnerabilities), newVulnerabilities))
+	}
+	if gate.MaxNewCodeSmells != nil {
+		conditions = append(conditions, gateCondition("new_code_smells", "lte", float64(*gate.MaxNewCodeSmells), newCodeSmells))
+	}
+	return conditions
+}
+
+// pullRequest returns the open pull request of a branch of a repository, if any.
+func (s *QualityGateService) pullRequest(branch, repoURL string) *model.PullRequest {
+	if branch == "" {
+		return nil
+	}
+	prs, err := s.repo.ListOpenPullRequests(branch)
+	if err != nil {
+		fmt.Printf("warning: pull requests of %s: %v\n", branch, err)
+		return nil
+	}
+	for i := range prs {
+		if repoURL == "" || sameRepo(prs[i].RepoURL, repoURL) {
+			return &prs[i]
+		}
+	}
+	return nil
+}
+
+// baselineBranch is the base branch of a pull request, otherwise the gate's
+// baseline branch or the project's default branch.
+func (s *QualityGateService) baselineBranch(gate *model.QualityGate, pr *model.PullRequest) string {
+	if pr != nil && pr.BaseBranch != "" {
+		return pr.BaseBranch
+	}
+	if gate.BaselineBranch != "" {
+		return gate.BaselineBranch
+	}
+	branch, err := s.repo.DefaultBranch(gate.ProjectID)
+	if err != nil {
+		fmt.Printf("warning: default branch of project %s: %v\n", gate.ProjectID, err)
+	}
+	return branch
+}
+
+func sameRepo(a, b string) bool {
+	trim := func(u string) string {
+		return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git"))
+	}
+	return trim(a) == trim(b)
+}
+
+func lowest(cur, v *float64) *float64 {
+	if v != nil && (cur == nil || *v < *cur) {
+		return v
+	}
+	return cur
+}
+
+func (s *QualityGateService) publishEvent(res *model.QualityGateResult) {
+	if s.mqClient == nil {
+		return
+	}
+	event := mq.Event{
+		EventType: mq.SubjectQualityGate,
+		Timestamp: time.Now().Format(time.RFC3339),
+		ProjectID: res.ProjectID,
+		Payload:   res,
+	}
+	data, _ := json.Marshal(event)
+	if err := s.mqClient.Publish(mq.SubjectQualityGate, data); err != nil {
+		fmt.Printf("warning: failed to publish quality gate event: %v\n", err)
+	}
+}
+
+func (s *QualityGateService) GetResult(projectID, commitSHA string) (*model.QualityGateResult, error) {
+	res, err := s.repo.GetResult(projectID, commitSHA)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, appErrors.ErrGateResultNotFound
+	}
+	return res, err
+}
+
+func (s *QualityGateService) ListResults(projectID string, page, pageSize int, filters map[string]string) ([]model.QualityGateResult, int64, error) {
+	return s.repo.ListResults(projectID, page, pageSize, filters)
+}
+
+// LatestResults returns the latest result of each branch, or of each pull
+// request if pullRequests is set.
+func (s *QualityGateService) LatestResults(projectID string, pullRequests bool) ([]model.QualityGateResult, error) {
+	return s.repo.LatestResults(projectID, pullRequests)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
+	"github.com/zcicd/zcicd-server/internal/quality/model"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func intp(v int) *int {
+	return &v
+}
+
+// conditionStatuses maps the metric of each condition to its status.
+func conditionStatuses(conditions []model.GateCondition) map[string]string {
+	statuses := make(map[string]string, len(conditions))
+	for _, c := range conditions {
+		statuses[c.Metric] = c.Status
+	}
+	return statuses
+}
+
+func TestEvaluateGate(t *testing.T) {
+	gate := &model.QualityGate{
+		MinCoverage:        float(80),
+		MaxBugs:            0,
+		MaxVulnerabilities: 1,
+		MaxCodeSmells:      10,
+		MaxDuplications:    float(5),
+		MaxFailedTests:     0,
+	}
+	tests := []struct {
+		name  string
+		gate  *model.QualityGate
+		scans []model.ScanRun
+		tests []model.TestRun
+		want  map[string]string
+	}{
+		{
+			name: "within thresholds",
+			gate: gate,
+			scans: []model.ScanRun{
+				{Vulnerabilities: 1, CodeSmells: 4, Coverage: float(50), Duplications: float(2)},
+				{CodeSmells: 6, Duplications: float(5)},
+			},
+			tests: []model.TestRun{{Coverage: float(85)}, {Coverage: float(80)}},
+			want: map[string]string{
+				"coverage": "passed", "bugs": "passed", "vulnerabilities": "passed",
+				"code_smells": "passed", "duplications": "passed", "failed_tests": "passed",
+			},
+		},
+		{
+			name: "thresholds exceeded",
+			gate: gate,
+			scans: []model.ScanRun{
+				{Bugs: 1, Vulnerabilities: 1, CodeSmells: 6, Duplications: float(7.5)},
+				{Vulnerabilities: 1, CodeSmells: 5},
+			},
+			tests: []model.TestRun{{Failed: 2, Coverage: float(90)}, {Coverage: float(79.9)}},
+			want: map[string]string{
+				"coverage": "failed", "bugs": "failed", "vulnerabilities": "failed",
+				"code_smells": "failed", "duplications": "failed", "failed_tests": "failed",
+			},
+		},
+		{
+			name:  "coverage of the scans without measuring tests",
+			gate:  gate,
+			scans: []model.ScanRun{{Coverage: float(82)}, {Coverage: float(78)}},
+			tests: []model.TestRun{{}},
+			want: map[string]string{
+				"coverage": "failed", "bugs": "passed", "vulnerabilities": "passed",
+				"code_smells": "passed", "duplications": "no_data", "failed_tests": "passed",
+			},
+		},
+		{
+			name: "no runs",
+			gate: gate,
+			want: map[string]string{
+				"coverage": "no_data", "bugs": "no_data", "vulnerabilities": "no_data",
+				"code_smells": "no_data", "duplications": "no_data", "failed_tests": "no_data",
+			},
+		},
+		{
+			name:  "optional thresholds unset",
+			gate:  &model.QualityGate{MaxCodeSmells: 10},
+			scans: []model.ScanRun{{Coverage: float(10), Duplications: float(40)}},
+			tests: []model.TestRun{{}},
+			want: map[string]string{
+				"bugs": "passed", "vulnerabilities": "passed", "code_smells": "passed", "failed_tests": "passed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := conditionStatuses(evaluateGate(tt.gate, tt.scans, tt.tests))
+			if len(got) != len(tt.want) {
+				t.Errorf("conditions = %v, want %v", got, tt.want)
+			}
+			for metric, want := range tt.want {
+				if got[metric] != want {
+					t.Errorf("%s = %q, want %q", metric, got[metric], want)
+				}
+			}
+		})
+	}
+}
+
+func TestScansReported(t *testing.T) {
+	tests := []struct {
+		name         string
+		configIDs    []string
+		statuses     map[string]string
+		wantStatus   string
+		wantReported float64
+	}{
+		{"no scan configs", nil, map[string]string{}, "passed", 0},
+		{"all reported", []string{"a", "b"}, map[string]string{"a": "completed", "b": "completed"}, "passed", 2},
+		{"waiting for a config", []string{"a", "b"}, map[string]string{"a": "completed"}, "pending", 1},
+		{"nothing reported", []string{"a", "b"}, map[string]string{}, "pending", 0},
+		{"failed before the others reported", []string{"a", "b"}, map[string]string{"a": "failed"}, "failed", 1},
+		{"failed run of a config no longer enabled", []string{"a"}, map[string]string{"a": "completed", "old": "failed"}, "failed", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scansReported(tt.configIDs, tt.statuses)
+			if c.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", c.Status, tt.wantStatus)
+			}
+			if *c.Actual != tt.wantReported || c.Threshold != float64(len(tt.configIDs)) {
+				t.Errorf("reported = %v of %v, want %v of %d", *c.Actual, c.Threshold, tt.wantReported, len(tt.configIDs))
+			}
+		})
+	}
+}
+
+func TestCompareNewCode(t *testing.T) {
+	gate := &model.QualityGate{
+		MinNewCoverage:        float(80),
+		MaxNewVulnerabilities: intp(0),
+		MaxNewCodeSmells:      intp(3),
+	}
+	types := map[string]string{"sonar": "sonar", "lint": "golangci-lint", "secrets": "gitleaks"}
+	newFindings := func(runID, baselineRunID string) (map[string]int, error) {
+		switch runID + ":" + baselineRunID {
+		case "lint-2:lint-1":
+			return map[string]int{engine.CategoryCodeSmell: 2}, nil
+		case "secrets-2:secrets-1":
+			return map[string]int{engine.CategorySecret: 1}, nil
+		}
+		return nil, errors.New("unexpected comparison " + runID + ":" + baselineRunID)
+	}
+	baseline := []model.ScanRun{
+		{ID: "sonar-1", ScanConfigID: "sonar", Vulnerabilities: 3, CodeSmells: 20},
+		{ID: "lint-1", ScanConfigID: "lint"},
+		{ID: "secrets-1", ScanConfigID: "secrets"},
+	}
+
+	tests := []struct {
+		name     string
+		gate     *model.QualityGate
+		scans    []model.ScanRun
+		baseline []model.ScanRun
+		want     map[string]string
+	}{
+		{
+			name: "sonar fixed more than it added",
+			gate: gate,
+			scans: []model.ScanRun{
+				{ID: "sonar-2", ScanConfigID: "sonar", Vulnerabilities: 1, CodeSmells: 21, NewCoverage: float(85)},
+				{ID: "lint-2", ScanConfigID: "lint"},
+			},
+			baseline: baseline,
+			want:     map[string]string{"new_coverage": "passed", "new_vulnerabilities": "passed", "new_code_smells": "passed"},
+		},
+		{
+			name: "new secret and smells over the limit",
+			gate: gate,
+			scans: []model.ScanRun{
+				{ID: "sonar-2", ScanConfigID: "sonar", Vulnerabilities: 3, CodeSmells: 22, NewCoverage: float(70)},
+				{ID: "lint-2", ScanConfigID: "lint"},
+				{ID: "secrets-2", ScanConfigID: "secrets"},
+			},
+			baseline: baseline,
+			want:     map[string]string{"new_coverage": "failed", "new_vulnerabilities": "failed", "new_code_smells": "failed"},
+		},
+		{
+			name:  "no baseline",
+			gate:  gate,
+			scans: []model.ScanRun{{ID: "lint-2", ScanConfigID: "lint"}},
+			want:  map[string]string{"new_coverage": "no_data", "new_vulnerabilities": "no_data", "new_code_smells": "no_data"},
+		},
+		{
+			name:     "failed comparison is skipped",
+			gate:     gate,
+			scans:    []model.ScanRun{{ID: "lint-3", ScanConfigID: "lint"}},
+			baseline: baseline,
+			want:     map[string]string{"new_coverage": "no_data", "new_vulnerabilities": "no_data", "new_code_smells": "no_data"},
+		},
+		{
+			name:     "no new-code thresholds",
+			gate:     &model.QualityGate{},
+			scans:    []model.ScanRun{{ID: "sonar-2", ScanConfigID: "sonar", Vulnerabilities: 9}},
+			baseline: baseline,
+			want:     map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := conditionStatuses(compareNewCode(tt.gate, types, tt.scans, tt.baseline, newFindings))
+			if len(got) != len(tt.want) {
+				t.Errorf("conditions = %v, want %v", got, tt.want)
+			}
+			for metric, want := range tt.want {
+				if got[metric] != want {
+					t.Errorf("%s = %q, want %q", metric, got[metric], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
+	"github.com/zcicd/zcicd-server/internal/quality/model"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ingestFindings stores the findings of a built-in linter run, applying the
+// config's suppressions and baseline, and rolls them up into the run's counts.
+func (s *ScanService) ingestFindings(cfg *model.ScanConfig, run *model.ScanRun, result *engine.TaskResult) error {
+	linter := engine.Linters[cfg.ScanType]
+	var report []byte
+	for name, data := range result.Files {
+		if path.Base(name) == linter.Report {
+			report = data
+		}
+	}
+	if report == nil {
+		return fmt.Errorf("%s 未生成报告 %s", cfg.ScanType, linter.Report)
+	}
+	found, err := linter.Parse(report)
+	if err != nil {
+		return fmt.Errorf("解析 %s 报告失败: %w", cfg.ScanType, err)
+	}
+
+	suppressions := s.activeSuppressions(cfg.ID)
+	baseline := map[string]bool{}
+	if cfg.BaselineRunID != nil {
+		if baseline, err = s.repo.RunFingerprints(*cfg.BaselineRunID); err != nil {
+			fmt.Printf("warning: baseline of scan %s: %v\n", cfg.ID, err)
+		}
+	}
+
+	findings := make([]model.ScanFinding, 0, len(found))
+	for _, f := range found {
+		finding := model.ScanFinding{
+			ScanRunID:    run.ID,
+			ScanConfigID: cfg.ID,
+			Tool:         f.Tool,
+			RuleID:       truncate(f.RuleID, 256),
+			Severity:     f.Severity,
+			Category:     f.Category,
+			File:         truncate(f.File, 512),
+			Line:         f.Line,
+			Message:      f.Message,
+			Fingerprint:  f.Fingerprint(),
+			Status:       "open",
+		}
+		finding.Baseline = baseline[finding.Fingerprint]
+		for i := range suppressions {
+			if suppressionMatches(&suppressions[i], &finding) {
+				finding.Status = "suppressed"
+				finding.SuppressionID = &suppressions[i].ID
+				break
+			}
+		}
+		findings = append(findings, finding)
+	}
+	if err := s.repo.CreateFindings(findings); err != nil {
+		return fmt.Errorf("保存扫描结果失败: %w", err)
+	}
+	rollupFindings(run, findings)
+	return nil
+}
+
+// rollupFindings counts the open findings of a run by category, the way
+// ScanRepository.RecountRuns does.
+func rollupFindings(run *model.ScanRun, findings []model.ScanFinding) {
+	run.Bugs, run.Vulnerabilities, run.CodeSmells, run.NewFindings = 0, 0, 0, 0
+	for _, f := range findings {
+		if f.Status != "open" {
+			continue
+		}
+		switch f.Category {
+		case engine.CategoryBug:
+			run.Bugs++
+		case engine.CategoryVulnerability, engine.CategorySecret:
+			run.Vulnerabilities++
+		case engine.CategoryCodeSmell:
+			run.CodeSmells++
+		}
+		if !f.Baseline {
+			run.NewFindings++
+		}
+	}
+}
+
+// activeSuppressions returns the unexpired suppressions of a scan config.
+func (s *ScanService) activeSuppressions(configID string) []model.ScanSuppression {
+	list, err := s.repo.ListSuppressions(configID)
+	if err != nil {
+		fmt.Printf("warning: suppressions of scan %s: %v\n", configID, err)
+		return nil
+	}
+	now := time.Now()
+	active := list[:0]
+	for _, sup := range list {
+		if sup.ExpiresAt == nil || sup.ExpiresAt.After(now) {
+			active = append(active, sup)
+		}
+	}
+	return active
+}
+
+// suppressionMatches reports whether a suppression hides a finding: by its
+// fingerprint, or by its rule in files matching the path pattern (a glob or
+// a directory prefix).
+func suppressionMatches(sup *model.ScanSuppression, f *model.ScanFinding) bool {
+	if sup.Fingerprint != "" {
+		return sup.Fingerprint == f.Fingerprint
+	}
+	if sup.RuleID == "" || sup.RuleID != f.RuleID {
+		return false
+	}
+	if sup.PathPattern == "" {
+		return true
+	}
+	if ok, _ := path.Match(sup.PathPattern, f.File); ok {
+		return true
+	}
+	return strings.HasPrefix(f.File, strings.TrimSuffix(sup.PathPattern, "/")+"/")
+}
+
+func (s *ScanService) ListFindings(runID string, page, pageSize int, filters map[string]string) ([]model.ScanFinding, int64, error) {
+	return s.repo.ListFindings(runID, page, pageSize, filters)
+}
+
+func (s *ScanService) ListSuppressions(configID string) ([]model.ScanSuppression, error) {
+	return s.repo.ListSuppressions(configID)
+}
+
+// CreateSuppression suppresses findings of a scan config, including the open
+// findings of its past runs whose counts are updated.
+func (s *ScanService) CreateSuppression(configID, userID string, req CreateSuppressionReq) (*model.ScanSuppression, error) {
+	if _, err := s.getConfig(configID); err != nil {
+		return nil, err
+	}
+	if req.Fingerprint == "" && req.RuleID == "" {
+		return nil, appErrors.ErrSuppressionInvalid
+	}
+	if req.PathPattern != "" {
+		if _, err := path.Match(req.PathPattern, ""); err != nil {
+			return nil, appErrors.ErrSuppressionInvalid
+		}
+	}
+	sup := &model.ScanSuppression{
+		ScanConfigID: configID,
+		Fingerprint:  req.Fingerprint,
+		RuleID:       req.RuleID,
+		PathPattern:  req.PathPattern,
+		Reason:       req.Reason,
+		ExpiresAt:    req.ExpiresAt,
+		CreatedAt:    time.Now(),
+	}
+	if req.Fingerprint != "" {
+		sup.RuleID, sup.PathPattern = "", ""
+	}
+	if userID != "" {
+		sup.CreatedBy = &userID
+	}
+	if err := s.repo.CreateSuppression(sup); err != nil {
+		return nil, err
+	}
+
+	open, err := s.repo.ListOpenFindings(configID)
+	if err != nil {
+		return sup, err
+	}
+	var ids []string
+	runs := map[string]bool{}
+	for i := range open {
+		if suppressionMatches(sup, &open[i]) {
+			ids = append(ids, open[i].ID)
+			runs[open[i].ScanRunID] = true
+		}
+	}
+	if err := s.repo.SuppressFindings(ids, sup.ID); err != nil {
+		return sup, err
+	}
+	return sup, s.repo.RecountRuns(keys(runs))
+}
+
+// DeleteSuppression reopens the findings of a suppression and deletes it.
+func (s *ScanService) DeleteSuppression(configID, id string) error {
+	runIDs, err := s.repo.UnsuppressFindings(id)
+	if err != nil {
+		return err
+	}
+	ok, err := s.repo.DeleteSuppression(configID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return appErrors.ErrSuppressionNotFound
+	}
+	return s.repo.RecountRuns(runIDs)
+}
+
+// SetBaseline makes a completed run the baseline of its scan config: findings
+// also present in it are existing debt and not counted as new.
+func (s *ScanService) SetBaseline(configID string, req SetScanBaselineReq) (*model.ScanConfig, error) {
+	cfg, err := s.getConfig(configID)
+	if err != nil {
+		return nil, err
+	}
+	if req.RunID != nil {
+		run, err := s.repo.GetRun(*req.RunID)
+		if err != nil || run.ScanConfigID != configID || run.Status != "completed" {
+			return nil, appErrors.ErrScanBaselineInvalid
+		}
+	}
+	if err := s.repo.SetBaseline(configID, req.RunID); err != nil {
+		return nil, err
+	}
+	cfg.BaselineRunID = req.RunID
+	runIDs, err := s.repo.FindingRunIDs(configID)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, s.repo.RecountRuns(runIDs)
+}
+
+func (s *ScanService) getConfig(id string) (*model.ScanConfig, error) {
+	cfg, err := s.repo.GetConfig(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrScanConfigNotFound
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func keys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for k := range set {
+		list = append(list, k)
+	}
+	return list
+}
@@ -1,18 +1,60 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
 	"time"
 
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
 	"github.com/zcicd/zcicd-server/internal/quality/model"
 	"github.com/zcicd/zcicd-server/internal/quality/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
+const (
+	scanPollInterval  = 10 * time.Second
+	sonarPollInterval = 5 * time.Second
+	// sonarTaskTimeout bounds the wait for SonarQube to process an analysis.
+	sonarTaskTimeout   = 30 * time.Minute
+	defaultScanTimeout = time.Hour
+	sonarScannerImage  = "sonarsource/sonar-scanner-cli:latest"
+)
+
+// scanOptions is the config JSON of a scan config.
+type scanOptions struct {
+	// IntegrationID is the SonarQube integration; the first active one if empty.
+	IntegrationID string `json:"integration_id"`
+	// Command runs before the scanner, e.g. to produce coverage reports.
+	Command string `json:"command"`
+	// Properties are the sonar-scanner analysis parameters.
+	Properties map[string]string `json:"properties"`
+	// Args replace the default arguments of a built-in linter.
+	Args    string `json:"args"`
+	Image   string `json:"image"`
+	Timeout int    `json:"timeout"` // seconds
+}
+
+// sonarConfig is the config JSON of a SonarQube integration.
+type sonarConfig struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
 type ScanService struct {
-	repo *repository.ScanRepository
+	repo     *repository.ScanRepository
+	runner   *engine.TaskRunner
+	mqClient *mq.Client
 }
 
-func NewScanService(repo *repository.ScanRepository) *ScanService {
-	return &ScanService{repo: repo}
+func NewScanService(repo *repository.ScanRepository, runner *engine.TaskRunner, mqClient *mq.Client) *ScanService {
+	return &ScanService{repo: repo, runner: runner, mqClient: mqClient}
 }
 
 func (s *ScanService) CreateConfig(projectID string, req CreateScanConfigReq) (*model.ScanConfig, error) {
@@ -26,6 +68,12 @@ func (s *ScanService) CreateConfig(projectID string, req CreateScanConfigReq) (*
 	if c.ScanType == "" {
 		c.ScanType = "sonar"
 	}
+	if len(req.Config) > 0 && string(req.Config) != "null" {
+		if err := json.Unmarshal(req.Config, &scanOptions{}); err != nil {
+			return nil, appErrors.Wrap(appErrors.ErrBadRequest.Code, "扫描配置格式错误", err)
+		}
+		c.Config = datatypes.JSON(req.Config)
+	}
 	return c, s.repo.CreateConfig(c)
 }
 
@@ -47,6 +95,12 @@ func (s *ScanService) UpdateConfig(id string, req UpdateScanConfigReq) (*model.S
 	if req.SonarProjectKey != "" {
 		c.SonarProjectKey = req.SonarProjectKey
 	}
+	if len(req.Config) > 0 && string(req.Config) != "null" {
+		if err := json.Unmarshal(req.Config, &scanOptions{}); err != nil {
+			return nil, appErrors.Wrap(appErrors.ErrBadRequest.Code, "扫描配置格式错误", err)
+		}
+		c.Config = datatypes.JSON(req.Config)
+	}
 	if req.Enabled != nil {
 		c.Enabled = *req.Enabled
 	}
@@ -61,14 +115,322 @@ func (s *ScanService) ListConfigs(projectID string, page, pageSize int) ([]model
 	return s.repo.ListConfigs(projectID, page, pageSize)
 }
 
-func (s *ScanService) TriggerRun(configID string) (*model.ScanRun, error) {
+// TriggerRun scans the source of a build run with a TaskRun. The run is
+// completed in the background from the scanner's results.
+func (s *ScanService) TriggerRun(ctx context.Context, configID string, req TriggerScanRunReq) (*model.ScanRun, error) {
+	cfg, err := s.repo.GetConfig(configID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrScanConfigNotFound
+		}
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return nil, appErrors.ErrScanConfigDisabled
+	}
+	opts := parseScanOptions(cfg)
+	sc, err := s.scanIntegration(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	build, err := s.repo.GetBuildRun(req.BuildRunID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrBuildRunNotFound
+		}
+		return nil, err
+	}
+	// A config only runs against the builds of its own project.
+	if build.ProjectID != cfg.ProjectID {
+		return nil, appErrors.ErrBuildRunNotFound
+	}
+	if s.runner == nil {
+		return nil, fmt.Errorf("scan runner not available")
+	}
+
 	now := time.Now()
 	run := &model.ScanRun{
-		ScanConfigID: configID,
-		Status:       "running",
+		ScanConfigID: cfg.ID,
+		BuildRunID:   build.ID,
+		Branch:       build.Branch,
+		CommitSHA:    build.CommitSHA,
+		Status:       "pending",
 		StartedAt:    &now,
 	}
-	return run, s.repo.CreateRun(run)
+	pr := s.pullRequest(build)
+	if pr != nil {
+		run.PullRequest = &pr.Number
+	}
+	if err := s.repo.CreateRun(run); err != nil {
+		return nil, err
+	}
+
+	spec := scanTask(cfg, opts, run, build, sc, sonarTarget(run, pr))
+	if err := s.runner.Submit(ctx, spec); err != nil {
+		run.Status = "failed"
+		run.ErrorMessage = err.Error()
+		run.FinishedAt = &now
+		s.repo.UpdateRun(run)
+		s.publishEvent(cfg.ProjectID, run)
+		return run, err
+	}
+	run.Status = "running"
+	run.TektonRef = spec.Name
+	if err := s.repo.UpdateRun(run); err != nil {
+		return nil, err
+	}
+	go s.watch(cfg, run, sonarClient(sc), scanTimeout(opts))
+	return run, nil
+}
+
+// ResumeRuns watches the TaskRuns of runs left running by a previous process.
+func (s *ScanService) ResumeRuns() {
+	if s.runner == nil {
+		return
+	}
+	runs, err := s.repo.ListActiveRuns()
+	if err != nil {
+		fmt.Printf("warning: failed to list running scan runs: %v\n", err)
+		return
+	}
+	for i := range runs {
+		run := &runs[i]
+		cfg, err := s.repo.GetConfig(run.ScanConfigID)
+		if err != nil {
+			fmt.Printf("warning: scan config of run %s: %v\n", run.ID, err)
+			continue
+		}
+		opts := parseScanOptions(cfg)
+		sc, err := s.scanIntegration(cfg, opts)
+		if err != nil {
+			fmt.Printf("warning: scan run %s: %v\n", run.ID, err)
+			continue
+		}
+		go s.watch(cfg, run, sonarClient(sc), scanTimeout(opts))
+	}
+}
+
+func parseScanOptions(cfg *model.ScanConfig) scanOptions {
+	var opts scanOptions
+	if len(cfg.Config) > 0 {
+		if err := json.Unmarshal(cfg.Config, &opts); err != nil {
+			fmt.Printf("warning: invalid config of scan %s: %v\n", cfg.ID, err)
+		}
+	}
+	return opts
+}
+
+func scanTimeout(opts scanOptions) time.Duration {
+	if opts.Timeout > 0 {
+		return time.Duration(opts.Timeout) * time.Second
+	}
+	return defaultScanTimeout
+}
+
+// scanIntegration returns the SonarQube server of a sonar scan, and nil for
+// the built-in linters which need none.
+func (s *ScanService) scanIntegration(cfg *model.ScanConfig, opts scanOptions) (*sonarConfig, error) {
+	if cfg.ScanType != "sonar" {
+		if _, ok := engine.Linters[cfg.ScanType]; !ok {
+			return nil, appErrors.ErrScanTypeUnsupported
+		}
+		return nil, nil
+	}
+	var integration *model.Integration
+	var err error
+	if opts.IntegrationID != "" {
+		integration, err = s.repo.GetIntegration(opts.IntegrationID)
+	} else {
+		integration, err = s.repo.FindIntegration("sonarqube")
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrScanIntegration
+		}
+		return nil, err
+	}
+	var sc sonarConfig
+	if err := json.Unmarshal(integration.ConfigEnc, &sc); err != nil || sc.URL == "" {
+		return nil, appErrors.ErrScanIntegration
+	}
+	return &sc, nil
+}
+
+// pullRequest returns the open pull request whose head is the branch of a
+// build, if any.
+func (s *ScanService) pullRequest(build *model.BuildRun) *model.PullRequest {
+	if build.Branch == "" {
+		return nil
+	}
+	prs, err := s.repo.ListOpenPullRequests(build.Branch)
+	if err != nil {
+		fmt.Printf("warning: pull requests of %s: %v\n", build.Branch, err)
+		return nil
+	}
+	for i := range prs {
+		if sameRepo(prs[i].RepoURL, build.RepoURL) {
+			return &prs[i]
+		}
+	}
+	return nil
+}
+
+// sonarTarget is the SonarQube branch or pull request the analysis of a run
+// is reported for. pr, if known, supplies the base branch.
+func sonarTarget(run *model.ScanRun, pr *model.PullRequest) engine.SonarTarget {
+	target := engine.SonarTarget{Branch: run.Branch}
+	if run.PullRequest != nil {
+		target.PullRequest = strconv.Itoa(*run.PullRequest)
+	}
+	if pr != nil {
+		target.BaseBranch = pr.BaseBranch
+	}
+	return target
+}
+
+func sonarClient(sc *sonarConfig) *engine.SonarClient {
+	if sc == nil {
+		return nil
+	}
+	return engine.NewSonarClient(sc.URL, sc.Token)
+}
+
+// scanTask is the TaskRun running the scanner of a scan config on the source
+// of a build: sonar-scanner for sonar scans (sc set) reporting to the target's
+// branch or pull request, a built-in linter otherwise.
+func scanTask(cfg *model.ScanConfig, opts scanOptions, run *model.ScanRun, build *model.BuildRun, sc *sonarConfig, target engine.SonarTarget) engine.TaskSpec {
+	spec := engine.TaskSpec{
+		Name: "zcicd-scan-" + run.ID,
+		Labels: map[string]string{
+			"zcicd.io/scan-config": cfg.ID,
+			"zcicd.io/scan-run":    run.ID,
+		},
+		RepoURL:   build.RepoURL,
+		Branch:    build.Branch,
+		CommitSHA: build.CommitSHA,
+		Image:     opts.Image,
+		Timeout:   scanTimeout(opts),
+	}
+	if sc == nil {
+		linter := engine.Linters[cfg.ScanType]
+		if spec.Image == "" {
+			spec.Image = linter.Image
+		}
+		spec.Script = linter.Script(opts.Command, opts.Args)
+		return spec
+	}
+
+	projectKey := cfg.SonarProjectKey
+	if projectKey == "" {
+		projectKey = cfg.ID
+	}
+	if spec.Image == "" {
+		spec.Image = sonarScannerImage
+	}
+	// Properties of the scan config override the target's
+	properties := target.ScannerProperties()
+	for k, v := range opts.Properties {
+		properties[k] = v
+	}
+	spec.Script = engine.SonarScript(projectKey, build.CommitSHA, opts.Command, properties)
+	spec.Env = map[string]string{"SONAR_HOST_URL": sc.URL}
+	if sc.Token != "" {
+		spec.SecretEnv = map[string]string{"SONAR_TOKEN": sc.Token}
+	}
+	return spec
+}
+
+// watch waits for the scanner TaskRun of a run and completes the run with the
+// measures of its SonarQube analysis (sonar set) or the findings of the linter.
+func (s *ScanService) watch(cfg *model.ScanConfig, run *model.ScanRun, sonar *engine.SonarClient, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+sonarTaskTimeout)
+	defer cancel()
+	if err := s.analyze(ctx, cfg, run, sonar); err != nil {
+		run.Status = "failed"
+		run.ErrorMessage = err.Error()
+	} else {
+		run.Status = "completed"
+	}
+	now := time.Now()
+	run.FinishedAt = &now
+	if err := s.repo.UpdateRun(run); err != nil {
+		fmt.Printf("warning: failed to update scan run %s: %v\n", run.ID, err)
+		return
+	}
+	s.publishEvent(cfg.ProjectID, run)
+}
+
+func (s *ScanService) analyze(ctx context.Context, cfg *model.ScanConfig, run *model.ScanRun, sonar *engine.SonarClient) error {
+	result, err := s.runner.Wait(ctx, run.TektonRef, scanPollInterval)
+	if err != nil {
+		return err
+	}
+	switch {
+	case result.Status == "timeout":
+		return fmt.Errorf("扫描超时")
+	case result.Status == "cancelled":
+		return fmt.Errorf("扫描已取消")
+	case result.ExitCode < 0:
+		return fmt.Errorf("扫描未运行: %s", result.Message)
+	case result.ExitCode != 0:
+		return fmt.Errorf("%s 退出码 %d", cfg.ScanType, result.ExitCode)
+	}
+	if sonar == nil {
+		return s.ingestFindings(cfg, run, result)
+	}
+
+	var report engine.SonarReportTask
+	for name, data := range result.Files {
+		if path.Base(name) == "report-task.txt" {
+			report = engine.ParseReportTask(data)
+		}
+	}
+	if report.CeTaskID == "" {
+		return fmt.Errorf("sonar-scanner 未生成 report-task.txt")
+	}
+	run.ReportURL = report.DashboardURL
+
+	task, err := sonar.WaitTask(ctx, report.CeTaskID, sonarPollInterval)
+	if err != nil {
+		return err
+	}
+	run.AnalysisID = task.AnalysisID
+
+	projectKey := report.ProjectKey
+	if projectKey == "" {
+		projectKey = cfg.SonarProjectKey
+	}
+	measures, err := sonar.Measures(ctx, projectKey, sonarTarget(run, nil))
+	if err != nil {
+		return err
+	}
+	run.Bugs = measures.Bugs
+	run.Vulnerabilities = measures.Vulnerabilities
+	run.CodeSmells = measures.CodeSmells
+	run.Coverage = measures.Coverage
+	run.Duplications = measures.Duplications
+	run.NewCoverage = measures.NewCoverage
+	run.QualityRating = measures.Rating
+	if run.ReportURL == "" {
+		run.ReportURL = sonar.BaseURL() + "/dashboard?" + sonarTarget(run, nil).DashboardQuery(projectKey)
+	}
+	return nil
+}
+
+func (s *ScanService) publishEvent(projectID string, run *model.ScanRun) {
+	if s.mqClient == nil {
+		return
+	}
+	event := mq.Event{
+		EventType: mq.SubjectScanCompleted,
+		Timestamp: time.Now().Format(time.RFC3339),
+		ProjectID: projectID,
+		Payload:   run,
+	}
+	data, _ := json.Marshal(event)
+	if err := s.mqClient.Publish(mq.SubjectScanCompleted, data); err != nil {
+		fmt.Printf("warning: failed to publish scan completed event: %v\n", err)
+	}
 }
 
 func (s *ScanService) GetRun(id string) (*model.ScanRun, error) {
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
+	"github.com/zcicd/zcicd-server/internal/quality/model"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Default number of recent runs flaky and slow tests are computed over.
+const (
+	defaultFlakyRuns = 50
+	defaultSlowRuns  = 20
+)
+
+// testKey identifies a test case across runs: its class (or suite) and name.
+func testKey(tc engine.TestCase) string {
+	prefix := tc.ClassName
+	if prefix == "" {
+		prefix = tc.Suite
+	}
+	if prefix == "" {
+		return truncate(tc.Name, 512)
+	}
+	return truncate(prefix+"."+tc.Name, 512)
+}
+
+// caseResults converts the test cases of a report into results of a run,
+// marking the failures of quarantined tests.
+func caseResults(run *model.TestRun, cases []engine.TestCase, quarantined map[string]bool) []model.TestCaseResult {
+	results := make([]model.TestCaseResult, 0, len(cases))
+	for _, tc := range cases {
+		key := testKey(tc)
+		results = append(results, model.TestCaseResult{
+			TestRunID:    run.ID,
+			TestConfigID: run.TestConfigID,
+			TestKey:      key,
+			Suite:        truncate(tc.Suite, 256),
+			ClassName:    truncate(tc.ClassName, 256),
+			Name:         truncate(tc.Name, 256),
+			Status:       tc.Status,
+			Duration:     int(tc.Duration * 1000),
+			Message:      tc.Message,
+			Quarantined:  tc.Status == "failed" && quarantined[key],
+		})
+	}
+	return results
+}
+
+// quarantinedKeys returns the keys of the quarantined tests of a config.
+func (s *TestService) quarantinedKeys(configID string) map[string]bool {
+	keys := map[string]bool{}
+	list, err := s.repo.ListQuarantines(configID)
+	if err != nil {
+		return keys
+	}
+	for _, q := range list {
+		keys[q.TestKey] = true
+	}
+	return keys
+}
+
+func (s *TestService) ListCaseResults(runID, status string, page, pageSize int) ([]model.TestCaseResult, int64, error) {
+	return s.repo.ListCaseResults(runID, status, page, pageSize)
+}
+
+// CaseHistory returns the pass/fail history of a test across runs.
+func (s *TestService) CaseHistory(configID, testKey string, page, pageSize int) ([]model.TestCaseHistory, int64, error) {
+	return s.repo.CaseHistory(configID, testKey, page, pageSize)
+}
+
+// FlakyTests returns the tests that both passed and failed on the same commit
+// within the latest runs of a config, most flaky first.
+func (s *TestService) FlakyTests(configID string, runs int) ([]model.FlakyTest, error) {
+	if runs <= 0 {
+		runs = defaultFlakyRuns
+	}
+	results, err := s.repo.RecentCaseResults(configID, runs)
+	if err != nil {
+		return nil, err
+	}
+	return flakyTests(results, s.quarantinedKeys(configID)), nil
+}
+
+// flakyTests computes the flaky tests of case results ordered by creation.
+func flakyTests(results []model.TestCaseRun, quarantined map[string]bool) []model.FlakyTest {
+	type commitResults struct{ passed, failed bool }
+	type stats struct {
+		test    *model.FlakyTest
+		last    string
+		commits map[string]*commitResults
+	}
+	byKey := map[string]*stats{}
+	var keys []string
+	for _, r := range results {
+		if r.Status == "skipped" {
+			continue
+		}
+		st, ok := byKey[r.TestKey]
+		if !ok {
+			st = &stats{
+				test: &model.FlakyTest{
+					TestKey: r.TestKey, Suite: r.Suite, ClassName: r.ClassName, Name: r.Name,
+					Quarantined: quarantined[r.TestKey],
+				},
+				commits: map[string]*commitResults{},
+			}
+			byKey[r.TestKey] = st
+			keys = append(keys, r.TestKey)
+		}
+		st.test.Runs++
+		if r.Status == "failed" {
+			st.test.Failures++
+		}
+		if st.last != "" && st.last != r.Status {
+			st.test.Flips++
+		}
+		st.last = r.Status
+
+		if r.CommitSHA == "" {
+			continue
+		}
+		c, ok := st.commits[r.CommitSHA]
+		if !ok {
+			c = &commitResults{}
+			st.commits[r.CommitSHA] = c
+		}
+		wasFlaky := c.passed && c.failed
+		if r.Status == "failed" {
+			c.failed = true
+		} else {
+			c.passed = true
+		}
+		if c.passed && c.failed {
+			if !wasFlaky {
+				st.test.FlakyCommits++
+			}
+			st.test.LastFlakyAt = r.CreatedAt
+		}
+	}
+
+	var flaky []model.FlakyTest
+	for _, key := range keys {
+		if t := byKey[key].test; t.FlakyCommits > 0 {
+			flaky = append(flaky, *t)
+		}
+	}
+	sort.SliceStable(flaky, func(i, j int) bool {
+		if flaky[i].FlakyCommits != flaky[j].FlakyCommits {
+			return flaky[i].FlakyCommits > flaky[j].FlakyCommits
+		}
+		return flaky[i].Flips > flaky[j].Flips
+	})
+	return flaky
+}
+
+// SlowTests returns the slowest tests over the latest runs of a config.
+func (s *TestService) SlowTests(configID string, runs, limit int) ([]model.SlowTest, error) {
+	if runs <= 0 {
+		runs = defaultSlowRuns
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.repo.SlowTests(configID, runs, limit)
+}
+
+func (s *TestService) ListQuarantines(configID string) ([]model.TestQuarantine, error) {
+	return s.repo.ListQuarantines(configID)
+}
+
+// Quarantine excludes the failures of a test from the status of later runs.
+func (s *TestService) Quarantine(configID, userID string, req QuarantineTestReq) (*model.TestQuarantine, error) {
+	if _, err := s.repo.GetConfig(configID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrTestConfigNotFound
+		}
+		return nil, err
+	}
+	_, err := s.repo.GetQuarantine(configID, req.TestKey)
+	if err == nil {
+		return nil, appErrors.ErrTestQuarantined
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	q := &model.TestQuarantine{
+		TestConfigID: configID,
+		TestKey:      req.TestKey,
+		Reason:       req.Reason,
+		CreatedAt:    time.Now(),
+	}
+	if userID != "" {
+		q.CreatedBy = &userID
+	}
+	return q, s.repo.CreateQuarantine(q)
+}
+
+func (s *TestService) Unquarantine(configID, id string) error {
+	ok, err := s.repo.DeleteQuarantine(configID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return appErrors.ErrQuarantineNotFound
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// Cut on a rune boundary.
+	for n > 0 && (s[n]&0xC0) == 0x80 {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zcicd/zcicd-server/internal/quality/model"
+)
+
+func TestFlakyTests(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	var results []model.TestCaseRun
+	add := func(key, commit, status string) {
+		results = append(results, model.TestCaseRun{
+			TestCaseResult: model.TestCaseResult{
+				TestKey: key, Name: key, Status: status,
+				CreatedAt: start.Add(time.Duration(len(results)) * time.Minute),
+			},
+			CommitSHA: commit,
+		})
+	}
+	add("retried", "c1", "passed")
+	add("retried", "c1", "failed")
+	add("fixed", "c1", "failed")
+	add("skipped", "c1", "passed")
+	add("quarantined", "c1", "failed")
+	add("no-commit", "", "passed")
+	add("retried", "c2", "failed")
+	add("fixed", "c2", "passed")
+	add("skipped", "c2", "skipped")
+	add("quarantined", "c1", "passed")
+	add("no-commit", "", "failed")
+	add("retried", "c2", "passed")
+	add("retried", "c2", "failed")
+
+	flaky := flakyTests(results, map[string]bool{"quarantined": true})
+
+	want := []model.FlakyTest{
+		{TestKey: "retried", Name: "retried", Runs: 5, Failures: 3, Flips: 3, FlakyCommits: 2, LastFlakyAt: start.Add(12 * time.Minute)},
+		{TestKey: "quarantined", Name: "quarantined", Runs: 2, Failures: 1, Flips: 1, FlakyCommits: 1, LastFlakyAt: start.Add(9 * time.Minute), Quarantined: true},
+	}
+	if len(flaky) != len(want) {
+		t.Fatalf("flakyTests() = %+v, want %+v", flaky, want)
+	}
+	for i := range want {
+		if flaky[i] != want[i] {
+			t.Errorf("flakyTests()[%d] = %+v, want %+v", i, flaky[i], want[i])
+		}
+	}
+}
@@ -1,18 +1,59 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/zcicd/zcicd-server/internal/quality/engine"
 	"github.com/zcicd/zcicd-server/internal/quality/model"
 	"github.com/zcicd/zcicd-server/internal/quality/repository"
+	appErrors "github.com/zcicd/zcicd-server/pkg/errors"
+	"github.com/zcicd/zcicd-server/pkg/mq"
+	"github.com/zcicd/zcicd-server/pkg/storage"
+	"gorm.io/gorm"
 )
 
+// testPollInterval is how often a running TaskRun is checked.
+const testPollInterval = 10 * time.Second
+
+// frameworkImages are the images tests of a framework run in.
+var frameworkImages = map[string]string{
+	"go":      "golang:1.24",
+	"gotest":  "golang:1.24",
+	"jest":    "node:20",
+	"mocha":   "node:20",
+	"vitest":  "node:20",
+	"pytest":  "python:3.12",
+	"junit":   "maven:3-eclipse-temurin-17",
+	"maven":   "maven:3-eclipse-temurin-17",
+	"gradle":  "gradle:8-jdk17",
+	"dotnet":  "mcr.microsoft.com/dotnet/sdk:8.0",
+	"phpunit": "composer:2",
+}
+
+const defaultTestImage = "alpine:latest"
+
+// reportPatterns are the report files collected from the source tree in
+// addition to everything written to $REPORT_DIR.
+var reportPatterns = []string{
+	"TEST-*.xml", "junit*.xml", "*junit.xml", "report.xml",
+	"cobertura*.xml", "coverage.xml", "lcov.info", "coverage.out", "cover.out", "*.coverprofile",
+}
+
 type TestService struct {
-	repo *repository.TestRepository
+	repo     *repository.TestRepository
+	runner   *engine.TaskRunner
+	store    *storage.Client
+	bucket   string
+	mqClient *mq.Client
 }
 
-func NewTestService(repo *repository.TestRepository) *TestService {
-	return &TestService{repo: repo}
+func NewTestService(repo *repository.TestRepository, runner *engine.TaskRunner, store *storage.Client, bucket string, mqClient *mq.Client) *TestService {
+	return &TestService{repo: repo, runner: runner, store: store, bucket: bucket, mqClient: mqClient}
 }
 
 func (s *TestService) CreateConfig(projectID string, req CreateTestConfigReq) (*model.TestConfig, error) {
@@ -72,20 +113,223 @@ func (s *TestService) ListConfigs(projectID string, page, pageSize int) ([]model
 	return s.repo.ListConfigs(projectID, page, pageSize)
 }
 
-func (s *TestService) TriggerRun(configID string) (*model.TestRun, error) {
+// TriggerRun runs a test config as a Tekton TaskRun against the source of a
+// build run. The run is completed in the background once the TaskRun finishes.
+func (s *TestService) TriggerRun(ctx context.Context, configID string, req TriggerTestRunReq) (*model.TestRun, error) {
+	cfg, err := s.repo.GetConfig(configID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrTestConfigNotFound
+		}
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return nil, appErrors.ErrTestConfigDisabled
+	}
+	build, err := s.repo.GetBuildRun(req.BuildRunID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrBuildRunNotFound
+		}
+		return nil, err
+	}
+	// A config only runs against the builds of its own project.
+	if build.ProjectID != cfg.ProjectID {
+		return nil, appErrors.ErrBuildRunNotFound
+	}
+	if s.runner == nil {
+		return nil, fmt.Errorf("test runner not available")
+	}
+
 	now := time.Now()
 	run := &model.TestRun{
-		TestConfigID: configID,
-		Status:       "running",
+		TestConfigID: cfg.ID,
+		BuildRunID:   build.ID,
+		CommitSHA:    build.CommitSHA,
+		Status:       "pending",
 		StartedAt:    &now,
 	}
-	return run, s.repo.CreateRun(run)
+	if err := s.repo.CreateRun(run); err != nil {
+		return nil, err
+	}
+
+	spec := engine.TaskSpec{
+		Name: "zcicd-test-" + run.ID,
+		Labels: map[string]string{
+			"zcicd.io/test-config": cfg.ID,
+			"zcicd.io/test-run":    run.ID,
+		},
+		RepoURL:   build.RepoURL,
+		Branch:    build.Branch,
+		CommitSHA: build.CommitSHA,
+		Image:     testImage(cfg.Framework),
+		Script:    cfg.Command,
+		Collect:   reportPatterns,
+		Timeout:   time.Duration(cfg.Timeout) * time.Second,
+	}
+	if err := s.runner.Submit(ctx, spec); err != nil {
+		run.Status = "error"
+		run.ErrorMessage = err.Error()
+		run.FinishedAt = &now
+		s.repo.UpdateRun(run)
+		return run, err
+	}
+	run.Status = "running"
+	run.TektonRef = spec.Name
+	if err := s.repo.UpdateRun(run); err != nil {
+		return nil, err
+	}
+	go s.watch(cfg, run)
+	return run, nil
+}
+
+// ResumeRuns watches the TaskRuns of runs left running by a previous process.
+func (s *TestService) ResumeRuns() {
+	if s.runner == nil {
+		return
+	}
+	runs, err := s.repo.ListActiveRuns()
+	if err != nil {
+		fmt.Printf("warning: failed to list running test runs: %v\n", err)
+		return
+	}
+	for i := range runs {
+		run := &runs[i]
+		cfg, err := s.repo.GetConfig(run.TestConfigID)
+		if err != nil {
+			fmt.Printf("warning: test config of run %s: %v\n", run.ID, err)
+			continue
+		}
+		go s.watch(cfg, run)
+	}
+}
+
+// watch waits for the TaskRun of a run and completes the run with its reports.
+func (s *TestService) watch(cfg *model.TestConfig, run *model.TestRun) {
+	// Leave the TaskRun time to hit its own timeout and report it.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Second+10*time.Minute)
+	defer cancel()
+	result, err := s.runner.Wait(ctx, run.TektonRef, testPollInterval)
+	if err != nil {
+		result = &engine.TaskResult{Status: "failed", Message: err.Error(), ExitCode: -1}
+	}
+	s.complete(ctx, cfg, run, result)
+}
+
+// complete records the outcome and reports of a finished TaskRun, uploads the
+// raw reports and publishes the test completed event.
+func (s *TestService) complete(ctx context.Context, cfg *model.TestConfig, run *model.TestRun, result *engine.TaskResult) {
+	report := engine.ParseReports(result.Files)
+	cases := caseResults(run, report.Cases, s.quarantinedKeys(cfg.ID))
+	run.Total = report.Total
+	run.Passed = report.Passed
+	run.Failed = report.Failed
+	run.Skipped = report.Skipped
+	run.Quarantined = 0
+	for _, c := range cases {
+		if c.Quarantined {
+			run.Quarantined++
+			run.Failed--
+		}
+	}
+	run.Coverage = report.Coverage
+
+	switch {
+	case result.Status == "timeout":
+		run.Status = "error"
+		run.ErrorMessage = fmt.Sprintf("测试超时（%d 秒）", cfg.Timeout)
+	case result.Status == "cancelled":
+		run.Status = "error"
+		run.ErrorMessage = "测试已取消"
+	case result.ExitCode < 0:
+		run.Status = "error"
+		run.ErrorMessage = result.Message
+	case run.Failed > 0 || (result.ExitCode != 0 && run.Quarantined == 0):
+		// A non-zero exit is put down to quarantined failures when there are any.
+		run.Status = "failed"
+		if run.Failed == 0 {
+			run.ErrorMessage = fmt.Sprintf("测试命令退出码 %d", result.ExitCode)
+		}
+	default:
+		run.Status = "passed"
+	}
+
+	finished := time.Now()
+	if result.FinishedAt != nil {
+		finished = *result.FinishedAt
+	}
+	started := finished
+	if result.StartedAt != nil {
+		started = *result.StartedAt
+	} else if run.StartedAt != nil {
+		started = *run.StartedAt
+	}
+	duration := int(finished.Sub(started).Milliseconds())
+	run.Duration = &duration
+	run.FinishedAt = &finished
+
+	if len(result.Archive) > 0 && s.store != nil {
+		object := fmt.Sprintf("test-reports/%s/%s.tar.gz", cfg.ID, run.ID)
+		err := s.store.UploadFile(ctx, s.bucket, object, bytes.NewReader(result.Archive), int64(len(result.Archive)), "application/gzip")
+		if err != nil {
+			fmt.Printf("warning: failed to upload reports of test run %s: %v\n", run.ID, err)
+		} else {
+			run.ReportURL = object
+		}
+	}
+
+	if err := s.repo.UpdateRun(run); err != nil {
+		fmt.Printf("warning: failed to update test run %s: %v\n", run.ID, err)
+		return
+	}
+	if err := s.repo.CreateCaseResults(cases); err != nil {
+		fmt.Printf("warning: failed to store test cases of run %s: %v\n", run.ID, err)
+	}
+	s.publishEvent(cfg.ProjectID, run)
+}
+
+func (s *TestService) publishEvent(projectID string, run *model.TestRun) {
+	if s.mqClient == nil {
+		return
+	}
+	event := mq.Event{
+		EventType: mq.SubjectTestCompleted,
+		Timestamp: time.Now().Format(time.RFC3339),
+		ProjectID: projectID,
+		Payload:   run,
+	}
+	data, _ := json.Marshal(event)
+	if err := s.mqClient.Publish(mq.SubjectTestCompleted, data); err != nil {
+		fmt.Printf("warning: failed to publish test completed event: %v\n", err)
+	}
 }
 
 func (s *TestService) GetRun(id string) (*model.TestRun, error) {
 	return s.repo.GetRun(id)
 }
 
+// ReportURL returns a download link of the raw reports of a run.
+func (s *TestService) ReportURL(ctx context.Context, id string) (string, error) {
+	run, err := s.repo.GetRun(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", appErrors.ErrTestRunNotFound
+		}
+		return "", err
+	}
+	if run.ReportURL == "" || s.store == nil {
+		return "", appErrors.ErrTestReportNotFound
+	}
+	return s.store.GetPresignedURL(ctx, s.bucket, run.ReportURL, time.Hour)
+}
+
+func testImage(framework string) string {
+	if image, ok := frameworkImages[framework]; ok {
+		return image
+	}
+	return defaultTestImage
+}
+
 func (s *TestService) ListRuns(configID string, page, pageSize int) ([]model.TestRun, int64, error) {
 	return s.repo.ListRuns(configID, page, pageSize)
 }
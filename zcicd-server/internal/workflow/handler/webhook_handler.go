@@ -40,6 +40,41 @@ type gitlabPushPayload struct {
 	} `json:"project"`
 }
 
+type githubPullRequestPayload struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title  string `json:"title"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+}
+
+type gitlabMergeRequestPayload struct {
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		Title        string `json:"title"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+	Project struct {
+		GitHTTPURL string `json:"git_http_url"`
+	} `json:"project"`
+}
+
 func (h *WebhookHandler) HandleGitHub(c *gin.Context) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -58,6 +93,10 @@ func (h *WebhookHandler) HandleGitHub(c *gin.Context) {
 	}
 
 	event := c.GetHeader("X-GitHub-Event")
+	if event == "pull_request" {
+		h.handleGitHubPullRequest(c, body)
+		return
+	}
 	if event != "push" {
 		response.OK(c, gin.H{"message": "event ignored", "event": event})
 		return
@@ -91,6 +130,10 @@ func (h *WebhookHandler) HandleGitLab(c *gin.Context) {
 	}
 
 	event := c.GetHeader("X-Gitlab-Event")
+	if event == "Merge Request Hook" {
+		h.handleGitLabMergeRequest(c, body)
+		return
+	}
 	if event != "Push Hook" {
 		response.OK(c, gin.H{"message": "event ignored", "event": event})
 		return
@@ -106,6 +149,81 @@ func (h *WebhookHandler) HandleGitLab(c *gin.Context) {
 	h.triggerMatchingWorkflows(c, payload.Project.GitHTTPURL, branch, payload.After)
 }
 
+func (h *WebhookHandler) handleGitHubPullRequest(c *gin.Context, body []byte) {
+	var payload githubPullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		response.BadRequest(c, "invalid payload")
+		return
+	}
+	var action string
+	switch payload.Action {
+	case "opened", "reopened":
+		action = "opened"
+	case "synchronize", "edited":
+		action = "updated"
+	case "closed":
+		action = "closed"
+		if payload.PullRequest.Merged {
+			action = "merged"
+		}
+	default:
+		response.OK(c, gin.H{"message": "action ignored", "action": payload.Action})
+		return
+	}
+	h.handlePullRequest(c, service.PullRequestEvent{
+		Provider:   "github",
+		RepoURL:    payload.Repository.CloneURL,
+		Number:     payload.Number,
+		Action:     action,
+		Title:      payload.PullRequest.Title,
+		Branch:     payload.PullRequest.Head.Ref,
+		BaseBranch: payload.PullRequest.Base.Ref,
+		CommitSHA:  payload.PullRequest.Head.SHA,
+	})
+}
+
+func (h *WebhookHandler) handleGitLabMergeRequest(c *gin.Context, body []byte) {
+	var payload gitlabMergeRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		response.BadRequest(c, "invalid payload")
+		return
+	}
+	attrs := payload.ObjectAttributes
+	var action string
+	switch attrs.Action {
+	case "open", "reopen":
+		action = "opened"
+	case "update":
+		action = "updated"
+	case "close":
+		action = "closed"
+	case "merge":
+		action = "merged"
+	default:
+		response.OK(c, gin.H{"message": "action ignored", "action": attrs.Action})
+		return
+	}
+	h.handlePullRequest(c, service.PullRequestEvent{
+		Provider:   "gitlab",
+		RepoURL:    payload.Project.GitHTTPURL,
+		Number:     attrs.IID,
+		Action:     action,
+		Title:      attrs.Title,
+		Branch:     attrs.SourceBranch,
+		BaseBranch: attrs.TargetBranch,
+		CommitSHA:  attrs.LastCommit.ID,
+	})
+}
+
+func (h *WebhookHandler) handlePullRequest(c *gin.Context, event service.PullRequestEvent) {
+	triggered, err := h.workflowSvc.HandlePullRequest(c.Request.Context(), event)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.OK(c, gin.H{"triggered": triggered, "action": event.Action})
+}
+
 func (h *WebhookHandler) triggerMatchingWorkflows(c *gin.Context, repoURL, branch, commitSHA string) {
 	triggered, err := h.workflowSvc.TriggerByWebhook(c.Request.Context(), repoURL, branch, commitSHA)
 	if err != nil {
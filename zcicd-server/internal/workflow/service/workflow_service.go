@@ -325,6 +325,33 @@ func (s *WorkflowService) TriggerByWebhook(ctx context.Context, repoURL, branch,
 	return triggered, nil
 }
 
+// PullRequestEvent is a pull/merge request webhook normalized across providers.
+type PullRequestEvent struct {
+	Provider   string `json:"provider"`
+	RepoURL    string `json:"repo_url"`
+	Number     int    `json:"number"`
+	Action     string `json:"action"` // opened, updated, closed, merged
+	Title      string `json:"title"`
+	Branch     string `json:"branch"`
+	BaseBranch string `json:"base_branch"`
+	CommitSHA  string `json:"commit_sha"`
+}
+
+// HandlePullRequest publishes a pull request event for preview environments
+// and, when the pull request is opened or reopened, triggers the workflows of
+// its head branch. New commits are built by the push webhook, and edits of
+// the title or description build nothing.
+func (s *WorkflowService) HandlePullRequest(ctx context.Context, event PullRequestEvent) (int, error) {
+	if s.mqClient != nil {
+		eventData, _ := json.Marshal(event)
+		s.mqClient.Publish(mq.SubjectPullRequest, eventData)
+	}
+	if event.Action != "opened" {
+		return 0, nil
+	}
+	return s.TriggerByWebhook(ctx, event.RepoURL, event.Branch, event.CommitSHA)
+}
+
 func mustMarshal(v interface{}) []byte {
 	data, _ := json.Marshal(v)
 	return data
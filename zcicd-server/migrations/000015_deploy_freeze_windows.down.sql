@@ -0,0 +1,2 @@
+-- Roll back deployment freeze windows
+DROP TABLE IF EXISTS deploy_freeze_windows CASCADE;
@@ -0,0 +1,20 @@
+-- Deployment freeze windows (one-off or cron-recurring) per project/environment
+CREATE TABLE IF NOT EXISTS deploy_freeze_windows (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id       UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment_id   UUID REFERENCES environments(id) ON DELETE CASCADE,
+    name             VARCHAR(128) NOT NULL,
+    reason           TEXT,
+    recurrence       VARCHAR(128),
+    duration_minutes INTEGER NOT NULL DEFAULT 0,
+    start_at         TIMESTAMPTZ,
+    end_at           TIMESTAMPTZ,
+    timezone         VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled          BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by       UUID,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deploy_freeze_windows_project ON deploy_freeze_windows(project_id);
+CREATE INDEX IF NOT EXISTS idx_deploy_freeze_windows_env ON deploy_freeze_windows(environment_id);
@@ -0,0 +1,5 @@
+-- Roll back per-environment deploy locks
+ALTER TABLE deploy_configs
+DROP COLUMN IF EXISTS lock_policy;
+
+DROP TABLE IF EXISTS deploy_env_locks CASCADE;
@@ -0,0 +1,24 @@
+-- Per-environment deploy lock and queue
+CREATE TABLE IF NOT EXISTS deploy_env_locks (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id        UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment_id    UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    kind              VARCHAR(16) NOT NULL,  -- deploy/manual
+    status            VARCHAR(16) NOT NULL,  -- held/queued/released/expired/cancelled
+    deploy_config_id  UUID REFERENCES deploy_configs(id) ON DELETE CASCADE,
+    deploy_history_id UUID REFERENCES deploy_histories(id) ON DELETE SET NULL,
+    holder_id         UUID,
+    reason            TEXT,
+    acquired_at       TIMESTAMPTZ,
+    expires_at        TIMESTAMPTZ NOT NULL,
+    released_at       TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one holder per environment
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_deploy_env_locks_held ON deploy_env_locks(environment_id) WHERE status = 'held';
+CREATE INDEX IF NOT EXISTS idx_deploy_env_locks_env_status ON deploy_env_locks(environment_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_deploy_env_locks_project ON deploy_env_locks(project_id);
+
+ALTER TABLE deploy_configs
+ADD COLUMN IF NOT EXISTS lock_policy VARCHAR(16) NOT NULL DEFAULT 'queue';
@@ -0,0 +1,2 @@
+-- Roll back canary analysis verdicts
+DROP TABLE IF EXISTS deploy_canary_analyses CASCADE;
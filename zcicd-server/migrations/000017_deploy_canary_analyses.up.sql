@@ -0,0 +1,16 @@
+-- Per-step canary analysis verdicts of a deploy
+CREATE TABLE IF NOT EXISTS deploy_canary_analyses (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    deploy_history_id UUID NOT NULL REFERENCES deploy_histories(id) ON DELETE CASCADE,
+    step_index        INTEGER NOT NULL DEFAULT 0,
+    verdict           VARCHAR(16) NOT NULL,  -- pass/fail/inconclusive
+    action            VARCHAR(16),           -- promote/abort/retry/none
+    canary_error_rate DOUBLE PRECISION,
+    stable_error_rate DOUBLE PRECISION,
+    canary_latency_ms DOUBLE PRECISION,
+    stable_latency_ms DOUBLE PRECISION,
+    reasons           JSONB,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deploy_canary_analyses_history ON deploy_canary_analyses(deploy_history_id);
@@ -0,0 +1,6 @@
+-- Roll back encrypted secret variables. Secret values that exist only in
+-- encrypted form are lost; re-enter them after rolling back.
+DROP INDEX IF EXISTS idx_env_variables_plaintext_secrets;
+
+ALTER TABLE env_variables
+DROP COLUMN IF EXISTS var_value_enc;
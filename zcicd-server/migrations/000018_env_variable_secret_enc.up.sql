@@ -0,0 +1,7 @@
+-- Encrypted storage for secret environment variables.
+-- Existing plain-text secrets are encrypted by the deploy service on startup,
+-- since the AES key lives in its config; it then clears var_value for those rows.
+ALTER TABLE env_variables ADD COLUMN IF NOT EXISTS var_value_enc BYTEA;
+
+CREATE INDEX IF NOT EXISTS idx_env_variables_plaintext_secrets
+    ON env_variables(id) WHERE is_secret AND var_value_enc IS NULL;
@@ -0,0 +1,8 @@
+-- Roll back preview environments. The 'preview' env_type value stays, since
+-- PostgreSQL cannot drop enum values.
+DROP TABLE IF EXISTS deploy_previews CASCADE;
+
+ALTER TABLE deploy_configs
+DROP COLUMN IF EXISTS preview_template,
+DROP COLUMN IF EXISTS preview_domain,
+DROP COLUMN IF EXISTS preview_ttl_hours;
@@ -0,0 +1,38 @@
+-- Ephemeral preview environments, one per pull request and template deploy config
+ALTER TYPE env_type ADD VALUE IF NOT EXISTS 'preview';
+
+ALTER TABLE deploy_configs
+ADD COLUMN IF NOT EXISTS preview_template  BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS preview_domain    VARCHAR(256),
+ADD COLUMN IF NOT EXISTS preview_ttl_hours INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS deploy_previews (
+    id                 UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id         UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    service_id         UUID NOT NULL,
+    template_config_id UUID REFERENCES deploy_configs(id) ON DELETE SET NULL,
+    environment_id     UUID REFERENCES environments(id) ON DELETE SET NULL,
+    deploy_config_id   UUID REFERENCES deploy_configs(id) ON DELETE SET NULL,
+    repo_url           VARCHAR(512) NOT NULL,
+    pr_number          INTEGER NOT NULL,
+    title              VARCHAR(512),
+    branch             VARCHAR(256) NOT NULL,
+    commit_sha         VARCHAR(64),
+    image              VARCHAR(512),
+    namespace          VARCHAR(128) NOT NULL,
+    url                VARCHAR(512),
+    status             VARCHAR(16) NOT NULL,  -- pending/deploying/active/failed/closing/closed
+    close_reason       VARCHAR(32),           -- merged/closed/expired/orphaned/manual
+    error_message      TEXT,
+    last_activity_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at         TIMESTAMPTZ NOT NULL,
+    closed_at          TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one open preview per pull request and template
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_deploy_previews_open
+    ON deploy_previews(template_config_id, repo_url, pr_number) WHERE status NOT IN ('closing', 'closed');
+CREATE INDEX IF NOT EXISTS idx_deploy_previews_project ON deploy_previews(project_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_deploy_previews_status ON deploy_previews(status, expires_at);
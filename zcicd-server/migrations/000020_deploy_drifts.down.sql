@@ -0,0 +1,2 @@
+-- Roll back drift records
+DROP TABLE IF EXISTS deploy_drifts CASCADE;
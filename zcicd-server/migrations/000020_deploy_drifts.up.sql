@@ -0,0 +1,24 @@
+-- Out-of-band changes to deployed applications detected through Argo CD
+CREATE TABLE IF NOT EXISTS deploy_drifts (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id        UUID NOT NULL,
+    deploy_config_id  UUID NOT NULL REFERENCES deploy_configs(id) ON DELETE CASCADE,
+    environment_id    UUID,
+    app_name          VARCHAR(128) NOT NULL,
+    revision          VARCHAR(128),
+    resources         JSONB,
+    status            VARCHAR(16) NOT NULL,  -- open/reconciled/adopted/resolved
+    resolved_by       UUID,
+    deploy_history_id UUID REFERENCES deploy_histories(id) ON DELETE SET NULL,
+    gitops_commit     VARCHAR(64),
+    error_message     TEXT,
+    detected_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at       TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deploy_drifts_project ON deploy_drifts(project_id, detected_at DESC);
+-- At most one open drift per deploy config
+CREATE UNIQUE INDEX IF NOT EXISTS uq_deploy_drifts_open
+    ON deploy_drifts(deploy_config_id) WHERE status = 'open';
@@ -0,0 +1,6 @@
+-- Roll back deploy history snapshots
+DROP TRIGGER IF EXISTS trg_deploy_histories_snapshot_immutable ON deploy_histories;
+DROP FUNCTION IF EXISTS deploy_histories_snapshot_immutable();
+ALTER TABLE deploy_histories DROP COLUMN IF EXISTS chart_version;
+ALTER TABLE deploy_histories DROP COLUMN IF EXISTS image_digest;
+ALTER TABLE deploy_histories DROP COLUMN IF EXISTS values_snapshot;
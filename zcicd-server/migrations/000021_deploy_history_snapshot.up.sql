@@ -0,0 +1,21 @@
+-- Immutable snapshot of what each deploy applied, restored by rollbacks
+ALTER TABLE deploy_histories ADD COLUMN IF NOT EXISTS values_snapshot JSONB;
+ALTER TABLE deploy_histories ADD COLUMN IF NOT EXISTS image_digest VARCHAR(128);
+ALTER TABLE deploy_histories ADD COLUMN IF NOT EXISTS chart_version VARCHAR(128);
+
+-- Once written, a snapshot cannot be changed
+CREATE OR REPLACE FUNCTION deploy_histories_snapshot_immutable() RETURNS trigger AS $$
+BEGIN
+    IF (OLD.values_snapshot IS NOT NULL AND NEW.values_snapshot IS DISTINCT FROM OLD.values_snapshot)
+       OR (COALESCE(OLD.image_digest, '') <> '' AND NEW.image_digest IS DISTINCT FROM OLD.image_digest)
+       OR (COALESCE(OLD.chart_version, '') <> '' AND NEW.chart_version IS DISTINCT FROM OLD.chart_version) THEN
+        RAISE EXCEPTION 'deploy history % snapshot is immutable', OLD.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_deploy_histories_snapshot_immutable ON deploy_histories;
+CREATE TRIGGER trg_deploy_histories_snapshot_immutable
+    BEFORE UPDATE ON deploy_histories
+    FOR EACH ROW EXECUTE FUNCTION deploy_histories_snapshot_immutable();
@@ -0,0 +1,2 @@
+-- Roll back kustomize overrides
+ALTER TABLE deploy_configs DROP COLUMN IF EXISTS kustomize;
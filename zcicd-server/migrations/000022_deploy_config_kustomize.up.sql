@@ -0,0 +1,2 @@
+-- Kustomize overrides (images, name prefix/suffix, labels, patches) of kustomize deploys
+ALTER TABLE deploy_configs ADD COLUMN IF NOT EXISTS kustomize JSONB;
@@ -0,0 +1,2 @@
+-- Roll back environment deploy engines
+ALTER TABLE environments DROP COLUMN IF EXISTS deploy_engine;
@@ -0,0 +1,3 @@
+-- Deploy engine of an environment: argocd, direct (server-side apply without
+-- Argo CD) or auto (Argo CD when installed, direct otherwise)
+ALTER TABLE environments ADD COLUMN IF NOT EXISTS deploy_engine VARCHAR(16) NOT NULL DEFAULT 'auto';
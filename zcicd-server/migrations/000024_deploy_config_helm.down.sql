@@ -0,0 +1,2 @@
+-- Roll back helm value files and parameters
+ALTER TABLE deploy_configs DROP COLUMN IF EXISTS helm;
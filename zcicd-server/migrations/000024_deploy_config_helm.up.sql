@@ -0,0 +1,2 @@
+-- Helm value files and parameters of helm deploys
+ALTER TABLE deploy_configs ADD COLUMN IF NOT EXISTS helm JSONB;
@@ -0,0 +1,2 @@
+-- Roll back the TaskRun reference of test runs
+ALTER TABLE test_runs DROP COLUMN IF EXISTS tekton_ref;
@@ -0,0 +1,2 @@
+-- TaskRun executing a test run
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS tekton_ref VARCHAR(256);
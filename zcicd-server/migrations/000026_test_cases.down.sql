@@ -0,0 +1,5 @@
+-- Roll back per-test-case results and quarantines
+DROP TABLE IF EXISTS test_quarantines CASCADE;
+DROP TABLE IF EXISTS test_case_results CASCADE;
+ALTER TABLE test_runs DROP COLUMN IF EXISTS quarantined;
+ALTER TABLE test_runs DROP COLUMN IF EXISTS commit_sha;
@@ -0,0 +1,31 @@
+-- Per-test-case results of test runs, and tests quarantined per config
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(64);
+ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS quarantined INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS test_case_results (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    test_run_id     UUID NOT NULL REFERENCES test_runs(id) ON DELETE CASCADE,
+    test_config_id  UUID NOT NULL REFERENCES test_configs(id) ON DELETE CASCADE,
+    test_key        VARCHAR(512) NOT NULL,   -- class name (or suite) and test name
+    suite           VARCHAR(256),
+    class_name      VARCHAR(256),
+    name            VARCHAR(256) NOT NULL,
+    status          VARCHAR(16) NOT NULL,    -- passed/failed/skipped
+    duration        INTEGER NOT NULL DEFAULT 0,  -- milliseconds
+    message         TEXT,
+    quarantined     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_test_case_results_run ON test_case_results(test_run_id);
+CREATE INDEX IF NOT EXISTS idx_test_case_results_key ON test_case_results(test_config_id, test_key, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS test_quarantines (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    test_config_id  UUID NOT NULL REFERENCES test_configs(id) ON DELETE CASCADE,
+    test_key        VARCHAR(512) NOT NULL,
+    reason          TEXT,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (test_config_id, test_key)
+);
@@ -0,0 +1,6 @@
+-- Roll back the source revision, TaskRun and analysis of scan runs
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS analysis_id;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS tekton_ref;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS commit_sha;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS branch;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS build_run_id;
@@ -0,0 +1,6 @@
+-- Source revision, TaskRun and SonarQube analysis of scan runs
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS build_run_id UUID;
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS branch VARCHAR(128);
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(64);
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS tekton_ref VARCHAR(256);
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS analysis_id VARCHAR(64);
@@ -0,0 +1,5 @@
+-- Roll back scan findings, suppressions and baselines
+DROP TABLE IF EXISTS scan_findings CASCADE;
+DROP TABLE IF EXISTS scan_suppressions CASCADE;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS new_findings;
+ALTER TABLE scan_configs DROP COLUMN IF EXISTS baseline_run_id;
@@ -0,0 +1,38 @@
+-- Findings of built-in static analysis scans, their suppressions and baselines
+ALTER TABLE scan_configs ADD COLUMN IF NOT EXISTS baseline_run_id UUID;
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS new_findings INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS scan_suppressions (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scan_config_id  UUID NOT NULL REFERENCES scan_configs(id) ON DELETE CASCADE,
+    fingerprint     VARCHAR(64),             -- a single finding
+    rule_id         VARCHAR(256),            -- or every finding of a rule
+    path_pattern    VARCHAR(512),            -- in files matching this glob or prefix
+    reason          TEXT NOT NULL,
+    created_by      UUID,
+    expires_at      TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_suppressions_config ON scan_suppressions(scan_config_id);
+
+CREATE TABLE IF NOT EXISTS scan_findings (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scan_run_id     UUID NOT NULL REFERENCES scan_runs(id) ON DELETE CASCADE,
+    scan_config_id  UUID NOT NULL REFERENCES scan_configs(id) ON DELETE CASCADE,
+    tool            VARCHAR(32) NOT NULL,
+    rule_id         VARCHAR(256) NOT NULL,
+    severity        VARCHAR(16) NOT NULL,    -- critical/high/medium/low/info
+    category        VARCHAR(32) NOT NULL,    -- bug/vulnerability/code_smell/secret
+    file            VARCHAR(512),
+    line            INTEGER NOT NULL DEFAULT 0,
+    message         TEXT,
+    fingerprint     VARCHAR(64) NOT NULL,
+    status          VARCHAR(16) NOT NULL DEFAULT 'open',  -- open/suppressed
+    suppression_id  UUID REFERENCES scan_suppressions(id) ON DELETE SET NULL,
+    baseline        BOOLEAN NOT NULL DEFAULT FALSE,       -- also found in the baseline run
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_findings_run ON scan_findings(scan_run_id, severity);
+CREATE INDEX IF NOT EXISTS idx_scan_findings_fingerprint ON scan_findings(scan_config_id, fingerprint);
@@ -0,0 +1,2 @@
+-- Roll back quality gate evaluations
+DROP TABLE IF EXISTS quality_gate_results CASCADE;
@@ -0,0 +1,16 @@
+-- Quality gate evaluations, one per project and commit
+CREATE TABLE IF NOT EXISTS quality_gate_results (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id      UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    commit_sha      VARCHAR(64) NOT NULL,
+    branch          VARCHAR(128),
+    status          VARCHAR(16) NOT NULL,    -- passed/failed
+    conditions      JSONB NOT NULL DEFAULT '[]',
+    scan_run_ids    JSONB NOT NULL DEFAULT '[]',
+    test_run_ids    JSONB NOT NULL DEFAULT '[]',
+    evaluated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(project_id, commit_sha)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quality_gate_results_project ON quality_gate_results(project_id, evaluated_at DESC);
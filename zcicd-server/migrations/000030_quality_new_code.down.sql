@@ -0,0 +1,13 @@
+-- Roll back new-code quality gate conditions
+DROP TABLE IF EXISTS quality_pull_requests CASCADE;
+DROP INDEX IF EXISTS idx_scan_runs_branch;
+DROP INDEX IF EXISTS idx_quality_gate_results_branch;
+ALTER TABLE quality_gate_results DROP COLUMN IF EXISTS baseline_commit_sha;
+ALTER TABLE quality_gate_results DROP COLUMN IF EXISTS baseline_branch;
+ALTER TABLE quality_gate_results DROP COLUMN IF EXISTS pull_request;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS new_coverage;
+ALTER TABLE scan_runs DROP COLUMN IF EXISTS pull_request;
+ALTER TABLE quality_gates DROP COLUMN IF EXISTS max_new_code_smells;
+ALTER TABLE quality_gates DROP COLUMN IF EXISTS max_new_vulnerabilities;
+ALTER TABLE quality_gates DROP COLUMN IF EXISTS min_new_coverage;
+ALTER TABLE quality_gates DROP COLUMN IF EXISTS baseline_branch;
@@ -0,0 +1,33 @@
+-- New-code quality gate conditions against a baseline branch, and the branch
+-- and pull request context of scan runs and gate results
+ALTER TABLE quality_gates ADD COLUMN IF NOT EXISTS baseline_branch VARCHAR(128);
+ALTER TABLE quality_gates ADD COLUMN IF NOT EXISTS min_new_coverage NUMERIC(5,2);
+ALTER TABLE quality_gates ADD COLUMN IF NOT EXISTS max_new_vulnerabilities INTEGER;
+ALTER TABLE quality_gates ADD COLUMN IF NOT EXISTS max_new_code_smells INTEGER;
+
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS pull_request INTEGER;
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS new_coverage NUMERIC(5,2);
+
+ALTER TABLE quality_gate_results ADD COLUMN IF NOT EXISTS pull_request INTEGER;
+ALTER TABLE quality_gate_results ADD COLUMN IF NOT EXISTS baseline_branch VARCHAR(128);
+ALTER TABLE quality_gate_results ADD COLUMN IF NOT EXISTS baseline_commit_sha VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_quality_gate_results_branch ON quality_gate_results(project_id, branch, evaluated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_scan_runs_branch ON scan_runs(scan_config_id, branch, created_at DESC);
+
+-- Pull requests seen by the SCM webhooks, to compare their commits with their target branch
+CREATE TABLE IF NOT EXISTS quality_pull_requests (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    repo_url        VARCHAR(512) NOT NULL,
+    number          INTEGER NOT NULL,
+    title           VARCHAR(512),
+    branch          VARCHAR(128) NOT NULL,
+    base_branch     VARCHAR(128),
+    commit_sha      VARCHAR(64),
+    state           VARCHAR(16) NOT NULL DEFAULT 'open',  -- open/closed/merged
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(repo_url, number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quality_pull_requests_branch ON quality_pull_requests(branch, state);
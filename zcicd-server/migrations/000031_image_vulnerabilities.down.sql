@@ -0,0 +1,6 @@
+-- Roll back per-CVE image scan results
+DROP TABLE IF EXISTS image_vulnerabilities CASCADE;
+ALTER TABLE image_scans DROP COLUMN IF EXISTS started_at;
+ALTER TABLE image_scans DROP COLUMN IF EXISTS error_message;
+ALTER TABLE image_scans DROP COLUMN IF EXISTS unknown;
+ALTER TABLE image_scans DROP COLUMN IF EXISTS digest;
@@ -0,0 +1,23 @@
+-- Per-CVE results of Trivy image scans
+ALTER TABLE image_scans ADD COLUMN IF NOT EXISTS digest VARCHAR(128);
+ALTER TABLE image_scans ADD COLUMN IF NOT EXISTS unknown INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE image_scans ADD COLUMN IF NOT EXISTS error_message TEXT;
+ALTER TABLE image_scans ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS image_vulnerabilities (
+    id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scan_id             UUID NOT NULL REFERENCES image_scans(id) ON DELETE CASCADE,
+    vulnerability_id    VARCHAR(64) NOT NULL,    -- CVE-2024-1234, GHSA-...
+    pkg_name            VARCHAR(256) NOT NULL,
+    pkg_type            VARCHAR(32),             -- alpine/debian/gomod/npm/...
+    target              VARCHAR(512),            -- the OS or lock file the package was found in
+    installed_version   VARCHAR(128),
+    fixed_version       VARCHAR(256),
+    severity            VARCHAR(16) NOT NULL,    -- CRITICAL/HIGH/MEDIUM/LOW/UNKNOWN
+    title               TEXT,
+    primary_url         VARCHAR(512),
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_vulnerabilities_scan ON image_vulnerabilities(scan_id, severity);
+CREATE INDEX IF NOT EXISTS idx_image_vulnerabilities_cve ON image_vulnerabilities(vulnerability_id);
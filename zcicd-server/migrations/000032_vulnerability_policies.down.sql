@@ -0,0 +1,4 @@
+-- Roll back vulnerability policies and the CVE allowlist
+DROP TABLE IF EXISTS image_policy_results CASCADE;
+DROP TABLE IF EXISTS cve_allowlist CASCADE;
+DROP TABLE IF EXISTS vulnerability_policies CASCADE;
@@ -0,0 +1,47 @@
+-- Vulnerability policies and CVE allowlist enforced on image deploys
+CREATE TABLE IF NOT EXISTS vulnerability_policies (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id      UUID REFERENCES projects(id) ON DELETE CASCADE,  -- NULL: default of all projects
+    env_type        VARCHAR(32) NOT NULL,                  -- dev/testing/staging/production/preview
+    max_severity    VARCHAR(16) NOT NULL DEFAULT 'HIGH',   -- highest severity allowed: NONE/LOW/MEDIUM/HIGH/CRITICAL
+    fixable_only    BOOLEAN NOT NULL DEFAULT FALSE,        -- only vulnerabilities with a fixed version violate it
+    block_deploy    BOOLEAN NOT NULL DEFAULT TRUE,
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_vulnerability_policies_scope
+    ON vulnerability_policies(COALESCE(project_id, '00000000-0000-0000-0000-000000000000'::uuid), env_type);
+
+-- Production refuses critical vulnerabilities unless a project says otherwise
+INSERT INTO vulnerability_policies (env_type, max_severity) VALUES ('production', 'HIGH') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS cve_allowlist (
+    id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    vulnerability_id    VARCHAR(64) NOT NULL,
+    pkg_name            VARCHAR(256),              -- empty: any package
+    image_name          VARCHAR(512),              -- empty: any image
+    justification       TEXT NOT NULL,
+    expires_at          TIMESTAMPTZ NOT NULL,
+    created_by          UUID,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cve_allowlist_cve ON cve_allowlist(vulnerability_id, expires_at);
+
+CREATE TABLE IF NOT EXISTS image_policy_results (
+    id                      UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scan_id                 UUID NOT NULL REFERENCES image_scans(id) ON DELETE CASCADE,
+    policy_id               UUID NOT NULL REFERENCES vulnerability_policies(id) ON DELETE CASCADE,
+    status                  VARCHAR(16) NOT NULL,              -- passed/failed
+    violations              JSONB NOT NULL DEFAULT '[]',
+    allowed                 JSONB NOT NULL DEFAULT '[]',       -- violations waived by the allowlist
+    allowlist_expires_at    TIMESTAMPTZ,                       -- earliest expiry of the entries relied on
+    evaluated_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(scan_id, policy_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_policy_results_policy ON image_policy_results(policy_id, status);
+CREATE INDEX IF NOT EXISTS idx_image_policy_results_expiry ON image_policy_results(allowlist_expires_at)
+    WHERE allowlist_expires_at IS NOT NULL;
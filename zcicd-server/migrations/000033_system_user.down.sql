@@ -0,0 +1,3 @@
+-- Roll back the system user
+UPDATE deploy_histories SET triggered_by = NULL WHERE triggered_by = '10000000-0000-0000-0000-000000000002';
+DELETE FROM users WHERE id = '10000000-0000-0000-0000-000000000002';
@@ -0,0 +1,15 @@
+-- System user that triggers automated deploys such as preview syncs, so
+-- deploy_histories.triggered_by always references a real user. It has no
+-- password and is disabled, so it can never log in.
+INSERT INTO users (id, username, email, password_hash, display_name, status, created_at, updated_at)
+VALUES (
+    '10000000-0000-0000-0000-000000000002',
+    'zcicd-system',
+    'system@zcicd.local',
+    NULL,
+    'ZCI/CD System',
+    'disabled',
+    NOW(),
+    NOW()
+)
+ON CONFLICT (id) DO NOTHING;
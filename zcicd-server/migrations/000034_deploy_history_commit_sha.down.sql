@@ -0,0 +1,13 @@
+-- Roll back the deploy history commit SHA
+CREATE OR REPLACE FUNCTION deploy_histories_snapshot_immutable() RETURNS trigger AS $$
+BEGIN
+    IF (OLD.values_snapshot IS NOT NULL AND NEW.values_snapshot IS DISTINCT FROM OLD.values_snapshot)
+       OR (COALESCE(OLD.image_digest, '') <> '' AND NEW.image_digest IS DISTINCT FROM OLD.image_digest)
+       OR (COALESCE(OLD.chart_version, '') <> '' AND NEW.chart_version IS DISTINCT FROM OLD.chart_version) THEN
+        RAISE EXCEPTION 'deploy history % snapshot is immutable', OLD.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE deploy_histories DROP COLUMN IF EXISTS commit_sha;
@@ -0,0 +1,16 @@
+-- Commit SHA a deploy synced, recorded apart from the chart version and
+-- restored by rollbacks
+ALTER TABLE deploy_histories ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(64);
+
+-- Like the rest of the snapshot, the commit SHA cannot change once written
+CREATE OR REPLACE FUNCTION deploy_histories_snapshot_immutable() RETURNS trigger AS $$
+BEGIN
+    IF (OLD.values_snapshot IS NOT NULL AND NEW.values_snapshot IS DISTINCT FROM OLD.values_snapshot)
+       OR (COALESCE(OLD.image_digest, '') <> '' AND NEW.image_digest IS DISTINCT FROM OLD.image_digest)
+       OR (COALESCE(OLD.chart_version, '') <> '' AND NEW.chart_version IS DISTINCT FROM OLD.chart_version)
+       OR (COALESCE(OLD.commit_sha, '') <> '' AND NEW.commit_sha IS DISTINCT FROM OLD.commit_sha) THEN
+        RAISE EXCEPTION 'deploy history % snapshot is immutable', OLD.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+-- Roll back the failed tests condition of quality gates
+ALTER TABLE quality_gates DROP COLUMN IF EXISTS max_failed_tests;
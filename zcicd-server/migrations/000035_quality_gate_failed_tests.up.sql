@@ -0,0 +1,2 @@
+-- Failed tests condition of quality gates; quarantined failures do not count
+ALTER TABLE quality_gates ADD COLUMN IF NOT EXISTS max_failed_tests INTEGER DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Roll back to one gate result per commit, keeping the latest
+DROP INDEX IF EXISTS uq_quality_gate_results_context;
+DELETE FROM quality_gate_results a USING quality_gate_results b
+    WHERE a.project_id = b.project_id AND a.commit_sha = b.commit_sha
+      AND (a.evaluated_at, a.id) < (b.evaluated_at, b.id);
+ALTER TABLE quality_gate_results ADD CONSTRAINT quality_gate_results_project_id_commit_sha_key UNIQUE (project_id, commit_sha);
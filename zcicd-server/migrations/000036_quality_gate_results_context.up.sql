@@ -0,0 +1,5 @@
+-- One gate result per commit and branch or pull request, so the result of a
+-- pull request and of its head branch no longer overwrite each other
+ALTER TABLE quality_gate_results DROP CONSTRAINT IF EXISTS quality_gate_results_project_id_commit_sha_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_quality_gate_results_context
+    ON quality_gate_results(project_id, commit_sha, (COALESCE(branch, '')), (COALESCE(pull_request, 0)));
@@ -71,6 +71,21 @@ var (
 	ErrDeployInProgress      = New(40704, "部署正在进行中")
 	ErrDeploySyncFailed      = New(40705, "部署同步失败")
 	ErrRollbackFailed        = New(40706, "回滚失败")
+	ErrDeployFrozen          = New(40707, "部署冻结期内禁止部署")
+	ErrFreezeOverrideInvalid = New(40708, "紧急放行必须填写理由")
+	ErrFreezeWindowNotFound  = New(40709, "冻结窗口不存在")
+	ErrFreezeWindowInvalid   = New(40710, "冻结窗口配置无效")
+	ErrEnvLocked             = New(40711, "环境已被锁定")
+	ErrEnvLockNotFound       = New(40712, "环境锁不存在")
+	ErrDriftNotFound         = New(40713, "配置漂移不存在")
+	ErrDriftResolved         = New(40714, "配置漂移已处理")
+	ErrRollbackTargetInvalid = New(40715, "只能回滚到部署成功的记录")
+	ErrQualityGateFailed     = New(40716, "质量门禁未通过，禁止部署")
+	ErrVulnPolicyFailed      = New(40717, "镜像存在未豁免的漏洞，禁止部署")
+	ErrStrategyUnsupported   = New(40718, "Kustomize 部署不支持金丝雀和蓝绿发布策略")
+	ErrGitWriteNotConfigured = New(40719, "GitOps 仓库写入尚未配置")
+	ErrPreviewTemplateUnsupported = New(40720, "原始清单部署无法替换镜像，不能作为预览模板")
+	ErrSystemNamespace = New(40721, "环境不能使用系统命名空间")
 
 	// Approval errors: 408xx
 	ErrApprovalNotFound       = New(40801, "审批记录不存在")
@@ -81,4 +96,34 @@ var (
 	// Environment errors (additional): 404xx
 	ErrEnvVariableNotFound = New(40403, "环境变量不存在")
 	ErrEnvQuotaExceeded    = New(40404, "环境资源配额超限")
+
+	// Quality errors: 410xx
+	ErrTestConfigNotFound  = New(41001, "测试配置不存在")
+	ErrTestConfigDisabled  = New(41002, "测试配置已禁用")
+	ErrTestRunNotFound     = New(41003, "测试运行不存在")
+	ErrTestReportNotFound  = New(41004, "测试报告不存在")
+	ErrTestQuarantined     = New(41005, "测试已隔离")
+	ErrQuarantineNotFound  = New(41006, "测试隔离记录不存在")
+	ErrScanConfigNotFound  = New(41011, "扫描配置不存在")
+	ErrScanConfigDisabled  = New(41012, "扫描配置已禁用")
+	ErrScanIntegration     = New(41013, "未配置可用的 SonarQube 集成")
+	ErrScanTypeUnsupported = New(41014, "不支持的扫描类型")
+	ErrSuppressionInvalid  = New(41015, "忽略规则需指定 fingerprint 或 rule_id")
+	ErrSuppressionNotFound = New(41016, "忽略规则不存在")
+	ErrScanBaselineInvalid = New(41017, "基线必须是该扫描配置已完成的运行")
+	ErrGateResultNotFound  = New(41021, "质量门禁评估结果不存在")
+
+	// Artifact errors: 411xx
+	ErrRegistryNotFound     = New(41101, "镜像仓库不存在")
+	ErrImageScanNotFound    = New(41102, "镜像扫描不存在")
+	ErrImageScanUnavailable = New(41103, "镜像扫描器不可用")
+	ErrImageScanNoReport    = New(41104, "镜像扫描报告不存在")
+	ErrImageRescanRunning   = New(41105, "镜像重新扫描正在进行中")
+	ErrRegistryUnsupported  = New(41106, "该镜像仓库类型不支持此操作")
+	ErrRegistryRequest      = New(41107, "镜像仓库请求失败")
+	ErrImageRepoNotFound    = New(41108, "镜像不存在")
+	ErrVulnPolicyNotFound   = New(41111, "漏洞策略不存在")
+	ErrVulnPolicyExists     = New(41112, "该环境类型的漏洞策略已存在")
+	ErrAllowlistNotFound    = New(41113, "漏洞豁免不存在")
+	ErrAllowlistInvalid     = New(41114, "漏洞豁免的过期时间必须晚于当前时间")
 )
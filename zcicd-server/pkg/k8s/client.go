@@ -9,6 +9,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -136,6 +137,36 @@ func (c *K8sClient) ApplyYAML(ctx context.Context, namespace string, yaml []byte
 	return nil
 }
 
+// DeleteYAML deletes every object of a YAML manifest. Objects that are
+// already gone are skipped.
+func (c *K8sClient) DeleteYAML(ctx context.Context, namespace string, yaml []byte) error {
+	decoder := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(yaml), 4096)
+
+	for {
+		var obj unstructured.Unstructured
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to decode YAML: %w", err)
+		}
+		if obj.GetKind() == "" {
+			continue
+		}
+
+		ns := obj.GetNamespace()
+		if namespace != "" {
+			ns = namespace
+		}
+		err := c.DeleteResource(ctx, ns, obj.GetAPIVersion(), obj.GetKind(), obj.GetName())
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
 // DeleteResource deletes a single resource by apiVersion, kind, and name.
 func (c *K8sClient) DeleteResource(ctx context.Context, namespace, apiVersion, kind, name string) error {
 	gv, err := schema.ParseGroupVersion(apiVersion)
@@ -156,6 +187,26 @@ func (c *K8sClient) DeleteResource(ctx context.Context, namespace, apiVersion, k
 	return c.DynamicClient.Resource(gvr).Delete(ctx, name, metav1.DeleteOptions{})
 }
 
+// GetResource reads a single resource by apiVersion, kind, and name.
+func (c *K8sClient) GetResource(ctx context.Context, namespace, apiVersion, kind, name string) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiVersion %s: %w", apiVersion, err)
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    gv.Group,
+		Version:  gv.Version,
+		Resource: pluralizeKind(kind),
+	}
+
+	if namespace != "" {
+		return c.DynamicClient.Resource(gvr).Namespace(namespace).
+			Get(ctx, name, metav1.GetOptions{})
+	}
+	return c.DynamicClient.Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+}
+
 // GetLogs returns the logs for a specific pod container.
 func (c *K8sClient) GetLogs(ctx context.Context, namespace, podName, container string, tailLines int64) (string, error) {
 	opts := &corev1.PodLogOptions{
@@ -68,19 +68,31 @@ func OptionalJWTAuth(secret string) gin.HandlerFunc {
 
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roles, exists := c.Get("roles")
-		if !exists {
+		if _, exists := c.Get("roles"); !exists {
 			response.Forbidden(c, "no roles found")
 			c.Abort()
 			return
 		}
-		for _, role := range roles.([]string) {
-			if role == "admin" {
-				c.Next()
-				return
-			}
+		if HasRole(c, "admin") {
+			c.Next()
+			return
 		}
 		response.Error(c, http.StatusForbidden, 40300, "admin role required")
 		c.Abort()
 	}
 }
+
+// HasRole reports whether the authenticated user carries the given role.
+func HasRole(c *gin.Context, role string) bool {
+	roles, exists := c.Get("roles")
+	if !exists {
+		return false
+	}
+	list, _ := roles.([]string)
+	for _, r := range list {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -9,13 +9,24 @@ const (
 	SubjectDeploySucceeded   = "zcicd.deploy.succeeded"
 	SubjectDeployFailed      = "zcicd.deploy.failed"
 	SubjectDeployRollback    = "zcicd.deploy.rollback"
+	SubjectDeployDrift       = "zcicd.deploy.drift"
 	SubjectWorkflowStarted   = "zcicd.workflow.started"
 	SubjectWorkflowApproval  = "zcicd.workflow.approval"
 	SubjectWorkflowCompleted = "zcicd.workflow.completed"
 	SubjectTestCompleted     = "zcicd.test.completed"
 	SubjectScanCompleted     = "zcicd.scan.completed"
+	SubjectQualityGate       = "zcicd.quality.gate"
 	SubjectGitOpsUpdate      = "zcicd.gitops.update"
 	SubjectAuditLog          = "zcicd.audit.log"
+	SubjectEnvCreated        = "zcicd.env.created"
+	SubjectEnvUpdated        = "zcicd.env.updated"
+	SubjectEnvDeleted        = "zcicd.env.deleted"
+	SubjectPullRequest       = "zcicd.scm.pull_request"
+	SubjectPreviewReady      = "zcicd.preview.ready"
+	SubjectPreviewClosed     = "zcicd.preview.closed"
+
+	// SubjectEnvAll matches every environment lifecycle event.
+	SubjectEnvAll = "zcicd.env.*"
 )
 
 // Event is the standard event envelope
@@ -0,0 +1,117 @@
+package strategy
+
+// RolloutSpec renders the Argo Rollout spec.strategy for a blue-green or canary
+// strategy, or nil for a rolling update.
+func (s *Strategy) RolloutSpec(name string) map[string]interface{} {
+	switch s.Type {
+	case TypeCanary:
+		c := s.Canary
+		steps := []interface{}{}
+		for _, step := range c.CanarySteps() {
+			switch {
+			case step.SetWeight != nil:
+				steps = append(steps, map[string]interface{}{"setWeight": *step.SetWeight})
+			case step.Pause != nil:
+				pause := map[string]interface{}{}
+				if step.Pause.Duration != "" {
+					pause["duration"] = step.Pause.Duration
+				}
+				steps = append(steps, map[string]interface{}{"pause": pause})
+			}
+		}
+		canary := map[string]interface{}{"steps": steps}
+		if c.MaxSurge != "" {
+			canary["maxSurge"] = c.MaxSurge
+		}
+		if c.MaxUnavailable != "" {
+			canary["maxUnavailable"] = c.MaxUnavailable
+		}
+		return map[string]interface{}{"canary": canary}
+	case TypeBlueGreen:
+		b := s.BlueGreen
+		if b == nil {
+			b = &BlueGreenConfig{}
+		}
+		active, preview := b.ActiveService, b.PreviewService
+		if active == "" {
+			active = name
+		}
+		if preview == "" {
+			preview = name + "-preview"
+		}
+		bg := map[string]interface{}{
+			"activeService":        active,
+			"previewService":       preview,
+			"autoPromotionEnabled": b.AutoPromotionEnabled,
+		}
+		if b.AutoPromotionSeconds > 0 {
+			bg["autoPromotionSeconds"] = b.AutoPromotionSeconds
+		}
+		if b.PreviewReplicaCount > 0 {
+			bg["previewReplicaCount"] = b.PreviewReplicaCount
+		}
+		if b.ScaleDownDelaySeconds > 0 {
+			bg["scaleDownDelaySeconds"] = b.ScaleDownDelaySeconds
+		}
+		return map[string]interface{}{"blueGreen": bg}
+	}
+	return nil
+}
+
+// HelmValues renders the strategy as chart values. Charts switch between a
+// Deployment and a Rollout on rollout.enabled and copy rollout.strategy (or, for
+// rolling updates, strategy) into the workload spec.
+func (s *Strategy) HelmValues(name string) map[string]interface{} {
+	if s.UsesRollout() {
+		return map[string]interface{}{
+			"rollout": map[string]interface{}{
+				"enabled":  true,
+				"strategy": s.RolloutSpec(name),
+			},
+		}
+	}
+	values := map[string]interface{}{
+		"rollout": map[string]interface{}{"enabled": false},
+	}
+	if s.Rolling != nil && (s.Rolling.MaxSurge != "" || s.Rolling.MaxUnavailable != "") {
+		ru := map[string]interface{}{}
+		if s.Rolling.MaxSurge != "" {
+			ru["maxSurge"] = s.Rolling.MaxSurge
+		}
+		if s.Rolling.MaxUnavailable != "" {
+			ru["maxUnavailable"] = s.Rolling.MaxUnavailable
+		}
+		values["strategy"] = map[string]interface{}{"type": "RollingUpdate", "rollingUpdate": ru}
+	}
+	return values
+}
+
+// RolloutManifest renders an Argo Rollout called name that takes over the
+// Deployment called deployment via workloadRef, so plain manifests need no
+// Rollout-specific pod spec. It returns nil for rolling updates.
+func (s *Strategy) RolloutManifest(name, deployment, namespace string) map[string]interface{} {
+	if !s.UsesRollout() {
+		return nil
+	}
+	metadata := map[string]interface{}{
+		"name":   name,
+		"labels": map[string]interface{}{"app.kubernetes.io/managed-by": "zcicd"},
+	}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"workloadRef": map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"name":       deployment,
+				"scaleDown":  "onsuccess",
+			},
+			"strategy": s.RolloutSpec(name),
+		},
+	}
+}
@@ -0,0 +1,119 @@
+// Package strategy is the typed model of Environment.DeployStrategy. The project
+// service validates it on save; the deploy service renders it into the Helm
+// values or Argo Rollout manifests of the workloads it deploys.
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	TypeRolling   = "rolling"
+	TypeBlueGreen = "bluegreen"
+	TypeCanary    = "canary"
+)
+
+// Strategy describes how new revisions replace old ones in an environment.
+type Strategy struct {
+	Type      string           `json:"type"` // rolling/bluegreen/canary
+	Rolling   *RollingConfig   `json:"rolling,omitempty"`
+	BlueGreen *BlueGreenConfig `json:"bluegreen,omitempty"`
+	Canary    *CanaryConfig    `json:"canary,omitempty"`
+}
+
+// RollingConfig for plain Deployment rolling updates.
+type RollingConfig struct {
+	MaxSurge       string `json:"max_surge,omitempty"`       // e.g. "25%" or "1"
+	MaxUnavailable string `json:"max_unavailable,omitempty"` // e.g. "0"
+}
+
+// BlueGreenConfig for blue-green rollouts.
+type BlueGreenConfig struct {
+	ActiveService         string `json:"active_service,omitempty"`  // default: <name>
+	PreviewService        string `json:"preview_service,omitempty"` // default: <name>-preview
+	AutoPromotionEnabled  bool   `json:"auto_promotion_enabled"`
+	AutoPromotionSeconds  int    `json:"auto_promotion_seconds,omitempty"`
+	PreviewReplicaCount   int    `json:"preview_replica_count,omitempty"`
+	ScaleDownDelaySeconds int    `json:"scale_down_delay_seconds,omitempty"`
+}
+
+// CanaryConfig for canary rollouts. Steps may be given explicitly or as a
+// weight shorthand ("10/30/60/100" is Weights [10, 30, 60, 100]).
+type CanaryConfig struct {
+	Steps          []CanaryStep  `json:"steps,omitempty"`
+	Weights        []int         `json:"weights,omitempty"`
+	StepPause      string        `json:"step_pause,omitempty"` // pause after each weight; empty waits for promotion
+	MaxSurge       string        `json:"max_surge,omitempty"`
+	MaxUnavailable string        `json:"max_unavailable,omitempty"`
+	Analysis       *AnalysisSpec `json:"analysis,omitempty"`
+}
+
+// CanaryStep is a single canary step: shift traffic, or pause.
+type CanaryStep struct {
+	SetWeight *int   `json:"setWeight,omitempty"`
+	Pause     *Pause `json:"pause,omitempty"`
+}
+
+// Pause duration for canary steps. An empty duration pauses until promoted,
+// either manually or by canary analysis.
+type Pause struct {
+	Duration string `json:"duration,omitempty"` // e.g. "5m"
+}
+
+// AnalysisSpec configures automated metric analysis of canary steps. Queries run
+// against a Prometheus-compatible Integration; {{namespace}}, {{pod_hash}} and
+// {{interval}} are substituted before each query.
+type AnalysisSpec struct {
+	IntegrationID         string  `json:"integration_id"`
+	Interval              string  `json:"interval,omitempty"`                 // query window and poll interval, default "1m"
+	MaxErrorRate          float64 `json:"max_error_rate,omitempty"`           // canary 5xx ratio, e.g. 0.01
+	MaxErrorRateIncrease  float64 `json:"max_error_rate_increase,omitempty"`  // canary minus stable
+	MaxLatencyMs          float64 `json:"max_latency_ms,omitempty"`           // canary p99
+	MaxLatencyIncreasePct float64 `json:"max_latency_increase_pct,omitempty"` // canary over stable, percent
+	MaxInconclusive       int     `json:"max_inconclusive,omitempty"`         // retries without data before aborting, default 3
+	ErrorRateQuery        string  `json:"error_rate_query,omitempty"`
+	LatencyQuery          string  `json:"latency_query,omitempty"`
+}
+
+// Parse decodes and validates a deploy strategy. Empty input is a default
+// rolling update.
+func Parse(raw []byte) (*Strategy, error) {
+	s := &Strategy{Type: TypeRolling}
+	if len(raw) == 0 || string(raw) == "null" || string(raw) == "{}" {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("invalid deploy strategy: %w", err)
+	}
+	if s.Type == "" {
+		s.Type = TypeRolling
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UsesRollout reports whether the strategy needs an Argo Rollout rather than a Deployment.
+func (s *Strategy) UsesRollout() bool {
+	return s.Type == TypeBlueGreen || s.Type == TypeCanary
+}
+
+// CanarySteps returns the explicit steps, or the steps expanded from Weights:
+// each weight below 100 is followed by a pause, so the rollout stops for
+// promotion (or analysis) before shifting more traffic.
+func (c *CanaryConfig) CanarySteps() []CanaryStep {
+	if len(c.Steps) > 0 {
+		return c.Steps
+	}
+	steps := make([]CanaryStep, 0, len(c.Weights)*2)
+	for _, w := range c.Weights {
+		weight := w
+		steps = append(steps, CanaryStep{SetWeight: &weight})
+		if w < 100 {
+			steps = append(steps, CanaryStep{Pause: &Pause{Duration: c.StepPause}})
+		}
+	}
+	return steps
+}
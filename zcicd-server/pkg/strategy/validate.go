@@ -0,0 +1,122 @@
+package strategy
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var intOrPercent = regexp.MustCompile(`^(\d+|\d+%)$`)
+
+// Validate checks the strategy is one zcicd can render and drive.
+func (s *Strategy) Validate() error {
+	switch s.Type {
+	case TypeRolling:
+		if s.Rolling != nil {
+			return validateSurge(s.Rolling.MaxSurge, s.Rolling.MaxUnavailable)
+		}
+		return nil
+	case TypeBlueGreen:
+		if s.BlueGreen == nil {
+			return nil
+		}
+		b := s.BlueGreen
+		if b.AutoPromotionSeconds < 0 || b.PreviewReplicaCount < 0 || b.ScaleDownDelaySeconds < 0 {
+			return fmt.Errorf("bluegreen: counts and delays must not be negative")
+		}
+		if b.ActiveService != "" && b.ActiveService == b.PreviewService {
+			return fmt.Errorf("bluegreen: active and preview service must differ")
+		}
+		return nil
+	case TypeCanary:
+		if s.Canary == nil {
+			return fmt.Errorf("canary: steps or weights are required")
+		}
+		return s.Canary.validate()
+	default:
+		return fmt.Errorf("unknown strategy type %q, want rolling, bluegreen or canary", s.Type)
+	}
+}
+
+func (c *CanaryConfig) validate() error {
+	if len(c.Steps) > 0 && len(c.Weights) > 0 {
+		return fmt.Errorf("canary: use either steps or weights, not both")
+	}
+	if len(c.Steps) == 0 && len(c.Weights) == 0 {
+		return fmt.Errorf("canary: steps or weights are required")
+	}
+	if err := validateSurge(c.MaxSurge, c.MaxUnavailable); err != nil {
+		return err
+	}
+	if c.StepPause != "" {
+		if err := validateDuration(c.StepPause); err != nil {
+			return fmt.Errorf("canary: step_pause: %w", err)
+		}
+	}
+
+	prev := 0
+	for i, w := range c.Weights {
+		if w <= prev || w > 100 {
+			return fmt.Errorf("canary: weights must increase within 1..100, got %d at position %d", w, i+1)
+		}
+		prev = w
+	}
+	for i, step := range c.Steps {
+		switch {
+		case step.SetWeight != nil && step.Pause != nil:
+			return fmt.Errorf("canary: step %d sets both setWeight and pause", i+1)
+		case step.SetWeight != nil:
+			if *step.SetWeight < 0 || *step.SetWeight > 100 {
+				return fmt.Errorf("canary: step %d weight %d out of range 0..100", i+1, *step.SetWeight)
+			}
+		case step.Pause != nil:
+			if step.Pause.Duration != "" {
+				if err := validateDuration(step.Pause.Duration); err != nil {
+					return fmt.Errorf("canary: step %d: %w", i+1, err)
+				}
+			}
+		default:
+			return fmt.Errorf("canary: step %d is empty", i+1)
+		}
+	}
+
+	if a := c.Analysis; a != nil {
+		if a.IntegrationID == "" {
+			return fmt.Errorf("canary analysis: integration_id is required")
+		}
+		if a.Interval != "" {
+			if err := validateDuration(a.Interval); err != nil {
+				return fmt.Errorf("canary analysis: interval: %w", err)
+			}
+		}
+		if a.MaxErrorRate < 0 || a.MaxErrorRateIncrease < 0 || a.MaxLatencyMs < 0 ||
+			a.MaxLatencyIncreasePct < 0 || a.MaxInconclusive < 0 {
+			return fmt.Errorf("canary analysis: thresholds must not be negative")
+		}
+		if a.MaxErrorRate == 0 && a.MaxErrorRateIncrease == 0 && a.MaxLatencyMs == 0 && a.MaxLatencyIncreasePct == 0 {
+			return fmt.Errorf("canary analysis: at least one threshold is required")
+		}
+	}
+	return nil
+}
+
+func validateSurge(maxSurge, maxUnavailable string) error {
+	if maxSurge != "" && !intOrPercent.MatchString(maxSurge) {
+		return fmt.Errorf("max_surge %q must be a number or percentage", maxSurge)
+	}
+	if maxUnavailable != "" && !intOrPercent.MatchString(maxUnavailable) {
+		return fmt.Errorf("max_unavailable %q must be a number or percentage", maxUnavailable)
+	}
+	return nil
+}
+
+func validateDuration(d string) error {
+	v, err := time.ParseDuration(d)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", d)
+	}
+	if v <= 0 {
+		return fmt.Errorf("duration %q must be positive", d)
+	}
+	return nil
+}
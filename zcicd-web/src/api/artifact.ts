@@ -3,7 +3,7 @@ import request from '@/utils/request'
 export interface ImageRegistry {
   id: string
   name: string
-  type: string
+  registry_type: string
   endpoint: string
   username: string
   is_default: boolean
@@ -11,17 +11,151 @@ export interface ImageRegistry {
   created_at: string
 }
 
+export interface RegistryTestResult {
+  connected: boolean
+  message: string
+  latency_ms: number
+}
+
+export interface HarborProject {
+  project_id: number
+  name: string
+  public: boolean
+  repo_count: number
+  creation_time: string
+}
+
+export interface HarborScanOverview {
+  status: string
+  severity: string
+  total: number
+  fixable: number
+  critical: number
+  high: number
+  medium: number
+  low: number
+  unknown: number
+  scanner?: string
+  scanned_at: string | null
+}
+
+export interface ImageBuild {
+  id: string
+  build_config_id: string
+  service_id: string
+  run_number: number
+  branch: string
+  commit_sha: string
+  image_tag: string
+  image_digest: string
+  created_at: string
+}
+
+export interface ImageDeployment {
+  deploy_config_id: string
+  deploy_config: string
+  project_id: string
+  environment_id: string
+  environment_name: string
+  env_type: string
+  is_production: boolean
+  deploy_history_id: string
+  image_tag: string
+  image_digest: string
+  deployed_at: string | null
+}
+
+export interface ImageTag {
+  name: string
+  digest: string
+  media_type: string
+  size: number
+  pushed_at: string | null
+  created_at: string | null
+  architectures: string[]
+  labels: Record<string, string>
+  build: ImageBuild | null
+  deployments: ImageDeployment[]
+  error?: string
+}
+
 export interface ImageScan {
   id: string
+  registry_id: string
   image_name: string
-  image_tag: string
+  tag: string
+  digest: string
   status: string
   critical: number
   high: number
   medium: number
   low: number
+  unknown: number
   report_url: string
-  scanned_at: string
+  error_message?: string
+  started_at: string | null
+  scanned_at: string | null
+  created_at: string
+}
+
+export interface ImageVulnerability {
+  id: string
+  scan_id: string
+  vulnerability_id: string
+  pkg_name: string
+  pkg_type: string
+  target: string
+  installed_version: string
+  fixed_version: string
+  severity: 'CRITICAL' | 'HIGH' | 'MEDIUM' | 'LOW' | 'UNKNOWN'
+  title: string
+  primary_url: string
+  created_at: string
+}
+
+export type Severity = 'NONE' | 'LOW' | 'MEDIUM' | 'HIGH' | 'CRITICAL'
+
+export interface VulnerabilityPolicy {
+  id: string
+  project_id: string | null
+  env_type: string
+  max_severity: Severity
+  fixable_only: boolean
+  block_deploy: boolean
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface CVEAllowlistEntry {
+  id: string
+  vulnerability_id: string
+  pkg_name?: string
+  image_name?: string
+  justification: string
+  expires_at: string
+  created_by: string | null
+  created_at: string
+}
+
+export interface PolicyViolation {
+  vulnerability_id: string
+  pkg_name: string
+  severity: string
+  installed_version: string
+  fixed_version?: string
+  allowlist_id?: string
+}
+
+export interface ImagePolicyResult {
+  id: string
+  scan_id: string
+  policy_id: string
+  status: 'passed' | 'failed'
+  violations: PolicyViolation[]
+  allowed: PolicyViolation[]
+  allowlist_expires_at: string | null
+  evaluated_at: string
 }
 
 export interface HelmChart {
@@ -42,8 +176,61 @@ export const artifactApi = {
   updateRegistry: (id: string, data: Partial<ImageRegistry>) =>
     request.put(`/artifacts/registries/${id}`, data),
   deleteRegistry: (id: string) => request.delete(`/artifacts/registries/${id}`),
-  getScanResults: (name: string) => request.get(`/artifacts/images/${name}/scan`),
-  triggerScan: (name: string) => request.post(`/artifacts/images/${name}/scan`),
+  testRegistry: (id: string) => request.post(`/artifacts/registries/${id}/test`),
+  listHarborProjects: (id: string) => request.get(`/artifacts/registries/${id}/projects`),
+  getHarborScanOverview: (id: string, params: { repository: string; reference: string }) =>
+    request.get(`/artifacts/registries/${id}/scan-overview`, { params }),
+  listRegistryRepositories: (id: string, params?: { keyword?: string; page?: number; page_size?: number }) =>
+    request.get(`/artifacts/registries/${id}/repositories`, { params }),
+  listRepositoryTags: (
+    id: string,
+    repository: string,
+    params?: { keyword?: string; page?: number; page_size?: number },
+  ) =>
+    request.get(
+      `/artifacts/registries/${id}/repositories/${repository.split('/').map(encodeURIComponent).join('/')}/tags`,
+      { params },
+    ),
+  getScanResults: (name: string, registryId: string) =>
+    request.get(`/artifacts/images/${name}/scan`, { params: { registry_id: registryId } }),
+  triggerScan: (name: string, data: { registry_id: string; tag: string }) =>
+    request.post(`/artifacts/images/${name}/scan`, data),
+  getScan: (id: string) => request.get(`/artifacts/scans/${id}`),
+  listScanVulnerabilities: (
+    id: string,
+    params?: {
+      page?: number
+      page_size?: number
+      severity?: string
+      pkg_name?: string
+      vulnerability_id?: string
+      fixable?: boolean
+    },
+  ) => request.get(`/artifacts/scans/${id}/vulnerabilities`, { params }),
+  getScanReport: (id: string) => request.get(`/artifacts/scans/${id}/report`),
+  getScanPolicyResults: (id: string) => request.get(`/artifacts/scans/${id}/policy-results`),
+  rescanImages: () => request.post('/artifacts/scans/rescan'),
+  listVulnerabilityPolicies: (params?: { project_id?: string; env_type?: string }) =>
+    request.get('/artifacts/vulnerability-policies', { params }),
+  getVulnerabilityPolicy: (id: string) => request.get(`/artifacts/vulnerability-policies/${id}`),
+  createVulnerabilityPolicy: (
+    data: Pick<VulnerabilityPolicy, 'env_type' | 'max_severity'> &
+      Partial<Pick<VulnerabilityPolicy, 'project_id' | 'fixable_only' | 'block_deploy' | 'enabled'>>,
+  ) => request.post('/artifacts/vulnerability-policies', data),
+  updateVulnerabilityPolicy: (
+    id: string,
+    data: Partial<Pick<VulnerabilityPolicy, 'max_severity' | 'fixable_only' | 'block_deploy' | 'enabled'>>,
+  ) => request.put(`/artifacts/vulnerability-policies/${id}`, data),
+  deleteVulnerabilityPolicy: (id: string) => request.delete(`/artifacts/vulnerability-policies/${id}`),
+  listCVEAllowlist: (params?: { expired?: boolean }) => request.get('/artifacts/cve-allowlist', { params }),
+  createCVEAllowlistEntry: (data: {
+    vulnerability_id: string
+    pkg_name?: string
+    image_name?: string
+    justification: string
+    expires_at: string
+  }) => request.post('/artifacts/cve-allowlist', data),
+  deleteCVEAllowlistEntry: (id: string) => request.delete(`/artifacts/cve-allowlist/${id}`),
   listCharts: (params?: { page?: number; page_size?: number }) =>
     request.get('/artifacts/charts', { params }),
   getChart: (name: string) => request.get(`/artifacts/charts/${name}`),
@@ -13,10 +13,90 @@ export interface DeployConfig {
   auto_sync: boolean
   require_approval: boolean
   values_override: Record<string, unknown>
+  deploy_type?: 'helm' | 'kustomize' | 'manifests' | 'yaml'
+  helm?: HelmOptions | null
+  kustomize?: KustomizeOptions | null
+  lock_policy?: 'queue' | 'reject'
+  preview_template?: boolean
+  preview_domain?: string
+  preview_ttl_hours?: number
   created_at: string
   updated_at: string
 }
 
+export interface HelmParameter {
+  name: string
+  value: string
+  force_string?: boolean
+}
+
+export interface HelmOptions {
+  value_files?: string[]
+  parameters?: HelmParameter[]
+}
+
+export interface KustomizePatch {
+  target?: {
+    group?: string
+    version?: string
+    kind?: string
+    name?: string
+    namespace?: string
+    label_selector?: string
+  }
+  patch: string
+}
+
+export interface KustomizeOptions {
+  images?: string[]
+  name_prefix?: string
+  name_suffix?: string
+  common_labels?: Record<string, string>
+  patches?: KustomizePatch[]
+}
+
+export interface CanaryStep {
+  setWeight?: number
+  pause?: { duration?: string }
+}
+
+export interface DeployStrategy {
+  type: 'rolling' | 'bluegreen' | 'canary'
+  rolling?: { max_surge?: string; max_unavailable?: string }
+  bluegreen?: {
+    active_service?: string
+    preview_service?: string
+    auto_promotion_enabled: boolean
+    auto_promotion_seconds?: number
+    preview_replica_count?: number
+    scale_down_delay_seconds?: number
+  }
+  canary?: {
+    steps?: CanaryStep[]
+    weights?: number[]
+    step_pause?: string
+    max_surge?: string
+    max_unavailable?: string
+    analysis?: {
+      integration_id: string
+      interval?: string
+      max_error_rate?: number
+      max_error_rate_increase?: number
+      max_latency_ms?: number
+      max_latency_increase_pct?: number
+      max_inconclusive?: number
+      error_rate_query?: string
+      latency_query?: string
+    }
+  }
+}
+
+export interface StrategyRender {
+  strategy: DeployStrategy
+  helm_values: Record<string, unknown>
+  rollout_manifest: Record<string, unknown> | null
+}
+
 export interface DeployHistory {
   id: string
   deploy_config_id: string
@@ -30,6 +110,26 @@ export interface DeployHistory {
   started_at: string
   finished_at: string
   created_at: string
+  rollback_from?: string | null
+  gitops_commit?: string
+  values_snapshot?: Record<string, unknown> | null
+  image_digest?: string
+  chart_version?: string
+  analyses?: CanaryAnalysis[]
+}
+
+export interface CanaryAnalysis {
+  id: string
+  deploy_history_id: string
+  step_index: number
+  verdict: 'pass' | 'fail' | 'inconclusive'
+  action: 'promote' | 'abort' | 'retry' | 'none'
+  canary_error_rate: number | null
+  stable_error_rate: number | null
+  canary_latency_ms: number | null
+  stable_latency_ms: number | null
+  reasons: string[]
+  created_at: string
 }
 
 export interface ApprovalRecord {
@@ -51,6 +151,20 @@ export interface EnvVariable {
   created_at: string
 }
 
+export interface QuotaDrift {
+  object: string
+  resource: string
+  desired: string
+  actual: string
+}
+
+export interface NamespaceStatus {
+  namespace: string
+  exists: boolean
+  quota_managed: boolean
+  drift: QuotaDrift[]
+}
+
 export interface EnvQuota {
   id: string
   env_id: string
@@ -59,6 +173,117 @@ export interface EnvQuota {
   pod_limit: number
 }
 
+export interface FreezeOverride {
+  emergency_override?: boolean
+  justification?: string
+}
+
+export interface CheckOverride {
+  override: boolean
+  justification: string
+}
+
+export interface SyncOverrides extends FreezeOverride {
+  quality_gate_override?: CheckOverride
+  vuln_policy_override?: CheckOverride
+}
+
+export interface FreezeWindow {
+  id: string
+  project_id: string
+  environment_id?: string | null
+  name: string
+  reason: string
+  recurrence: string
+  duration_minutes: number
+  start_at?: string | null
+  end_at?: string | null
+  timezone: string
+  enabled: boolean
+  created_by: string
+  created_at: string
+  updated_at: string
+}
+
+export interface FreezeOccurrence {
+  window_id: string
+  name: string
+  reason: string
+  environment_id?: string | null
+  start_at: string
+  end_at: string
+}
+
+export interface EnvLock {
+  id: string
+  project_id: string
+  environment_id: string
+  kind: 'deploy' | 'manual'
+  status: 'held' | 'queued' | 'released' | 'expired' | 'cancelled'
+  deploy_config_id?: string | null
+  deploy_history_id?: string | null
+  holder_id: string
+  reason: string
+  acquired_at?: string | null
+  expires_at: string
+  released_at?: string | null
+  created_at: string
+}
+
+export interface PreviewEnvironment {
+  id: string
+  project_id: string
+  service_id: string
+  template_config_id?: string | null
+  environment_id?: string | null
+  deploy_config_id?: string | null
+  repo_url: string
+  pr_number: number
+  title: string
+  branch: string
+  commit_sha: string
+  image: string
+  namespace: string
+  url: string
+  status: 'pending' | 'deploying' | 'active' | 'failed' | 'closing' | 'closed'
+  close_reason?: 'merged' | 'closed' | 'expired' | 'orphaned' | 'manual' | ''
+  error_message?: string
+  last_activity_at: string
+  expires_at: string
+  closed_at?: string | null
+  created_at: string
+}
+
+export interface DriftedResource {
+  group: string
+  version: string
+  kind: string
+  namespace: string
+  name: string
+  status: string
+  health: string
+  live?: Record<string, unknown>
+  error?: string
+}
+
+export interface DeployDrift {
+  id: string
+  project_id: string
+  deploy_config_id: string
+  environment_id: string
+  app_name: string
+  revision: string
+  resources: DriftedResource[]
+  status: 'open' | 'reconciled' | 'adopted' | 'resolved'
+  resolved_by?: string
+  deploy_history_id?: string | null
+  gitops_commit?: string
+  error_message?: string
+  detected_at: string
+  resolved_at?: string | null
+  created_at: string
+}
+
 export const deployApi = {
   list: (params?: { project_id?: string; env_id?: string; page?: number; page_size?: number }) =>
     request.get('/deploys', { params }),
@@ -68,17 +293,42 @@ export const deployApi = {
   create: (data: Partial<DeployConfig>) => request.post('/deploys', data),
   update: (id: string, data: Partial<DeployConfig>) => request.put(`/deploys/${id}`, data),
   delete: (id: string) => request.delete(`/deploys/${id}`),
-  triggerSync: (id: string) => request.post(`/deploys/${id}/sync`),
-  rollback: (id: string, data?: { history_id?: string }) => request.post(`/deploys/${id}/rollback`, data),
+  triggerSync: (id: string, data?: SyncOverrides) => request.post(`/deploys/${id}/sync`, data),
+  rollback: (id: string, data?: { history_id?: string } & FreezeOverride) =>
+    request.post(`/deploys/${id}/rollback`, data),
   getStatus: (id: string) => request.get(`/deploys/${id}/status`),
   getResources: (id: string) => request.get(`/deploys/${id}/resources`),
   listHistory: (id: string, params?: { page?: number; page_size?: number }) =>
     request.get(`/deploys/${id}/history`, { params }),
   getHistory: (id: string, historyId: string) => request.get(`/deploys/${id}/history/${historyId}`),
   // Rollout (Argo Rollouts)
+  renderStrategy: (id: string) => request.get(`/deploys/${id}/strategy`),
   getRolloutStatus: (id: string) => request.get(`/deploys/${id}/rollout`),
-  promoteRollout: (id: string) => request.post(`/deploys/${id}/rollout/promote`),
+  promoteRollout: (id: string, data?: FreezeOverride) => request.post(`/deploys/${id}/rollout/promote`, data),
   abortRollout: (id: string) => request.post(`/deploys/${id}/rollout/abort`),
+  // Freeze windows
+  listFreezeWindows: (projectId: string) => request.get('/deploy/freeze-windows', { params: { project_id: projectId } }),
+  createFreezeWindow: (data: Partial<FreezeWindow>) => request.post('/deploy/freeze-windows', data),
+  updateFreezeWindow: (id: string, data: Partial<FreezeWindow>) => request.put(`/deploy/freeze-windows/${id}`, data),
+  deleteFreezeWindow: (id: string) => request.delete(`/deploy/freeze-windows/${id}`),
+  upcomingFreezes: (params: { project_id: string; environment_id?: string; days?: number }) =>
+    request.get('/deploy/freeze-windows/upcoming', { params }),
+  // Environment locks
+  listLocks: (params: { project_id?: string; environment_id?: string }) => request.get('/deploy/locks', { params }),
+  lockEnvironment: (data: { project_id: string; environment_id: string; reason: string; ttl_minutes?: number }) =>
+    request.post('/deploy/locks', data),
+  releaseLock: (id: string) => request.delete(`/deploy/locks/${id}`),
+  // Pull request previews
+  listPreviews: (params: { project_id: string; status?: string; page?: number; page_size?: number }) =>
+    request.get('/deploy/previews', { params }),
+  getPreview: (id: string) => request.get(`/deploy/previews/${id}`),
+  closePreview: (id: string) => request.delete(`/deploy/previews/${id}`),
+  // Drift
+  listDrifts: (params: { project_id?: string; deploy_config_id?: string; status?: string; page?: number; page_size?: number }) =>
+    request.get('/deploy/drifts', { params }),
+  getDrift: (id: string) => request.get(`/deploy/drifts/${id}`),
+  reconcileDrift: (id: string, data?: SyncOverrides) => request.post(`/deploy/drifts/${id}/reconcile`, data),
+  adoptDrift: (id: string) => request.post(`/deploy/drifts/${id}/adopt`),
   // Approvals
   listPendingApprovals: () => request.get('/approvals/pending'),
   getApproval: (id: string) => request.get(`/approvals/${id}`),
@@ -92,9 +342,13 @@ export const deployApi = {
     request.put(`/environments/${envId}/variables/${varId}`, data),
   deleteEnvVar: (envId: string, varId: string) =>
     request.delete(`/environments/${envId}/variables/${varId}`),
+  revealEnvVar: (envId: string, varId: string) =>
+    request.post(`/environments/${envId}/variables/${varId}/reveal`),
   batchUpsertVars: (envId: string, data: { variables: Partial<EnvVariable>[] }) =>
     request.put(`/environments/${envId}/variables/batch`, data),
   getEnvQuota: (envId: string) => request.get(`/environments/${envId}/quota`),
   upsertEnvQuota: (envId: string, data: Partial<EnvQuota>) =>
     request.put(`/environments/${envId}/quota`, data),
+  getEnvNamespace: (envId: string) => request.get(`/environments/${envId}/namespace`),
+  syncEnvNamespace: (envId: string) => request.post(`/environments/${envId}/namespace/sync`),
 }
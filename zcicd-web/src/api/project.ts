@@ -34,6 +34,7 @@ export interface Environment {
   namespace: string
   cluster: string
   auto_deploy: boolean
+  deploy_engine?: 'auto' | 'argocd' | 'direct'
   created_at: string
 }
 
@@ -18,26 +18,97 @@ export interface TestConfig {
 export interface TestRun {
   id: string
   test_config_id: string
-  status: string
+  build_run_id: string
+  commit_sha: string
+  status: 'pending' | 'running' | 'passed' | 'failed' | 'error'
   total: number
   passed: number
   failed: number
   skipped: number
-  coverage: number
-  duration_sec: number
+  quarantined: number
+  coverage: number | null
+  duration: number | null
   report_url: string
-  triggered_by: string
+  tekton_ref: string
+  error_message?: string
   started_at: string
   finished_at: string
   created_at: string
 }
 
+export interface TestCaseResult {
+  id: string
+  test_run_id: string
+  test_config_id: string
+  test_key: string
+  suite: string
+  class_name: string
+  name: string
+  status: 'passed' | 'failed' | 'skipped'
+  duration: number
+  message?: string
+  quarantined: boolean
+  created_at: string
+}
+
+export interface TestCaseHistory {
+  test_run_id: string
+  commit_sha: string
+  status: 'passed' | 'failed' | 'skipped'
+  duration: number
+  message?: string
+  created_at: string
+}
+
+export interface FlakyTest {
+  test_key: string
+  suite: string
+  class_name: string
+  name: string
+  runs: number
+  failures: number
+  flips: number
+  flaky_commits: number
+  last_flaky_at: string
+  quarantined: boolean
+}
+
+export interface SlowTest {
+  test_key: string
+  suite: string
+  class_name: string
+  name: string
+  runs: number
+  avg_duration: number
+  max_duration: number
+}
+
+export interface TestQuarantine {
+  id: string
+  test_config_id: string
+  test_key: string
+  reason: string
+  created_by: string | null
+  created_at: string
+}
+
+export interface ScanOptions {
+  integration_id?: string
+  command?: string
+  properties?: Record<string, string>
+  args?: string
+  image?: string
+  timeout?: number
+}
+
 export interface ScanConfig {
   id: string
   project_id: string
   name: string
   scan_type: string
   sonar_project_key: string
+  config?: ScanOptions
+  baseline_run_id: string | null
   repo_url: string
   branch: string
   enabled: boolean
@@ -48,19 +119,59 @@ export interface ScanConfig {
 export interface ScanRun {
   id: string
   scan_config_id: string
-  status: string
+  build_run_id: string
+  branch: string
+  commit_sha: string
+  pull_request: number | null
+  status: 'pending' | 'running' | 'completed' | 'failed'
   bugs: number
   vulnerabilities: number
   code_smells: number
-  coverage: number
-  duplications: number
+  new_findings: number
+  coverage: number | null
+  new_coverage: number | null
+  duplications: number | null
+  quality_rating: string
+  gate_status: '' | 'passed' | 'failed'
   report_url: string
-  triggered_by: string
+  tekton_ref: string
+  analysis_id: string
+  error_message?: string
   started_at: string
   finished_at: string
   created_at: string
 }
 
+export interface ScanFinding {
+  id: string
+  scan_run_id: string
+  scan_config_id: string
+  tool: string
+  rule_id: string
+  severity: 'critical' | 'high' | 'medium' | 'low' | 'info'
+  category: 'bug' | 'vulnerability' | 'code_smell' | 'secret'
+  file: string
+  line: number
+  message: string
+  fingerprint: string
+  status: 'open' | 'suppressed'
+  suppression_id: string | null
+  baseline: boolean
+  created_at: string
+}
+
+export interface ScanSuppression {
+  id: string
+  scan_config_id: string
+  fingerprint?: string
+  rule_id?: string
+  path_pattern?: string
+  reason: string
+  created_by: string | null
+  expires_at: string | null
+  created_at: string
+}
+
 export interface QualityGate {
   id: string
   project_id: string
@@ -69,9 +180,38 @@ export interface QualityGate {
   max_vulnerabilities: number
   max_code_smells: number
   max_duplications: number
+  max_failed_tests: number
+  baseline_branch: string
+  min_new_coverage: number | null
+  max_new_vulnerabilities: number | null
+  max_new_code_smells: number | null
   block_deploy: boolean
 }
 
+export interface GateCondition {
+  metric: 'coverage' | 'bugs' | 'vulnerabilities' | 'code_smells' | 'duplications' | 'failed_tests'
+    | 'new_coverage' | 'new_vulnerabilities' | 'new_code_smells' | 'scans'
+  operator: 'gte' | 'lte'
+  threshold: number
+  actual: number | null
+  status: 'passed' | 'failed' | 'no_data' | 'pending'
+}
+
+export interface QualityGateResult {
+  id: string
+  project_id: string
+  commit_sha: string
+  branch: string
+  pull_request: number | null
+  baseline_branch: string
+  baseline_commit_sha: string
+  status: 'passed' | 'failed' | 'pending'
+  conditions: GateCondition[]
+  scan_run_ids: string[]
+  test_run_ids: string[]
+  evaluated_at: string
+}
+
 export const qualityApi = {
   // Tests
   listTests: (params?: { project_id?: string; page?: number; page_size?: number }) =>
@@ -82,12 +222,28 @@ export const qualityApi = {
   updateTest: (projectId: string, id: string, data: Partial<TestConfig>) =>
     request.put(`/projects/${projectId}/tests/${id}`, data),
   deleteTest: (projectId: string, id: string) => request.delete(`/projects/${projectId}/tests/${id}`),
-  triggerTest: (projectId: string, id: string) =>
-    request.post(`/projects/${projectId}/tests/${id}/run`),
+  triggerTest: (projectId: string, id: string, data: { build_run_id: string }) =>
+    request.post(`/projects/${projectId}/tests/${id}/run`, data),
   listTestRuns: (projectId: string, id: string, params?: { page?: number; page_size?: number }) =>
     request.get(`/projects/${projectId}/tests/${id}/runs`, { params }),
   getTestRun: (projectId: string, id: string, runId: string) =>
     request.get(`/projects/${projectId}/tests/${id}/runs/${runId}`),
+  getTestReport: (projectId: string, id: string, runId: string) =>
+    request.get(`/projects/${projectId}/tests/${id}/runs/${runId}/report`),
+  listTestCases: (projectId: string, id: string, runId: string, params?: { status?: string; page?: number; page_size?: number }) =>
+    request.get(`/projects/${projectId}/tests/${id}/runs/${runId}/cases`, { params }),
+  getTestCaseHistory: (projectId: string, id: string, params: { key: string; page?: number; page_size?: number }) =>
+    request.get(`/projects/${projectId}/tests/${id}/cases/history`, { params }),
+  listFlakyTests: (projectId: string, id: string, params?: { runs?: number }) =>
+    request.get(`/projects/${projectId}/tests/${id}/cases/flaky`, { params }),
+  listSlowTests: (projectId: string, id: string, params?: { runs?: number; limit?: number }) =>
+    request.get(`/projects/${projectId}/tests/${id}/cases/slow`, { params }),
+  listQuarantines: (projectId: string, id: string) =>
+    request.get(`/projects/${projectId}/tests/${id}/quarantines`),
+  quarantineTest: (projectId: string, id: string, data: { test_key: string; reason?: string }) =>
+    request.post(`/projects/${projectId}/tests/${id}/quarantines`, data),
+  unquarantineTest: (projectId: string, id: string, quarantineId: string) =>
+    request.delete(`/projects/${projectId}/tests/${id}/quarantines/${quarantineId}`),
 
   // Scans
   listScans: (params?: { project_id?: string; page?: number; page_size?: number }) =>
@@ -98,14 +254,37 @@ export const qualityApi = {
   updateScan: (projectId: string, id: string, data: Partial<ScanConfig>) =>
     request.put(`/projects/${projectId}/scans/${id}`, data),
   deleteScan: (projectId: string, id: string) => request.delete(`/projects/${projectId}/scans/${id}`),
-  triggerScan: (projectId: string, id: string) =>
-    request.post(`/projects/${projectId}/scans/${id}/run`),
+  triggerScan: (projectId: string, id: string, data: { build_run_id: string }) =>
+    request.post(`/projects/${projectId}/scans/${id}/run`, data),
   listScanRuns: (projectId: string, id: string, params?: { page?: number; page_size?: number }) =>
     request.get(`/projects/${projectId}/scans/${id}/runs`, { params }),
   getScanRun: (runId: string) => request.get(`/scans/runs/${runId}`),
+  listScanFindings: (runId: string, params?: {
+    severity?: string; category?: string; tool?: string; rule_id?: string
+    file?: string; status?: string; new?: boolean; page?: number; page_size?: number
+  }) => request.get(`/scans/runs/${runId}/findings`, { params }),
+  setScanBaseline: (projectId: string, id: string, runId: string | null) =>
+    request.put(`/projects/${projectId}/scans/${id}/baseline`, { run_id: runId }),
+  listScanSuppressions: (projectId: string, id: string) =>
+    request.get(`/projects/${projectId}/scans/${id}/suppressions`),
+  createScanSuppression: (projectId: string, id: string, data: Partial<ScanSuppression>) =>
+    request.post(`/projects/${projectId}/scans/${id}/suppressions`, data),
+  deleteScanSuppression: (projectId: string, id: string, suppressionId: string) =>
+    request.delete(`/projects/${projectId}/scans/${id}/suppressions/${suppressionId}`),
 
   // Quality Gate
   getQualityGate: (projectId: string) => request.get(`/projects/${projectId}/quality-gate`),
   upsertQualityGate: (projectId: string, data: Partial<QualityGate>) =>
     request.put(`/projects/${projectId}/quality-gate`, data),
+  evaluateQualityGate: (projectId: string, commitSha: string) =>
+    request.post(`/projects/${projectId}/quality-gate/evaluate`, { commit_sha: commitSha }),
+  listQualityGateResults: (projectId: string, params?: {
+    branch?: string; pull_request?: number; status?: string; page?: number; page_size?: number
+  }) => request.get(`/projects/${projectId}/quality-gate/results`, { params }),
+  listBranchGateResults: (projectId: string) =>
+    request.get(`/projects/${projectId}/quality-gate/branches`),
+  listPullRequestGateResults: (projectId: string) =>
+    request.get(`/projects/${projectId}/quality-gate/pull-requests`),
+  getQualityGateResult: (projectId: string, commitSha: string) =>
+    request.get(`/projects/${projectId}/quality-gate/results/${commitSha}`),
 }
@@ -4,9 +4,9 @@ import {
   Table, Button, Input, Tag, Modal, Form, Select, Space, App, Popconfirm, Typography,
 } from 'antd'
 import {
-  PlusOutlined, SearchOutlined, EditOutlined, DeleteOutlined,
+  PlusOutlined, SearchOutlined, EditOutlined, DeleteOutlined, ApiOutlined,
 } from '@ant-design/icons'
-import { artifactApi, ImageRegistry } from '@/api/artifact'
+import { artifactApi, ImageRegistry, RegistryTestResult } from '@/api/artifact'
 
 const { Title } = Typography
 
@@ -72,11 +72,24 @@ export default function ArtifactRegistries() {
     onError: (err: any) => message.error(err?.message || '删除失败'),
   })
 
+  const testMutation = useMutation({
+    mutationFn: (id: string) => artifactApi.testRegistry(id),
+    onSuccess: (res: any) => {
+      const result: RegistryTestResult = res?.data
+      if (result?.connected) {
+        message.success(`连接成功（${result.latency_ms} ms）`)
+      } else {
+        message.error(result?.message || '连接失败')
+      }
+    },
+    onError: (err: any) => message.error(err?.message || '连接测试失败'),
+  })
+
   // --- Modal helpers ---
   const openCreate = () => {
     setEditingRegistry(null)
     form.resetFields()
-    form.setFieldsValue({ type: 'harbor' })
+    form.setFieldsValue({ registry_type: 'harbor' })
     setModalOpen(true)
   }
 
@@ -84,7 +97,7 @@ export default function ArtifactRegistries() {
     setEditingRegistry(registry)
     form.setFieldsValue({
       name: registry.name,
-      type: registry.type,
+      registry_type: registry.registry_type,
       endpoint: registry.endpoint,
       username: registry.username,
     })
@@ -123,8 +136,8 @@ export default function ArtifactRegistries() {
     },
     {
       title: '类型',
-      dataIndex: 'type',
-      key: 'type',
+      dataIndex: 'registry_type',
+      key: 'registry_type',
       width: 120,
       render: (type: string) => {
         const t = getRegistryType(type)
@@ -160,9 +173,18 @@ export default function ArtifactRegistries() {
     {
       title: '操作',
       key: 'actions',
-      width: 150,
+      width: 220,
       render: (_: any, record: ImageRegistry) => (
         <Space size="small">
+          <Button
+            type="link"
+            size="small"
+            icon={<ApiOutlined />}
+            loading={testMutation.isPending && testMutation.variables === record.id}
+            onClick={() => testMutation.mutate(record.id)}
+          >
+            测试
+          </Button>
           <Button
             type="link"
             size="small"
@@ -243,7 +265,7 @@ export default function ArtifactRegistries() {
           form={form}
           layout="vertical"
           style={{ marginTop: 16 }}
-          initialValues={{ type: 'harbor' }}
+          initialValues={{ registry_type: 'harbor' }}
         >
           <Form.Item
             name="name"
@@ -254,7 +276,7 @@ export default function ArtifactRegistries() {
           </Form.Item>
 
           <Form.Item
-            name="type"
+            name="registry_type"
             label="仓库类型"
             rules={[{ required: true, message: '请选择仓库类型' }]}
           >
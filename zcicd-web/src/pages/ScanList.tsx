@@ -9,11 +9,15 @@ import {
   PlayCircleOutlined, EyeOutlined,
 } from '@ant-design/icons'
 import { qualityApi, ScanConfig } from '@/api/quality'
+import { workflowApi, BuildRun } from '@/api/workflow'
 
 const { Title } = Typography
 
 const SCAN_TYPE_MAP: Record<string, { color: string; label: string }> = {
   sonar: { color: 'blue', label: 'SonarQube' },
+  'golangci-lint': { color: 'cyan', label: 'golangci-lint' },
+  semgrep: { color: 'purple', label: 'Semgrep' },
+  gitleaks: { color: 'magenta', label: 'Gitleaks' },
   sast: { color: 'red', label: 'SAST' },
   dependency: { color: 'orange', label: '依赖扫描' },
 }
@@ -30,6 +34,8 @@ export default function ScanList() {
   const [pageSize, setPageSize] = useState(10)
   const [modalOpen, setModalOpen] = useState(false)
   const [editingScan, setEditingScan] = useState<ScanConfig | null>(null)
+  const [triggerScanId, setTriggerScanId] = useState<string | null>(null)
+  const [buildRunId, setBuildRunId] = useState<string>()
 
   // --- Data fetching ---
   const { data, isLoading } = useQuery({
@@ -48,6 +54,16 @@ export default function ScanList() {
   const scans: ScanConfig[] = data?.data ?? []
   const total: number = data?.pagination?.total ?? 0
 
+  const { data: buildRunsData } = useQuery({
+    queryKey: ['build-runs', 'recent'],
+    queryFn: async () => {
+      const res: any = await workflowApi.listBuildRuns({ page: 1, page_size: 20 })
+      return res
+    },
+    enabled: !!triggerScanId,
+  })
+  const buildRuns: BuildRun[] = buildRunsData?.data ?? []
+
   // --- Mutations ---
   const createMutation = useMutation({
     mutationFn: (values: Partial<ScanConfig>) => qualityApi.createScan(projectId!, values),
@@ -80,9 +96,12 @@ export default function ScanList() {
   })
 
   const triggerMutation = useMutation({
-    mutationFn: (id: string) => qualityApi.triggerScan(projectId!, id),
+    mutationFn: ({ id, buildRunId }: { id: string; buildRunId: string }) =>
+      qualityApi.triggerScan(projectId!, id, { build_run_id: buildRunId }),
     onSuccess: () => {
       message.success('扫描已触发')
+      setTriggerScanId(null)
+      setBuildRunId(undefined)
     },
     onError: (err: any) => message.error(err?.message || '触发失败'),
   })
@@ -185,7 +204,7 @@ export default function ScanList() {
             type="link"
             size="small"
             icon={<PlayCircleOutlined />}
-            onClick={() => triggerMutation.mutate(record.id)}
+            onClick={() => setTriggerScanId(record.id)}
           >
             触发
           </Button>
@@ -295,8 +314,9 @@ export default function ScanList() {
             <Select
               options={[
                 { value: 'sonar', label: 'SonarQube' },
-                { value: 'sast', label: 'SAST' },
-                { value: 'dependency', label: '依赖扫描' },
+                { value: 'golangci-lint', label: 'golangci-lint' },
+                { value: 'semgrep', label: 'Semgrep (SARIF)' },
+                { value: 'gitleaks', label: 'Gitleaks 密钥扫描' },
               ]}
             />
           </Form.Item>
@@ -329,6 +349,27 @@ export default function ScanList() {
           </Form.Item>
         </Form>
       </Modal>
+
+      <Modal
+        title="触发扫描"
+        open={!!triggerScanId}
+        onCancel={() => { setTriggerScanId(null); setBuildRunId(undefined) }}
+        onOk={() => triggerMutation.mutate({ id: triggerScanId!, buildRunId: buildRunId! })}
+        okButtonProps={{ disabled: !buildRunId }}
+        confirmLoading={triggerMutation.isPending}
+        okText="触发"
+      >
+        <Select
+          style={{ width: '100%' }}
+          placeholder="选择要扫描的构建"
+          value={buildRunId}
+          onChange={setBuildRunId}
+          options={buildRuns.map((run) => ({
+            value: run.id,
+            label: `#${run.run_number} ${run.branch} ${run.commit_sha?.slice(0, 8) ?? ''}`,
+          }))}
+        />
+      </Modal>
     </div>
   )
 }
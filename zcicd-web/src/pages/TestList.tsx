@@ -9,6 +9,7 @@ import {
   PlayCircleOutlined, EyeOutlined,
 } from '@ant-design/icons'
 import { qualityApi, TestConfig } from '@/api/quality'
+import { workflowApi, BuildRun } from '@/api/workflow'
 
 const { Title } = Typography
 
@@ -31,6 +32,8 @@ export default function TestList() {
   const [pageSize, setPageSize] = useState(10)
   const [modalOpen, setModalOpen] = useState(false)
   const [editingTest, setEditingTest] = useState<TestConfig | null>(null)
+  const [triggerTestId, setTriggerTestId] = useState<string | null>(null)
+  const [buildRunId, setBuildRunId] = useState<string>()
 
   // --- Data fetching ---
   const { data, isLoading } = useQuery({
@@ -46,6 +49,16 @@ export default function TestList() {
     enabled: !!projectId,
   })
 
+  const { data: buildRunsData } = useQuery({
+    queryKey: ['build-runs', 'recent'],
+    queryFn: async () => {
+      const res: any = await workflowApi.listBuildRuns({ page: 1, page_size: 20 })
+      return res
+    },
+    enabled: !!triggerTestId,
+  })
+  const buildRuns: BuildRun[] = buildRunsData?.data ?? []
+
   const tests: TestConfig[] = data?.data ?? []
   const total: number = data?.pagination?.total ?? 0
 
@@ -81,9 +94,12 @@ export default function TestList() {
   })
 
   const triggerMutation = useMutation({
-    mutationFn: (id: string) => qualityApi.triggerTest(projectId!, id),
+    mutationFn: ({ id, buildRunId }: { id: string; buildRunId: string }) =>
+      qualityApi.triggerTest(projectId!, id, { build_run_id: buildRunId }),
     onSuccess: () => {
       message.success('测试已触发')
+      setTriggerTestId(null)
+      setBuildRunId(undefined)
     },
     onError: (err: any) => message.error(err?.message || '触发失败'),
   })
@@ -189,7 +205,7 @@ export default function TestList() {
             type="link"
             size="small"
             icon={<PlayCircleOutlined />}
-            onClick={() => triggerMutation.mutate(record.id)}
+            onClick={() => setTriggerTestId(record.id)}
           >
             触发
           </Button>
@@ -351,6 +367,27 @@ export default function TestList() {
           </Form.Item>
         </Form>
       </Modal>
+
+      <Modal
+        title="触发测试"
+        open={!!triggerTestId}
+        onCancel={() => { setTriggerTestId(null); setBuildRunId(undefined) }}
+        onOk={() => triggerMutation.mutate({ id: triggerTestId!, buildRunId: buildRunId! })}
+        okButtonProps={{ disabled: !buildRunId }}
+        confirmLoading={triggerMutation.isPending}
+        okText="触发"
+      >
+        <Select
+          style={{ width: '100%' }}
+          placeholder="选择要测试的构建"
+          value={buildRunId}
+          onChange={setBuildRunId}
+          options={buildRuns.map((run) => ({
+            value: run.id,
+            label: `#${run.run_number} ${run.branch} ${run.commit_sha?.slice(0, 8) ?? ''}`,
+          }))}
+        />
+      </Modal>
     </div>
   )
 }